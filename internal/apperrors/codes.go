@@ -0,0 +1,115 @@
+package apperrors
+
+import "net/http"
+
+// General errors
+var (
+	ErrValidation       = New(http.StatusBadRequest, "VALIDATION_FAILED", "Request validation failed")
+	ErrMalformedBody    = New(http.StatusBadRequest, "MALFORMED_BODY", "Request body is not valid JSON")
+	ErrRouteNotFound    = New(http.StatusNotFound, "ROUTE_NOT_FOUND", "Route not found")
+	ErrInternal         = New(http.StatusInternalServerError, "INTERNAL_ERROR", "An internal error occurred")
+	ErrForbidden        = New(http.StatusForbidden, "FORBIDDEN", "Insufficient permissions")
+	ErrPermissionDenied = New(http.StatusForbidden, "PERMISSION_DENIED", "You do not have permission to perform this action")
+	ErrAdminRequired    = New(http.StatusForbidden, "ADMIN_REQUIRED", "Only admin can perform this action")
+	ErrUnauthorized     = New(http.StatusUnauthorized, "UNAUTHORIZED", "Authorization header required")
+	ErrInvalidHeader    = New(http.StatusUnauthorized, "INVALID_AUTH_HEADER", "Invalid authorization header format")
+	ErrInvalidToken     = New(http.StatusUnauthorized, "INVALID_TOKEN", "Invalid token")
+	ErrRoleNotFound     = New(http.StatusUnauthorized, "ROLE_NOT_FOUND", "User role not found")
+	ErrTokenIssueFail   = New(http.StatusInternalServerError, "TOKEN_ISSUE_FAILED", "Failed to generate token")
+)
+
+// Authentication, tenant and user errors
+var (
+	ErrInvalidCredentials         = New(http.StatusUnauthorized, "INVALID_CREDENTIALS", "Invalid credentials")
+	ErrRestaurantContextMissing   = New(http.StatusUnauthorized, "RESTAURANT_CONTEXT_MISSING", "Restaurant context not found")
+	ErrInvalidRestaurant          = New(http.StatusUnauthorized, "INVALID_RESTAURANT", "Invalid restaurant")
+	ErrSquareAppAlreadyRegistered = New(http.StatusConflict, "SQUARE_APP_ALREADY_REGISTERED", "Square App ID already exists")
+	ErrSquareLocationUnavailable  = New(http.StatusBadGateway, "SQUARE_LOCATION_UNAVAILABLE", "Failed to fetch Square location ID")
+	ErrUserNotFound               = New(http.StatusNotFound, "USER_NOT_FOUND", "User not found")
+	ErrEmailAlreadyExists         = New(http.StatusConflict, "EMAIL_ALREADY_EXISTS", "Email already exists for this restaurant")
+	ErrUsernameAlreadyExists      = New(http.StatusConflict, "USERNAME_ALREADY_EXISTS", "Username already exists")
+	ErrLastAdmin                  = New(http.StatusConflict, "LAST_ADMIN", "The restaurant must keep at least one active admin")
+	ErrUnknownRole                = New(http.StatusBadRequest, "UNKNOWN_ROLE", "Role does not exist")
+	ErrCustomRoleNotFound         = New(http.StatusNotFound, "CUSTOM_ROLE_NOT_FOUND", "Role not found")
+	ErrRoleAlreadyExists          = New(http.StatusConflict, "ROLE_ALREADY_EXISTS", "A role with this name already exists")
+	ErrRoleInUse                  = New(http.StatusConflict, "ROLE_IN_USE", "Role is still assigned to users")
+	ErrUnknownPermission          = New(http.StatusBadRequest, "UNKNOWN_PERMISSION", "Unknown permission")
+	ErrRoleExceedsPermissions     = New(http.StatusForbidden, "ROLE_EXCEEDS_PERMISSIONS", "You cannot grant or manage a role with permissions you do not have")
+	ErrAccountDisabled            = New(http.StatusForbidden, "ACCOUNT_DISABLED", "This account has been deactivated")
+	ErrTokenRevoked               = New(http.StatusUnauthorized, "TOKEN_REVOKED", "Token has been revoked")
+	ErrInvalidRefreshToken        = New(http.StatusUnauthorized, "INVALID_REFRESH_TOKEN", "Invalid or expired refresh token")
+	ErrRefreshTokenReused         = New(http.StatusUnauthorized, "REFRESH_TOKEN_REUSED", "Refresh token was already used, please log in again")
+	ErrEmailNotVerified           = New(http.StatusForbidden, "EMAIL_NOT_VERIFIED", "Please verify your email address before logging in")
+	ErrInvalidResetToken          = New(http.StatusBadRequest, "INVALID_RESET_TOKEN", "Password reset link is invalid or has expired")
+	ErrInvalidVerificationToken   = New(http.StatusBadRequest, "INVALID_VERIFICATION_TOKEN", "Verification link is invalid or has expired")
+	ErrCannotDeactivateSelf       = New(http.StatusBadRequest, "CANNOT_DEACTIVATE_SELF", "You cannot deactivate your own account")
+	ErrAccountLocked              = New(http.StatusTooManyRequests, "ACCOUNT_LOCKED", "Too many failed logins for this account, try again later")
+	ErrTooManyLoginAttempts       = New(http.StatusTooManyRequests, "TOO_MANY_LOGIN_ATTEMPTS", "Too many failed logins from this address, try again later")
+	ErrLoginThrottled             = New(http.StatusTooManyRequests, "LOGIN_THROTTLED", "Too many failed logins, wait before trying again")
+	ErrInvalidLoginChallenge      = New(http.StatusUnauthorized, "INVALID_LOGIN_CHALLENGE", "Login challenge is invalid or has expired, please log in again")
+	ErrInvalidTwoFactorCode       = New(http.StatusUnauthorized, "INVALID_TWO_FACTOR_CODE", "Invalid two-factor code")
+	ErrTwoFactorAlreadyEnabled    = New(http.StatusConflict, "TWO_FACTOR_ALREADY_ENABLED", "Two-factor authentication is already enabled")
+	ErrTwoFactorNotEnabled        = New(http.StatusConflict, "TWO_FACTOR_NOT_ENABLED", "Two-factor authentication is not enabled")
+	ErrTwoFactorNotEnrolled       = New(http.StatusConflict, "TWO_FACTOR_NOT_ENROLLED", "Set up two-factor authentication before confirming a code")
+	ErrTwoFactorRequired          = New(http.StatusConflict, "TWO_FACTOR_REQUIRED", "Your role requires two-factor authentication")
+	ErrNotAMember                 = New(http.StatusForbidden, "NOT_A_MEMBER", "You are not a member of this restaurant")
+	ErrNoActiveMembership         = New(http.StatusForbidden, "NO_ACTIVE_MEMBERSHIP", "This account has no active restaurant membership")
+	ErrMemberNotFound             = New(http.StatusNotFound, "MEMBER_NOT_FOUND", "Member not found")
+	ErrMemberAlreadyExists        = New(http.StatusConflict, "MEMBER_ALREADY_EXISTS", "User is already a member of this restaurant")
+	ErrHomeMembership             = New(http.StatusConflict, "HOME_MEMBERSHIP", "Members of their home restaurant are managed through the user endpoints")
+)
+
+// Location errors
+var (
+	ErrLocationNotFound   = New(http.StatusNotFound, "LOCATION_NOT_FOUND", "Location does not belong to this restaurant")
+	ErrLocationInactive   = New(http.StatusConflict, "LOCATION_INACTIVE", "Location is not active in Square")
+	ErrLocationNotAllowed = New(http.StatusForbidden, "LOCATION_NOT_ALLOWED", "You are not assigned to this location")
+	ErrLocationMismatch   = New(http.StatusBadRequest, "LOCATION_MISMATCH", "Payment location does not match the order's location")
+)
+
+// Device and PIN login errors
+var (
+	ErrInvalidDevice      = New(http.StatusUnauthorized, "INVALID_DEVICE", "Device credential is missing, invalid or revoked")
+	ErrDeviceNotFound     = New(http.StatusNotFound, "DEVICE_NOT_FOUND", "Device not found")
+	ErrInvalidPin         = New(http.StatusUnauthorized, "INVALID_PIN", "Invalid PIN")
+	ErrDeviceLocked       = New(http.StatusTooManyRequests, "DEVICE_LOCKED", "Too many failed PIN attempts on this device, try again later")
+	ErrPinLocked          = New(http.StatusTooManyRequests, "PIN_LOCKED", "Too many failed PIN attempts for this user, try again later")
+	ErrPinAlreadyInUse    = New(http.StatusConflict, "PIN_IN_USE", "PIN is already used by another user of this restaurant")
+	ErrPinLoginNotAllowed = New(http.StatusForbidden, "PIN_LOGIN_NOT_ALLOWED", "Your account uses two-factor authentication, log in with your password instead")
+)
+
+// Order and payment errors
+var (
+	ErrOrderNotFound           = New(http.StatusNotFound, "ORDER_NOT_FOUND", "Order not found")
+	ErrPaymentNotFound         = New(http.StatusNotFound, "PAYMENT_NOT_FOUND", "Payment not found")
+	ErrPaymentAlreadyCompleted = New(http.StatusConflict, "PAYMENT_ALREADY_COMPLETED", "Payment has already been completed")
+	ErrPaymentNotCompleted     = New(http.StatusConflict, "PAYMENT_NOT_COMPLETED", "Only completed payments can be refunded")
+	ErrRefundExceedsBalance    = New(http.StatusBadRequest, "REFUND_EXCEEDS_BALANCE", "Refund amount exceeds the refundable balance")
+	ErrOrderNotCancellable     = New(http.StatusConflict, "ORDER_NOT_CANCELLABLE", "Only pending orders can be cancelled")
+	ErrDiscountOverLimit       = New(http.StatusForbidden, "DISCOUNT_OVER_LIMIT", "Discounts exceed the restaurant's limit and need a manager")
+)
+
+// Tax and service charge errors
+var (
+	ErrTaxRuleNotFound       = New(http.StatusNotFound, "TAX_RULE_NOT_FOUND", "Tax rule not found")
+	ErrTaxRulePushFailed     = New(http.StatusBadGateway, "TAX_RULE_PUSH_FAILED", "Failed to push tax rule to Square")
+	ErrServiceChargeNotFound = New(http.StatusNotFound, "SERVICE_CHARGE_NOT_FOUND", "Service charge not found")
+	ErrInvalidServiceCharge  = New(http.StatusBadRequest, "INVALID_SERVICE_CHARGE", "Taxable service charges must use SUBTOTAL_PHASE")
+)
+
+// Square API errors, see square.go for how Square categories and codes map onto them
+var (
+	ErrSquareCardDeclined        = New(http.StatusPaymentRequired, "SQUARE_CARD_DECLINED", "The card was declined")
+	ErrSquareInsufficientFunds   = New(http.StatusPaymentRequired, "SQUARE_INSUFFICIENT_FUNDS", "The card has insufficient funds")
+	ErrSquareCardExpired         = New(http.StatusPaymentRequired, "SQUARE_CARD_EXPIRED", "The card has expired")
+	ErrSquareCardVerification    = New(http.StatusPaymentRequired, "SQUARE_CARD_VERIFICATION_FAILED", "The card details could not be verified")
+	ErrSquarePaymentMethod       = New(http.StatusPaymentRequired, "SQUARE_PAYMENT_METHOD_ERROR", "The payment method was rejected")
+	ErrSquareRefund              = New(http.StatusUnprocessableEntity, "SQUARE_REFUND_ERROR", "Square rejected the refund")
+	ErrSquareInvalidRequest      = New(http.StatusUnprocessableEntity, "SQUARE_INVALID_REQUEST", "Square rejected the request")
+	ErrSquareNotFound            = New(http.StatusNotFound, "SQUARE_NOT_FOUND", "The requested Square resource was not found")
+	ErrSquareIdempotencyConflict = New(http.StatusConflict, "SQUARE_IDEMPOTENCY_CONFLICT", "The request conflicts with an earlier request")
+	ErrSquareVersionMismatch     = New(http.StatusConflict, "SQUARE_VERSION_MISMATCH", "The Square resource was changed by another request")
+	ErrSquareAuthentication      = New(http.StatusBadGateway, "SQUARE_AUTHENTICATION_FAILED", "The restaurant's Square credentials were rejected")
+	ErrSquareRateLimited         = New(http.StatusTooManyRequests, "SQUARE_RATE_LIMITED", "Too many requests to Square, try again shortly")
+	ErrSquareUnavailable         = New(http.StatusBadGateway, "SQUARE_UNAVAILABLE", "Square is temporarily unavailable")
+)
@@ -0,0 +1,142 @@
+package apperrors
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/go-playground/validator/v10"
+
+	"square-pos-integration/internal/reponses"
+)
+
+// Code is a stable, machine-readable error identifier returned to clients
+type Code string
+
+// AppError is an error that knows how it is presented to API clients. Err holds the
+// internal cause, which is logged but never serialized.
+type AppError struct {
+	Status  int
+	Code    Code
+	Message string
+	Details interface{}
+	Err     error
+}
+
+// New creates an application error
+func New(status int, code Code, message string) *AppError {
+	return &AppError{Status: status, Code: code, Message: message}
+}
+
+func (e *AppError) Error() string {
+	if e.Err != nil {
+		return fmt.Sprintf("%s: %s: %v", e.Code, e.Message, e.Err)
+	}
+	return fmt.Sprintf("%s: %s", e.Code, e.Message)
+}
+
+// Unwrap returns the internal cause
+func (e *AppError) Unwrap() error {
+	return e.Err
+}
+
+// Is matches application errors by code, so errors.Is works against the sentinels in codes.go
+func (e *AppError) Is(target error) bool {
+	t, ok := target.(*AppError)
+	return ok && t.Code == e.Code
+}
+
+// Wrap returns a copy of the error carrying an internal cause
+func (e *AppError) Wrap(err error) *AppError {
+	wrapped := *e
+	wrapped.Err = err
+	return &wrapped
+}
+
+// WithDetails returns a copy of the error carrying client-safe details
+func (e *AppError) WithDetails(details interface{}) *AppError {
+	detailed := *e
+	detailed.Details = details
+	return &detailed
+}
+
+// RetryDetails tells clients how long to wait before trying again
+type RetryDetails struct {
+	RetryAfterSeconds int `json:"retry_after_seconds"`
+}
+
+// RetryAfter returns a copy of the error telling clients to wait for the duration, which
+// is rounded up to whole seconds
+func (e *AppError) RetryAfter(wait time.Duration) *AppError {
+	return e.WithDetails(RetryDetails{RetryAfterSeconds: int(math.Ceil(wait.Seconds()))})
+}
+
+// Response builds the JSON body sent to clients
+func (e *AppError) Response() reponses.ErrorResponse {
+	return reponses.ErrorResponse{
+		Error:   e.Message,
+		Code:    string(e.Code),
+		Details: e.Details,
+	}
+}
+
+// IsServerError reports whether the error should be treated as a server-side failure
+func (e *AppError) IsServerError() bool {
+	return e.Status >= http.StatusInternalServerError
+}
+
+// From converts any error into an application error. Square API errors are mapped to
+// SQUARE_* codes and anything unrecognized is treated as an internal error.
+func From(err error) *AppError {
+	var appErr *AppError
+	if errors.As(err, &appErr) {
+		return appErr
+	}
+	if squareErr := FromSquare(err); squareErr != nil {
+		return squareErr
+	}
+	return ErrInternal.Wrap(err)
+}
+
+// FieldError describes a single invalid request field
+type FieldError struct {
+	Field string `json:"field"`
+	Rule  string `json:"rule"`
+}
+
+// Validation converts a request binding error into a VALIDATION_FAILED error
+func Validation(err error) *AppError {
+	var validationErrors validator.ValidationErrors
+	if errors.As(err, &validationErrors) {
+		fields := make([]FieldError, 0, len(validationErrors))
+		for _, fieldErr := range validationErrors {
+			fields = append(fields, FieldError{
+				Field: jsonFieldName(fieldErr),
+				Rule:  fieldErr.Tag(),
+			})
+		}
+		return ErrValidation.Wrap(err).WithDetails(fields)
+	}
+
+	var syntaxErr *json.SyntaxError
+	var typeErr *json.UnmarshalTypeError
+	if errors.As(err, &syntaxErr) || errors.As(err, &typeErr) {
+		return ErrMalformedBody.Wrap(err)
+	}
+
+	return ErrValidation.Wrap(err)
+}
+
+// jsonFieldName converts a validator namespace like CreateOrderRequest.Items[0].Name
+// into the dotted path of the request struct fields
+func jsonFieldName(fieldErr validator.FieldError) string {
+	namespace := fieldErr.StructNamespace()
+	if i := strings.Index(namespace, "."); i >= 0 {
+		return namespace[i+1:]
+	}
+	return fieldErr.Field()
+}
@@ -0,0 +1,85 @@
+package apperrors
+
+import (
+	"encoding/json"
+	"errors"
+
+	square "github.com/square/square-go-sdk/v2"
+	"github.com/square/square-go-sdk/v2/core"
+)
+
+// squareCodeErrors maps specific Square error codes to application errors
+var squareCodeErrors = map[square.ErrorCode]*AppError{
+	"CARD_DECLINED":                       ErrSquareCardDeclined,
+	"GENERIC_DECLINE":                     ErrSquareCardDeclined,
+	"CARD_DECLINED_CALL_ISSUER":           ErrSquareCardDeclined,
+	"CARD_DECLINED_VERIFICATION_REQUIRED": ErrSquareCardDeclined,
+	"INSUFFICIENT_FUNDS":                  ErrSquareInsufficientFunds,
+	"CARD_EXPIRED":                        ErrSquareCardExpired,
+	"INVALID_EXPIRATION":                  ErrSquareCardExpired,
+	"CVV_FAILURE":                         ErrSquareCardVerification,
+	"ADDRESS_VERIFICATION_FAILURE":        ErrSquareCardVerification,
+	"INVALID_POSTAL_CODE":                 ErrSquareCardVerification,
+	"IDEMPOTENCY_KEY_REUSED":              ErrSquareIdempotencyConflict,
+	"VERSION_MISMATCH":                    ErrSquareVersionMismatch,
+	"NOT_FOUND":                           ErrSquareNotFound,
+	"SERVICE_UNAVAILABLE":                 ErrSquareUnavailable,
+	"GATEWAY_TIMEOUT":                     ErrSquareUnavailable,
+}
+
+// squareCategoryErrors maps Square error categories to application errors when the code is not specific enough
+var squareCategoryErrors = map[square.ErrorCategory]*AppError{
+	"PAYMENT_METHOD_ERROR":        ErrSquarePaymentMethod,
+	"REFUND_ERROR":                ErrSquareRefund,
+	"INVALID_REQUEST_ERROR":       ErrSquareInvalidRequest,
+	"AUTHENTICATION_ERROR":        ErrSquareAuthentication,
+	"RATE_LIMIT_ERROR":            ErrSquareRateLimited,
+	"API_ERROR":                   ErrSquareUnavailable,
+	"MERCHANT_SUBSCRIPTION_ERROR": ErrSquareInvalidRequest,
+	"EXTERNAL_VENDOR_ERROR":       ErrSquareUnavailable,
+}
+
+// squareErrorBody is the error envelope returned by the Square API
+type squareErrorBody struct {
+	Errors []*square.Error `json:"errors"`
+}
+
+// FromSquare maps an error returned by the Square SDK to an application error.
+// It returns nil when err is not a Square API error.
+func FromSquare(err error) *AppError {
+	var apiErr *core.APIError
+	if !errors.As(err, &apiErr) {
+		return nil
+	}
+
+	var body squareErrorBody
+	if cause := apiErr.Unwrap(); cause != nil {
+		_ = json.Unmarshal([]byte(cause.Error()), &body)
+	}
+
+	for _, squareErr := range body.Errors {
+		if squareErr == nil {
+			continue
+		}
+		if mapped, ok := squareCodeErrors[squareErr.Code]; ok {
+			return mapped.Wrap(err)
+		}
+		if mapped, ok := squareCategoryErrors[squareErr.Category]; ok {
+			return mapped.Wrap(err)
+		}
+	}
+
+	// Fall back to the HTTP status when the body could not be interpreted
+	switch {
+	case apiErr.StatusCode == 401 || apiErr.StatusCode == 403:
+		return ErrSquareAuthentication.Wrap(err)
+	case apiErr.StatusCode == 404:
+		return ErrSquareNotFound.Wrap(err)
+	case apiErr.StatusCode == 429:
+		return ErrSquareRateLimited.Wrap(err)
+	case apiErr.StatusCode >= 400 && apiErr.StatusCode < 500:
+		return ErrSquareInvalidRequest.Wrap(err)
+	default:
+		return ErrSquareUnavailable.Wrap(err)
+	}
+}
@@ -0,0 +1,55 @@
+package authz
+
+import (
+	"github.com/gin-gonic/gin"
+
+	"square-pos-integration/internal/apperrors"
+)
+
+// contextKey is where the current user's permissions are stored on the gin context
+const contextKey = "permissions"
+
+// SetPermissions stores the current user's permissions on the request context
+func SetPermissions(c *gin.Context, permissions Set) {
+	c.Set(contextKey, permissions)
+}
+
+// FromContext returns the current user's permissions, or an empty set when none were loaded
+func FromContext(c *gin.Context) Set {
+	if value, exists := c.Get(contextKey); exists {
+		if permissions, ok := value.(Set); ok {
+			return permissions
+		}
+	}
+	return Set{}
+}
+
+// Can reports whether the current user has the permission
+func Can(c *gin.Context, permission Permission) bool {
+	return FromContext(c).Has(permission)
+}
+
+// Require returns a permission denied error unless the current user has the permission
+func Require(c *gin.Context, permission Permission) error {
+	if !Can(c, permission) {
+		return denied(permission)
+	}
+	return nil
+}
+
+// RequireOwned checks a permission on a resource owned by ownerID. Users may act on their
+// own resources with the permission alone, and on other users' resources only when they
+// also hold anyPermission.
+func RequireOwned(c *gin.Context, permission, anyPermission Permission, ownerID uint) error {
+	if err := Require(c, permission); err != nil {
+		return err
+	}
+	if userID, _ := c.Get("user_id"); userID == ownerID {
+		return nil
+	}
+	return Require(c, anyPermission)
+}
+
+func denied(permission Permission) error {
+	return apperrors.ErrPermissionDenied.WithDetails(map[string]Permission{"permission": permission})
+}
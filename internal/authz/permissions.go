@@ -0,0 +1,117 @@
+package authz
+
+import (
+	"sort"
+)
+
+// Permission is an action a user may be allowed to perform
+type Permission string
+
+const (
+	OrdersView              Permission = "orders.view"
+	OrdersCreate            Permission = "orders.create"
+	OrdersCancel            Permission = "orders.cancel"
+	OrdersManageAny         Permission = "orders.manage_any" // Act on orders opened by other staff
+	PaymentsProcess         Permission = "payments.process"
+	PaymentsRefund          Permission = "payments.refund"
+	DiscountsApplyOverLimit Permission = "discounts.apply_over_limit"
+	ReportsView             Permission = "reports.view"
+	UsersManage             Permission = "users.manage"
+	RolesManage             Permission = "roles.manage"
+	DevicesManage           Permission = "devices.manage"
+	SettingsManage          Permission = "settings.manage" // Tax and service charge rules
+)
+
+// All lists every permission that can be granted to a role
+var All = []Permission{
+	OrdersView,
+	OrdersCreate,
+	OrdersCancel,
+	OrdersManageAny,
+	PaymentsProcess,
+	PaymentsRefund,
+	DiscountsApplyOverLimit,
+	ReportsView,
+	UsersManage,
+	RolesManage,
+	DevicesManage,
+	SettingsManage,
+}
+
+// builtinRoles are available to every restaurant and cannot be changed
+var builtinRoles = map[string][]Permission{
+	"admin": All,
+	"manager": {
+		OrdersView, OrdersCreate, OrdersCancel, OrdersManageAny,
+		PaymentsProcess, PaymentsRefund,
+		DiscountsApplyOverLimit, ReportsView,
+	},
+	"staff": {
+		OrdersView, OrdersCreate, OrdersCancel,
+		PaymentsProcess,
+	},
+}
+
+// BuiltinRole returns the permissions of a built-in role
+func BuiltinRole(name string) ([]Permission, bool) {
+	permissions, ok := builtinRoles[name]
+	return permissions, ok
+}
+
+// BuiltinRoleNames returns the names of the built-in roles in alphabetical order
+func BuiltinRoleNames() []string {
+	names := make([]string, 0, len(builtinRoles))
+	for name := range builtinRoles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Valid reports whether the permission exists
+func Valid(permission Permission) bool {
+	for _, known := range All {
+		if known == permission {
+			return true
+		}
+	}
+	return false
+}
+
+// Set is the set of permissions granted to a user
+type Set map[Permission]bool
+
+// NewSet builds a set from a list of permissions
+func NewSet(permissions ...Permission) Set {
+	set := make(Set, len(permissions))
+	for _, permission := range permissions {
+		set[permission] = true
+	}
+	return set
+}
+
+// Has reports whether the set grants the permission
+func (s Set) Has(permission Permission) bool {
+	return s[permission]
+}
+
+// List returns the permissions in the set in alphabetical order
+func (s Set) List() []Permission {
+	permissions := make([]Permission, 0, len(s))
+	for permission := range s {
+		permissions = append(permissions, permission)
+	}
+	sort.Slice(permissions, func(i, j int) bool { return permissions[i] < permissions[j] })
+	return permissions
+}
+
+// Missing returns the permissions of other that the set does not grant, in alphabetical order
+func (s Set) Missing(other Set) []Permission {
+	missing := []Permission{}
+	for _, permission := range other.List() {
+		if !s.Has(permission) {
+			missing = append(missing, permission)
+		}
+	}
+	return missing
+}
@@ -0,0 +1,40 @@
+package authz
+
+import (
+	"errors"
+
+	"gorm.io/gorm"
+
+	"square-pos-integration/internal/models"
+)
+
+// Resolver looks up the permissions granted to a role, built-in or custom
+type Resolver struct {
+	DB *gorm.DB
+}
+
+func NewResolver(db *gorm.DB) *Resolver {
+	return &Resolver{DB: db}
+}
+
+// Permissions returns the permissions of a role in a restaurant. Unknown roles grant nothing.
+func (r *Resolver) Permissions(restaurantID uint, roleName string) (Set, error) {
+	if permissions, ok := BuiltinRole(roleName); ok {
+		return NewSet(permissions...), nil
+	}
+
+	var role models.Role
+	err := r.DB.Preload("Permissions").Where("restaurant_id = ? AND name = ?", restaurantID, roleName).First(&role).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return Set{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	set := make(Set, len(role.Permissions))
+	for _, granted := range role.Permissions {
+		set[Permission(granted.Permission)] = true
+	}
+	return set, nil
+}
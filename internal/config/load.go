@@ -0,0 +1,213 @@
+package config
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pelletier/go-toml/v2"
+	"gopkg.in/yaml.v3"
+)
+
+// Load builds the configuration from, lowest precedence first:
+//
+//  1. the defaults, see Default
+//  2. the config file named by -config or CONFIG_FILE, YAML (.yaml, .yml) or TOML (.toml)
+//  3. environment variables
+//  4. command-line flags
+//
+// It returns the configuration with the arguments left after the flags, such as a
+// subcommand. Values that don't parse are errors; whether the configuration is complete is
+// up to the caller, see Validate. Usage and flag errors are written to out.
+func Load(args []string, out io.Writer) (*Config, []string, error) {
+	cfg := Default()
+	settings := fields(cfg)
+
+	flags := flag.NewFlagSet(filepath.Base(os.Args[0]), flag.ContinueOnError)
+	flags.SetOutput(out)
+	configFile := flags.String("config", os.Getenv("CONFIG_FILE"), "YAML or TOML config file (env CONFIG_FILE)")
+	// Flags are applied last, so they are collected while parsing and set after the file and environment
+	var fromFlags []func() error
+	for _, s := range settings {
+		s := s
+		flags.Func(s.key, fmt.Sprintf("%s (env %s)", s.help, s.env), func(value string) error {
+			fromFlags = append(fromFlags, func() error {
+				if err := s.set(value); err != nil {
+					return fmt.Errorf("flag -%s: %w", s.key, err)
+				}
+				return nil
+			})
+			return nil
+		})
+	}
+	if err := flags.Parse(args); err != nil {
+		return nil, nil, err
+	}
+
+	if *configFile != "" {
+		if err := loadFile(settings, *configFile); err != nil {
+			return nil, nil, err
+		}
+	}
+	for _, s := range settings {
+		if value := os.Getenv(s.env); value != "" {
+			if err := s.set(value); err != nil {
+				return nil, nil, fmt.Errorf("environment variable %s: %w", s.env, err)
+			}
+		}
+	}
+	for _, set := range fromFlags {
+		if err := set(); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	return cfg, flags.Args(), nil
+}
+
+// loadFile sets the values in a config file. Keys the configuration doesn't have are an
+// error, so typos are not silently ignored.
+func loadFile(settings []setting, path string) error {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("reading config file: %w", err)
+	}
+
+	var sections map[string]interface{}
+	switch filepath.Ext(path) {
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(content, &sections)
+	case ".toml":
+		err = toml.NewDecoder(bytes.NewReader(content)).Decode(&sections)
+	default:
+		return fmt.Errorf("config file %s: unknown format, expected .yaml, .yml or .toml", path)
+	}
+	if err != nil {
+		return fmt.Errorf("config file %s: %w", path, err)
+	}
+
+	byKey := map[string]setting{}
+	for _, s := range settings {
+		byKey[s.key] = s
+	}
+	values := map[string]interface{}{}
+	flatten("", sections, values)
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		s, ok := byKey[key]
+		if !ok {
+			return fmt.Errorf("config file %s: unknown setting %s", path, key)
+		}
+		if err := s.set(fileValue(values[key])); err != nil {
+			return fmt.Errorf("config file %s: %s: %w", path, key, err)
+		}
+	}
+	return nil
+}
+
+// flatten turns nested sections into dotted keys such as server.port
+func flatten(prefix string, section map[string]interface{}, values map[string]interface{}) {
+	for key, value := range section {
+		if prefix != "" {
+			key = prefix + "." + key
+		}
+		if nested, ok := value.(map[string]interface{}); ok {
+			flatten(key, nested, values)
+		} else {
+			values[key] = value
+		}
+	}
+}
+
+// fileValue formats a value decoded from a config file as it was written. YAML reads
+// unquoted dates such as api_version: 2025-05-21 as timestamps.
+func fileValue(value interface{}) string {
+	if t, ok := value.(time.Time); ok {
+		if t.Equal(t.Truncate(24 * time.Hour)) {
+			return t.Format("2006-01-02")
+		}
+		return t.Format(time.RFC3339)
+	}
+	return fmt.Sprint(value)
+}
+
+// setting is one field of the configuration and the names it goes by in each source
+type setting struct {
+	key   string // server.port, in files and as the flag name
+	env   string
+	help  string
+	value reflect.Value
+}
+
+// fields lists the settings of cfg, section by section
+func fields(cfg *Config) []setting {
+	var settings []setting
+	sections := reflect.ValueOf(cfg).Elem()
+	for i := 0; i < sections.NumField(); i++ {
+		section := sections.Type().Field(i)
+		values := sections.Field(i)
+		for j := 0; j < values.NumField(); j++ {
+			field := values.Type().Field(j)
+			settings = append(settings, setting{
+				key:   section.Tag.Get("key") + "." + field.Tag.Get("key"),
+				env:   field.Tag.Get("env"),
+				help:  field.Tag.Get("help"),
+				value: values.Field(j),
+			})
+		}
+	}
+	return settings
+}
+
+// describe names a setting for error messages, such as server.port (PORT)
+func describe(cfg *Config, key string) string {
+	for _, s := range fields(cfg) {
+		if s.key == key {
+			return fmt.Sprintf("%s (%s)", key, s.env)
+		}
+	}
+	return key
+}
+
+var durationType = reflect.TypeOf(time.Duration(0))
+
+// set parses value into the setting's field
+func (s setting) set(value string) error {
+	switch {
+	case s.value.Type() == durationType:
+		d, err := time.ParseDuration(value)
+		if err != nil {
+			return fmt.Errorf("%q is not a duration such as 30s or 5m", value)
+		}
+		s.value.SetInt(int64(d))
+	case s.value.Kind() == reflect.Int:
+		n, err := strconv.Atoi(strings.TrimSpace(value))
+		if err != nil {
+			return fmt.Errorf("%q is not a whole number", value)
+		}
+		s.value.SetInt(int64(n))
+	case s.value.Kind() == reflect.Bool:
+		b, err := strconv.ParseBool(strings.TrimSpace(value))
+		if err != nil {
+			return fmt.Errorf("%q is not true or false", value)
+		}
+		s.value.SetBool(b)
+	case s.value.Kind() == reflect.String:
+		s.value.SetString(value)
+	default:
+		return fmt.Errorf("unsupported setting type %s", s.value.Type())
+	}
+	return nil
+}
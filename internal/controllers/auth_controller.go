@@ -2,206 +2,429 @@ package controllers
 
 import (
 	"github.com/gin-gonic/gin"
-	"gorm.io/gorm"
-	"log"
 	"net/http"
-	"strings"
 
+	"square-pos-integration/internal/apperrors"
+	"square-pos-integration/internal/authz"
+	"square-pos-integration/internal/mappers"
 	"square-pos-integration/internal/models"
+	"square-pos-integration/internal/reponses"
 	"square-pos-integration/internal/requests"
 	"square-pos-integration/internal/service"
-	"square-pos-integration/internal/utils"
 )
 
 type AuthController struct {
-	DB            *gorm.DB
-	SquareService service.ISquareService
+	Auth        service.IAuthService
+	Sessions    *service.SessionService
+	Users       *service.UserService
+	Accounts    *service.AccountService
+	Memberships *service.MembershipService
+	TwoFactor   *service.TwoFactorService
 }
 
-// NewAuthController creates a new auth controller
-func NewAuthController(db *gorm.DB, squareService service.ISquareService) *AuthController {
-	return &AuthController{DB: db, SquareService: squareService}
+// NewAuthController creates a new auth controller for the auth service and the services it uses
+func NewAuthController(auth *service.AuthService) *AuthController {
+	return &AuthController{
+		Auth:        auth,
+		Sessions:    auth.Sessions,
+		Users:       auth.UserService,
+		Accounts:    auth.Accounts,
+		Memberships: auth.Memberships,
+		TwoFactor:   auth.TwoFactor,
+	}
 }
 
-// Login handles user authentication
+// Login handles user authentication. Failed attempts are throttled and locked out per
+// account and per client IP by the login guard. The session starts in the user's home
+// restaurant and the response lists every restaurant they can switch to.
 func (ac *AuthController) Login(c *gin.Context) {
-	log.Printf("Login attempt for IP: %s", c.ClientIP())
+	clientIP := c.ClientIP()
+	requestLogger(c).Debug("Login attempt")
 
 	var loginRequest requests.LoginRequest
 	if err := c.ShouldBindJSON(&loginRequest); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		c.Error(apperrors.Validation(err))
 		return
 	}
 
-	// Find user by email
-	var user models.User
-	if err := ac.DB.Where("email = ?", loginRequest.Email).First(&user).Error; err != nil {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid credentials"})
+	result, err := ac.Auth.Login(c.Request.Context(), loginRequest.Email, loginRequest.Password, clientIP)
+	if err != nil {
+		c.Error(err)
 		return
 	}
 
-	// Verify password
-	if !utils.VerifyPassword(user.PasswordHash, loginRequest.Password) {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid credentials"})
+	// Users with two-factor authentication, or whose role requires it, get a challenge
+	// to complete with /auth/2fa/verify instead of tokens
+	if challenge := result.Challenge; challenge != nil {
+		requestLogger(c).Info("Two-factor challenge issued", "subject_user_id", result.User.ID)
+
+		c.JSON(http.StatusOK, mappers.ToTwoFactorChallengeResponse(challenge.Token, challenge.ExpiresAt, challenge.EnrollmentRequired))
 		return
 	}
 
-	// Generate JWT token
-	token, err := utils.GenerateJWT(user)
+	user, tokens := result.User, result.Tokens
+	requestLogger(c).Info("Login succeeded", "subject_user_id", user.ID, "subject_restaurant_id", user.RestaurantID)
+
+	c.JSON(http.StatusOK, mappers.ToLoginResponse(tokens.AccessToken, tokens.RefreshToken, user, result.Memberships, tokens.ExpiresAt))
+}
+
+// SwitchRestaurant issues tokens for another restaurant the current user is a member of.
+// The current tokens stay valid for the restaurant they were issued for.
+func (ac *AuthController) SwitchRestaurant(c *gin.Context) {
+	var switchRequest requests.SwitchRestaurantRequest
+	if err := c.ShouldBindJSON(&switchRequest); err != nil {
+		c.Error(apperrors.Validation(err))
+		return
+	}
+	user, ok := ac.currentUser(c)
+	if !ok {
+		return
+	}
+
+	result, err := ac.Auth.SwitchRestaurant(c.Request.Context(), user, switchRequest.RestaurantID)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate token"})
+		c.Error(err)
 		return
 	}
 
-	log.Printf("Successful login for user: %s (ID: %d)", loginRequest.Email, user.ID)
+	user, tokens := result.User, result.Tokens
+	requestLogger(c).Info("Switched restaurant", "subject_restaurant_id", user.RestaurantID)
 
-	c.JSON(http.StatusOK, gin.H{
-		"token": token,
-	})
+	c.JSON(http.StatusOK, mappers.ToLoginResponse(tokens.AccessToken, tokens.RefreshToken, user, result.Memberships, tokens.ExpiresAt))
 }
 
-// Register creates a new user (only admin can register users for their restaurant)
-func (ac *AuthController) Register(c *gin.Context) {
-	log.Printf("User registration attempt by IP: %s", c.ClientIP())
+// SetupTwoFactor returns a new authenticator secret to a user whose role requires
+// two-factor authentication but who has not set it up, during their login
+func (ac *AuthController) SetupTwoFactor(c *gin.Context) {
+	var challengeRequest requests.TwoFactorChallengeRequest
+	if err := c.ShouldBindJSON(&challengeRequest); err != nil {
+		c.Error(apperrors.Validation(err))
+		return
+	}
 
-	var registerRequest requests.RegisterUserRequest
+	user, err := ac.TwoFactor.ChallengeUser(challengeRequest.ChallengeToken)
+	if err != nil {
+		c.Error(err)
+		return
+	}
 
-	if err := c.ShouldBindJSON(&registerRequest); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	secret, provisioningURI, err := ac.TwoFactor.Enroll(&user)
+	if err != nil {
+		c.Error(err)
 		return
 	}
 
-	// Get current user's restaurant ID from context
-	restaurantID, _ := c.Get("restaurant_id")
-	userRole, _ := c.Get("user_role")
+	c.JSON(http.StatusOK, reponses.TwoFactorEnrollmentResponse{Secret: secret, ProvisioningURI: provisioningURI})
+}
 
-	// Only admin can register new users
-	if userRole != "admin" {
-		c.JSON(http.StatusForbidden, gin.H{"error": "Only admin can register new users"})
+// VerifyTwoFactor completes a login with a code from the authenticator app or a recovery
+// code. Wrong codes count as failed logins.
+func (ac *AuthController) VerifyTwoFactor(c *gin.Context) {
+	var verifyRequest requests.VerifyTwoFactorRequest
+	if err := c.ShouldBindJSON(&verifyRequest); err != nil {
+		c.Error(apperrors.Validation(err))
 		return
 	}
 
-	// Hash password
-	hashedPassword, err := utils.HashPassword(registerRequest.Password)
+	result, err := ac.Auth.VerifyTwoFactor(c.Request.Context(), verifyRequest.ChallengeToken, verifyRequest.Code, verifyRequest.RecoveryCode, c.ClientIP())
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to hash password"})
+		c.Error(err)
 		return
 	}
 
-	// Create new user
-	user := models.User{
-		Email:        registerRequest.Email,
-		PasswordHash: hashedPassword,
-		RestaurantID: restaurantID.(uint),
-		Role:         registerRequest.Role,
-		Username:     registerRequest.Username,
-	}
+	user, tokens := result.User, result.Tokens
+	requestLogger(c).Info("Two-factor login succeeded", "subject_user_id", user.ID)
 
-	if err := ac.DB.Create(&user).Error; err != nil {
-		// Check for duplicate email in same restaurant
-		if strings.Contains(err.Error(), "unique_email_restaurant") {
+	c.JSON(http.StatusOK, mappers.ToTwoFactorLoginResponse(tokens.AccessToken, tokens.RefreshToken, user, result.Memberships, tokens.ExpiresAt, result.RecoveryCodes))
+}
 
-			log.Printf("Duplicate email registration attempt: %s for restaurant ID: %d", registerRequest.Email, restaurantID)
+// EnrollTwoFactor starts two-factor setup for the current user and returns the secret for
+// their authenticator app
+func (ac *AuthController) EnrollTwoFactor(c *gin.Context) {
+	user, ok := ac.currentUser(c)
+	if !ok {
+		return
+	}
 
-			c.JSON(http.StatusConflict, gin.H{"error": "Email already exists for this restaurant"})
-			return
-		}
+	secret, provisioningURI, err := ac.TwoFactor.Enroll(&user)
+	if err != nil {
+		c.Error(err)
+		return
+	}
 
-		log.Printf("User creation failed for email: %s, error: %v", registerRequest.Email, err)
+	c.JSON(http.StatusOK, reponses.TwoFactorEnrollmentResponse{Secret: secret, ProvisioningURI: provisioningURI})
+}
 
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create user"})
+// ActivateTwoFactor enables two-factor authentication for the current user once they
+// confirm a code, and returns their recovery codes
+func (ac *AuthController) ActivateTwoFactor(c *gin.Context) {
+	var codeRequest requests.TwoFactorCodeRequest
+	if err := c.ShouldBindJSON(&codeRequest); err != nil {
+		c.Error(apperrors.Validation(err))
+		return
+	}
+	user, ok := ac.currentUser(c)
+	if !ok {
 		return
 	}
-	ac.DB.Preload("Restaurant").First(&user, user.ID)
 
-	log.Printf("User created successfully: %s (ID: %d)", user.Email, user.ID)
+	recoveryCodes, err := ac.TwoFactor.Activate(&user, codeRequest.Code)
+	if err != nil {
+		c.Error(err)
+		return
+	}
 
-	c.JSON(http.StatusCreated, gin.H{
-		"message": "User created successfully",
-		"user":    user,
-	})
+	requestLogger(c).Info("Two-factor authentication enabled")
+
+	c.JSON(http.StatusOK, reponses.RecoveryCodesResponse{RecoveryCodes: recoveryCodes})
 }
 
-// GetProfile returns the current user's profile
-func (ac *AuthController) GetProfile(c *gin.Context) {
-	userID, _ := c.Get("user_id")
+// DisableTwoFactor turns two-factor authentication off for the current user
+func (ac *AuthController) DisableTwoFactor(c *gin.Context) {
+	var codeRequest requests.TwoFactorCodeRequest
+	if err := c.ShouldBindJSON(&codeRequest); err != nil {
+		c.Error(apperrors.Validation(err))
+		return
+	}
+	user, ok := ac.currentUser(c)
+	if !ok {
+		return
+	}
 
-	var user models.User
-	if err := ac.DB.Preload("Restaurant").First(&user, userID).Error; err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+	if err := ac.TwoFactor.Disable(&user, codeRequest.Code); err != nil {
+		c.Error(err)
 		return
 	}
 
-	log.Printf("Profile retrieved successfully for user: %s (ID: %d)", user.Email, user.ID)
+	requestLogger(c).Info("Two-factor authentication disabled")
 
-	c.JSON(http.StatusOK, gin.H{"user": user})
+	c.JSON(http.StatusOK, reponses.SuccessResponse{Message: "Two-factor authentication disabled"})
 }
 
-// RegisterRestaurant handles restaurant registration (public endpoint)
-func (ac *AuthController) RegisterRestaurant(c *gin.Context) {
-	log.Printf("Restaurant registration attempt from IP: %s", c.ClientIP())
+// RegenerateRecoveryCodes replaces the current user's recovery codes
+func (ac *AuthController) RegenerateRecoveryCodes(c *gin.Context) {
+	var codeRequest requests.TwoFactorCodeRequest
+	if err := c.ShouldBindJSON(&codeRequest); err != nil {
+		c.Error(apperrors.Validation(err))
+		return
+	}
+	user, ok := ac.currentUser(c)
+	if !ok {
+		return
+	}
 
-	var restaurantRequest requests.RegisterRestaurantRequest
+	recoveryCodes, err := ac.TwoFactor.RegenerateRecoveryCodes(&user, codeRequest.Code)
+	if err != nil {
+		c.Error(err)
+		return
+	}
 
-	if err := c.ShouldBindJSON(&restaurantRequest); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	c.JSON(http.StatusOK, reponses.RecoveryCodesResponse{RecoveryCodes: recoveryCodes})
+}
+
+// currentUser loads the authenticated user acting in the current restaurant with the role
+// of their membership, attaching an error when it fails
+func (ac *AuthController) currentUser(c *gin.Context) (models.User, bool) {
+	userID, _ := c.Get("user_id")
+
+	user, err := ac.Auth.CurrentUser(c.Request.Context(), userID.(uint), currentMembership(c))
+	if err != nil {
+		c.Error(err)
+		return user, false
+	}
+	return user, true
+}
+
+// RefreshToken exchanges a refresh token for a new access token and a rotated refresh token
+func (ac *AuthController) RefreshToken(c *gin.Context) {
+	var refreshRequest requests.RefreshTokenRequest
+	if err := c.ShouldBindJSON(&refreshRequest); err != nil {
+		c.Error(apperrors.Validation(err))
 		return
 	}
-	locationID, err := ac.SquareService.FetchLocationID(restaurantRequest.SquareToken)
+
+	user, tokens, err := ac.Sessions.Refresh(refreshRequest.RefreshToken)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch Square location ID"})
+		c.Error(err)
 		return
 	}
-	// Create restaurant
-	restaurant := models.Restaurant{
-		Name:        restaurantRequest.Name,
-		SquareAppID: restaurantRequest.SquareAppID,
-		SquareToken: restaurantRequest.SquareToken,
-		LocationID:  locationID,
+
+	requestLogger(c).Info("Token refreshed", "subject_user_id", user.ID)
+
+	c.JSON(http.StatusOK, mappers.ToTokenResponse(tokens.AccessToken, tokens.RefreshToken, tokens.ExpiresAt))
+}
+
+// Logout revokes the current access token and its refresh token, or every session of the user
+func (ac *AuthController) Logout(c *gin.Context) {
+	var logoutRequest requests.LogoutRequest
+	if c.Request.ContentLength != 0 {
+		if err := c.ShouldBindJSON(&logoutRequest); err != nil {
+			c.Error(apperrors.Validation(err))
+			return
+		}
 	}
+	userID, _ := c.Get("user_id")
+	tokenID := c.GetString("token_id")
+	tokenExpiresAt := c.GetTime("token_expires_at")
 
-	if err := ac.DB.Create(&restaurant).Error; err != nil {
-		if strings.Contains(err.Error(), "square_app_id") {
-			
-			log.Printf("Duplicate Square App ID registration attempt: %s", restaurantRequest.SquareAppID)
-			
-			c.JSON(http.StatusConflict, gin.H{"error": "Square App ID already exists"})
+	if err := ac.Sessions.Logout(userID.(uint), tokenID, tokenExpiresAt, logoutRequest.RefreshToken); err != nil {
+		c.Error(apperrors.ErrInternal.Wrap(err))
+		return
+	}
+	if logoutRequest.AllSessions {
+		if err := ac.Sessions.RevokeAllSessions(userID.(uint)); err != nil {
+			c.Error(apperrors.ErrInternal.Wrap(err))
 			return
 		}
-		log.Printf("Restaurant creation failed for name: %s, error: %v", restaurantRequest.Name, err)
+	}
 
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create restaurant"})
+	requestLogger(c).Info("Logged out", "all_sessions", logoutRequest.AllSessions)
+
+	c.JSON(http.StatusOK, reponses.SuccessResponse{Message: "Logged out successfully"})
+}
+
+// ChangePassword updates the current user's password and ends all of their sessions
+func (ac *AuthController) ChangePassword(c *gin.Context) {
+	var passwordRequest requests.ChangePasswordRequest
+	if err := c.ShouldBindJSON(&passwordRequest); err != nil {
+		c.Error(apperrors.Validation(err))
 		return
 	}
+	userID, _ := c.Get("user_id")
 
-	// Hash admin password
-	hashedPassword, err := utils.HashPassword(restaurantRequest.AdminPassword)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to hash password"})
+	if err := ac.Auth.ChangePassword(c.Request.Context(), userID.(uint), passwordRequest.CurrentPassword, passwordRequest.NewPassword); err != nil {
+		c.Error(err)
+		return
+	}
+
+	requestLogger(c).Info("Password changed, all sessions revoked")
+
+	c.JSON(http.StatusOK, reponses.SuccessResponse{Message: "Password changed, please log in again"})
+}
+
+// ForgotPassword emails a password reset link. The response is the same whether or not
+// the email belongs to a user.
+func (ac *AuthController) ForgotPassword(c *gin.Context) {
+	var forgotRequest requests.ForgotPasswordRequest
+	if err := c.ShouldBindJSON(&forgotRequest); err != nil {
+		c.Error(apperrors.Validation(err))
+		return
+	}
+
+	if err := ac.Accounts.RequestPasswordReset(forgotRequest.Email); err != nil {
+		requestLogger(c).Error("Password reset request failed", "error", err)
+	}
+
+	c.JSON(http.StatusAccepted, reponses.SuccessResponse{Message: "If the email belongs to an account, a reset link has been sent"})
+}
+
+// ResetPassword sets a new password using the token from a reset email
+func (ac *AuthController) ResetPassword(c *gin.Context) {
+	var resetRequest requests.ResetPasswordRequest
+	if err := c.ShouldBindJSON(&resetRequest); err != nil {
+		c.Error(apperrors.Validation(err))
+		return
+	}
+
+	if err := ac.Accounts.ResetPassword(resetRequest.Token, resetRequest.NewPassword); err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, reponses.SuccessResponse{Message: "Password reset, please log in again"})
+}
+
+// VerifyEmail confirms a user's email address using the token from a verification email
+func (ac *AuthController) VerifyEmail(c *gin.Context) {
+	var verifyRequest requests.VerifyEmailRequest
+	if err := c.ShouldBindJSON(&verifyRequest); err != nil {
+		c.Error(apperrors.Validation(err))
+		return
+	}
+
+	if err := ac.Accounts.VerifyEmail(verifyRequest.Token); err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, reponses.SuccessResponse{Message: "Email verified, you can now log in"})
+}
+
+// ResendVerification emails a new verification link to an unverified user
+func (ac *AuthController) ResendVerification(c *gin.Context) {
+	var resendRequest requests.ForgotPasswordRequest
+	if err := c.ShouldBindJSON(&resendRequest); err != nil {
+		c.Error(apperrors.Validation(err))
 		return
 	}
 
-	// Create admin user
-	adminUser := models.User{
-		Email:        restaurantRequest.AdminEmail,
-		PasswordHash: hashedPassword,
-		RestaurantID: restaurant.ID,
-		Role:         "admin",
+	if err := ac.Accounts.ResendVerification(resendRequest.Email); err != nil {
+		requestLogger(c).Error("Verification resend failed", "error", err)
 	}
 
-	if err := ac.DB.Create(&adminUser).Error; err != nil {
-		log.Printf("Admin user creation failed for restaurant: %s, admin email: %s, error: %v", restaurantRequest.Name, restaurantRequest.AdminEmail, err)
+	c.JSON(http.StatusAccepted, reponses.SuccessResponse{Message: "If the email needs verifying, a new link has been sent"})
+}
 
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create admin user"})
+// Register creates a new user in the current user's restaurant
+func (ac *AuthController) Register(c *gin.Context) {
+	requestLogger(c).Debug("User registration attempt")
+
+	var registerRequest requests.RegisterUserRequest
+
+	if err := c.ShouldBindJSON(&registerRequest); err != nil {
+		c.Error(apperrors.Validation(err))
 		return
 	}
 
-	log.Printf("Restaurant registration completed successfully: %s (ID: %d) with admin user: %s (ID: %d)",
-		restaurantRequest.Name, restaurant.ID, restaurantRequest.AdminEmail, adminUser.ID)
+	// The new user always joins the current user's restaurant, whatever the body says
+	restaurantID, _ := c.Get("restaurant_id")
+	user, err := ac.Users.RegisterUser(authz.FromContext(c), restaurantID.(uint), registerRequest)
+	if err != nil {
+		requestLogger(c).Info("User registration failed", "error", err)
+
+		c.Error(err)
+		return
+	}
+	requestLogger(c).Info("User created", "subject_user_id", user.ID)
 
-	c.JSON(http.StatusCreated, gin.H{
-		"message":    "Restaurant registered successfully",
-		"restaurant": restaurant,
-		"admin_user": adminUser,
+	c.JSON(http.StatusCreated, reponses.SuccessResponse{
+		Message: "User created successfully",
+		Data:    mappers.ToUserResponse(user),
 	})
 }
+
+// GetProfile returns the current user's profile in the restaurant they are acting in
+func (ac *AuthController) GetProfile(c *gin.Context) {
+	user, ok := ac.currentUser(c)
+	if !ok {
+		return
+	}
+
+	requestLogger(c).Debug("Profile retrieved")
+
+	c.JSON(http.StatusOK, mappers.ToProfileResponse(user))
+}
+
+// RegisterRestaurant handles restaurant registration (public endpoint)
+func (ac *AuthController) RegisterRestaurant(c *gin.Context) {
+	requestLogger(c).Debug("Restaurant registration attempt")
+
+	var restaurantRequest requests.RegisterRestaurantRequest
+
+	if err := c.ShouldBindJSON(&restaurantRequest); err != nil {
+		c.Error(apperrors.Validation(err))
+		return
+	}
+
+	restaurant, adminUser, err := ac.Auth.RegisterRestaurant(c.Request.Context(), restaurantRequest)
+	if err != nil {
+		requestLogger(c).Warn("Restaurant registration failed", "restaurant_name", restaurantRequest.Name, "error", err)
+
+		c.Error(err)
+		return
+	}
+
+	requestLogger(c).Info("Restaurant registered", "subject_restaurant_id", restaurant.ID, "subject_user_id", adminUser.ID)
+
+	c.JSON(http.StatusCreated, mappers.ToRestaurantRegistrationResponse(restaurant, adminUser, "Restaurant registered successfully"))
+}
@@ -0,0 +1,130 @@
+package controllers
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"square-pos-integration/internal/apperrors"
+	"square-pos-integration/internal/authz"
+	"square-pos-integration/internal/mappers"
+	"square-pos-integration/internal/reponses"
+	"square-pos-integration/internal/requests"
+	"square-pos-integration/internal/service"
+	"square-pos-integration/internal/utils"
+)
+
+// DeviceTokenHeader carries the credential of an enrolled device
+const DeviceTokenHeader = "X-Device-Token"
+
+type DeviceController struct {
+	Devices *service.DeviceService
+	Users   *service.UserService
+}
+
+func NewDeviceController(devices *service.DeviceService, users *service.UserService) *DeviceController {
+	return &DeviceController{Devices: devices, Users: users}
+}
+
+// ListDevices returns the devices enrolled for the current restaurant
+func (dc *DeviceController) ListDevices(c *gin.Context) {
+	devices, err := dc.Devices.ListDevices(currentRestaurantID(c))
+	if err != nil {
+		c.Error(apperrors.ErrInternal.Wrap(err))
+		return
+	}
+
+	c.JSON(http.StatusOK, mappers.ToDeviceListResponse(devices))
+}
+
+// EnrollDevice registers a shared POS device and returns its long-lived credential
+func (dc *DeviceController) EnrollDevice(c *gin.Context) {
+	var deviceRequest requests.EnrollDeviceRequest
+	if err := c.ShouldBindJSON(&deviceRequest); err != nil {
+		c.Error(apperrors.Validation(err))
+		return
+	}
+	restaurantID, _ := c.Get("restaurant_id")
+
+	device, credential, err := dc.Devices.EnrollDevice(restaurantID.(uint), deviceRequest.Name)
+	if err != nil {
+		c.Error(apperrors.ErrInternal.Wrap(err))
+		return
+	}
+
+	requestLogger(c).Info("Device enrolled", "device_id", device.ID, "device_name", device.Name)
+
+	c.JSON(http.StatusCreated, mappers.ToDeviceEnrollmentResponse(device, credential))
+}
+
+// RevokeDevice revokes a device's credential and the PIN sessions started on it
+func (dc *DeviceController) RevokeDevice(c *gin.Context) {
+	restaurantID, _ := c.Get("restaurant_id")
+
+	if err := dc.Devices.RevokeDevice(restaurantID.(uint), c.Param("id")); err != nil {
+		c.Error(err)
+		return
+	}
+
+	requestLogger(c).Info("Device revoked", "device_id", c.Param("id"))
+
+	c.JSON(http.StatusOK, reponses.SuccessResponse{Message: "Device revoked successfully"})
+}
+
+// SetUserPin sets the PIN a user of the current restaurant signs in with on shared devices.
+// Like other changes to a user's credentials it needs every permission of the user's role.
+func (dc *DeviceController) SetUserPin(c *gin.Context) {
+	var pinRequest requests.SetPinRequest
+	if err := c.ShouldBindJSON(&pinRequest); err != nil {
+		c.Error(apperrors.Validation(err))
+		return
+	}
+	restaurantID, _ := c.Get("restaurant_id")
+
+	user, err := dc.Users.FindUser(restaurantID.(uint), c.Param("id"))
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	if err := dc.Users.EnsureCanManage(authz.FromContext(c), user); err != nil {
+		c.Error(err)
+		return
+	}
+
+	if err := dc.Devices.SetPin(&user, pinRequest.Pin); err != nil {
+		c.Error(err)
+		return
+	}
+
+	requestLogger(c).Info("PIN set", "subject_user_id", user.ID)
+
+	c.JSON(http.StatusOK, reponses.SuccessResponse{Message: "PIN set successfully"})
+}
+
+// PinLogin signs a staff member in with their PIN on an enrolled device
+func (dc *DeviceController) PinLogin(c *gin.Context) {
+	var pinRequest requests.PinLoginRequest
+	if err := c.ShouldBindJSON(&pinRequest); err != nil {
+		c.Error(apperrors.Validation(err))
+		return
+	}
+
+	device, err := dc.Devices.AuthenticateDevice(c.GetHeader(DeviceTokenHeader))
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	user, token, err := dc.Devices.PinLogin(device, pinRequest.UserID, pinRequest.Pin)
+	if err != nil {
+		requestLogger(c).Warn("PIN login failed", "device_id", device.ID, "error", err)
+		c.Error(err)
+		return
+	}
+
+	requestLogger(c).Info("PIN login succeeded", "subject_user_id", user.ID, "device_id", device.ID)
+
+	c.JSON(http.StatusOK, mappers.ToDeviceLoginResponse(token, device.ID, user, time.Now().Add(utils.DeviceJWTExpiration)))
+}
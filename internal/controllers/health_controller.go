@@ -0,0 +1,34 @@
+package controllers
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"square-pos-integration/internal/service"
+)
+
+type HealthController struct {
+	Health *service.HealthService
+}
+
+func NewHealthController(health *service.HealthService) *HealthController {
+	return &HealthController{Health: health}
+}
+
+// Healthz is the liveness probe. It answers as long as the process serves requests and
+// checks nothing else, so a database outage doesn't get every instance restarted.
+func (hc *HealthController) Healthz(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"status": "ok"})
+}
+
+// Readyz is the readiness probe. It answers 503 with the failed checks while the instance
+// should not get traffic, including once it has started shutting down.
+func (hc *HealthController) Readyz(c *gin.Context) {
+	checks, ready := hc.Health.Ready(c.Request.Context())
+	if !ready {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"status": "unavailable", "checks": checks})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"status": "ready", "checks": checks})
+}
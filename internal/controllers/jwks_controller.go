@@ -0,0 +1,26 @@
+package controllers
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"square-pos-integration/internal/signing"
+)
+
+type JWKSController struct {
+	Keys *signing.KeyRing
+}
+
+func NewJWKSController(keys *signing.KeyRing) *JWKSController {
+	return &JWKSController{Keys: keys}
+}
+
+// GetJWKS publishes the public keys access tokens are verified with, so other services
+// can verify tokens without sharing a secret. Verifiers should refetch when they see an
+// unknown kid.
+func (jc *JWKSController) GetJWKS(c *gin.Context) {
+	c.Header("Cache-Control", "public, max-age=300")
+	c.JSON(http.StatusOK, jc.Keys.JWKS(time.Now()))
+}
@@ -0,0 +1,66 @@
+package controllers
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+
+	"square-pos-integration/internal/apperrors"
+	"square-pos-integration/internal/mappers"
+	"square-pos-integration/internal/models"
+	"square-pos-integration/internal/service"
+)
+
+type LocationController struct {
+	DB            *gorm.DB
+	SquareService service.ISquareService
+	Locations     *service.LocationService
+}
+
+func NewLocationController(db *gorm.DB, squareService service.ISquareService) *LocationController {
+	return &LocationController{DB: db, SquareService: squareService, Locations: service.NewLocationService(db)}
+}
+
+// ListMyLocations returns the active locations the current user is assigned to in the
+// current restaurant
+func (lc *LocationController) ListMyLocations(c *gin.Context) {
+	membership, _ := c.Get("membership")
+
+	locations, err := lc.Locations.AllowedLocations(membership.(models.Membership))
+	if err != nil {
+		c.Error(apperrors.ErrInternal.Wrap(err))
+		return
+	}
+
+	c.JSON(http.StatusOK, mappers.ToLocationListResponse(locations))
+}
+
+// ListLocations returns every location of the current restaurant, including inactive ones
+func (lc *LocationController) ListLocations(c *gin.Context) {
+	restaurantID, _ := c.Get("restaurant_id")
+
+	locations, err := lc.Locations.ListLocations(restaurantID.(uint))
+	if err != nil {
+		c.Error(apperrors.ErrInternal.Wrap(err))
+		return
+	}
+
+	c.JSON(http.StatusOK, mappers.ToLocationListResponse(locations))
+}
+
+// SyncLocations refreshes the restaurant's locations from Square
+func (lc *LocationController) SyncLocations(c *gin.Context) {
+	restaurantID, _ := c.Get("restaurant_id")
+
+	locations, err := lc.SquareService.SyncLocations(c.Request.Context(), restaurantID.(uint))
+	if err != nil {
+		requestLogger(c).Warn("Location sync failed", "error", err)
+		c.Error(err)
+		return
+	}
+
+	requestLogger(c).Info("Locations synced", "count", len(locations))
+
+	c.JSON(http.StatusOK, mappers.ToLocationListResponse(locations))
+}
@@ -0,0 +1,105 @@
+package controllers
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+
+	"square-pos-integration/internal/apperrors"
+	"square-pos-integration/internal/authz"
+	"square-pos-integration/internal/mappers"
+	"square-pos-integration/internal/reponses"
+	"square-pos-integration/internal/requests"
+	"square-pos-integration/internal/service"
+)
+
+type MembershipController struct {
+	DB          *gorm.DB
+	Memberships *service.MembershipService
+}
+
+func NewMembershipController(db *gorm.DB) *MembershipController {
+	return &MembershipController{DB: db, Memberships: service.NewMembershipService(db)}
+}
+
+// ListMembers returns every user with access to the current restaurant, including those
+// whose home restaurant is another one
+func (mc *MembershipController) ListMembers(c *gin.Context) {
+	restaurantID, _ := c.Get("restaurant_id")
+
+	memberships, err := mc.Memberships.ListMembers(restaurantID.(uint))
+	if err != nil {
+		c.Error(apperrors.ErrInternal.Wrap(err))
+		return
+	}
+
+	c.JSON(http.StatusOK, mappers.ToMemberListResponse(memberships))
+}
+
+// AddMember gives an existing user of another restaurant access to the current restaurant
+func (mc *MembershipController) AddMember(c *gin.Context) {
+	var addRequest requests.AddMemberRequest
+	if err := c.ShouldBindJSON(&addRequest); err != nil {
+		c.Error(apperrors.Validation(err))
+		return
+	}
+	restaurantID, _ := c.Get("restaurant_id")
+
+	membership, err := mc.Memberships.AddMember(authz.FromContext(c), restaurantID.(uint), addRequest)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	requestLogger(c).Info("Member added", "subject_user_id", membership.UserID, "role", membership.Role)
+
+	c.JSON(http.StatusCreated, mappers.ToMemberResponse(membership))
+}
+
+// UpdateMember changes the role, allowed locations or active flag of a member of the current restaurant
+func (mc *MembershipController) UpdateMember(c *gin.Context) {
+	var updateRequest requests.UpdateMemberRequest
+	if err := c.ShouldBindJSON(&updateRequest); err != nil {
+		c.Error(apperrors.Validation(err))
+		return
+	}
+	restaurantID, _ := c.Get("restaurant_id")
+	currentUserID, _ := c.Get("user_id")
+
+	if c.Param("user_id") == strconv.FormatUint(uint64(currentUserID.(uint)), 10) && updateRequest.IsActive != nil && !*updateRequest.IsActive {
+		c.Error(apperrors.ErrCannotDeactivateSelf)
+		return
+	}
+
+	membership, err := mc.Memberships.UpdateMember(authz.FromContext(c), restaurantID.(uint), c.Param("user_id"), updateRequest)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	requestLogger(c).Info("Membership updated", "subject_user_id", membership.UserID)
+
+	c.JSON(http.StatusOK, mappers.ToMemberResponse(membership))
+}
+
+// RemoveMember takes away a user's access to the current restaurant
+func (mc *MembershipController) RemoveMember(c *gin.Context) {
+	restaurantID, _ := c.Get("restaurant_id")
+	currentUserID, _ := c.Get("user_id")
+
+	if c.Param("user_id") == strconv.FormatUint(uint64(currentUserID.(uint)), 10) {
+		c.Error(apperrors.ErrCannotDeactivateSelf)
+		return
+	}
+
+	if err := mc.Memberships.RemoveMember(authz.FromContext(c), restaurantID.(uint), c.Param("user_id")); err != nil {
+		c.Error(err)
+		return
+	}
+
+	requestLogger(c).Info("Member removed", "subject_user_id", c.Param("user_id"))
+
+	c.JSON(http.StatusOK, reponses.SuccessResponse{Message: "Member removed successfully"})
+}
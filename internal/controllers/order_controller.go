@@ -1,119 +1,157 @@
 package controllers
 
 import (
-	"encoding/json"
+	"log/slog"
 	"net/http"
-	"strconv"
 	"time"
 
-	"github.com/google/uuid"
 	"github.com/gin-gonic/gin"
-	"gorm.io/datatypes"
 	"gorm.io/gorm"
+	"square-pos-integration/internal/apperrors"
+	"square-pos-integration/internal/authz"
+	"square-pos-integration/internal/logging"
+	"square-pos-integration/internal/mappers"
 	"square-pos-integration/internal/models"
 	"square-pos-integration/internal/requests"
 	"square-pos-integration/internal/service"
+	"square-pos-integration/internal/tenant"
 	"square-pos-integration/internal/utils"
 )
 
 type OrderController struct {
-	DB            *gorm.DB
-	SquareService *service.SquareService
+	Orders service.IOrderService
 }
 
-func NewOrderController(db *gorm.DB, squareService *service.SquareService) *OrderController {
-	return &OrderController{
-		DB:            db,
-		SquareService: squareService,
-	}
+func NewOrderController(db *gorm.DB, squareService service.ISquareService) *OrderController {
+	return &OrderController{Orders: service.NewOrderService(db, squareService)}
 }
 
 // CreateOrder creates order in Square and local DB
 func (oc *OrderController) CreateOrder(c *gin.Context) {
 	var orderRequest requests.CreateOrderRequest
 	if err := c.ShouldBindJSON(&orderRequest); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		c.Error(apperrors.Validation(err))
 		return
 	}
-	restaurantID, _ := c.Get("restaurant_id")
 	userID, _ := c.Get("user_id")
-	idempotencyKey := "order-" + uuid.NewString()
-	// Create order in Square first
-	squareOrder, err := oc.SquareService.CreateOrder(restaurantID.(uint), orderRequest, idempotencyKey)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create order in Square: " + err.Error()})
+	if err := checkDiscountLimit(c, orderRequest); err != nil {
+		c.Error(err)
 		return
 	}
 
-	// Create order in local DB with full persistence
-
-	jsonBytes, err := json.Marshal(squareOrder)
+	order, squareOrder, err := oc.Orders.CreateOrder(c.Request.Context(), currentMembership(c), userID.(uint), orderRequest)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to marshal Square order: " + err.Error()})
+		c.Error(err)
 		return
 	}
-	order := models.Order{
-		SquareOrderID: utils.SafeString(squareOrder.ID),
-		RestaurantID:  restaurantID.(uint),
-		UserID:        userID.(uint),
-		TableNumber:   orderRequest.TableNumber,
-		Status:        "pending",
-		TotalAmount:   utils.SafeInt64(squareOrder.TotalMoney.Amount),
-		Currency:      utils.SafeCurrency(squareOrder.TotalMoney.Currency),
-		LocationID:    orderRequest.LocationID,
-		RawSquareData: datatypes.JSON(jsonBytes),
-		OpenedAt:      time.Now(), // Store complete Square response
+	c.JSON(http.StatusCreated, mappers.ToOrderResponseFromSquare(order, squareOrder))
+}
+
+// PreviewOrder prices an order through Square, including taxes, discounts and service
+// charges, without creating it in Square or the local DB
+func (oc *OrderController) PreviewOrder(c *gin.Context) {
+	var orderRequest requests.CreateOrderRequest
+	if err := c.ShouldBindJSON(&orderRequest); err != nil {
+		c.Error(apperrors.Validation(err))
+		return
 	}
 
-	if err := oc.DB.Create(&order).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save order to database"})
+	calculatedOrder, err := oc.Orders.PreviewOrder(c.Request.Context(), currentMembership(c), orderRequest)
+	if err != nil {
+		c.Error(err)
 		return
 	}
 
-	// Persist order line items
-	for _, lineItem := range squareOrder.LineItems {
-		orderItem := models.OrderItem{
-			OrderID:      strconv.FormatUint(uint64(order.ID), 10),
-			Name:         utils.SafeString(lineItem.Name),
-			UnitPrice:    utils.SafeInt64(lineItem.BasePriceMoney.Amount),
-			Quantity:     utils.ParseQuantity(lineItem.Quantity),
-			Amount:       int(utils.SafeInt64(lineItem.TotalMoney.Amount)),
-			SquareItemID: utils.SafeString(lineItem.CatalogObjectID),
-			SquareUID:    utils.SafeString(lineItem.UID)}
-		oc.DB.Create(&orderItem)
+	preview := models.Order{
+		TableNumber: orderRequest.TableNumber,
+		OpenedAt:    time.Now(),
+		Totals:      utils.BuildOrderTotals(calculatedOrder, 0, 0),
 	}
-	c.JSON(http.StatusCreated, gin.H{
-		"order":        order,
-		"square_order": squareOrder,
-	})
+	c.JSON(http.StatusOK, mappers.ToOrderResponseFromSquare(preview, calculatedOrder))
 }
 
 // GetOrderByTableNumber retrieves orders by table number
 func (oc *OrderController) GetOrderByTableNumber(c *gin.Context) {
-	tableNumber := c.Param("table_number")
-	restaurantID, _ := c.Get("restaurant_id")
-
-	var orders []models.Order
-	if err := oc.DB.Where("table_number = ? AND restaurant_id = ?", tableNumber, restaurantID).Find(&orders).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve orders"})
+	orders, err := oc.Orders.ListTableOrders(c.Request.Context(), currentRestaurantID(c), c.Param("table_number"))
+	if err != nil {
+		c.Error(err)
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"orders": orders})
+	c.JSON(http.StatusOK, mappers.ToOrderListResponse(orders))
 }
 
 // GetOrderByID retrieves order by ID
 func (oc *OrderController) GetOrderByID(c *gin.Context) {
-	orderID := c.Param("id")
-	restaurantID, _ := c.Get("restaurant_id")
+	order, err := oc.Orders.GetOrder(c.Request.Context(), currentRestaurantID(c), c.Param("id"))
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, mappers.ToOrderResponse(order))
+}
+
+// CancelOrder cancels a pending order in Square and locally. Staff may cancel their own
+// orders, cancelling another user's order also needs orders.manage_any.
+func (oc *OrderController) CancelOrder(c *gin.Context) {
+	order, err := oc.Orders.GetOrder(c.Request.Context(), currentRestaurantID(c), c.Param("id"))
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	if err := authz.RequireOwned(c, authz.OrdersCancel, authz.OrdersManageAny, order.UserID); err != nil {
+		c.Error(err)
+		return
+	}
 
-	var order models.Order
-	if err := oc.DB.Where("id = ? AND restaurant_id = ?", orderID, restaurantID).First(&order).Error; err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Order not found"})
+	squareOrder, err := oc.Orders.CancelOrder(c.Request.Context(), &order)
+	if err != nil {
+		c.Error(err)
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"order": order})
+	c.JSON(http.StatusOK, mappers.ToOrderResponseFromSquare(order, squareOrder))
+}
+
+// checkDiscountLimit rejects orders whose discounts exceed the restaurant's limit unless
+// the user may apply discounts over the limit
+func checkDiscountLimit(c *gin.Context, orderRequest requests.CreateOrderRequest) error {
+	if authz.Can(c, authz.DiscountsApplyOverLimit) {
+		return nil
+	}
+
+	limitPercent := 20
+	if restaurant, ok := c.Value("restaurant").(models.Restaurant); ok {
+		limitPercent = restaurant.DiscountLimitPercent
+	}
+
+	discount, gross := orderRequest.DiscountTotals()
+	if discount*100 > gross*int64(limitPercent) {
+		return apperrors.ErrDiscountOverLimit.WithDetails(map[string]int{"limit_percent": limitPercent})
+	}
+	return nil
 }
 
+// currentMembership returns the membership the request is acting under
+func currentMembership(c *gin.Context) models.Membership {
+	membership, _ := c.Value("membership").(models.Membership)
+	return membership
+}
 
+// currentRestaurantID returns the restaurant the request is acting for
+func currentRestaurantID(c *gin.Context) uint {
+	restaurantID, _ := c.Value("restaurant_id").(uint)
+	return restaurantID
+}
+
+// requestLogger returns the logger of the request, see logging.FromContext
+func requestLogger(c *gin.Context) *slog.Logger {
+	return logging.FromContext(c.Request.Context())
+}
+
+// scopedDB limits queries on restaurant-owned tables to the current restaurant. Without a
+// restaurant in the context those queries fail with tenant.ErrMissingScope.
+func scopedDB(c *gin.Context, db *gorm.DB) *gorm.DB {
+	return tenant.Scoped(db, currentRestaurantID(c))
+}
@@ -0,0 +1,89 @@
+package controllers
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+
+	"square-pos-integration/internal/apperrors"
+	"square-pos-integration/internal/authz"
+	"square-pos-integration/internal/mappers"
+	"square-pos-integration/internal/reponses"
+	"square-pos-integration/internal/requests"
+	"square-pos-integration/internal/service"
+)
+
+type RoleController struct {
+	DB    *gorm.DB
+	Roles *service.RoleService
+}
+
+func NewRoleController(db *gorm.DB) *RoleController {
+	return &RoleController{DB: db, Roles: service.NewRoleService(db)}
+}
+
+// ListRoles returns the built-in roles, the restaurant's custom roles and every grantable permission
+func (rc *RoleController) ListRoles(c *gin.Context) {
+	restaurantID, _ := c.Get("restaurant_id")
+
+	roles, err := rc.Roles.ListRoles(restaurantID.(uint))
+	if err != nil {
+		c.Error(apperrors.ErrInternal.Wrap(err))
+		return
+	}
+
+	c.JSON(http.StatusOK, mappers.ToRoleListResponse(roles))
+}
+
+// CreateRole adds a custom role to the current restaurant
+func (rc *RoleController) CreateRole(c *gin.Context) {
+	var roleRequest requests.CreateRoleRequest
+	if err := c.ShouldBindJSON(&roleRequest); err != nil {
+		c.Error(apperrors.Validation(err))
+		return
+	}
+	restaurantID, _ := c.Get("restaurant_id")
+
+	role, err := rc.Roles.CreateRole(authz.FromContext(c), restaurantID.(uint), roleRequest)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	requestLogger(c).Info("Role created", "role_id", role.ID, "role", role.Name)
+
+	c.JSON(http.StatusCreated, mappers.ToRoleResponse(role))
+}
+
+// UpdateRole changes the description or permissions of a custom role
+func (rc *RoleController) UpdateRole(c *gin.Context) {
+	var roleRequest requests.UpdateRoleRequest
+	if err := c.ShouldBindJSON(&roleRequest); err != nil {
+		c.Error(apperrors.Validation(err))
+		return
+	}
+	restaurantID, _ := c.Get("restaurant_id")
+
+	role, err := rc.Roles.UpdateRole(authz.FromContext(c), restaurantID.(uint), c.Param("id"), roleRequest)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	requestLogger(c).Info("Role updated", "role_id", role.ID, "role", role.Name)
+
+	c.JSON(http.StatusOK, mappers.ToRoleResponse(role))
+}
+
+// DeleteRole removes a custom role that is not assigned to any user
+func (rc *RoleController) DeleteRole(c *gin.Context) {
+	restaurantID, _ := c.Get("restaurant_id")
+
+	if err := rc.Roles.DeleteRole(restaurantID.(uint), c.Param("id")); err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, reponses.SuccessResponse{Message: "Role deleted successfully"})
+}
@@ -0,0 +1,50 @@
+package controllers
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+
+	"square-pos-integration/internal/apperrors"
+	"square-pos-integration/internal/models"
+	"square-pos-integration/internal/reponses"
+	"square-pos-integration/internal/requests"
+	"square-pos-integration/internal/service"
+)
+
+type SecurityController struct {
+	DB        *gorm.DB
+	TwoFactor *service.TwoFactorService
+}
+
+func NewSecurityController(db *gorm.DB, twoFactor *service.TwoFactorService) *SecurityController {
+	return &SecurityController{DB: db, TwoFactor: twoFactor}
+}
+
+// GetSecuritySettings returns the current restaurant's security settings
+func (sc *SecurityController) GetSecuritySettings(c *gin.Context) {
+	restaurant := c.MustGet("restaurant").(models.Restaurant)
+
+	c.JSON(http.StatusOK, reponses.SecuritySettingsResponse{TwoFactorRoles: service.TwoFactorRoles(restaurant)})
+}
+
+// UpdateSecuritySettings sets the roles that must sign in with two-factor authentication.
+// Users of those roles who have not set it up are asked to on their next login.
+func (sc *SecurityController) UpdateSecuritySettings(c *gin.Context) {
+	var settingsRequest requests.SecuritySettingsRequest
+	if err := c.ShouldBindJSON(&settingsRequest); err != nil {
+		c.Error(apperrors.Validation(err))
+		return
+	}
+	restaurant := c.MustGet("restaurant").(models.Restaurant)
+
+	if err := sc.TwoFactor.SetRequiredRoles(&restaurant, settingsRequest.TwoFactorRoles); err != nil {
+		c.Error(err)
+		return
+	}
+
+	requestLogger(c).Info("Two-factor roles updated", "two_factor_roles", restaurant.TwoFactorRoles)
+
+	c.JSON(http.StatusOK, reponses.SecuritySettingsResponse{TwoFactorRoles: service.TwoFactorRoles(restaurant)})
+}
@@ -0,0 +1,87 @@
+package controllers
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+
+	"square-pos-integration/internal/apperrors"
+	"square-pos-integration/internal/mappers"
+	"square-pos-integration/internal/models"
+	"square-pos-integration/internal/reponses"
+	"square-pos-integration/internal/requests"
+)
+
+type ServiceChargeController struct {
+	DB *gorm.DB
+}
+
+func NewServiceChargeController(db *gorm.DB) *ServiceChargeController {
+	return &ServiceChargeController{DB: db}
+}
+
+// ListServiceCharges returns the service charge rules configured for the current restaurant
+func (sc *ServiceChargeController) ListServiceCharges(c *gin.Context) {
+	var rules []models.ServiceChargeRule
+	if err := scopedDB(c, sc.DB).Order("id").Find(&rules).Error; err != nil {
+		c.Error(apperrors.ErrInternal.Wrap(err))
+		return
+	}
+
+	c.JSON(http.StatusOK, mappers.ToServiceChargeListResponse(rules))
+}
+
+// CreateServiceCharge stores a new service charge rule such as an auto gratuity or delivery fee
+func (sc *ServiceChargeController) CreateServiceCharge(c *gin.Context) {
+	var chargeRequest requests.CreateServiceChargeRuleRequest
+	if err := c.ShouldBindJSON(&chargeRequest); err != nil {
+		c.Error(apperrors.Validation(err))
+		return
+	}
+	restaurantID, _ := c.Get("restaurant_id")
+
+	rule := models.ServiceChargeRule{
+		RestaurantID:     restaurantID.(uint),
+		LocationID:       chargeRequest.LocationID,
+		Name:             chargeRequest.Name,
+		Percentage:       chargeRequest.Percentage,
+		Amount:           chargeRequest.Amount,
+		CalculationPhase: chargeRequest.CalculationPhase,
+		Taxable:          chargeRequest.Taxable,
+		Enabled:          chargeRequest.Enabled == nil || *chargeRequest.Enabled,
+		MinGuestCount:    chargeRequest.MinGuestCount,
+		OrderType:        chargeRequest.OrderType,
+	}
+	if rule.CalculationPhase == "" {
+		rule.CalculationPhase = "SUBTOTAL_PHASE"
+	}
+
+	// Square only taxes service charges applied before taxes are calculated
+	if rule.Taxable && rule.CalculationPhase != "SUBTOTAL_PHASE" {
+		c.Error(apperrors.ErrInvalidServiceCharge)
+		return
+	}
+
+	if err := scopedDB(c, sc.DB).Create(&rule).Error; err != nil {
+		c.Error(apperrors.ErrInternal.Wrap(err))
+		return
+	}
+
+	c.JSON(http.StatusCreated, mappers.ToServiceChargeResponse(rule))
+}
+
+// DeleteServiceCharge removes a service charge rule so it is no longer applied to new orders
+func (sc *ServiceChargeController) DeleteServiceCharge(c *gin.Context) {
+	result := scopedDB(c, sc.DB).Where("id = ?", c.Param("id")).Delete(&models.ServiceChargeRule{})
+	if result.Error != nil {
+		c.Error(apperrors.ErrInternal.Wrap(result.Error))
+		return
+	}
+	if result.RowsAffected == 0 {
+		c.Error(apperrors.ErrServiceChargeNotFound)
+		return
+	}
+
+	c.JSON(http.StatusOK, reponses.SuccessResponse{Message: "Service charge deleted successfully"})
+}
@@ -0,0 +1,121 @@
+package controllers
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+
+	"square-pos-integration/internal/apperrors"
+	"square-pos-integration/internal/mappers"
+	"square-pos-integration/internal/models"
+	"square-pos-integration/internal/reponses"
+	"square-pos-integration/internal/requests"
+	"square-pos-integration/internal/service"
+)
+
+type TaxController struct {
+	DB            *gorm.DB
+	SquareService service.ISquareService
+}
+
+func NewTaxController(db *gorm.DB, squareService service.ISquareService) *TaxController {
+	return &TaxController{
+		DB:            db,
+		SquareService: squareService,
+	}
+}
+
+// ListTaxRules returns the tax rules configured for the current restaurant
+func (tc *TaxController) ListTaxRules(c *gin.Context) {
+	var rules []models.TaxRule
+	if err := scopedDB(c, tc.DB).Order("id").Find(&rules).Error; err != nil {
+		c.Error(apperrors.ErrInternal.Wrap(err))
+		return
+	}
+
+	c.JSON(http.StatusOK, mappers.ToTaxRuleListResponse(rules))
+}
+
+// CreateTaxRule stores a new tax rule and optionally pushes it to the Square catalog
+func (tc *TaxController) CreateTaxRule(c *gin.Context) {
+	var taxRequest requests.CreateTaxRuleRequest
+	if err := c.ShouldBindJSON(&taxRequest); err != nil {
+		c.Error(apperrors.Validation(err))
+		return
+	}
+	restaurantID, _ := c.Get("restaurant_id")
+
+	rule := models.TaxRule{
+		RestaurantID:  restaurantID.(uint),
+		LocationID:    taxRequest.LocationID,
+		Name:          taxRequest.Name,
+		Percentage:    taxRequest.Percentage,
+		InclusionType: taxRequest.InclusionType,
+		Enabled:       taxRequest.Enabled == nil || *taxRequest.Enabled,
+	}
+	if rule.InclusionType == "" {
+		rule.InclusionType = "ADDITIVE"
+	}
+
+	if err := scopedDB(c, tc.DB).Create(&rule).Error; err != nil {
+		c.Error(apperrors.ErrInternal.Wrap(err))
+		return
+	}
+
+	if taxRequest.PushToSquare {
+		if err := tc.SquareService.PushTaxRule(c.Request.Context(), rule.RestaurantID, &rule); err != nil {
+			requestLogger(c).Warn("Failed to push tax rule to Square", "tax_rule_id", rule.ID, "error", err)
+			c.Error(apperrors.ErrTaxRulePushFailed.Wrap(err).WithDetails(mappers.ToTaxRuleResponse(rule)))
+			return
+		}
+	}
+
+	c.JSON(http.StatusCreated, mappers.ToTaxRuleResponse(rule))
+}
+
+// PushTaxRule creates or updates an existing tax rule in the Square catalog
+func (tc *TaxController) PushTaxRule(c *gin.Context) {
+	var rule models.TaxRule
+	if err := scopedDB(c, tc.DB).Where("id = ?", c.Param("id")).First(&rule).Error; err != nil {
+		c.Error(apperrors.ErrTaxRuleNotFound.Wrap(err))
+		return
+	}
+
+	if err := tc.SquareService.PushTaxRule(c.Request.Context(), rule.RestaurantID, &rule); err != nil {
+		requestLogger(c).Warn("Failed to push tax rule to Square", "tax_rule_id", rule.ID, "error", err)
+		c.Error(apperrors.ErrTaxRulePushFailed.Wrap(err))
+		return
+	}
+
+	c.JSON(http.StatusOK, mappers.ToTaxRuleResponse(rule))
+}
+
+// SyncTaxRules imports the restaurant's Square catalog taxes as local tax rules
+func (tc *TaxController) SyncTaxRules(c *gin.Context) {
+	restaurantID, _ := c.Get("restaurant_id")
+
+	rules, err := tc.SquareService.SyncCatalogTaxes(c.Request.Context(), restaurantID.(uint))
+	if err != nil {
+		requestLogger(c).Warn("Tax sync failed", "error", err)
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, mappers.ToTaxRuleListResponse(rules))
+}
+
+// DeleteTaxRule removes a tax rule so it is no longer applied to new orders
+func (tc *TaxController) DeleteTaxRule(c *gin.Context) {
+	result := scopedDB(c, tc.DB).Where("id = ?", c.Param("id")).Delete(&models.TaxRule{})
+	if result.Error != nil {
+		c.Error(apperrors.ErrInternal.Wrap(result.Error))
+		return
+	}
+	if result.RowsAffected == 0 {
+		c.Error(apperrors.ErrTaxRuleNotFound)
+		return
+	}
+
+	c.JSON(http.StatusOK, reponses.SuccessResponse{Message: "Tax rule deleted successfully"})
+}
@@ -0,0 +1,180 @@
+package controllers
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+
+	"square-pos-integration/internal/apperrors"
+	"square-pos-integration/internal/authz"
+	"square-pos-integration/internal/mappers"
+	"square-pos-integration/internal/models"
+	"square-pos-integration/internal/reponses"
+	"square-pos-integration/internal/requests"
+	"square-pos-integration/internal/service"
+)
+
+type UserController struct {
+	DB         *gorm.DB
+	Users      *service.UserService
+	LoginGuard *service.LoginGuard
+	TwoFactor  *service.TwoFactorService
+}
+
+func NewUserController(db *gorm.DB, loginGuard *service.LoginGuard, twoFactor *service.TwoFactorService) *UserController {
+	return &UserController{DB: db, Users: service.NewUserService(db, twoFactor.Sessions), LoginGuard: loginGuard, TwoFactor: twoFactor}
+}
+
+// ListUsers returns the users of the current restaurant
+func (uc *UserController) ListUsers(c *gin.Context) {
+	restaurantID, _ := c.Get("restaurant_id")
+
+	var users []models.User
+	if err := uc.DB.Where("restaurant_id = ?", restaurantID).Order("id").Find(&users).Error; err != nil {
+		c.Error(apperrors.ErrInternal.Wrap(err))
+		return
+	}
+
+	c.JSON(http.StatusOK, mappers.ToUserListResponse(users))
+}
+
+// GetUser returns a user of the current restaurant
+func (uc *UserController) GetUser(c *gin.Context) {
+	restaurantID, _ := c.Get("restaurant_id")
+
+	user, err := uc.Users.FindUser(restaurantID.(uint), c.Param("id"))
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, mappers.ToUserResponse(user))
+}
+
+// UpdateUser changes the username, email, role or active flag of a user of the current restaurant
+func (uc *UserController) UpdateUser(c *gin.Context) {
+	var updateRequest requests.UpdateUserRequest
+	if err := c.ShouldBindJSON(&updateRequest); err != nil {
+		c.Error(apperrors.Validation(err))
+		return
+	}
+	restaurantID, _ := c.Get("restaurant_id")
+	currentUserID, _ := c.Get("user_id")
+
+	user, err := uc.Users.FindUser(restaurantID.(uint), c.Param("id"))
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	if user.ID == currentUserID.(uint) && updateRequest.IsActive != nil && !*updateRequest.IsActive {
+		c.Error(apperrors.ErrCannotDeactivateSelf)
+		return
+	}
+
+	if err := uc.Users.UpdateUser(authz.FromContext(c), &user, updateRequest); err != nil {
+		c.Error(err)
+		return
+	}
+
+	requestLogger(c).Info("User updated", "subject_user_id", user.ID)
+
+	c.JSON(http.StatusOK, mappers.ToUserResponse(user))
+}
+
+// DeactivateUser disables a user of the current restaurant and ends all of their sessions
+func (uc *UserController) DeactivateUser(c *gin.Context) {
+	restaurantID, _ := c.Get("restaurant_id")
+	currentUserID, _ := c.Get("user_id")
+
+	user, err := uc.Users.FindUser(restaurantID.(uint), c.Param("id"))
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	if user.ID == currentUserID.(uint) {
+		c.Error(apperrors.ErrCannotDeactivateSelf)
+		return
+	}
+
+	if err := uc.Users.DeactivateUser(authz.FromContext(c), &user); err != nil {
+		c.Error(err)
+		return
+	}
+
+	requestLogger(c).Info("User deactivated", "subject_user_id", user.ID)
+
+	c.JSON(http.StatusOK, reponses.SuccessResponse{Message: "User deactivated successfully"})
+}
+
+// ResetPassword sets a new password for a user of the current restaurant and ends all of their sessions
+func (uc *UserController) ResetPassword(c *gin.Context) {
+	var resetRequest requests.ResetUserPasswordRequest
+	if err := c.ShouldBindJSON(&resetRequest); err != nil {
+		c.Error(apperrors.Validation(err))
+		return
+	}
+	restaurantID, _ := c.Get("restaurant_id")
+
+	user, err := uc.Users.FindUser(restaurantID.(uint), c.Param("id"))
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	if err := uc.Users.ResetPassword(authz.FromContext(c), &user, resetRequest.NewPassword); err != nil {
+		c.Error(err)
+		return
+	}
+
+	requestLogger(c).Info("Password reset by admin", "subject_user_id", user.ID)
+
+	c.JSON(http.StatusOK, reponses.SuccessResponse{Message: "Password reset successfully"})
+}
+
+// UnlockUser lifts the login lockout of a user of the current restaurant
+func (uc *UserController) UnlockUser(c *gin.Context) {
+	restaurantID, _ := c.Get("restaurant_id")
+	currentUserID, _ := c.Get("user_id")
+
+	user, err := uc.Users.FindUser(restaurantID.(uint), c.Param("id"))
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	if err := uc.LoginGuard.Unlock(c.Request.Context(), user, currentUserID.(uint), c.ClientIP()); err != nil {
+		c.Error(apperrors.ErrInternal.Wrap(err))
+		return
+	}
+
+	requestLogger(c).Info("Login unlocked", "subject_user_id", user.ID)
+
+	c.JSON(http.StatusOK, reponses.SuccessResponse{Message: "User unlocked successfully"})
+}
+
+// ResetTwoFactor turns two-factor authentication off for a user of the current restaurant
+// who lost their authenticator, and ends all of their sessions
+func (uc *UserController) ResetTwoFactor(c *gin.Context) {
+	restaurantID, _ := c.Get("restaurant_id")
+
+	user, err := uc.Users.FindUser(restaurantID.(uint), c.Param("id"))
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	if err := uc.Users.EnsureCanManage(authz.FromContext(c), user); err != nil {
+		c.Error(err)
+		return
+	}
+
+	if err := uc.TwoFactor.Reset(&user); err != nil {
+		c.Error(apperrors.ErrInternal.Wrap(err))
+		return
+	}
+
+	requestLogger(c).Info("Two-factor authentication reset", "subject_user_id", user.ID)
+
+	c.JSON(http.StatusOK, reponses.SuccessResponse{Message: "Two-factor authentication reset successfully"})
+}
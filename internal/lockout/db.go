@@ -0,0 +1,80 @@
+package lockout
+
+import (
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"square-pos-integration/internal/models"
+)
+
+// DBStore keeps attempts in the login_attempts table so every replica sees the same counters
+type DBStore struct {
+	DB *gorm.DB
+}
+
+// Get returns the attempts for the key
+func (s *DBStore) Get(key string) (Attempts, error) {
+	var row models.LoginAttempt
+	err := s.DB.Where("attempt_key = ?", key).First(&row).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return Attempts{}, nil
+	}
+	if err != nil {
+		return Attempts{}, err
+	}
+	return toAttempts(row), nil
+}
+
+// RecordFailure counts a failure for the key. The row is locked while it is updated so
+// concurrent failures on different replicas are all counted.
+func (s *DBStore) RecordFailure(key string, now time.Time, window time.Duration) (Attempts, error) {
+	var row models.LoginAttempt
+	err := s.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.OnConflict{DoNothing: true}).
+			Create(&models.LoginAttempt{Key: key}).Error; err != nil {
+			return err
+		}
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("attempt_key = ?", key).First(&row).Error; err != nil {
+			return err
+		}
+
+		if row.LastFailureAt == nil || now.Sub(*row.LastFailureAt) > window {
+			row.Failures = 0
+		}
+		row.Failures++
+		row.LastFailureAt = &now
+		return tx.Model(&models.LoginAttempt{}).Where("attempt_key = ?", key).Updates(map[string]interface{}{
+			"failures":        row.Failures,
+			"last_failure_at": now,
+		}).Error
+	})
+	if err != nil {
+		return Attempts{}, err
+	}
+	return toAttempts(row), nil
+}
+
+// Lock locks the key until the given time
+func (s *DBStore) Lock(key string, until time.Time) error {
+	return s.DB.Model(&models.LoginAttempt{}).Where("attempt_key = ?", key).Updates(map[string]interface{}{
+		"failures":     0,
+		"locked_until": until,
+	}).Error
+}
+
+// Reset forgets the key
+func (s *DBStore) Reset(key string) error {
+	return s.DB.Where("attempt_key = ?", key).Delete(&models.LoginAttempt{}).Error
+}
+
+func toAttempts(row models.LoginAttempt) Attempts {
+	attempts := Attempts{Failures: row.Failures, LockedUntil: row.LockedUntil}
+	if row.LastFailureAt != nil {
+		attempts.LastFailureAt = *row.LastFailureAt
+	}
+	return attempts
+}
@@ -0,0 +1,76 @@
+package lockout
+
+import (
+	"sync"
+	"time"
+)
+
+// MemoryStore keeps attempts in process memory. Counters are lost on restart and are not
+// shared between replicas.
+type MemoryStore struct {
+	mu        sync.Mutex
+	attempts  map[string]Attempts
+	lastSweep time.Time
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{attempts: make(map[string]Attempts)}
+}
+
+// Get returns the attempts for the key
+func (s *MemoryStore) Get(key string) (Attempts, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.attempts[key], nil
+}
+
+// RecordFailure counts a failure for the key
+func (s *MemoryStore) RecordFailure(key string, now time.Time, window time.Duration) (Attempts, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.sweep(now, window)
+
+	attempts := s.attempts[key]
+	if now.Sub(attempts.LastFailureAt) > window {
+		attempts.Failures = 0
+	}
+	attempts.Failures++
+	attempts.LastFailureAt = now
+	s.attempts[key] = attempts
+	return attempts, nil
+}
+
+// Lock locks the key until the given time
+func (s *MemoryStore) Lock(key string, until time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	attempts := s.attempts[key]
+	attempts.Failures = 0
+	attempts.LockedUntil = &until
+	s.attempts[key] = attempts
+	return nil
+}
+
+// Reset forgets the key
+func (s *MemoryStore) Reset(key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.attempts, key)
+	return nil
+}
+
+// sweep drops keys with no recent failures and no active lock, at most once per window,
+// so addresses and emails that are tried once do not pile up
+func (s *MemoryStore) sweep(now time.Time, window time.Duration) {
+	if now.Sub(s.lastSweep) < window {
+		return
+	}
+	for key, attempts := range s.attempts {
+		if now.Sub(attempts.LastFailureAt) > window && !attempts.Locked(now) {
+			delete(s.attempts, key)
+		}
+	}
+	s.lastSweep = now
+}
@@ -0,0 +1,29 @@
+package lockout
+
+import "time"
+
+// Attempts is the failure history kept for one key, such as an account or a client IP
+type Attempts struct {
+	Failures      int
+	LastFailureAt time.Time
+	LockedUntil   *time.Time
+}
+
+// Locked reports whether the key is locked at the given time
+func (a Attempts) Locked(now time.Time) bool {
+	return a.LockedUntil != nil && now.Before(*a.LockedUntil)
+}
+
+// Store keeps failed login attempts. MemoryStore suits a single instance, DBStore shares
+// the counters between replicas.
+type Store interface {
+	// Get returns the attempts for the key, or zero Attempts when there are none
+	Get(key string) (Attempts, error)
+	// RecordFailure counts a failure at now and returns the updated attempts. Failures
+	// older than window are forgotten before counting.
+	RecordFailure(key string, now time.Time, window time.Duration) (Attempts, error)
+	// Lock locks the key until the given time and clears its failure count
+	Lock(key string, until time.Time) error
+	// Reset forgets the key's failures and lock
+	Reset(key string) error
+}
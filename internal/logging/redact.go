@@ -0,0 +1,95 @@
+package logging
+
+import (
+	"fmt"
+	"log/slog"
+	"regexp"
+	"strings"
+)
+
+// Redacted replaces values that must never be logged
+const Redacted = "[REDACTED]"
+
+// secretKeys are attribute keys whose values are dropped whole. Keys match when they
+// contain one of these, so access_token and square_token are covered by "token".
+var secretKeys = []string{"password", "token", "secret", "authorization", "source_id", "nonce"}
+
+// secretNames are short secret keys that only match exactly, so error_code is kept
+var secretNames = []string{"pin", "code", "recovery_code", "card_number"}
+
+// emailKeys are attribute keys holding an email address, which is masked rather than
+// dropped so support can still tell accounts apart
+var emailKeys = []string{"email"}
+
+var (
+	emailPattern = regexp.MustCompile(`[A-Za-z0-9._%+\-]+@([A-Za-z0-9\-]+\.)+[A-Za-z]{2,}`)
+	// Bearer headers, JWTs, Square access tokens and card nonces or card on file IDs
+	tokenPatterns = []*regexp.Regexp{
+		regexp.MustCompile(`(?i)bearer\s+[A-Za-z0-9\-._~+/]+=*`),
+		regexp.MustCompile(`eyJ[A-Za-z0-9_\-]+\.[A-Za-z0-9_\-]+\.[A-Za-z0-9_\-]*`),
+		regexp.MustCompile(`\b(EAAA|sq0atp-|sq0csp-)[A-Za-z0-9_\-]{10,}`),
+		regexp.MustCompile(`\b(cnon|ccof):[A-Za-z0-9_\-]+`),
+	}
+)
+
+// Redact is a slog ReplaceAttr function. It drops the values of secret attributes such as
+// passwords, tokens and card source IDs, masks email addresses, and scrubs the same from
+// messages, errors and other strings, which often embed them.
+func Redact(groups []string, attr slog.Attr) slog.Attr {
+	key := strings.ToLower(attr.Key)
+	if attr.Key != slog.MessageKey && (containsAny(key, secretKeys) || equalsAny(key, secretNames)) {
+		return slog.String(attr.Key, Redacted)
+	}
+
+	switch attr.Value.Kind() {
+	case slog.KindString:
+		if containsAny(key, emailKeys) {
+			return slog.String(attr.Key, MaskEmail(attr.Value.String()))
+		}
+		return slog.String(attr.Key, RedactString(attr.Value.String()))
+	case slog.KindAny:
+		switch value := attr.Value.Any().(type) {
+		case error:
+			return slog.String(attr.Key, RedactString(value.Error()))
+		case fmt.Stringer:
+			return slog.String(attr.Key, RedactString(value.String()))
+		}
+	}
+	return attr
+}
+
+// RedactString removes tokens and card source IDs from free text and masks the email
+// addresses in it
+func RedactString(text string) string {
+	for _, pattern := range tokenPatterns {
+		text = pattern.ReplaceAllString(text, Redacted)
+	}
+	return emailPattern.ReplaceAllStringFunc(text, MaskEmail)
+}
+
+// MaskEmail keeps the first letter and the domain of an address: j***@example.com
+func MaskEmail(email string) string {
+	local, domain, found := strings.Cut(email, "@")
+	if !found || local == "" {
+		return Redacted
+	}
+	return local[:1] + "***@" + domain
+}
+
+func containsAny(key string, parts []string) bool {
+	for _, part := range parts {
+		if strings.Contains(key, part) {
+			return true
+		}
+	}
+	return false
+}
+
+func equalsAny(key string, names []string) bool {
+	for _, name := range names {
+		if key == name {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,44 @@
+package mailer
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"regexp"
+	"time"
+)
+
+// unsafeFileChars matches characters not allowed in the generated file names
+var unsafeFileChars = regexp.MustCompile(`[^a-zA-Z0-9@._-]`)
+
+// FileMailer writes each message to an .eml file in Dir instead of sending it
+type FileMailer struct {
+	Dir  string
+	From string
+}
+
+// Send writes the message to <Dir>/<timestamp>-<recipient>.eml
+func (m *FileMailer) Send(message Message) error {
+	if err := os.MkdirAll(m.Dir, 0o755); err != nil {
+		return err
+	}
+
+	name := fmt.Sprintf("%d-%s.eml", time.Now().UnixNano(), unsafeFileChars.ReplaceAllString(message.To, "_"))
+	path := filepath.Join(m.Dir, name)
+	if err := os.WriteFile(path, format(m.From, message), 0o600); err != nil {
+		return err
+	}
+
+	slog.Info("Email written", "to", headerValue(message.To), "path", path)
+	return nil
+}
+
+// LogMailer writes each message to the application log instead of sending it
+type LogMailer struct{}
+
+// Send logs the message
+func (m *LogMailer) Send(message Message) error {
+	slog.Info("Email", "to", headerValue(message.To), "subject", headerValue(message.Subject), "body", message.Body)
+	return nil
+}
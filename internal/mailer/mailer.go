@@ -0,0 +1,21 @@
+package mailer
+
+import "strings"
+
+// Message is a plain text email to a single recipient
+type Message struct {
+	To      string
+	Subject string
+	Body    string
+}
+
+// Mailer sends email. SMTPMailer delivers it, FileMailer and LogMailer keep it local for
+// development and tests. The mail.driver setting picks one, see config.MailConfig.
+type Mailer interface {
+	Send(message Message) error
+}
+
+// headerValue strips line breaks so user supplied values cannot inject extra headers
+func headerValue(value string) string {
+	return strings.NewReplacer("\r", "", "\n", "").Replace(value)
+}
@@ -0,0 +1,47 @@
+package mappers
+
+import (
+	"time"
+
+	"square-pos-integration/internal/models"
+	"square-pos-integration/internal/reponses"
+)
+
+// ToDeviceResponse maps an enrolled device to the device response
+func ToDeviceResponse(device models.Device) reponses.DeviceResponse {
+	return reponses.DeviceResponse{
+		ID:          device.ID,
+		Name:        device.Name,
+		Revoked:     device.RevokedAt != nil,
+		LastSeenAt:  device.LastSeenAt,
+		LockedUntil: device.LockedUntil,
+		CreatedAt:   device.CreatedAt,
+	}
+}
+
+// ToDeviceListResponse maps enrolled devices to the device list response
+func ToDeviceListResponse(devices []models.Device) reponses.DeviceListResponse {
+	responses := make([]reponses.DeviceResponse, 0, len(devices))
+	for _, device := range devices {
+		responses = append(responses, ToDeviceResponse(device))
+	}
+	return reponses.DeviceListResponse{Devices: responses}
+}
+
+// ToDeviceEnrollmentResponse maps a newly enrolled device and its credential to the enrollment response
+func ToDeviceEnrollmentResponse(device models.Device, credential string) reponses.DeviceEnrollmentResponse {
+	return reponses.DeviceEnrollmentResponse{
+		Device:     ToDeviceResponse(device),
+		Credential: credential,
+	}
+}
+
+// ToDeviceLoginResponse maps a PIN login on a device to the device login response
+func ToDeviceLoginResponse(token string, deviceID uint, user models.User, expiresAt time.Time) reponses.DeviceLoginResponse {
+	return reponses.DeviceLoginResponse{
+		Token:     token,
+		DeviceID:  deviceID,
+		User:      ToUserResponse(user),
+		ExpiresAt: expiresAt,
+	}
+}
@@ -0,0 +1,36 @@
+package mappers
+
+import (
+	"square-pos-integration/internal/models"
+	"square-pos-integration/internal/reponses"
+)
+
+// ToLocationResponse maps a location to the location response
+func ToLocationResponse(location models.Location) reponses.LocationResponse {
+	return reponses.LocationResponse{
+		ID:               location.ID,
+		SquareLocationID: location.SquareLocationID,
+		Name:             location.Name,
+		Address: reponses.Address{
+			AddressLine1: location.AddressLine1,
+			AddressLine2: location.AddressLine2,
+			Locality:     location.Locality,
+			Region:       location.Region,
+			PostalCode:   location.PostalCode,
+			Country:      location.Country,
+		},
+		Timezone: location.Timezone,
+		Currency: location.Currency,
+		Status:   location.Status,
+		SyncedAt: location.SyncedAt,
+	}
+}
+
+// ToLocationListResponse maps locations to the location list response
+func ToLocationListResponse(locations []models.Location) reponses.LocationListResponse {
+	responses := make([]reponses.LocationResponse, 0, len(locations))
+	for _, location := range locations {
+		responses = append(responses, ToLocationResponse(location))
+	}
+	return reponses.LocationListResponse{Locations: responses}
+}
@@ -0,0 +1,156 @@
+package mappers
+
+import (
+	"strconv"
+
+	square "github.com/square/square-go-sdk/v2"
+
+	"square-pos-integration/internal/models"
+	"square-pos-integration/internal/reponses"
+	"square-pos-integration/internal/utils"
+)
+
+// ToOrderResponse maps a stored order and its preloaded items to the order response
+func ToOrderResponse(order models.Order) reponses.OrderResponse {
+	items := make([]reponses.ItemResponse, 0, len(order.Items))
+	for _, item := range order.Items {
+		items = append(items, ToItemResponse(item))
+	}
+
+	response := orderHeader(order)
+	response.Items = items
+	return response
+}
+
+// ToOrderResponseFromSquare maps a stored order to the order response, taking the items from
+// the Square order they were priced in
+func ToOrderResponseFromSquare(order models.Order, squareOrder *square.Order) reponses.OrderResponse {
+	response := orderHeader(order)
+	response.Items = ToItemResponsesFromSquare(squareOrder)
+	return response
+}
+
+// ToOrderListResponse maps stored orders to the order list response
+func ToOrderListResponse(orders []models.Order) reponses.OrderListResponse {
+	responses := make([]reponses.OrderResponse, 0, len(orders))
+	for _, order := range orders {
+		responses = append(responses, ToOrderResponse(order))
+	}
+	return reponses.OrderListResponse{Orders: responses}
+}
+
+// orderHeader maps the fields shared by every order response
+func orderHeader(order models.Order) reponses.OrderResponse {
+	var id string
+	if order.Model != nil {
+		id = strconv.FormatUint(uint64(order.ID), 10)
+	}
+
+	return reponses.OrderResponse{
+		ID:       id,
+		OpenedAt: order.OpenedAt,
+		IsClosed: order.IsClosed || order.Status == "paid",
+		Table:    strconv.Itoa(order.TableNumber),
+		Totals:   ToOrderTotalsResponse(order.Totals),
+	}
+}
+
+// ToItemResponse maps a stored order item to the item response
+func ToItemResponse(item models.OrderItem) reponses.ItemResponse {
+	discounts := make([]reponses.DiscountResponse, 0, len(item.Discounts))
+	for _, discount := range item.Discounts {
+		discounts = append(discounts, reponses.DiscountResponse{
+			Name:         discount.Name,
+			IsPercentage: discount.IsPercentage,
+			Value:        discount.Value / 100,
+			Amount:       discount.Amount / 100,
+		})
+	}
+
+	modifiers := make([]reponses.ModifierResponse, 0, len(item.Modifiers))
+	for _, modifier := range item.Modifiers {
+		modifiers = append(modifiers, reponses.ModifierResponse{
+			Name:      modifier.Name,
+			UnitPrice: modifier.UnitPrice / 100,
+			Quantity:  modifier.Quantity,
+			Amount:    modifier.Amount / 100,
+		})
+	}
+
+	return reponses.ItemResponse{
+		Name:      item.Name,
+		Comment:   item.Comment,
+		UnitPrice: int(item.UnitPrice) / 100,
+		Quantity:  item.Quantity,
+		Discounts: discounts,
+		Modifiers: modifiers,
+		Amount:    item.Amount / 100,
+	}
+}
+
+// ToOrderTotalsResponse maps stored order totals to the totals response
+func ToOrderTotalsResponse(totals models.OrderTotals) reponses.OrderTotals {
+	return reponses.OrderTotals{
+		Discounts:     totals.Discounts / 100,
+		Due:           totals.Due / 100,
+		Tax:           totals.Tax / 100,
+		ServiceCharge: totals.ServiceCharge / 100,
+		Paid:          totals.Paid / 100,
+		Tips:          totals.Tips / 100,
+		Total:         totals.Total / 100,
+	}
+}
+
+// ToItemResponsesFromSquare maps the line items of a Square order to item responses
+func ToItemResponsesFromSquare(squareOrder *square.Order) []reponses.ItemResponse {
+	items := make([]reponses.ItemResponse, 0, len(squareOrder.LineItems))
+	for _, lineItem := range squareOrder.LineItems {
+		items = append(items, reponses.ItemResponse{
+			Name:      utils.SafeString(lineItem.Name),
+			Comment:   utils.SafeString(lineItem.Note),
+			UnitPrice: int(utils.SafeMoney(lineItem.BasePriceMoney)) / 100,
+			Quantity:  utils.ParseQuantity(lineItem.Quantity),
+			Discounts: toDiscountResponsesFromSquare(lineItem.AppliedDiscounts, squareOrder.Discounts),
+			Modifiers: toModifierResponsesFromSquare(lineItem.Modifiers),
+			Amount:    int(utils.SafeMoney(lineItem.TotalMoney)) / 100,
+		})
+	}
+	return items
+}
+
+// toDiscountResponsesFromSquare maps the discounts applied to a Square line item
+func toDiscountResponsesFromSquare(appliedDiscounts []*square.OrderLineItemAppliedDiscount, orderDiscounts []*square.OrderLineItemDiscount) []reponses.DiscountResponse {
+	discounts := make([]reponses.DiscountResponse, 0, len(appliedDiscounts))
+	for _, applied := range appliedDiscounts {
+		discount := utils.FindDiscountByUID(orderDiscounts, applied.DiscountUID)
+		if discount == nil {
+			continue
+		}
+
+		amount := utils.SafeMoney(discount.AmountMoney)
+		if applied.AppliedMoney != nil {
+			amount = utils.SafeMoney(applied.AppliedMoney)
+		}
+		discounts = append(discounts, reponses.DiscountResponse{
+			Name:         utils.SafeString(discount.Name),
+			IsPercentage: discount.Type != nil && *discount.Type == "PERCENTAGE",
+			Value:        int(utils.SafeMoney(discount.AmountMoney)) / 100,
+			Amount:       int(amount) / 100,
+		})
+	}
+	return discounts
+}
+
+// toModifierResponsesFromSquare maps the modifiers of a Square line item
+func toModifierResponsesFromSquare(modifiers []*square.OrderLineItemModifier) []reponses.ModifierResponse {
+	mods := make([]reponses.ModifierResponse, 0, len(modifiers))
+	for _, modifier := range modifiers {
+		mods = append(mods, reponses.ModifierResponse{
+			Name:      utils.SafeString(modifier.Name),
+			UnitPrice: int(utils.SafeMoney(modifier.BasePriceMoney)) / 100,
+			Quantity:  1, // Default to 1 if not specified
+			Amount:    int(utils.SafeMoney(modifier.TotalPriceMoney)) / 100,
+		})
+	}
+	return mods
+}
@@ -0,0 +1,43 @@
+package mappers
+
+import (
+	"strconv"
+
+	square "github.com/square/square-go-sdk/v2"
+
+	"square-pos-integration/internal/models"
+	"square-pos-integration/internal/reponses"
+	"square-pos-integration/internal/utils"
+)
+
+// ToPaymentResponse maps a stored payment to the payment response
+func ToPaymentResponse(payment models.Payment, message string) reponses.PaymentResponse {
+	var id string
+	if payment.Model != nil {
+		id = strconv.FormatUint(uint64(payment.ID), 10)
+	}
+
+	return reponses.PaymentResponse{
+		ID:             id,
+		PaymentID:      payment.SquarePaymentID,
+		BillAmount:     float64(payment.BillAmount) / 100,
+		TipAmount:      float64(payment.TipAmount) / 100,
+		TotalAmount:    float64(payment.TotalAmount) / 100,
+		RefundedAmount: float64(payment.RefundedAmount) / 100,
+		Status:         payment.Status,
+		ProcessedAt:    payment.ProcessedAt,
+		Message:        message,
+	}
+}
+
+// ToRefundResponse maps a Square refund and the updated payment and order to the refund response
+func ToRefundResponse(refund *square.PaymentRefund, payment models.Payment, order models.Order, message string) reponses.RefundResponse {
+	return reponses.RefundResponse{
+		RefundID: refund.ID,
+		Status:   utils.SafeString(refund.Status),
+		Amount:   float64(utils.SafeMoney(refund.AmountMoney)) / 100,
+		Payment:  ToPaymentResponse(payment, ""),
+		Totals:   ToOrderTotalsResponse(order.Totals),
+		Message:  message,
+	}
+}
@@ -0,0 +1,51 @@
+package mappers
+
+import (
+	"square-pos-integration/internal/authz"
+	"square-pos-integration/internal/models"
+	"square-pos-integration/internal/reponses"
+)
+
+// ToRoleResponse maps a custom role to the role response
+func ToRoleResponse(role models.Role) reponses.RoleResponse {
+	permissions := make([]authz.Permission, 0, len(role.Permissions))
+	for _, granted := range role.Permissions {
+		permissions = append(permissions, authz.Permission(granted.Permission))
+	}
+
+	return reponses.RoleResponse{
+		ID:          role.ID,
+		Name:        role.Name,
+		Description: role.Description,
+		Permissions: permissionNames(authz.NewSet(permissions...).List()),
+	}
+}
+
+// ToRoleListResponse maps the built-in roles followed by the restaurant's custom roles to the role list response
+func ToRoleListResponse(customRoles []models.Role) reponses.RoleListResponse {
+	roles := make([]reponses.RoleResponse, 0, len(customRoles)+3)
+	for _, name := range authz.BuiltinRoleNames() {
+		permissions, _ := authz.BuiltinRole(name)
+		roles = append(roles, reponses.RoleResponse{
+			Name:        name,
+			BuiltIn:     true,
+			Permissions: permissionNames(authz.NewSet(permissions...).List()),
+		})
+	}
+	for _, role := range customRoles {
+		roles = append(roles, ToRoleResponse(role))
+	}
+
+	return reponses.RoleListResponse{
+		Roles:       roles,
+		Permissions: permissionNames(authz.All),
+	}
+}
+
+func permissionNames(permissions []authz.Permission) []string {
+	names := make([]string, 0, len(permissions))
+	for _, permission := range permissions {
+		names = append(names, string(permission))
+	}
+	return names
+}
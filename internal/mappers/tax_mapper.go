@@ -0,0 +1,53 @@
+package mappers
+
+import (
+	"square-pos-integration/internal/models"
+	"square-pos-integration/internal/reponses"
+)
+
+// ToTaxRuleResponse maps a tax rule to the tax rule response
+func ToTaxRuleResponse(rule models.TaxRule) reponses.TaxRuleResponse {
+	return reponses.TaxRuleResponse{
+		ID:                    rule.ID,
+		Name:                  rule.Name,
+		LocationID:            rule.LocationID,
+		Percentage:            rule.Percentage,
+		InclusionType:         rule.InclusionType,
+		Enabled:               rule.Enabled,
+		SquareCatalogObjectID: rule.SquareCatalogObjectID,
+	}
+}
+
+// ToTaxRuleListResponse maps tax rules to the tax rule list response
+func ToTaxRuleListResponse(rules []models.TaxRule) reponses.TaxRuleListResponse {
+	responses := make([]reponses.TaxRuleResponse, 0, len(rules))
+	for _, rule := range rules {
+		responses = append(responses, ToTaxRuleResponse(rule))
+	}
+	return reponses.TaxRuleListResponse{TaxRules: responses}
+}
+
+// ToServiceChargeResponse maps a service charge rule to the service charge response
+func ToServiceChargeResponse(rule models.ServiceChargeRule) reponses.ServiceChargeResponse {
+	return reponses.ServiceChargeResponse{
+		ID:               rule.ID,
+		Name:             rule.Name,
+		LocationID:       rule.LocationID,
+		Percentage:       rule.Percentage,
+		Amount:           rule.Amount,
+		CalculationPhase: rule.CalculationPhase,
+		Taxable:          rule.Taxable,
+		Enabled:          rule.Enabled,
+		MinGuestCount:    rule.MinGuestCount,
+		OrderType:        rule.OrderType,
+	}
+}
+
+// ToServiceChargeListResponse maps service charge rules to the service charge list response
+func ToServiceChargeListResponse(rules []models.ServiceChargeRule) reponses.ServiceChargeListResponse {
+	responses := make([]reponses.ServiceChargeResponse, 0, len(rules))
+	for _, rule := range rules {
+		responses = append(responses, ToServiceChargeResponse(rule))
+	}
+	return reponses.ServiceChargeListResponse{ServiceCharges: responses}
+}
@@ -0,0 +1,142 @@
+package mappers
+
+import (
+	"time"
+
+	"square-pos-integration/internal/models"
+	"square-pos-integration/internal/reponses"
+)
+
+// ToUserResponse maps a user to the user response
+func ToUserResponse(user models.User) reponses.UserResponse {
+	return reponses.UserResponse{
+		ID:           user.ID,
+		Username:     user.Username,
+		Email:        user.Email,
+		Role:         user.Role,
+		RestaurantID: user.RestaurantID,
+		IsActive:     user.IsActive,
+		HasPin:       user.PinHash != nil,
+		TwoFactor:    user.TOTPEnabledAt != nil,
+	}
+}
+
+// ToUserListResponse maps users to the user list response
+func ToUserListResponse(users []models.User) reponses.UserListResponse {
+	responses := make([]reponses.UserResponse, 0, len(users))
+	for _, user := range users {
+		responses = append(responses, ToUserResponse(user))
+	}
+	return reponses.UserListResponse{Users: responses}
+}
+
+// ToRestaurantResponse maps a restaurant to the restaurant response
+func ToRestaurantResponse(restaurant models.Restaurant) reponses.RestaurantResponse {
+	return reponses.RestaurantResponse{
+		ID:         restaurant.ID,
+		Name:       restaurant.Name,
+		LocationID: restaurant.LocationID,
+		IsActive:   true,
+	}
+}
+
+// ToLoginResponse maps an issued token pair, the user with its preloaded restaurant and the
+// user's memberships to the login response
+func ToLoginResponse(token, refreshToken string, user models.User, memberships []models.Membership, expiresAt time.Time) reponses.LoginResponse {
+	return reponses.LoginResponse{
+		Token:          token,
+		RefreshToken:   refreshToken,
+		RestaurantName: user.Restaurant.Name,
+		User:           ToUserResponse(user),
+		Memberships:    ToMembershipResponses(memberships),
+		ExpiresAt:      expiresAt,
+	}
+}
+
+// ToMembershipResponses maps memberships with their preloaded restaurants
+func ToMembershipResponses(memberships []models.Membership) []reponses.MembershipResponse {
+	responses := make([]reponses.MembershipResponse, 0, len(memberships))
+	for _, membership := range memberships {
+		responses = append(responses, reponses.MembershipResponse{
+			RestaurantID:   membership.RestaurantID,
+			RestaurantName: membership.Restaurant.Name,
+			Role:           membership.Role,
+			LocationIDs:    locationIDs(membership),
+		})
+	}
+	return responses
+}
+
+// ToMemberResponse maps a membership with its preloaded user
+func ToMemberResponse(membership models.Membership) reponses.MemberResponse {
+	return reponses.MemberResponse{
+		UserID:      membership.UserID,
+		Username:    membership.User.Username,
+		Email:       membership.User.Email,
+		Role:        membership.Role,
+		LocationIDs: locationIDs(membership),
+		IsActive:    membership.IsActive && membership.User.IsActive,
+		Home:        membership.User.RestaurantID == membership.RestaurantID,
+	}
+}
+
+// ToMemberListResponse maps the memberships of a restaurant
+func ToMemberListResponse(memberships []models.Membership) reponses.MemberListResponse {
+	responses := make([]reponses.MemberResponse, 0, len(memberships))
+	for _, membership := range memberships {
+		responses = append(responses, ToMemberResponse(membership))
+	}
+	return reponses.MemberListResponse{Members: responses}
+}
+
+// locationIDs returns the membership's allowed locations, never nil so it encodes as []
+func locationIDs(membership models.Membership) []string {
+	if locations := membership.AllowedLocations(); locations != nil {
+		return locations
+	}
+	return []string{}
+}
+
+// ToTwoFactorChallengeResponse maps a login challenge to the response sent instead of tokens
+func ToTwoFactorChallengeResponse(challengeToken string, expiresAt time.Time, enrollmentRequired bool) reponses.TwoFactorChallengeResponse {
+	return reponses.TwoFactorChallengeResponse{
+		TwoFactorRequired:  true,
+		EnrollmentRequired: enrollmentRequired,
+		ChallengeToken:     challengeToken,
+		ExpiresAt:          expiresAt,
+	}
+}
+
+// ToTwoFactorLoginResponse maps a completed two-factor login and any recovery codes issued during it
+func ToTwoFactorLoginResponse(token, refreshToken string, user models.User, memberships []models.Membership, expiresAt time.Time, recoveryCodes []string) reponses.TwoFactorLoginResponse {
+	return reponses.TwoFactorLoginResponse{
+		LoginResponse: ToLoginResponse(token, refreshToken, user, memberships, expiresAt),
+		RecoveryCodes: recoveryCodes,
+	}
+}
+
+// ToTokenResponse maps a renewed token pair to the refresh response
+func ToTokenResponse(token, refreshToken string, expiresAt time.Time) reponses.TokenResponse {
+	return reponses.TokenResponse{
+		Token:        token,
+		RefreshToken: refreshToken,
+		ExpiresAt:    expiresAt,
+	}
+}
+
+// ToProfileResponse maps a user with its preloaded restaurant to the profile response
+func ToProfileResponse(user models.User) reponses.ProfileResponse {
+	return reponses.ProfileResponse{
+		User:       ToUserResponse(user),
+		Restaurant: ToRestaurantResponse(user.Restaurant),
+	}
+}
+
+// ToRestaurantRegistrationResponse maps a newly registered restaurant and its admin user
+func ToRestaurantRegistrationResponse(restaurant models.Restaurant, admin models.User, message string) reponses.RestaurantRegistrationResponse {
+	return reponses.RestaurantRegistrationResponse{
+		Message:    message,
+		Restaurant: ToRestaurantResponse(restaurant),
+		AdminUser:  ToUserResponse(admin),
+	}
+}
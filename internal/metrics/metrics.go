@@ -0,0 +1,136 @@
+package metrics
+
+import (
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// Registry holds the app's metrics and the Go runtime and process metrics. It is separate
+// from the Prometheus default registry so only what is defined here is exported.
+var Registry = prometheus.NewRegistry()
+
+var (
+	httpRequestDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "http_request_duration_seconds",
+		Help:    "Time taken to handle HTTP requests, by route and status.",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"method", "route", "status"})
+
+	dbQueryDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "db_query_duration_seconds",
+		Help:    "Time taken by database queries, by operation and table.",
+		Buckets: prometheus.ExponentialBuckets(0.0005, 2, 14),
+	}, []string{"operation", "table"})
+	dbQueryErrors = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "db_query_errors_total",
+		Help: "Database queries that failed, by operation and table. Missing records are not counted.",
+	}, []string{"operation", "table"})
+
+	squareRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "square_requests_total",
+		Help: "Calls to the Square API, by endpoint and result category.",
+	}, []string{"endpoint", "category"})
+	squareRequestDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "square_request_duration_seconds",
+		Help:    "Time taken by calls to the Square API, by endpoint.",
+		Buckets: []float64{0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30},
+	}, []string{"endpoint"})
+
+	ordersCreated = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "pos_orders_created_total",
+		Help: "Orders created, by restaurant.",
+	}, []string{"restaurant"})
+	paymentsCompleted = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "pos_payments_completed_total",
+		Help: "Payments completed, by restaurant.",
+	}, []string{"restaurant"})
+	paymentsRefunded = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "pos_payments_refunded_total",
+		Help: "Refunds issued, by restaurant.",
+	}, []string{"restaurant"})
+	tips = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "pos_tips_cents_total",
+		Help: "Tips on completed payments in cents of the restaurant's currency, by restaurant.",
+	}, []string{"restaurant"})
+)
+
+func init() {
+	Registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		httpRequestDuration,
+		dbQueryDuration, dbQueryErrors,
+		squareRequests, squareRequestDuration,
+		ordersCreated, paymentsCompleted, paymentsRefunded, tips,
+	)
+}
+
+// Handler serves the metrics of Registry in the Prometheus text format
+func Handler() http.Handler {
+	return promhttp.HandlerFor(Registry, promhttp.HandlerOpts{Registry: Registry})
+}
+
+// ObserveRequest records a handled HTTP request. route is the route template, such as
+// /api/v1/orders/:id, never the raw path.
+func ObserveRequest(method, route string, status int, duration time.Duration) {
+	httpRequestDuration.WithLabelValues(method, route, strconv.Itoa(status)).Observe(duration.Seconds())
+}
+
+// OrderCreated counts an order created for the restaurant
+func OrderCreated(restaurantID uint) {
+	ordersCreated.WithLabelValues(restaurantLabel(restaurantID)).Inc()
+}
+
+// PaymentCompleted counts a completed payment of the restaurant and adds its tip
+func PaymentCompleted(restaurantID uint, tipCents int) {
+	label := restaurantLabel(restaurantID)
+	paymentsCompleted.WithLabelValues(label).Inc()
+	tips.WithLabelValues(label).Add(float64(tipCents))
+}
+
+// PaymentRefunded counts a refund issued by the restaurant
+func PaymentRefunded(restaurantID uint) {
+	paymentsRefunded.WithLabelValues(restaurantLabel(restaurantID)).Inc()
+}
+
+// OtherRestaurants is the restaurant label of the restaurants past the limit, see
+// LimitRestaurants
+const OtherRestaurants = "other"
+
+// restaurants keeps the restaurants that have their own label
+var restaurants = struct {
+	mu    sync.Mutex
+	limit int
+	seen  map[uint]string
+}{limit: 100, seen: map[uint]string{}}
+
+// LimitRestaurants sets how many restaurants get their own label on the business counters.
+// Restaurants are labelled by ID in the order they are first seen; the ones after the
+// first limit share the label "other", so the number of series stays bounded. 0 labels
+// every restaurant "other".
+func LimitRestaurants(limit int) {
+	restaurants.mu.Lock()
+	defer restaurants.mu.Unlock()
+	restaurants.limit = limit
+}
+
+func restaurantLabel(restaurantID uint) string {
+	restaurants.mu.Lock()
+	defer restaurants.mu.Unlock()
+
+	if label, ok := restaurants.seen[restaurantID]; ok {
+		return label
+	}
+	if len(restaurants.seen) >= restaurants.limit {
+		return OtherRestaurants
+	}
+	label := strconv.FormatUint(uint64(restaurantID), 10)
+	restaurants.seen[restaurantID] = label
+	return label
+}
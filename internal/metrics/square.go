@@ -0,0 +1,62 @@
+package metrics
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// pathWord matches the fixed parts of Square API paths, such as orders or batch-upsert.
+// Other segments are IDs.
+var pathWord = regexp.MustCompile(`^(v[0-9]+|[a-z][a-z_\-]*)$`)
+
+// SquareEndpoint returns the endpoint label of a Square call, its method and path with IDs
+// replaced: POST /v2/payments/{id}/complete
+func SquareEndpoint(method, path string) string {
+	segments := strings.Split(strings.Trim(path, "/"), "/")
+	for i, segment := range segments {
+		if !pathWord.MatchString(segment) {
+			segments[i] = "{id}"
+		}
+	}
+	return method + " /" + strings.Join(segments, "/")
+}
+
+// SquareCategory names the outcome of a Square call after Square's error categories:
+// ok, authentication, invalid_request, payment_method, rate_limit, api_error, timeout or
+// network. resp is nil when err is set.
+func SquareCategory(resp *http.Response, err error) string {
+	if err != nil {
+		var netErr net.Error
+		if errors.Is(err, context.DeadlineExceeded) || (errors.As(err, &netErr) && netErr.Timeout()) {
+			return "timeout"
+		}
+		return "network"
+	}
+	switch status := resp.StatusCode; {
+	case status < 400:
+		return "ok"
+	case status == http.StatusUnauthorized || status == http.StatusForbidden:
+		return "authentication"
+	case status == http.StatusPaymentRequired:
+		return "payment_method"
+	case status == http.StatusTooManyRequests:
+		return "rate_limit"
+	case status >= 500:
+		return "api_error"
+	default:
+		return "invalid_request"
+	}
+}
+
+// ObserveSquareCall records one attempt of a Square call. Retried calls are recorded once
+// per attempt.
+func ObserveSquareCall(req *http.Request, resp *http.Response, err error, duration time.Duration) {
+	endpoint := SquareEndpoint(req.Method, req.URL.Path)
+	squareRequests.WithLabelValues(endpoint, SquareCategory(resp, err)).Inc()
+	squareRequestDuration.WithLabelValues(endpoint).Observe(duration.Seconds())
+}
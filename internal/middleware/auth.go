@@ -1,34 +1,38 @@
 package middleware
 
 import(
-	"net/http"
 	"strings"
 	"github.com/gin-gonic/gin"
+	"square-pos-integration/internal/apperrors"
+	"square-pos-integration/internal/service"
 	"square-pos-integration/internal/utils"
-	"square-pos-integration/internal/models"
 	"gorm.io/gorm"
 )
-func JWTMiddleware(db *gorm.DB) gin.HandlerFunc {
+// JWTMiddleware authenticates requests by their access token, verified with the keys of sessions
+func JWTMiddleware(sessions *service.SessionService) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		authHeader := c.GetHeader("Authorization")
 		if authHeader == "" {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "Authorization header required"})
-			c.Abort()
+			abortWithError(c, apperrors.ErrUnauthorized)
 			return
 		}
 
 		// Extract token from "Bearer <token>"
 		tokenString := strings.TrimPrefix(authHeader, "Bearer ")
 		if tokenString == authHeader {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid authorization header format"})
-			c.Abort()
+			abortWithError(c, apperrors.ErrInvalidHeader)
 			return
 		}
 
-		claims, err := utils.ValidateJWT(tokenString)
+		claims, err := utils.ValidateJWT(sessions.Keys, tokenString)
 		if err != nil {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid token"})
-			c.Abort()
+			abortWithError(c, apperrors.ErrInvalidToken.Wrap(err))
+			return
+		}
+
+		// Reject revoked tokens and tokens of deactivated users
+		if _, err := sessions.Authenticate(claims); err != nil {
+			abortWithError(c, err)
 			return
 		}
 
@@ -37,32 +41,46 @@ func JWTMiddleware(db *gorm.DB) gin.HandlerFunc {
 		c.Set("restaurant_id", claims.RestaurantID)
 		c.Set("user_email", claims.Email)
 		c.Set("user_role", claims.Role)
+		c.Set("token_id", claims.ID)
+		if claims.ExpiresAt != nil {
+			c.Set("token_expires_at", claims.ExpiresAt.Time)
+		}
+		withLogFields(c, "user_id", claims.UserID, "restaurant_id", claims.RestaurantID)
 
 		c.Next()
 	}
 }
 
-// MultiTenantMiddleware validates restaurant context and ensures data isolation
+// MultiTenantMiddleware checks that the user is still an active member of the restaurant
+// their token was issued for, and takes their role from the membership rather than the
+// token so role changes and removals apply straight away
 func MultiTenantMiddleware(db *gorm.DB) gin.HandlerFunc {
+	memberships := service.NewMembershipService(db)
+
 	return func(c *gin.Context) {
 		// Get restaurant ID from JWT claims (set by JWTMiddleware)
 		restaurantID, exists := c.Get("restaurant_id")
 		if !exists {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "Restaurant context not found"})
-			c.Abort()
+			abortWithError(c, apperrors.ErrRestaurantContextMissing)
 			return
 		}
+		userID, _ := c.Get("user_id")
 
-		// Verify restaurant exists and is active
-		var restaurant models.Restaurant
-		if err := db.First(&restaurant, restaurantID).Error; err != nil {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid restaurant"})
-			c.Abort()
+		membership, err := memberships.FindMembership(userID.(uint), restaurantID.(uint))
+		if err != nil {
+			abortWithError(c, err)
+			return
+		}
+		// The membership outlives a deleted restaurant
+		if membership.Restaurant.ID == 0 {
+			abortWithError(c, apperrors.ErrInvalidRestaurant)
 			return
 		}
 
 		// Store restaurant info in context
-		c.Set("restaurant", restaurant)
+		c.Set("restaurant", membership.Restaurant)
+		c.Set("membership", membership)
+		c.Set("user_role", membership.Role)
 		c.Next()
 	}
 }
@@ -72,8 +90,7 @@ func RoleMiddleware(requiredRoles ...string) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		userRole, exists := c.Get("user_role")
 		if !exists {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "User role not found"})
-			c.Abort()
+			abortWithError(c, apperrors.ErrRoleNotFound)
 			return
 		}
 
@@ -85,7 +102,6 @@ func RoleMiddleware(requiredRoles ...string) gin.HandlerFunc {
 			}
 		}
 
-		c.JSON(http.StatusForbidden, gin.H{"error": "Insufficient permissions"})
-		c.Abort()
+		abortWithError(c, apperrors.ErrForbidden)
 	}
 }
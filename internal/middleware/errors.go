@@ -0,0 +1,47 @@
+package middleware
+
+import (
+	"log/slog"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+
+	"square-pos-integration/internal/apperrors"
+	"square-pos-integration/internal/logging"
+)
+
+// ErrorHandler renders errors attached with c.Error as a reponses.ErrorResponse. Internal
+// causes are logged here and never sent to the client. Errors carrying RetryDetails also
+// set the Retry-After header.
+func ErrorHandler() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Next()
+
+		if len(c.Errors) == 0 {
+			return
+		}
+
+		appErr := apperrors.From(c.Errors.Last().Err)
+		if appErr.Err != nil {
+			level := slog.LevelWarn
+			if appErr.Status >= 500 {
+				level = slog.LevelError
+			}
+			ctx := c.Request.Context()
+			logging.FromContext(ctx).Log(ctx, level, "Request failed", "error_code", appErr.Code, "error", appErr.Err)
+		}
+
+		if !c.Writer.Written() {
+			if retry, ok := appErr.Details.(apperrors.RetryDetails); ok {
+				c.Header("Retry-After", strconv.Itoa(retry.RetryAfterSeconds))
+			}
+			c.JSON(appErr.Status, appErr.Response())
+		}
+	}
+}
+
+// abortWithError stops the handler chain and leaves the error for ErrorHandler to render
+func abortWithError(c *gin.Context, err error) {
+	_ = c.Error(err)
+	c.Abort()
+}
@@ -0,0 +1,68 @@
+package middleware
+
+import (
+	"log/slog"
+	"regexp"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+
+	"square-pos-integration/internal/logging"
+)
+
+// RequestIDHeader carries the request ID in requests and responses, and in calls to Square
+const RequestIDHeader = "X-Request-ID"
+
+// requestIDPattern limits the incoming IDs that are kept, so clients cannot inject text into logs
+var requestIDPattern = regexp.MustCompile(`^[A-Za-z0-9\-_.]{1,128}$`)
+
+// RequestID gives every request an ID, taken from the X-Request-ID header when it is a
+// plain token and generated otherwise. The ID is echoed in the response and carried by the
+// request's context together with a logger that adds it, the method and the route to every
+// record, see logging.FromContext.
+func RequestID() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id := c.GetHeader(RequestIDHeader)
+		if !requestIDPattern.MatchString(id) {
+			id = uuid.NewString()
+		}
+		c.Header(RequestIDHeader, id)
+
+		ctx := logging.WithRequestID(c.Request.Context(), id)
+		ctx = logging.With(ctx, "request_id", id, "method", c.Request.Method, "route", c.FullPath())
+		c.Request = c.Request.WithContext(ctx)
+		c.Next()
+	}
+}
+
+// RequestLogger logs every request once it is handled, with its status and duration.
+// Health checks are logged at debug level so probes do not flood the log, and server
+// errors at error level.
+func RequestLogger() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+		c.Next()
+
+		level := slog.LevelInfo
+		switch {
+		case c.FullPath() == "/healthz" || c.FullPath() == "/readyz":
+			level = slog.LevelDebug
+		case c.Writer.Status() >= 500:
+			level = slog.LevelError
+		}
+
+		ctx := c.Request.Context()
+		logging.FromContext(ctx).Log(ctx, level, "Request handled",
+			"status", c.Writer.Status(),
+			"duration", time.Since(start),
+			"client_ip", c.ClientIP(),
+			"bytes", c.Writer.Size(),
+		)
+	}
+}
+
+// withLogFields adds fields to the logger of the request, see logging.With
+func withLogFields(c *gin.Context, args ...any) {
+	c.Request = c.Request.WithContext(logging.With(c.Request.Context(), args...))
+}
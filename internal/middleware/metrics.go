@@ -0,0 +1,40 @@
+package middleware
+
+import (
+	"crypto/subtle"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"square-pos-integration/internal/apperrors"
+	"square-pos-integration/internal/metrics"
+)
+
+// Metrics records every request in http_request_duration_seconds by its route template.
+// Requests matching no route share the route "unmatched", so scanners cannot create series.
+func Metrics() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+		c.Next()
+
+		route := c.FullPath()
+		if route == "" {
+			route = "unmatched"
+		}
+		metrics.ObserveRequest(c.Request.Method, route, c.Writer.Status(), time.Since(start))
+	}
+}
+
+// StaticToken only lets through requests with the token as their bearer token, for
+// endpoints read by machines such as the metrics scraper
+func StaticToken(token string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		given, found := strings.CutPrefix(c.GetHeader("Authorization"), "Bearer ")
+		if !found || subtle.ConstantTimeCompare([]byte(given), []byte(token)) != 1 {
+			abortWithError(c, apperrors.ErrUnauthorized)
+			return
+		}
+		c.Next()
+	}
+}
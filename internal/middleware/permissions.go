@@ -0,0 +1,42 @@
+package middleware
+
+import (
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+
+	"square-pos-integration/internal/apperrors"
+	"square-pos-integration/internal/authz"
+)
+
+// LoadPermissions resolves the permissions of the authenticated user's role, built-in or
+// custom, and stores them on the context for RequirePermission and handler checks
+func LoadPermissions(db *gorm.DB) gin.HandlerFunc {
+	resolver := authz.NewResolver(db)
+
+	return func(c *gin.Context) {
+		restaurantID, _ := c.Get("restaurant_id")
+		role := c.GetString("user_role")
+
+		permissions, err := resolver.Permissions(restaurantID.(uint), role)
+		if err != nil {
+			abortWithError(c, apperrors.ErrInternal.Wrap(err))
+			return
+		}
+
+		authz.SetPermissions(c, permissions)
+		c.Next()
+	}
+}
+
+// RequirePermission aborts the request unless the user has every listed permission
+func RequirePermission(permissions ...authz.Permission) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		for _, permission := range permissions {
+			if err := authz.Require(c, permission); err != nil {
+				abortWithError(c, err)
+				return
+			}
+		}
+		c.Next()
+	}
+}
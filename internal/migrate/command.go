@@ -0,0 +1,110 @@
+package migrate
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"io/fs"
+	"strconv"
+	"text/tabwriter"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const usage = `usage: migrate <command>
+
+  up              apply all pending migrations
+  down [n]        revert the last n migrations (default 1)
+  status          list migrations and when they were applied
+  create <name>   add empty up and down files for a new migration
+`
+
+// Run runs the migrate subcommand with its arguments. open connects to the database and
+// source returns the migrations for its dialect, create writes new files to -dir.
+func Run(args []string, source func(dialect string) (fs.FS, error), open func() (*gorm.DB, error), out io.Writer) error {
+	flags := flag.NewFlagSet("migrate", flag.ContinueOnError)
+	flags.SetOutput(out)
+	dir := flags.String("dir", "migrations", "directory new migrations are created in")
+	flags.Usage = func() {
+		fmt.Fprint(out, usage)
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if flags.NArg() == 0 {
+		flags.Usage()
+		return fmt.Errorf("missing command")
+	}
+
+	command, rest := flags.Arg(0), flags.Args()[1:]
+	if command == "create" {
+		if len(rest) != 1 {
+			return fmt.Errorf("usage: migrate create <name>")
+		}
+		paths, err := Create(*dir, rest[0])
+		for _, path := range paths {
+			fmt.Fprintf(out, "Created %s\n", path)
+		}
+		return err
+	}
+
+	db, err := open()
+	if err != nil {
+		return err
+	}
+	fsys, err := source(db.Dialector.Name())
+	if err != nil {
+		return err
+	}
+	migrator, err := New(db, fsys)
+	if err != nil {
+		return err
+	}
+
+	switch command {
+	case "up":
+		applied, err := migrator.Up()
+		for _, migration := range applied {
+			fmt.Fprintf(out, "Applied %s\n", migration)
+		}
+		if err == nil && len(applied) == 0 {
+			fmt.Fprintln(out, "No pending migrations")
+		}
+		return err
+	case "down":
+		steps := 1
+		if len(rest) > 0 {
+			if steps, err = strconv.Atoi(rest[0]); err != nil || steps < 1 {
+				return fmt.Errorf("down takes a positive number of migrations, got %q", rest[0])
+			}
+		}
+		reverted, err := migrator.Down(steps)
+		for _, migration := range reverted {
+			fmt.Fprintf(out, "Reverted %s\n", migration)
+		}
+		return err
+	case "status":
+		statuses, err := migrator.Status()
+		if err != nil {
+			return err
+		}
+		w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "VERSION\tNAME\tAPPLIED")
+		for _, status := range statuses {
+			applied := "pending"
+			if status.AppliedAt != nil {
+				applied = status.AppliedAt.UTC().Format(time.RFC3339)
+			}
+			if status.Missing {
+				applied += " (no files)"
+			}
+			fmt.Fprintf(w, "%04d\t%s\t%s\n", status.Version, status.Name, applied)
+		}
+		return w.Flush()
+	default:
+		flags.Usage()
+		return fmt.Errorf("unknown command %q", command)
+	}
+}
@@ -0,0 +1,119 @@
+package migrate
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// fileName matches migration files such as 0004_add_order_notes.up.sql
+var fileName = regexp.MustCompile(`^(\d+)_([a-z0-9_]+)\.(up|down)\.sql$`)
+
+// Load reads the migration files at the root of fsys, sorted by version. Every version
+// needs an up file. Down files are optional, a missing one cannot be reverted.
+func Load(fsys fs.FS) ([]Migration, error) {
+	entries, err := fs.ReadDir(fsys, ".")
+	if err != nil {
+		return nil, err
+	}
+
+	byVersion := map[int64]*Migration{}
+	hasUp := map[int64]bool{}
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), ".sql") {
+			continue
+		}
+		match := fileName.FindStringSubmatch(entry.Name())
+		if match == nil {
+			return nil, fmt.Errorf("migration file %s is not named <version>_<name>.(up|down).sql", entry.Name())
+		}
+		version, err := strconv.ParseInt(match[1], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("migration file %s: %w", entry.Name(), err)
+		}
+		content, err := fs.ReadFile(fsys, entry.Name())
+		if err != nil {
+			return nil, err
+		}
+
+		migration, ok := byVersion[version]
+		if !ok {
+			migration = &Migration{Version: version, Name: match[2]}
+			byVersion[version] = migration
+		}
+		if migration.Name != match[2] {
+			return nil, fmt.Errorf("migration version %d is used by both %s and %s", version, migration.Name, match[2])
+		}
+		if match[3] == "up" {
+			migration.Up = string(content)
+			hasUp[version] = true
+		} else {
+			migration.Down = string(content)
+		}
+	}
+
+	var migrations []Migration
+	for version, migration := range byVersion {
+		if !hasUp[version] {
+			return nil, fmt.Errorf("migration %s has no up file", migration)
+		}
+		migrations = append(migrations, *migration)
+	}
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].Version < migrations[j].Version })
+	return migrations, nil
+}
+
+// Create writes empty up and down files for a new migration, numbered after the newest
+// one, and returns their paths. When dir has a directory per database the files are added
+// to each of them, so all databases keep the same versions.
+func Create(dir, name string) ([]string, error) {
+	name = strings.Trim(regexp.MustCompile(`[^a-z0-9]+`).ReplaceAllString(strings.ToLower(name), "_"), "_")
+	if name == "" {
+		return nil, fmt.Errorf("migration name is required")
+	}
+
+	dirs := []string{dir}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	var subdirs []string
+	for _, entry := range entries {
+		if entry.IsDir() {
+			subdirs = append(subdirs, filepath.Join(dir, entry.Name()))
+		}
+	}
+	if len(subdirs) > 0 {
+		dirs = subdirs
+	}
+
+	version := int64(1)
+	for _, d := range dirs {
+		migrations, err := Load(os.DirFS(d))
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", d, err)
+		}
+		if n := len(migrations); n > 0 && migrations[n-1].Version >= version {
+			version = migrations[n-1].Version + 1
+		}
+	}
+
+	var paths []string
+	for _, d := range dirs {
+		base := filepath.Join(d, Migration{Version: version, Name: name}.String())
+		up, down := base+".up.sql", base+".down.sql"
+		if err := os.WriteFile(up, []byte("-- Write the statements that apply the change, each ending with a semicolon\n"), 0o644); err != nil {
+			return paths, err
+		}
+		if err := os.WriteFile(down, []byte("-- Write the statements that revert the change\n"), 0o644); err != nil {
+			return paths, err
+		}
+		paths = append(paths, up, down)
+	}
+	return paths, nil
+}
@@ -0,0 +1,90 @@
+package migrate
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// lockName is the MySQL named lock held while migrating, lockKey the Postgres advisory lock
+const (
+	lockName = "schema_migrations"
+	lockKey  = 7263950147
+)
+
+// ErrLockTimeout is returned when another migrator holds the lock for longer than LockTimeout
+var ErrLockTimeout = errors.New("timed out waiting for another migration to finish")
+
+// lock takes the migration lock so replicas starting together don't apply the same
+// migration twice, and returns the function that releases it. On MySQL and Postgres this
+// is a named or advisory lock held by a connection of its own. SQLite only has one writer,
+// so it needs none.
+func lock(ctx context.Context, db *gorm.DB) (func(), error) {
+	switch db.Dialector.Name() {
+	case "mysql":
+		return mysqlLock(ctx, db)
+	case "postgres":
+		return postgresLock(ctx, db)
+	default:
+		return func() {}, nil
+	}
+}
+
+func mysqlLock(ctx context.Context, db *gorm.DB) (func(), error) {
+	sqlDB, err := db.DB()
+	if err != nil {
+		return nil, err
+	}
+	conn, err := sqlDB.Conn(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	timeout := 0
+	if deadline, ok := ctx.Deadline(); ok {
+		timeout = int(math.Ceil(time.Until(deadline).Seconds()))
+	}
+	var acquired *int
+	if err := conn.QueryRowContext(ctx, "SELECT GET_LOCK(?, ?)", lockName, timeout).Scan(&acquired); err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("taking the migration lock: %w", err)
+	}
+	if acquired == nil || *acquired != 1 {
+		conn.Close()
+		return nil, ErrLockTimeout
+	}
+
+	return func() {
+		conn.ExecContext(context.Background(), "SELECT RELEASE_LOCK(?)", lockName)
+		conn.Close()
+	}, nil
+}
+
+func postgresLock(ctx context.Context, db *gorm.DB) (func(), error) {
+	sqlDB, err := db.DB()
+	if err != nil {
+		return nil, err
+	}
+	conn, err := sqlDB.Conn(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	// pg_advisory_lock waits until the context's deadline cancels it
+	if _, err := conn.ExecContext(ctx, "SELECT pg_advisory_lock($1)", lockKey); err != nil {
+		conn.Close()
+		if ctx.Err() != nil {
+			return nil, ErrLockTimeout
+		}
+		return nil, fmt.Errorf("taking the migration lock: %w", err)
+	}
+
+	return func() {
+		conn.ExecContext(context.Background(), "SELECT pg_advisory_unlock($1)", lockKey)
+		conn.Close()
+	}, nil
+}
@@ -0,0 +1,241 @@
+// Package migrate applies the versioned SQL migrations in the migrations directory and
+// records them in the schema_migrations table.
+package migrate
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+	"sort"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Migration is one version of the schema with the SQL that applies and reverts it
+type Migration struct {
+	Version int64
+	Name    string
+	Up      string
+	Down    string
+}
+
+func (m Migration) String() string {
+	return fmt.Sprintf("%04d_%s", m.Version, m.Name)
+}
+
+// Status is a migration and when it was applied. AppliedAt is nil for pending migrations,
+// Missing is set for applied versions that have no files.
+type Status struct {
+	Migration
+	AppliedAt *time.Time
+	Missing   bool
+}
+
+// Migrator applies migrations to a database. Only one migrator at a time changes the
+// schema, others wait up to LockTimeout.
+type Migrator struct {
+	DB          *gorm.DB
+	Migrations  []Migration
+	LockTimeout time.Duration
+}
+
+// New returns a migrator for the migration files in fsys
+func New(db *gorm.DB, fsys fs.FS) (*Migrator, error) {
+	migrations, err := Load(fsys)
+	if err != nil {
+		return nil, err
+	}
+	return &Migrator{DB: db, Migrations: migrations, LockTimeout: time.Minute}, nil
+}
+
+// Up applies all pending migrations in version order and returns them
+func (m *Migrator) Up() ([]Migration, error) {
+	var applied []Migration
+	err := m.withLock(func() error {
+		pending, err := m.pending()
+		if err != nil {
+			return err
+		}
+		for _, migration := range pending {
+			if err := m.apply(migration, migration.Up, true); err != nil {
+				return err
+			}
+			applied = append(applied, migration)
+		}
+		return nil
+	})
+	return applied, err
+}
+
+// Down reverts the last steps applied migrations, newest first, and returns them
+func (m *Migrator) Down(steps int) ([]Migration, error) {
+	var reverted []Migration
+	err := m.withLock(func() error {
+		versions, err := m.appliedVersions()
+		if err != nil {
+			return err
+		}
+		for i := len(versions) - 1; i >= 0 && len(reverted) < steps; i-- {
+			migration, ok := m.find(versions[i])
+			if !ok {
+				return fmt.Errorf("migration %04d is applied but has no files", versions[i])
+			}
+			if err := m.apply(migration, migration.Down, false); err != nil {
+				return err
+			}
+			reverted = append(reverted, migration)
+		}
+		return nil
+	})
+	return reverted, err
+}
+
+// Pending returns the migrations that are not applied yet
+func (m *Migrator) Pending() ([]Migration, error) {
+	if err := m.ensureTable(); err != nil {
+		return nil, err
+	}
+	return m.pending()
+}
+
+// Status returns every known migration, applied or not, in version order
+func (m *Migrator) Status() ([]Status, error) {
+	if err := m.ensureTable(); err != nil {
+		return nil, err
+	}
+	var rows []struct {
+		Version   int64
+		Name      string
+		AppliedAt time.Time
+	}
+	if err := m.DB.Raw("SELECT version, name, applied_at FROM schema_migrations ORDER BY version").Scan(&rows).Error; err != nil {
+		return nil, err
+	}
+
+	applied := map[int64]time.Time{}
+	var statuses []Status
+	for _, row := range rows {
+		applied[row.Version] = row.AppliedAt
+		if _, ok := m.find(row.Version); !ok {
+			appliedAt := row.AppliedAt
+			statuses = append(statuses, Status{Migration: Migration{Version: row.Version, Name: row.Name}, AppliedAt: &appliedAt, Missing: true})
+		}
+	}
+	for _, migration := range m.Migrations {
+		status := Status{Migration: migration}
+		if appliedAt, ok := applied[migration.Version]; ok {
+			status.AppliedAt = &appliedAt
+		}
+		statuses = append(statuses, status)
+	}
+	sort.SliceStable(statuses, func(i, j int) bool { return statuses[i].Version < statuses[j].Version })
+	return statuses, nil
+}
+
+// apply runs the statements of one direction of a migration and records the result in the
+// same transaction. MySQL commits schema changes right away, so a failing statement there
+// leaves the earlier ones of the migration in place.
+func (m *Migrator) apply(migration Migration, sql string, up bool) error {
+	err := m.DB.Transaction(func(tx *gorm.DB) error {
+		for _, statement := range statements(sql) {
+			if err := tx.Exec(statement).Error; err != nil {
+				return err
+			}
+		}
+		if up {
+			return tx.Exec("INSERT INTO schema_migrations (version, name, applied_at) VALUES (?, ?, ?)",
+				migration.Version, migration.Name, time.Now().UTC()).Error
+		}
+		return tx.Exec("DELETE FROM schema_migrations WHERE version = ?", migration.Version).Error
+	})
+	if err != nil {
+		direction := "up"
+		if !up {
+			direction = "down"
+		}
+		return fmt.Errorf("migration %s (%s): %w", migration, direction, err)
+	}
+	return nil
+}
+
+func (m *Migrator) ensureTable() error {
+	return m.DB.Exec(`CREATE TABLE IF NOT EXISTS schema_migrations (
+		version BIGINT NOT NULL PRIMARY KEY,
+		name VARCHAR(255) NOT NULL,
+		applied_at TIMESTAMP NOT NULL
+	)`).Error
+}
+
+func (m *Migrator) appliedVersions() ([]int64, error) {
+	var versions []int64
+	err := m.DB.Raw("SELECT version FROM schema_migrations ORDER BY version").Scan(&versions).Error
+	return versions, err
+}
+
+func (m *Migrator) pending() ([]Migration, error) {
+	versions, err := m.appliedVersions()
+	if err != nil {
+		return nil, err
+	}
+	applied := map[int64]bool{}
+	for _, version := range versions {
+		applied[version] = true
+	}
+	var pending []Migration
+	for _, migration := range m.Migrations {
+		if !applied[migration.Version] {
+			pending = append(pending, migration)
+		}
+	}
+	return pending, nil
+}
+
+func (m *Migrator) find(version int64) (Migration, bool) {
+	for _, migration := range m.Migrations {
+		if migration.Version == version {
+			return migration, true
+		}
+	}
+	return Migration{}, false
+}
+
+// withLock runs fn while holding the migration lock and after creating schema_migrations
+func (m *Migrator) withLock(fn func() error) error {
+	ctx, cancel := context.WithTimeout(context.Background(), m.LockTimeout)
+	defer cancel()
+	unlock, err := lock(ctx, m.DB)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	if err := m.ensureTable(); err != nil {
+		return err
+	}
+	return fn()
+}
+
+// statements splits a migration file into statements. A statement ends at a line ending
+// with a semicolon, lines starting with -- are comments.
+func statements(sql string) []string {
+	var result []string
+	var current strings.Builder
+	for _, line := range strings.Split(sql, "\n") {
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "" || strings.HasPrefix(trimmed, "--") {
+			continue
+		}
+		current.WriteString(line)
+		current.WriteString("\n")
+		if strings.HasSuffix(trimmed, ";") {
+			result = append(result, strings.TrimSuffix(strings.TrimSpace(current.String()), ";"))
+			current.Reset()
+		}
+	}
+	if rest := strings.TrimSpace(current.String()); rest != "" {
+		result = append(result, rest)
+	}
+	return result
+}
@@ -0,0 +1,32 @@
+package models
+
+import (
+	"time"
+)
+
+// Security events recorded in the audit log
+const (
+	AuditLoginSucceeded  = "login_succeeded"
+	AuditLoginFailed     = "login_failed"
+	AuditLoginThrottled  = "login_throttled"
+	AuditAccountLocked   = "account_locked"
+	AuditAccountUnlocked = "account_unlocked"
+)
+
+// AuditLog is a security event such as a failed login. UserID is nil when the event
+// could not be tied to a user, e.g. a login with an unknown email.
+type AuditLog struct {
+	ID           uint      `json:"id" gorm:"primarykey"`
+	RestaurantID *uint     `json:"restaurant_id" gorm:"index"`
+	UserID       *uint     `json:"user_id" gorm:"index"`
+	ActorID      *uint     `json:"actor_id"` // The admin who performed the action, when it was not the user
+	Event        string    `json:"event" gorm:"not null;size:50;index"`
+	Email        string    `json:"email" gorm:"size:255"`
+	IPAddress    string    `json:"ip_address" gorm:"size:45"`
+	CreatedAt    time.Time `json:"created_at" gorm:"index"`
+}
+
+// TableName returns the table name for AuditLog model
+func (AuditLog) TableName() string {
+	return "audit_logs"
+}
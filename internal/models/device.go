@@ -0,0 +1,31 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Device is a shared POS terminal enrolled by an admin, on which staff sign in with a PIN
+type Device struct {
+	gorm.Model
+
+	RestaurantID      uint       `json:"restaurant_id" gorm:"not null;index"`
+	Name              string     `json:"name" gorm:"not null;size:100"`
+	CredentialHash    string     `json:"-" gorm:"not null;uniqueIndex;size:64"` // SHA-256 of the device credential
+	LastSeenAt        *time.Time `json:"last_seen_at"`
+	RevokedAt         *time.Time `json:"revoked_at"`
+	FailedPinAttempts int        `json:"-" gorm:"not null;default:0"`
+	LockedUntil       *time.Time `json:"locked_until"`
+
+	// Relationships
+	Restaurant Restaurant `json:"-" gorm:"foreignKey:RestaurantID"`
+}
+
+// TableName returns the table name for Device model
+func (Device) TableName() string {
+	return "devices"
+}
+
+// TenantOwned limits queries on Device to the scoped restaurant, see package tenant
+func (Device) TenantOwned() {}
@@ -0,0 +1,44 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Location statuses as reported by Square
+const (
+	LocationStatusActive   = "ACTIVE"
+	LocationStatusInactive = "INACTIVE"
+)
+
+// Location is one of a restaurant's Square locations, such as a brunch and a dinner venue
+// under the same merchant. It mirrors Square's Locations API and is refreshed by a sync.
+type Location struct {
+	gorm.Model
+
+	RestaurantID     uint       `json:"restaurant_id" gorm:"not null;uniqueIndex:idx_locations_restaurant_square,priority:1"`
+	SquareLocationID string     `json:"square_location_id" gorm:"not null;size:64;uniqueIndex:idx_locations_restaurant_square,priority:2"`
+	Name             string     `json:"name" gorm:"not null;size:255"`
+	AddressLine1     string     `json:"address_line_1" gorm:"size:255"`
+	AddressLine2     string     `json:"address_line_2" gorm:"size:255"`
+	Locality         string     `json:"locality" gorm:"size:100"`
+	Region           string     `json:"region" gorm:"size:100"` // Square's administrative_district_level_1, e.g. the state
+	PostalCode       string     `json:"postal_code" gorm:"size:20"`
+	Country          string     `json:"country" gorm:"size:2"`
+	Timezone         string     `json:"timezone" gorm:"size:64"`
+	Currency         string     `json:"currency" gorm:"size:3"`
+	Status           string     `json:"status" gorm:"not null;size:20;default:ACTIVE"`
+	SyncedAt         *time.Time `json:"synced_at"` // Nil for locations carried over from before the first sync
+
+	// Relationships
+	Restaurant Restaurant `json:"-" gorm:"foreignKey:RestaurantID"`
+}
+
+// TableName returns the table name for Location model
+func (Location) TableName() string {
+	return "locations"
+}
+
+// TenantOwned limits queries on Location to the scoped restaurant, see package tenant
+func (Location) TenantOwned() {}
@@ -0,0 +1,19 @@
+package models
+
+import (
+	"time"
+)
+
+// LoginAttempt counts recent failed logins for an account or client IP, keyed like
+// "account:<email>" or "ip:<address>". Used by the database login attempt store.
+type LoginAttempt struct {
+	Key           string     `json:"key" gorm:"column:attempt_key;primaryKey;size:191"`
+	Failures      int        `json:"failures" gorm:"not null;default:0"`
+	LastFailureAt *time.Time `json:"last_failure_at"`
+	LockedUntil   *time.Time `json:"locked_until"`
+}
+
+// TableName returns the table name for LoginAttempt model
+func (LoginAttempt) TableName() string {
+	return "login_attempts"
+}
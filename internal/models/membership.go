@@ -0,0 +1,54 @@
+package models
+
+import (
+	"strings"
+	"time"
+)
+
+// Membership gives a user access to a restaurant with a role. Every user is a member of
+// the restaurant they were created in, their home restaurant, and can be added to others.
+type Membership struct {
+	ID           uint      `json:"id" gorm:"primarykey"`
+	UserID       uint      `json:"user_id" gorm:"not null;uniqueIndex:idx_memberships_user_restaurant,priority:1"`
+	RestaurantID uint      `json:"restaurant_id" gorm:"not null;index;uniqueIndex:idx_memberships_user_restaurant,priority:2"`
+	Role         string    `json:"role" gorm:"not null;size:50;default:staff"`
+	LocationIDs  string    `json:"location_ids" gorm:"size:1000"` // Comma-separated Square location IDs, empty allows every location
+	IsActive     bool      `json:"is_active" gorm:"not null;default:true"`
+	CreatedAt    time.Time `json:"created_at"`
+	UpdatedAt    time.Time `json:"updated_at"`
+
+	// Relationships
+	User       User       `json:"-" gorm:"foreignKey:UserID"`
+	Restaurant Restaurant `json:"-" gorm:"foreignKey:RestaurantID"`
+}
+
+// TableName returns the table name for Membership model
+func (Membership) TableName() string {
+	return "memberships"
+}
+
+// AllowedLocations returns the Square location IDs the member is limited to, or nil when
+// every location of the restaurant is allowed
+func (m Membership) AllowedLocations() []string {
+	var locations []string
+	for _, id := range strings.Split(m.LocationIDs, ",") {
+		if id = strings.TrimSpace(id); id != "" {
+			locations = append(locations, id)
+		}
+	}
+	return locations
+}
+
+// AllowsLocation reports whether the member may work at the Square location
+func (m Membership) AllowsLocation(locationID string) bool {
+	locations := m.AllowedLocations()
+	if len(locations) == 0 {
+		return true
+	}
+	for _, id := range locations {
+		if id == locationID {
+			return true
+		}
+	}
+	return false
+}
@@ -11,8 +11,8 @@ type Order struct {
 
 	RestaurantID  uint           `json:"restaurant_id" gorm:"not null;index"`
 	SquareOrderID string         `json:"square_order_id" gorm:"size:255;index"`
-	TableID       *uint          `json:"table_id" gorm:"type:uuid;index"`
-	PaymentID     string         `gorm:"type:char(36);index"`
+	TableID       *uint          `json:"table_id" gorm:"index"`
+	PaymentID     string         `gorm:"size:36;index"`
 	TableNumber   int            `json:"table_number" binding:"required,min=1"`
 	OpenedAt      time.Time      `json:"opened_at" gorm:"not null"`
 	IsClosed      bool           `json:"is_closed" gorm:"default:false;index"`
@@ -48,3 +48,6 @@ type OrderTotals struct {
 func (Order) TableName() string {
 	return "orders"
 }
+
+// TenantOwned limits queries on Order to the scoped restaurant, see package tenant
+func (Order) TenantOwned() {}
@@ -13,6 +13,7 @@ type Payment struct {
 	BillAmount    int            `json:"bill_amount" gorm:"not null"`
 	TipAmount     int            `json:"tip_amount" gorm:"default:0"`
 	TotalAmount   int            `json:"total_amount" gorm:"not null"`
+	RefundedAmount int           `json:"refunded_amount" gorm:"default:0"`
 	Status        string         `json:"status" gorm:"default:pending;size:100"`
 	PaymentMethod string         `json:"payment_method" gorm:"size:50"`
 	ProcessedAt   time.Time      `json:"processed_at"`
@@ -36,3 +37,6 @@ type Payment struct {
 func (Payment) TableName() string {
 	return "payments"
 }
+
+// TenantOwned limits queries on Payment to the scoped restaurant, see package tenant
+func (Payment) TenantOwned() {}
@@ -0,0 +1,27 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// RefreshToken is one link in a chain of rotating refresh tokens issued for a login
+type RefreshToken struct {
+	gorm.Model
+
+	UserID       uint       `json:"user_id" gorm:"not null;index"`
+	RestaurantID uint       `json:"restaurant_id" gorm:"not null;default:0"` // Restaurant the session acts in, zero for tokens issued before memberships
+	TokenHash    string     `json:"-" gorm:"not null;uniqueIndex;size:64"`   // SHA-256 of the token, the token itself is never stored
+	FamilyID     string     `json:"family_id" gorm:"not null;index;size:36"` // Shared by every token rotated from the same login
+	ExpiresAt    time.Time  `json:"expires_at" gorm:"not null"`
+	RevokedAt    *time.Time `json:"revoked_at"`
+
+	// Relationships
+	User User `json:"-" gorm:"foreignKey:UserID"`
+}
+
+// TableName returns the table name for RefreshToken model
+func (RefreshToken) TableName() string {
+	return "refresh_tokens"
+}
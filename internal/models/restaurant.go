@@ -13,6 +13,8 @@ type Restaurant struct {
 	SquareToken string `json:"-"             gorm:"not null"` 
 	MerchantID    string `json:"merchant_id" gorm:"not null"`                     
 	LocationID    string `json:"location_id" gorm:"not null"`                     
+	DiscountLimitPercent int `json:"discount_limit_percent" gorm:"not null;default:20"` // Larger discounts need discounts.apply_over_limit
+	TwoFactorRoles string `json:"two_factor_roles" gorm:"size:255"` // Comma separated roles that must sign in with two-factor authentication
 
 
 	//Relationships
@@ -0,0 +1,19 @@
+package models
+
+import (
+	"time"
+)
+
+// RevokedToken records an access token that was revoked before it expired
+type RevokedToken struct {
+	ID        uint      `json:"id" gorm:"primarykey"`
+	JTI       string    `json:"jti" gorm:"column:jti;not null;uniqueIndex;size:36"`
+	UserID    uint      `json:"user_id" gorm:"not null;index"`
+	ExpiresAt time.Time `json:"expires_at" gorm:"not null;index"` // Rows can be pruned once the token would have expired anyway
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// TableName returns the table name for RevokedToken model
+func (RevokedToken) TableName() string {
+	return "revoked_tokens"
+}
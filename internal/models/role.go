@@ -0,0 +1,35 @@
+package models
+
+import (
+	"gorm.io/gorm"
+)
+
+// Role is a custom role defined by a restaurant, in addition to the built-in admin, manager and staff roles
+type Role struct {
+	gorm.Model
+
+	RestaurantID uint   `json:"restaurant_id" gorm:"not null;uniqueIndex:idx_roles_restaurant_name,priority:1"`
+	Name         string `json:"name" gorm:"not null;size:50;uniqueIndex:idx_roles_restaurant_name,priority:2"`
+	Description  string `json:"description" gorm:"size:255"`
+
+	// Relationships
+	Permissions []RolePermission `json:"permissions" gorm:"constraint:OnDelete:CASCADE;"`
+	Restaurant  Restaurant       `json:"-" gorm:"foreignKey:RestaurantID"`
+}
+
+// TableName returns the table name for Role model
+func (Role) TableName() string {
+	return "roles"
+}
+
+// RolePermission grants one permission to a custom role
+type RolePermission struct {
+	ID         uint   `json:"-" gorm:"primarykey"`
+	RoleID     uint   `json:"-" gorm:"not null;uniqueIndex:idx_role_permissions_role_permission,priority:1"`
+	Permission string `json:"permission" gorm:"not null;size:64;uniqueIndex:idx_role_permissions_role_permission,priority:2"`
+}
+
+// TableName returns the table name for RolePermission model
+func (RolePermission) TableName() string {
+	return "role_permissions"
+}
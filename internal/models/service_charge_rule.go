@@ -0,0 +1,47 @@
+package models
+
+import (
+	"gorm.io/gorm"
+)
+
+type ServiceChargeRule struct {
+	gorm.Model
+
+	RestaurantID     uint   `json:"restaurant_id" gorm:"not null;index"`
+	LocationID       string `json:"location_id" gorm:"size:255;index"` // Empty applies the rule to every location
+	Name             string `json:"name" gorm:"not null;size:255"`
+	Percentage       string `json:"percentage" gorm:"size:20"` // Set either Percentage or Amount
+	Amount           int64  `json:"amount" gorm:"default:0"`   // Fixed amount in cents
+	CalculationPhase string `json:"calculation_phase" gorm:"not null;size:50;default:SUBTOTAL_PHASE"`
+	Taxable          bool   `json:"taxable" gorm:"default:false"`
+	Enabled          bool   `json:"enabled" gorm:"index"`
+
+	// Conditions for applying the charge automatically
+	MinGuestCount int    `json:"min_guest_count" gorm:"default:0"` // e.g. auto gratuity for parties of 6 or more
+	OrderType     string `json:"order_type" gorm:"size:50"`        // e.g. "delivery" for a delivery fee; empty matches every order
+
+	// Relationships
+	Restaurant Restaurant `json:"-" gorm:"foreignKey:RestaurantID"`
+}
+
+// AppliesTo reports whether the charge should be added to an order for the given party size and order type
+func (r ServiceChargeRule) AppliesTo(guestCount int, orderType string) bool {
+	if !r.Enabled {
+		return false
+	}
+	if r.MinGuestCount > 0 && guestCount < r.MinGuestCount {
+		return false
+	}
+	if r.OrderType != "" && r.OrderType != orderType {
+		return false
+	}
+	return true
+}
+
+// TableName returns the table name for ServiceChargeRule model
+func (ServiceChargeRule) TableName() string {
+	return "service_charge_rules"
+}
+
+// TenantOwned limits queries on ServiceChargeRule to the scoped restaurant, see package tenant
+func (ServiceChargeRule) TenantOwned() {}
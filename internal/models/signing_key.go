@@ -0,0 +1,22 @@
+package models
+
+import (
+	"time"
+)
+
+// SigningKey is an asymmetric key access tokens are signed with. Every replica loads the
+// keys that have not retired, so rotating a key only needs a new row.
+type SigningKey struct {
+	ID          uint       `json:"-" gorm:"primarykey"`
+	KID         string     `json:"kid" gorm:"column:kid;not null;uniqueIndex;size:64"`
+	Algorithm   string     `json:"algorithm" gorm:"not null;size:16"`
+	PrivateKey  string     `json:"-" gorm:"not null;type:text"` // Encrypted PKCS #8 PEM
+	ActivatesAt time.Time  `json:"activates_at" gorm:"not null;index"`
+	RetiresAt   *time.Time `json:"retires_at" gorm:"index"`
+	CreatedAt   time.Time  `json:"created_at"`
+}
+
+// TableName returns the table name for SigningKey model
+func (SigningKey) TableName() string {
+	return "signing_keys"
+}
@@ -0,0 +1,31 @@
+package models
+
+import (
+	"gorm.io/gorm"
+)
+
+type TaxRule struct {
+	gorm.Model
+
+	RestaurantID  uint   `json:"restaurant_id" gorm:"not null;index"`
+	LocationID    string `json:"location_id" gorm:"size:255;index"` // Empty applies the rule to every location
+	Name          string `json:"name" gorm:"not null;size:255"`
+	Percentage    string `json:"percentage" gorm:"not null;size:20"`                      // Decimal string as Square expects, e.g. "8.875"
+	InclusionType string `json:"inclusion_type" gorm:"not null;size:20;default:ADDITIVE"` // ADDITIVE or INCLUSIVE
+	Enabled       bool   `json:"enabled" gorm:"index"`
+
+	// Square specific fields for catalog sync
+	SquareCatalogObjectID string `json:"square_catalog_object_id" gorm:"size:255;index"`
+	SquareCatalogVersion  int64  `json:"square_catalog_version" gorm:"default:0"`
+
+	// Relationships
+	Restaurant Restaurant `json:"-" gorm:"foreignKey:RestaurantID"`
+}
+
+// TableName returns the table name for TaxRule model
+func (TaxRule) TableName() string {
+	return "tax_rules"
+}
+
+// TenantOwned limits queries on TaxRule to the scoped restaurant, see package tenant
+func (TaxRule) TenantOwned() {}
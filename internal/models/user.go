@@ -1,6 +1,8 @@
 package models
 
 import(
+	"time"
+
 	"gorm.io/gorm"
 )
 
@@ -10,9 +12,25 @@ type User struct {
 	Username     string `json:"username" gorm:"not null;uniqueIndex;size:100"`
 	Email        string `json:"email" gorm:"not null;uniqueIndex;size:255"`
 	PasswordHash string `json:"-" gorm:"not null;size:255"`
-	RestaurantID uint   `json:"restaurant_id" gorm:"not null;index"`
+	RestaurantID uint   `json:"restaurant_id" gorm:"not null;index;uniqueIndex:idx_users_restaurant_pin,priority:1"`
 	Role         string `json:"role" gorm:"not null;size:50;default:staff"`
 	IsActive     bool   `json:"is_active" gorm:"default:true"`
+	TokenVersion int    `json:"-" gorm:"not null;default:0"` // Bumped to invalidate every token issued to the user
+
+	// Email verification, only required for admins who registered a restaurant
+	EmailVerificationPending bool       `json:"-" gorm:"not null;default:false"`
+	EmailVerifiedAt          *time.Time `json:"email_verified_at"`
+
+	// Staff PIN for shared devices, nil when the user has no PIN
+	PinHash           *string    `json:"-" gorm:"size:64;uniqueIndex:idx_users_restaurant_pin,priority:2"`
+	FailedPinAttempts int        `json:"-" gorm:"not null;default:0"`
+	PinLockedUntil    *time.Time `json:"-"`
+
+	// TOTP two-factor authentication. The secret is stored encrypted from enrollment on,
+	// and TOTPEnabledAt stays nil until the user confirms a first code.
+	TOTPSecret    *string    `json:"-" gorm:"size:255"`
+	TOTPEnabledAt *time.Time `json:"-"`
+	TOTPLastStep  int64      `json:"-" gorm:"not null;default:0"` // Last accepted time step, so a code cannot be replayed
 	
 	// Relationships
 	Restaurant Restaurant `json:"restaurant,omitempty" gorm:"foreignKey:RestaurantID"`
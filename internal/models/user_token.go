@@ -0,0 +1,31 @@
+package models
+
+import (
+	"time"
+)
+
+// Purposes of single-use user tokens
+const (
+	TokenPurposePasswordReset     = "password_reset"
+	TokenPurposeEmailVerification = "email_verification"
+	TokenPurposeLoginChallenge    = "login_challenge"
+)
+
+// UserToken is a single-use token emailed to a user, such as a password reset link
+type UserToken struct {
+	ID        uint       `json:"id" gorm:"primarykey"`
+	UserID    uint       `json:"user_id" gorm:"not null;index"`
+	Purpose   string     `json:"purpose" gorm:"not null;size:32;index"`
+	TokenHash string     `json:"-" gorm:"not null;uniqueIndex;size:64"` // SHA-256 of the emailed token
+	ExpiresAt time.Time  `json:"expires_at" gorm:"not null"`
+	UsedAt    *time.Time `json:"used_at"`
+	CreatedAt time.Time  `json:"created_at"`
+
+	// Relationships
+	User User `json:"-" gorm:"foreignKey:UserID"`
+}
+
+// TableName returns the table name for UserToken model
+func (UserToken) TableName() string {
+	return "user_tokens"
+}
@@ -52,10 +52,87 @@ type OrderTotals struct {
 
 // LoginResponse represents the login response structure
 type LoginResponse struct {
-	Token          string             `json:"token"`
-	RestaurantName string             `json:"restaurant_name"`
-	User           UserResponse       `json:"user"`
-	ExpiresAt      time.Time          `json:"expires_at"`
+	Token          string               `json:"token"`
+	RefreshToken   string               `json:"refresh_token"`
+	RestaurantName string               `json:"restaurant_name"`
+	User           UserResponse         `json:"user"`
+	Memberships    []MembershipResponse `json:"memberships"` // Restaurants the user can switch to
+	ExpiresAt      time.Time            `json:"expires_at"`
+}
+
+// MembershipResponse represents a restaurant the user is a member of
+type MembershipResponse struct {
+	RestaurantID   uint     `json:"restaurant_id"`
+	RestaurantName string   `json:"restaurant_name"`
+	Role           string   `json:"role"`
+	LocationIDs    []string `json:"location_ids"` // Empty when every location is allowed
+}
+
+// TwoFactorChallengeResponse represents a login that needs a second factor before tokens are issued
+type TwoFactorChallengeResponse struct {
+	TwoFactorRequired  bool      `json:"two_factor_required"`
+	EnrollmentRequired bool      `json:"enrollment_required"` // The user's role requires 2FA and they have not set it up yet
+	ChallengeToken     string    `json:"challenge_token"`
+	ExpiresAt          time.Time `json:"expires_at"`
+}
+
+// TwoFactorLoginResponse represents a completed two-factor login. RecoveryCodes is only
+// set when the user enrolled during this login.
+type TwoFactorLoginResponse struct {
+	LoginResponse
+	RecoveryCodes []string `json:"recovery_codes,omitempty"`
+}
+
+// TwoFactorEnrollmentResponse represents a new TOTP secret for an authenticator app
+type TwoFactorEnrollmentResponse struct {
+	Secret          string `json:"secret"`
+	ProvisioningURI string `json:"provisioning_uri"` // otpauth:// URI to show as a QR code
+}
+
+// RecoveryCodesResponse represents newly issued recovery codes, which are only shown once
+type RecoveryCodesResponse struct {
+	RecoveryCodes []string `json:"recovery_codes"`
+}
+
+// SecuritySettingsResponse represents a restaurant's security settings
+type SecuritySettingsResponse struct {
+	TwoFactorRoles []string `json:"two_factor_roles"`
+}
+
+// TokenResponse represents a renewed token pair in the response
+type TokenResponse struct {
+	Token        string    `json:"token"`
+	RefreshToken string    `json:"refresh_token"`
+	ExpiresAt    time.Time `json:"expires_at"`
+}
+
+// DeviceLoginResponse represents the PIN login response on a shared device
+type DeviceLoginResponse struct {
+	Token     string       `json:"token"`
+	DeviceID  uint         `json:"device_id"`
+	User      UserResponse `json:"user"`
+	ExpiresAt time.Time    `json:"expires_at"`
+}
+
+// DeviceResponse represents an enrolled device in the response
+type DeviceResponse struct {
+	ID          uint       `json:"id"`
+	Name        string     `json:"name"`
+	Revoked     bool       `json:"revoked"`
+	LastSeenAt  *time.Time `json:"last_seen_at"`
+	LockedUntil *time.Time `json:"locked_until"`
+	CreatedAt   time.Time  `json:"created_at"`
+}
+
+// DeviceListResponse represents the list of enrolled devices in the response
+type DeviceListResponse struct {
+	Devices []DeviceResponse `json:"devices"`
+}
+
+// DeviceEnrollmentResponse represents a newly enrolled device and its credential in the response
+type DeviceEnrollmentResponse struct {
+	Device     DeviceResponse `json:"device"`
+	Credential string         `json:"credential"` // Only returned once, send it as X-Device-Token
 }
 
 // UserResponse represents a user in the response
@@ -65,32 +142,164 @@ type UserResponse struct {
 	Email        string `json:"email"`
 	Role         string `json:"role"`
 	RestaurantID uint   `json:"restaurant_id"`
+	IsActive     bool   `json:"is_active"`
+	HasPin       bool   `json:"has_pin"`
+	TwoFactor    bool   `json:"two_factor_enabled"`
+}
+
+// MemberResponse represents a user with access to the current restaurant
+type MemberResponse struct {
+	UserID      uint     `json:"user_id"`
+	Username    string   `json:"username"`
+	Email       string   `json:"email"`
+	Role        string   `json:"role"`
+	LocationIDs []string `json:"location_ids"`
+	IsActive    bool     `json:"is_active"`
+	Home        bool     `json:"home"` // The restaurant the user was created in
+}
+
+// MemberListResponse represents the members of a restaurant
+type MemberListResponse struct {
+	Members []MemberResponse `json:"members"`
+}
+
+// RoleResponse represents a built-in or custom role in the response
+type RoleResponse struct {
+	ID          uint     `json:"id,omitempty"` // Zero for built-in roles
+	Name        string   `json:"name"`
+	Description string   `json:"description,omitempty"`
+	BuiltIn     bool     `json:"built_in"`
+	Permissions []string `json:"permissions"`
+}
+
+// RoleListResponse represents the roles available to a restaurant and every grantable permission
+type RoleListResponse struct {
+	Roles       []RoleResponse `json:"roles"`
+	Permissions []string       `json:"permissions"`
+}
+
+// UserListResponse represents the list of users in the response
+type UserListResponse struct {
+	Users []UserResponse `json:"users"`
 }
 
 // PaymentResponse represents the payment response structure
 type PaymentResponse struct {
-	ID          string    `json:"id"`
-	PaymentID   string    `json:"payment_id"`
-	BillAmount  float64   `json:"bill_amount"`
-	TipAmount   float64   `json:"tip_amount"`
-	TotalAmount float64   `json:"total_amount"`
-	Status      string    `json:"status"`
-	ProcessedAt time.Time `json:"processed_at"`
-	Message     string    `json:"message"`
+	ID             string    `json:"id"`
+	PaymentID      string    `json:"payment_id"`
+	BillAmount     float64   `json:"bill_amount"`
+	TipAmount      float64   `json:"tip_amount"`
+	TotalAmount    float64   `json:"total_amount"`
+	RefundedAmount float64   `json:"refunded_amount"`
+	Status         string    `json:"status"`
+	ProcessedAt    time.Time `json:"processed_at"`
+	Message        string    `json:"message"`
+}
+
+// RefundResponse represents the refund payment response structure
+type RefundResponse struct {
+	RefundID string          `json:"refund_id"`
+	Status   string          `json:"status"`
+	Amount   float64         `json:"amount"`
+	Payment  PaymentResponse `json:"payment"`
+	Totals   OrderTotals     `json:"totals"`
+	Message  string          `json:"message"`
+}
+
+// OrderListResponse represents a list of orders in the response
+type OrderListResponse struct {
+	Orders []OrderResponse `json:"orders"`
+}
+
+// ProfileResponse represents the current user's profile response structure
+type ProfileResponse struct {
+	User       UserResponse       `json:"user"`
+	Restaurant RestaurantResponse `json:"restaurant"`
+}
+
+// RestaurantRegistrationResponse represents the register restaurant response structure
+type RestaurantRegistrationResponse struct {
+	Message    string             `json:"message"`
+	Restaurant RestaurantResponse `json:"restaurant"`
+	AdminUser  UserResponse       `json:"admin_user"`
 }
 
 // RestaurantResponse represents the restaurant response structure
 type RestaurantResponse struct {
-	ID       uint   `json:"id"`
-	Name     string `json:"name"`
-	IsActive bool   `json:"is_active"`
+	ID         uint   `json:"id"`
+	Name       string `json:"name"`
+	LocationID string `json:"location_id"`
+	IsActive   bool   `json:"is_active"`
+}
+
+// LocationResponse represents a Square location of the restaurant in the response
+type LocationResponse struct {
+	ID               uint       `json:"id"`
+	SquareLocationID string     `json:"square_location_id"`
+	Name             string     `json:"name"`
+	Address          Address    `json:"address"`
+	Timezone         string     `json:"timezone"`
+	Currency         string     `json:"currency"`
+	Status           string     `json:"status"`
+	SyncedAt         *time.Time `json:"synced_at"`
+}
+
+// Address represents a postal address in the response
+type Address struct {
+	AddressLine1 string `json:"address_line_1"`
+	AddressLine2 string `json:"address_line_2"`
+	Locality     string `json:"locality"`
+	Region       string `json:"region"`
+	PostalCode   string `json:"postal_code"`
+	Country      string `json:"country"`
+}
+
+// LocationListResponse represents a list of locations in the response
+type LocationListResponse struct {
+	Locations []LocationResponse `json:"locations"`
+}
+
+// TaxRuleResponse represents a tax rule in the response
+type TaxRuleResponse struct {
+	ID                    uint   `json:"id"`
+	Name                  string `json:"name"`
+	LocationID            string `json:"location_id"`
+	Percentage            string `json:"percentage"`
+	InclusionType         string `json:"inclusion_type"`
+	Enabled               bool   `json:"enabled"`
+	SquareCatalogObjectID string `json:"square_catalog_object_id"`
+}
+
+// TaxRuleListResponse represents a list of tax rules in the response
+type TaxRuleListResponse struct {
+	TaxRules []TaxRuleResponse `json:"tax_rules"`
+}
+
+// ServiceChargeResponse represents a service charge rule in the response
+type ServiceChargeResponse struct {
+	ID               uint    `json:"id"`
+	Name             string  `json:"name"`
+	LocationID       string  `json:"location_id"`
+	Percentage       string  `json:"percentage"`
+	Amount           int64   `json:"amount"` // Fixed amount in cents, as configured
+	CalculationPhase string  `json:"calculation_phase"`
+	Taxable          bool    `json:"taxable"`
+	Enabled          bool    `json:"enabled"`
+	MinGuestCount    int     `json:"min_guest_count"`
+	OrderType        string  `json:"order_type"`
+}
+
+// ServiceChargeListResponse represents a list of service charge rules in the response
+type ServiceChargeListResponse struct {
+	ServiceCharges []ServiceChargeResponse `json:"service_charges"`
 }
 
-// ErrorResponse represents an error response structure
+// ErrorResponse represents an error response structure. Code is a stable machine-readable
+// identifier such as ORDER_NOT_FOUND; Error is a human-readable message.
 type ErrorResponse struct {
-	Error   string `json:"error"`
-	Message string `json:"message,omitempty"`
-	Code    int    `json:"code,omitempty"`
+	Error   string      `json:"error"`
+	Code    string      `json:"code"`
+	Details interface{} `json:"details,omitempty"`
 }
 
 // SuccessResponse represents a success response structure
@@ -0,0 +1,77 @@
+package repository
+
+import (
+	"strconv"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"square-pos-integration/internal/models"
+	"square-pos-integration/internal/tenant"
+)
+
+// OrderRepository stores orders and their line items. Lookups only see the given
+// restaurant's orders.
+type OrderRepository interface {
+	Create(order *models.Order, items []models.OrderItem) error
+	FindByID(restaurantID uint, orderID string) (models.Order, error)
+	FindByPaymentID(restaurantID uint, paymentID string) (models.Order, error)
+	ListByTable(restaurantID uint, tableNumber string) ([]models.Order, error)
+	Update(order *models.Order, fields map[string]interface{}) error
+}
+
+type GormOrderRepository struct {
+	DB *gorm.DB
+}
+
+func NewOrderRepository(db *gorm.DB) *GormOrderRepository {
+	return &GormOrderRepository{DB: db}
+}
+
+// Create stores the order and its line items in one transaction
+func (r *GormOrderRepository) Create(order *models.Order, items []models.OrderItem) error {
+	return tenant.Scoped(r.DB, order.RestaurantID).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(order).Error; err != nil {
+			return err
+		}
+		for i := range items {
+			items[i].OrderID = strconv.FormatUint(uint64(order.ID), 10)
+			if err := tx.Create(&items[i]).Error; err != nil {
+				return err
+			}
+		}
+		order.Items = items
+		return nil
+	})
+}
+
+// FindByID returns the order with its items, discounts and modifiers
+func (r *GormOrderRepository) FindByID(restaurantID uint, orderID string) (models.Order, error) {
+	var order models.Order
+	err := withItems(tenant.Scoped(r.DB, restaurantID)).Where("id = ?", orderID).First(&order).Error
+	return order, err
+}
+
+// FindByPaymentID returns the order a payment intent was created for
+func (r *GormOrderRepository) FindByPaymentID(restaurantID uint, paymentID string) (models.Order, error) {
+	var order models.Order
+	err := tenant.Scoped(r.DB, restaurantID).Where("payment_id = ?", paymentID).First(&order).Error
+	return order, err
+}
+
+// ListByTable returns the orders of a table with their items
+func (r *GormOrderRepository) ListByTable(restaurantID uint, tableNumber string) ([]models.Order, error) {
+	var orders []models.Order
+	err := withItems(tenant.Scoped(r.DB, restaurantID)).Where("table_number = ?", tableNumber).Find(&orders).Error
+	return orders, err
+}
+
+// Update writes the given columns of the order
+func (r *GormOrderRepository) Update(order *models.Order, fields map[string]interface{}) error {
+	return tenant.Scoped(r.DB, order.RestaurantID).Model(order).Omit(clause.Associations).Updates(fields).Error
+}
+
+// withItems preloads order items with their discounts and modifiers for order responses
+func withItems(db *gorm.DB) *gorm.DB {
+	return db.Preload("Items.Discounts").Preload("Items.Modifiers")
+}
@@ -0,0 +1,60 @@
+package repository
+
+import (
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"square-pos-integration/internal/models"
+	"square-pos-integration/internal/tenant"
+)
+
+// PaymentRepository stores payments. Lookups only see the given restaurant's payments.
+type PaymentRepository interface {
+	Create(payment *models.Payment) error
+	FindByID(restaurantID uint, paymentID string) (models.Payment, error)
+	FindBySquareID(restaurantID uint, squarePaymentID string) (models.Payment, error)
+	ListCompleted(restaurantID uint, orderID string) ([]models.Payment, error)
+	Save(payment *models.Payment) error
+	Update(payment *models.Payment, fields map[string]interface{}) error
+}
+
+type GormPaymentRepository struct {
+	DB *gorm.DB
+}
+
+func NewPaymentRepository(db *gorm.DB) *GormPaymentRepository {
+	return &GormPaymentRepository{DB: db}
+}
+
+func (r *GormPaymentRepository) Create(payment *models.Payment) error {
+	return tenant.Scoped(r.DB, payment.RestaurantID).Create(payment).Error
+}
+
+func (r *GormPaymentRepository) FindByID(restaurantID uint, paymentID string) (models.Payment, error) {
+	var payment models.Payment
+	err := tenant.Scoped(r.DB, restaurantID).Where("id = ?", paymentID).First(&payment).Error
+	return payment, err
+}
+
+// FindBySquareID returns the payment with the Square payment ID
+func (r *GormPaymentRepository) FindBySquareID(restaurantID uint, squarePaymentID string) (models.Payment, error) {
+	var payment models.Payment
+	err := tenant.Scoped(r.DB, restaurantID).Where("square_payment_id = ?", squarePaymentID).First(&payment).Error
+	return payment, err
+}
+
+// ListCompleted returns the completed payments of an order
+func (r *GormPaymentRepository) ListCompleted(restaurantID uint, orderID string) ([]models.Payment, error) {
+	var payments []models.Payment
+	err := tenant.Scoped(r.DB, restaurantID).Where("order_id = ? AND status = ?", orderID, "COMPLETED").Find(&payments).Error
+	return payments, err
+}
+
+func (r *GormPaymentRepository) Save(payment *models.Payment) error {
+	return tenant.Scoped(r.DB, payment.RestaurantID).Omit(clause.Associations).Save(payment).Error
+}
+
+// Update writes the given columns of the payment
+func (r *GormPaymentRepository) Update(payment *models.Payment, fields map[string]interface{}) error {
+	return tenant.Scoped(r.DB, payment.RestaurantID).Model(payment).Omit(clause.Associations).Updates(fields).Error
+}
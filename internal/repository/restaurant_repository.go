@@ -0,0 +1,31 @@
+package repository
+
+import (
+	"gorm.io/gorm"
+
+	"square-pos-integration/internal/models"
+)
+
+// RestaurantRepository stores restaurants, the tenants every other record belongs to
+type RestaurantRepository interface {
+	Create(restaurant *models.Restaurant) error
+	FindByID(restaurantID uint) (models.Restaurant, error)
+}
+
+type GormRestaurantRepository struct {
+	DB *gorm.DB
+}
+
+func NewRestaurantRepository(db *gorm.DB) *GormRestaurantRepository {
+	return &GormRestaurantRepository{DB: db}
+}
+
+func (r *GormRestaurantRepository) Create(restaurant *models.Restaurant) error {
+	return r.DB.Create(restaurant).Error
+}
+
+func (r *GormRestaurantRepository) FindByID(restaurantID uint) (models.Restaurant, error) {
+	var restaurant models.Restaurant
+	err := r.DB.First(&restaurant, restaurantID).Error
+	return restaurant, err
+}
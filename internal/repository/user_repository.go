@@ -0,0 +1,34 @@
+package repository
+
+import (
+	"gorm.io/gorm"
+
+	"square-pos-integration/internal/models"
+)
+
+// UserRepository looks up user accounts. Users are not limited to one restaurant, since an
+// account can be a member of several.
+type UserRepository interface {
+	FindByEmail(email string) (models.User, error)
+	FindByID(userID uint) (models.User, error)
+}
+
+type GormUserRepository struct {
+	DB *gorm.DB
+}
+
+func NewUserRepository(db *gorm.DB) *GormUserRepository {
+	return &GormUserRepository{DB: db}
+}
+
+func (r *GormUserRepository) FindByEmail(email string) (models.User, error) {
+	var user models.User
+	err := r.DB.Where("email = ?", email).First(&user).Error
+	return user, err
+}
+
+func (r *GormUserRepository) FindByID(userID uint) (models.User, error) {
+	var user models.User
+	err := r.DB.First(&user, userID).Error
+	return user, err
+}
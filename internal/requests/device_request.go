@@ -0,0 +1,18 @@
+package requests
+
+// EnrollDeviceRequest represents the enroll device request structure
+type EnrollDeviceRequest struct {
+	Name string `json:"name" binding:"required,min=1,max=100"`
+}
+
+// PinLoginRequest represents the PIN login request structure.
+// UserID is set when the device lets staff pick their name before entering the PIN.
+type PinLoginRequest struct {
+	UserID uint   `json:"user_id"`
+	Pin    string `json:"pin" binding:"required,numeric,min=4,max=6"`
+}
+
+// SetPinRequest represents the set PIN request structure
+type SetPinRequest struct {
+	Pin string `json:"pin" binding:"required,numeric,min=4,max=6"`
+}
@@ -0,0 +1,21 @@
+package requests
+
+// AddMemberRequest represents the request to give an existing user access to the current restaurant
+type AddMemberRequest struct {
+	Email       string   `json:"email" binding:"required,email"`
+	Role        string   `json:"role" binding:"omitempty,max=50"`
+	LocationIDs []string `json:"location_ids" binding:"omitempty,dive,min=1,max=64"`
+}
+
+// UpdateMemberRequest represents the update membership request structure. LocationIDs
+// replaces the allowed locations when present, an empty list allows every location.
+type UpdateMemberRequest struct {
+	Role        string    `json:"role" binding:"omitempty,max=50"`
+	LocationIDs *[]string `json:"location_ids" binding:"omitempty"`
+	IsActive    *bool     `json:"is_active" binding:"omitempty"`
+}
+
+// SwitchRestaurantRequest represents the request to act in another restaurant the user is a member of
+type SwitchRestaurantRequest struct {
+	RestaurantID uint `json:"restaurant_id" binding:"required"`
+}
@@ -16,6 +16,25 @@ type CreateOrderRequest struct {
 	LocationID    string            `json:"location_id" binding:"required"`
 	Note          string            `json:"note" binding:"omitempty,max=500"`
 	PaymentMethod string            `json:"payment_method" binding:"omitempty,oneof=cash card"`
+	GuestCount    int               `json:"guest_count" binding:"omitempty,min=1"`
+	OrderType     string            `json:"order_type" binding:"omitempty,oneof=dine_in takeout delivery"`
+}
+
+// DiscountTotals returns the requested discounts and the gross amount of the items they
+// apply to, both in cents
+func (r CreateOrderRequest) DiscountTotals() (discount, gross int64) {
+	for _, item := range r.Items {
+		unitPrice := int64(item.UnitPrice)
+		for _, m := range item.Modifiers {
+			unitPrice += int64(m.UnitPrice)
+		}
+		gross += unitPrice * int64(item.Quantity)
+
+		for _, d := range item.Discounts {
+			discount += int64(d.Value)
+		}
+	}
+	return discount, gross
 }
 
 // CreateOrderItem represents an item in the create order request
@@ -63,6 +82,12 @@ type CompletePaymentRequest struct {
     PaymentID  string  `json:"paymentId" binding:"required"` // This is the Square source ID (card nonce)
 }
 
+// RefundPaymentRequest represents the refund payment request structure
+type RefundPaymentRequest struct {
+	Amount float64 `json:"amount" binding:"required,gt=0"`
+	Reason string  `json:"reason" binding:"omitempty,max=192"`
+}
+
 type ProcessPaymentResponse struct {
     ID       string      `json:"id"`
     OpenedAt string      `json:"opened_at"`
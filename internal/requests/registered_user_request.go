@@ -5,16 +5,18 @@ type RegisterUserRequest struct {
 	Username     string `json:"username" binding:"required,min=3,max=100"`
 	Email        string `json:"email" binding:"required,email"`
 	Password     string `json:"password" binding:"required,min=6"`
-	RestaurantID uint   `json:"restaurant_id" binding:"required"`
-	Role         string `json:"role" binding:"omitempty,oneof=admin manager staff"`
+	Role         string `json:"role" binding:"omitempty,max=50"`
 }
 
 // UpdateUserRequest represents the update user request structure
 type UpdateUserRequest struct {
 	Username string `json:"username" binding:"omitempty,min=3,max=100"`
 	Email    string `json:"email" binding:"omitempty,email"`
-	Role     string `json:"role" binding:"omitempty,oneof=admin manager staff"`
+	Role     string `json:"role" binding:"omitempty,max=50"`
 	IsActive *bool  `json:"is_active" binding:"omitempty"`
 }
 
-
+// ResetUserPasswordRequest represents the admin reset password request structure
+type ResetUserPasswordRequest struct {
+	NewPassword string `json:"new_password" binding:"required,min=6"`
+}
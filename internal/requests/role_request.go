@@ -0,0 +1,14 @@
+package requests
+
+// CreateRoleRequest represents the create custom role request structure
+type CreateRoleRequest struct {
+	Name        string   `json:"name" binding:"required,min=1,max=50"`
+	Description string   `json:"description" binding:"omitempty,max=255"`
+	Permissions []string `json:"permissions" binding:"required,min=1"`
+}
+
+// UpdateRoleRequest represents the update custom role request structure
+type UpdateRoleRequest struct {
+	Description *string  `json:"description" binding:"omitempty,max=255"`
+	Permissions []string `json:"permissions" binding:"omitempty,min=1"`
+}
@@ -0,0 +1,57 @@
+package requests
+
+// RefreshTokenRequest represents the refresh token request structure
+type RefreshTokenRequest struct {
+	RefreshToken string `json:"refresh_token" binding:"required"`
+}
+
+// LogoutRequest represents the logout request structure
+type LogoutRequest struct {
+	RefreshToken string `json:"refresh_token"`
+	AllSessions  bool   `json:"all_sessions"` // Also ends the user's sessions on every other device
+}
+
+// ChangePasswordRequest represents the change password request structure
+type ChangePasswordRequest struct {
+	CurrentPassword string `json:"current_password" binding:"required"`
+	NewPassword     string `json:"new_password" binding:"required,min=6"`
+}
+
+// ForgotPasswordRequest represents the forgot password and resend verification request structure
+type ForgotPasswordRequest struct {
+	Email string `json:"email" binding:"required,email"`
+}
+
+// ResetPasswordRequest represents the reset password request structure
+type ResetPasswordRequest struct {
+	Token       string `json:"token" binding:"required"`
+	NewPassword string `json:"new_password" binding:"required,min=6"`
+}
+
+// VerifyEmailRequest represents the verify email request structure
+type VerifyEmailRequest struct {
+	Token string `json:"token" binding:"required"`
+}
+
+// TwoFactorChallengeRequest represents the request that starts authenticator setup during a login
+type TwoFactorChallengeRequest struct {
+	ChallengeToken string `json:"challenge_token" binding:"required"`
+}
+
+// VerifyTwoFactorRequest represents the second step of a two-factor login. Either a code
+// from the authenticator app or a recovery code is required.
+type VerifyTwoFactorRequest struct {
+	ChallengeToken string `json:"challenge_token" binding:"required"`
+	Code           string `json:"code" binding:"required_without=RecoveryCode,omitempty,numeric,len=6"`
+	RecoveryCode   string `json:"recovery_code" binding:"required_without=Code,omitempty,max=20"`
+}
+
+// TwoFactorCodeRequest represents a request confirmed with a code from the authenticator app
+type TwoFactorCodeRequest struct {
+	Code string `json:"code" binding:"required,numeric,len=6"`
+}
+
+// SecuritySettingsRequest represents the restaurant security settings update request structure
+type SecuritySettingsRequest struct {
+	TwoFactorRoles []string `json:"two_factor_roles" binding:"required,dive,min=1,max=50"`
+}
@@ -0,0 +1,24 @@
+package requests
+
+// CreateTaxRuleRequest represents the create tax rule request structure
+type CreateTaxRuleRequest struct {
+	Name          string `json:"name" binding:"required,min=1,max=255"`
+	LocationID    string `json:"location_id" binding:"omitempty,max=255"`
+	Percentage    string `json:"percentage" binding:"required,numeric"`
+	InclusionType string `json:"inclusion_type" binding:"omitempty,oneof=ADDITIVE INCLUSIVE"`
+	Enabled       *bool  `json:"enabled" binding:"omitempty"`
+	PushToSquare  bool   `json:"push_to_square"`
+}
+
+// CreateServiceChargeRuleRequest represents the create service charge rule request structure
+type CreateServiceChargeRuleRequest struct {
+	Name             string `json:"name" binding:"required,min=1,max=255"`
+	LocationID       string `json:"location_id" binding:"omitempty,max=255"`
+	Percentage       string `json:"percentage" binding:"required_without=Amount,omitempty,numeric"`
+	Amount           int64  `json:"amount" binding:"required_without=Percentage,omitempty,min=0"`
+	CalculationPhase string `json:"calculation_phase" binding:"omitempty,oneof=SUBTOTAL_PHASE TOTAL_PHASE"`
+	Taxable          bool   `json:"taxable"`
+	Enabled          *bool  `json:"enabled" binding:"omitempty"`
+	MinGuestCount    int    `json:"min_guest_count" binding:"omitempty,min=0"`
+	OrderType        string `json:"order_type" binding:"omitempty,oneof=dine_in takeout delivery"`
+}
@@ -1,7 +1,10 @@
 package routes
 
 import (
+	"square-pos-integration/internal/apperrors"
+	"square-pos-integration/internal/authz"
 	"square-pos-integration/internal/controllers"
+	"square-pos-integration/internal/metrics"
 	"square-pos-integration/internal/middleware"
 	"square-pos-integration/internal/service"
 	"square-pos-integration/internal/config"
@@ -9,12 +12,58 @@ import (
 	"gorm.io/gorm"
 )
 
-// SetupRoutes configures auth and order routes
-func SetupRoutes(router *gin.Engine, db *gorm.DB, appCfg *config.AppConfig) {
+// SetupRoutes configures auth and order routes, with services set up as cfg says and
+// tokens, PINs and stored secrets protected by keys
+func SetupRoutes(router *gin.Engine, db *gorm.DB, cfg *config.Config, keys service.Keys) {
 	squareService := service.NewSquareService(db)
-	authController := controllers.NewAuthController(db, squareService)
+	squareService.BaseURL = cfg.Square.URL()
+	squareService.APIVersion = cfg.Square.APIVersion
+	squareService.Timeout = cfg.Square.Timeout
+	squareService.MaxAttempts = uint(cfg.Square.MaxAttempts)
+	// Shared by login and admin unlock so both see the same attempt counters
+	loginGuard := service.NewLoginGuard(db, cfg.Security.LoginAttempts(db))
+	sessions := service.NewSessionService(db, keys.Signing)
+	twoFactor := service.NewTwoFactorService(db, service.NewAccountService(db, cfg.Mail.Mailer(), sessions), keys.Secrets)
+	authController := controllers.NewAuthController(service.NewAuthService(db, squareService, twoFactor, loginGuard))
+	authController.Accounts.BaseURL = cfg.Server.BaseURL
+	authController.TwoFactor.Issuer = cfg.Security.TOTPIssuer
 	orderController := controllers.NewOrderController(db, squareService)
 	paymentController:= controllers.NewPaymentController(db, squareService)
+	taxController := controllers.NewTaxController(db, squareService)
+	serviceChargeController := controllers.NewServiceChargeController(db)
+	userController := controllers.NewUserController(db, loginGuard, authController.TwoFactor)
+	deviceController := controllers.NewDeviceController(service.NewDeviceService(db, twoFactor, keys.PINs, keys.Signing), userController.Users)
+	securityController := controllers.NewSecurityController(db, authController.TwoFactor)
+	jwksController := controllers.NewJWKSController(keys.Signing)
+	roleController := controllers.NewRoleController(db)
+	membershipController := controllers.NewMembershipController(db)
+	locationController := controllers.NewLocationController(db, squareService)
+
+	// Give every request an ID and a logger carrying it, and log each request once handled
+	router.Use(middleware.RequestID(), middleware.RequestLogger())
+	// Time requests by route, with the status ErrorHandler renders
+	if cfg.Metrics.Enabled {
+		metrics.LimitRestaurants(cfg.Metrics.RestaurantLabels)
+		router.Use(middleware.Metrics())
+	}
+
+	// Render errors attached by handlers and middleware as {error, code, details}
+	router.Use(middleware.ErrorHandler())
+	router.NoRoute(func(c *gin.Context) {
+		c.Error(apperrors.ErrRouteNotFound)
+	})
+
+	// Request, database and Square metrics for Prometheus, optionally behind a bearer token
+	if cfg.Metrics.Enabled {
+		metricsHandlers := []gin.HandlerFunc{gin.WrapH(metrics.Handler())}
+		if cfg.Metrics.Token != "" {
+			metricsHandlers = append([]gin.HandlerFunc{middleware.StaticToken(cfg.Metrics.Token)}, metricsHandlers...)
+		}
+		router.GET("/metrics", metricsHandlers...)
+	}
+
+	// Public keys for verifying access tokens
+	router.GET("/.well-known/jwks.json", jwksController.GetJWKS)
 
 	// API versioning
 	v1 := router.Group("/api/v1")
@@ -24,32 +73,104 @@ func SetupRoutes(router *gin.Engine, db *gorm.DB, appCfg *config.AppConfig) {
 		{
 			public.POST("/register-restaurant", authController.RegisterRestaurant)
 			public.POST("/login", authController.Login)
+			public.POST("/auth/refresh", authController.RefreshToken)
+			public.POST("/auth/forgot-password", authController.ForgotPassword)
+			public.POST("/auth/reset-password", authController.ResetPassword)
+			public.POST("/auth/verify-email", authController.VerifyEmail)
+			public.POST("/auth/resend-verification", authController.ResendVerification)
+			public.POST("/auth/pin-login", deviceController.PinLogin)
+			public.POST("/auth/2fa/setup", authController.SetupTwoFactor)
+			public.POST("/auth/2fa/verify", authController.VerifyTwoFactor)
 		}
 
 		// Protected routes (require authentication)
 		protected := v1.Group("/")
-		protected.Use(middleware.JWTMiddleware(db))
+		protected.Use(middleware.JWTMiddleware(sessions))
 		protected.Use(middleware.MultiTenantMiddleware(db))
+		protected.Use(middleware.LoadPermissions(db))
 		{
 			protected.GET("/profile", authController.GetProfile)
+			protected.POST("/auth/logout", authController.Logout)
+			protected.POST("/auth/change-password", authController.ChangePassword)
+			protected.POST("/auth/switch-restaurant", authController.SwitchRestaurant)
+			protected.POST("/auth/2fa/enroll", authController.EnrollTwoFactor)
+			protected.POST("/auth/2fa/activate", authController.ActivateTwoFactor)
+			protected.POST("/auth/2fa/disable", authController.DisableTwoFactor)
+			protected.POST("/auth/2fa/recovery-codes", authController.RegenerateRecoveryCodes)
+
+			// Locations the current user can take orders at
+			protected.GET("/locations", locationController.ListMyLocations)
 			
 			// Order routes
-			protected.POST("/orders", orderController.CreateOrder)
-			protected.GET("/orders/table/:table_number", orderController.GetOrderByTableNumber)
-			protected.GET("/orders/:id", orderController.GetOrderByID)
+			protected.POST("/orders", middleware.RequirePermission(authz.OrdersCreate), orderController.CreateOrder)
+			protected.POST("/orders/preview", middleware.RequirePermission(authz.OrdersCreate), orderController.PreviewOrder)
+			protected.GET("/orders/table/:table_number", middleware.RequirePermission(authz.OrdersView), orderController.GetOrderByTableNumber)
+			protected.GET("/orders/:id", middleware.RequirePermission(authz.OrdersView), orderController.GetOrderByID)
+			protected.POST("/orders/:id/cancel", middleware.RequirePermission(authz.OrdersCancel), orderController.CancelOrder)
 
 			// Payment routes
-			protected.POST("/payment/:id/payment-intent", paymentController.CreatePaymentIntent)
+			protected.POST("/payment/:id/payment-intent", middleware.RequirePermission(authz.PaymentsProcess), paymentController.CreatePaymentIntent)
 			// protected.POST("/payment/:id/complete", paymentController.CompletePayment)
-			protected.POST("/payment/complete", paymentController.CompletePayment)
+			protected.POST("/payment/complete", middleware.RequirePermission(authz.PaymentsProcess), paymentController.CompletePayment)
+			protected.POST("/payment/:id/refund", middleware.RequirePermission(authz.PaymentsRefund), paymentController.RefundPayment)
 
 			
-			// Admin only routes
+			// Administration routes, each group guarded by its permission
 			admin := protected.Group("/admin")
-			admin.Use(middleware.RoleMiddleware("admin"))
 			{
-				admin.POST("/users", authController.Register)
+				users := admin.Group("/users", middleware.RequirePermission(authz.UsersManage))
+				users.GET("", userController.ListUsers)
+				users.POST("", authController.Register)
+				users.GET("/:id", userController.GetUser)
+				users.PATCH("/:id", userController.UpdateUser)
+				users.POST("/:id/deactivate", userController.DeactivateUser)
+				users.POST("/:id/reset-password", userController.ResetPassword)
+				users.POST("/:id/unlock", userController.UnlockUser)
+				users.POST("/:id/2fa/reset", userController.ResetTwoFactor)
+				users.PUT("/:id/pin", deviceController.SetUserPin)
+
+				// Users of other restaurants with access to this one
+				members := admin.Group("/members", middleware.RequirePermission(authz.UsersManage))
+				members.GET("", membershipController.ListMembers)
+				members.POST("", membershipController.AddMember)
+				members.PATCH("/:user_id", membershipController.UpdateMember)
+				members.DELETE("/:user_id", membershipController.RemoveMember)
+
+				roles := admin.Group("/roles", middleware.RequirePermission(authz.RolesManage))
+				roles.GET("", roleController.ListRoles)
+				roles.POST("", roleController.CreateRole)
+				roles.PATCH("/:id", roleController.UpdateRole)
+				roles.DELETE("/:id", roleController.DeleteRole)
+
+				// Shared POS devices
+				devices := admin.Group("/devices", middleware.RequirePermission(authz.DevicesManage))
+				devices.GET("", deviceController.ListDevices)
+				devices.POST("", deviceController.EnrollDevice)
+				devices.DELETE("/:id", deviceController.RevokeDevice)
+
+				// Tax and service charge configuration
+				settings := admin.Group("/", middleware.RequirePermission(authz.SettingsManage))
+				settings.GET("/taxes", taxController.ListTaxRules)
+				settings.POST("/taxes", taxController.CreateTaxRule)
+				settings.POST("/taxes/sync", taxController.SyncTaxRules)
+				settings.POST("/taxes/:id/push", taxController.PushTaxRule)
+				settings.DELETE("/taxes/:id", taxController.DeleteTaxRule)
+				settings.GET("/service-charges", serviceChargeController.ListServiceCharges)
+				settings.POST("/service-charges", serviceChargeController.CreateServiceCharge)
+				settings.DELETE("/service-charges/:id", serviceChargeController.DeleteServiceCharge)
+				settings.GET("/locations", locationController.ListLocations)
+				settings.POST("/locations/sync", locationController.SyncLocations)
+				settings.GET("/security", securityController.GetSecuritySettings)
+				settings.PUT("/security", securityController.UpdateSecuritySettings)
 			}
 		}
 	}
-}
\ No newline at end of file
+}
+
+// SetupHealthRoutes adds the liveness and readiness probes. They are not versioned and
+// need no authentication.
+func SetupHealthRoutes(router *gin.Engine, health *service.HealthService) {
+	healthController := controllers.NewHealthController(health)
+	router.GET("/healthz", healthController.Healthz)
+	router.GET("/readyz", healthController.Readyz)
+}
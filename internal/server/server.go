@@ -0,0 +1,104 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	"square-pos-integration/internal/config"
+)
+
+// Worker is background work, such as signing key rotation, that runs until stop is
+// closed. Work in progress when stop closes is finished before returning.
+type Worker func(stop <-chan struct{})
+
+// Server runs the HTTP server and the background workers, and stops both gracefully
+type Server struct {
+	HTTP    *http.Server
+	Workers []Worker
+	// ShutdownTimeout is how long shutdown waits for running requests, and then as long
+	// again for the workers
+	ShutdownTimeout time.Duration
+	// OnShutdown is called when shutdown starts, before the server stops accepting requests
+	OnShutdown func()
+}
+
+// New returns a server for the handler with the listen address and timeouts of cfg
+func New(cfg config.ServerConfig, handler http.Handler) *Server {
+	return &Server{
+		HTTP: &http.Server{
+			Addr:              fmt.Sprintf(":%d", cfg.Port),
+			Handler:           handler,
+			ReadHeaderTimeout: cfg.ReadHeaderTimeout,
+			ReadTimeout:       cfg.ReadTimeout,
+			WriteTimeout:      cfg.WriteTimeout,
+			IdleTimeout:       cfg.IdleTimeout,
+		},
+		ShutdownTimeout: cfg.ShutdownTimeout,
+	}
+}
+
+// Run listens on the server's address and serves until ctx is done, see Serve
+func (s *Server) Run(ctx context.Context) error {
+	listener, err := net.Listen("tcp", s.HTTP.Addr)
+	if err != nil {
+		return err
+	}
+	return s.Serve(ctx, listener)
+}
+
+// Serve starts the workers and serves requests from the listener until ctx is done, for
+// example by SIGTERM. It then stops accepting connections, waits for running requests and
+// stops the workers, each for up to ShutdownTimeout. An error means the server failed or
+// work was cut off.
+func (s *Server) Serve(ctx context.Context, listener net.Listener) error {
+	stop := make(chan struct{})
+	var workers sync.WaitGroup
+	for _, worker := range s.Workers {
+		workers.Add(1)
+		go func(worker Worker) {
+			defer workers.Done()
+			worker(stop)
+		}(worker)
+	}
+
+	served := make(chan error, 1)
+	go func() { served <- s.HTTP.Serve(listener) }()
+
+	var err error
+	select {
+	case err = <-served:
+		err = fmt.Errorf("server failed: %w", err)
+	case <-ctx.Done():
+		slog.Info("Shutting down, waiting for running requests", "timeout", s.ShutdownTimeout)
+		if s.OnShutdown != nil {
+			s.OnShutdown()
+		}
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), s.ShutdownTimeout)
+		defer cancel()
+		if shutdownErr := s.HTTP.Shutdown(shutdownCtx); shutdownErr != nil {
+			err = fmt.Errorf("requests still running after %s: %w", s.ShutdownTimeout, shutdownErr)
+		}
+		if serveErr := <-served; !errors.Is(serveErr, http.ErrServerClosed) && err == nil {
+			err = serveErr
+		}
+	}
+
+	close(stop)
+	stopped := make(chan struct{})
+	go func() {
+		workers.Wait()
+		close(stopped)
+	}()
+	select {
+	case <-stopped:
+	case <-time.After(s.ShutdownTimeout):
+		err = errors.Join(err, fmt.Errorf("background workers still running after %s", s.ShutdownTimeout))
+	}
+	return err
+}
@@ -0,0 +1,194 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"time"
+
+	"gorm.io/gorm"
+
+	"square-pos-integration/internal/apperrors"
+	"square-pos-integration/internal/mailer"
+	appModels "square-pos-integration/internal/models"
+	"square-pos-integration/internal/utils"
+)
+
+// PasswordResetExpiration is how long a password reset link can be used
+const PasswordResetExpiration = time.Hour
+
+// EmailVerificationExpiration is how long an email verification link can be used
+const EmailVerificationExpiration = 48 * time.Hour
+
+// AccountService runs the emailed password reset and email verification flows
+type AccountService struct {
+	DB       *gorm.DB
+	Mailer   mailer.Mailer
+	Sessions *SessionService
+	BaseURL  string // Where the links in emails point
+}
+
+func NewAccountService(db *gorm.DB, mail mailer.Mailer, sessions *SessionService) *AccountService {
+	return &AccountService{DB: db, Mailer: mail, Sessions: sessions, BaseURL: "http://localhost:8080"}
+}
+
+// RequestPasswordReset emails a reset link when an active user has the address. It does
+// not report whether the address exists so the endpoint cannot be used to find accounts.
+func (as *AccountService) RequestPasswordReset(email string) error {
+	var user appModels.User
+	err := as.DB.Where("email = ?", email).First(&user).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if !user.IsActive {
+		return nil
+	}
+
+	token, err := as.issueToken(user.ID, appModels.TokenPurposePasswordReset, PasswordResetExpiration)
+	if err != nil {
+		return err
+	}
+
+	return as.Mailer.Send(mailer.Message{
+		To:      user.Email,
+		Subject: "Reset your password",
+		Body: fmt.Sprintf("We received a request to reset your password.\n\n"+
+			"Reset it here within the next hour:\n%s\n\n"+
+			"If you did not ask for this, you can ignore this email.\n",
+			as.link("/reset-password", token)),
+	})
+}
+
+// ResetPassword sets a new password using an emailed reset token and ends all of the user's sessions
+func (as *AccountService) ResetPassword(token, newPassword string) error {
+	record, err := as.consumeToken(token, appModels.TokenPurposePasswordReset)
+	if errors.Is(err, errTokenUnusable) {
+		return apperrors.ErrInvalidResetToken
+	}
+	if err != nil {
+		return err
+	}
+
+	hashedPassword, err := utils.HashPassword(newPassword)
+	if err != nil {
+		return err
+	}
+	if err := as.DB.Model(&appModels.User{}).Where("id = ?", record.UserID).Update("password_hash", hashedPassword).Error; err != nil {
+		return err
+	}
+	return as.Sessions.RevokeAllSessions(record.UserID)
+}
+
+// SendEmailVerification emails a verification link to a user whose address is not verified yet
+func (as *AccountService) SendEmailVerification(user appModels.User) error {
+	token, err := as.issueToken(user.ID, appModels.TokenPurposeEmailVerification, EmailVerificationExpiration)
+	if err != nil {
+		return err
+	}
+
+	return as.Mailer.Send(mailer.Message{
+		To:      user.Email,
+		Subject: "Verify your email address",
+		Body: fmt.Sprintf("Welcome! Please confirm your email address to start using your account:\n%s\n\n"+
+			"The link expires in 48 hours.\n",
+			as.link("/verify-email", token)),
+	})
+}
+
+// ResendVerification sends a new verification link when the address belongs to an unverified user
+func (as *AccountService) ResendVerification(email string) error {
+	var user appModels.User
+	err := as.DB.Where("email = ? AND email_verification_pending = ?", email, true).First(&user).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	return as.SendEmailVerification(user)
+}
+
+// VerifyEmail marks the user's email address as verified using an emailed token
+func (as *AccountService) VerifyEmail(token string) error {
+	record, err := as.consumeToken(token, appModels.TokenPurposeEmailVerification)
+	if errors.Is(err, errTokenUnusable) {
+		return apperrors.ErrInvalidVerificationToken
+	}
+	if err != nil {
+		return err
+	}
+
+	return as.DB.Model(&appModels.User{}).Where("id = ?", record.UserID).Updates(map[string]interface{}{
+		"email_verification_pending": false,
+		"email_verified_at":          time.Now(),
+	}).Error
+}
+
+// issueToken stores a new single-use token for the user, retiring earlier unused tokens for the same purpose
+func (as *AccountService) issueToken(userID uint, purpose string, expiration time.Duration) (string, error) {
+	token, tokenHash, err := utils.GenerateOpaqueToken()
+	if err != nil {
+		return "", err
+	}
+
+	err = as.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&appModels.UserToken{}).
+			Where("user_id = ? AND purpose = ? AND used_at IS NULL", userID, purpose).
+			Update("used_at", time.Now()).Error; err != nil {
+			return err
+		}
+		return tx.Create(&appModels.UserToken{
+			UserID:    userID,
+			Purpose:   purpose,
+			TokenHash: tokenHash,
+			ExpiresAt: time.Now().Add(expiration),
+		}).Error
+	})
+	return token, err
+}
+
+// errTokenUnusable is returned by consumeToken for unknown, expired or already used tokens
+var errTokenUnusable = errors.New("token is unknown, expired or already used")
+
+// consumeToken marks a token as used. Only one request can use a token even when two race.
+func (as *AccountService) consumeToken(token, purpose string) (appModels.UserToken, error) {
+	record, err := as.findToken(token, purpose)
+	if err != nil {
+		return record, err
+	}
+
+	result := as.DB.Model(&appModels.UserToken{}).
+		Where("id = ? AND used_at IS NULL", record.ID).
+		Update("used_at", time.Now())
+	if result.Error != nil {
+		return record, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return record, errTokenUnusable
+	}
+	return record, nil
+}
+
+// findToken returns an unused, unexpired token without using it up
+func (as *AccountService) findToken(token, purpose string) (appModels.UserToken, error) {
+	var record appModels.UserToken
+	err := as.DB.Where("token_hash = ? AND purpose = ?", utils.HashToken(token), purpose).First(&record).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return record, errTokenUnusable
+	}
+	if err != nil {
+		return record, err
+	}
+	if record.UsedAt != nil || time.Now().After(record.ExpiresAt) {
+		return record, errTokenUnusable
+	}
+	return record, nil
+}
+
+// link builds an absolute link to a frontend page carrying the token
+func (as *AccountService) link(path, token string) string {
+	return as.BaseURL + path + "?token=" + url.QueryEscape(token)
+}
@@ -0,0 +1,301 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+
+	"square-pos-integration/internal/apperrors"
+	"square-pos-integration/internal/logging"
+	appModels "square-pos-integration/internal/models"
+	"square-pos-integration/internal/repository"
+	"square-pos-integration/internal/requests"
+	"square-pos-integration/internal/utils"
+)
+
+// IAuthService starts sessions and registers restaurants
+type IAuthService interface {
+	Login(ctx context.Context, email, password, clientIP string) (LoginResult, error)
+	VerifyTwoFactor(ctx context.Context, challengeToken, code, recoveryCode, clientIP string) (LoginResult, error)
+	SwitchRestaurant(ctx context.Context, user appModels.User, restaurantID uint) (LoginResult, error)
+	RegisterRestaurant(ctx context.Context, restaurantRequest requests.RegisterRestaurantRequest) (appModels.Restaurant, appModels.User, error)
+	CurrentUser(ctx context.Context, userID uint, membership appModels.Membership) (appModels.User, error)
+	ChangePassword(ctx context.Context, userID uint, currentPassword, newPassword string) error
+}
+
+// LoginResult is a started session, or the two-factor challenge to complete first
+type LoginResult struct {
+	User          appModels.User
+	Memberships   []appModels.Membership
+	Tokens        TokenPair
+	Challenge     *TwoFactorChallenge
+	RecoveryCodes []string
+}
+
+// TwoFactorChallenge is handed out instead of tokens when a login needs a second factor
+type TwoFactorChallenge struct {
+	Token              string
+	ExpiresAt          time.Time
+	EnrollmentRequired bool
+}
+
+type AuthService struct {
+	DB          *gorm.DB
+	Users       repository.UserRepository
+	Restaurants repository.RestaurantRepository
+	Accounts    *AccountService
+	UserService *UserService
+	Sessions    *SessionService
+	Memberships *MembershipService
+	Locations   *LocationService
+	TwoFactor   *TwoFactorService
+	LoginGuard  *LoginGuard
+	Square      ISquareService
+}
+
+func NewAuthService(db *gorm.DB, squareService ISquareService, twoFactor *TwoFactorService, loginGuard *LoginGuard) *AuthService {
+	return &AuthService{
+		DB:          db,
+		Users:       repository.NewUserRepository(db),
+		Restaurants: repository.NewRestaurantRepository(db),
+		Accounts:    twoFactor.Accounts,
+		UserService: NewUserService(db, twoFactor.Sessions),
+		Sessions:    twoFactor.Sessions,
+		Memberships: NewMembershipService(db),
+		Locations:   NewLocationService(db),
+		TwoFactor:   twoFactor,
+		LoginGuard:  loginGuard,
+		Square:      squareService,
+	}
+}
+
+// Login checks the credentials of a user. Failed attempts are throttled and locked out per
+// account and per client IP by the login guard. The session starts in the user's home
+// restaurant and lists every restaurant they can switch to.
+func (as *AuthService) Login(ctx context.Context, email, password, clientIP string) (LoginResult, error) {
+	if err := as.LoginGuard.Check(ctx, email, clientIP); err != nil {
+		return LoginResult{}, err
+	}
+
+	user, err := as.Users.FindByEmail(email)
+	if err != nil {
+		as.recordLoginFailure(ctx, nil, email, clientIP)
+		return LoginResult{}, apperrors.ErrInvalidCredentials
+	}
+	if !utils.VerifyPassword(user.PasswordHash, password) {
+		as.recordLoginFailure(ctx, &user, email, clientIP)
+		return LoginResult{}, apperrors.ErrInvalidCredentials
+	}
+
+	if !user.IsActive {
+		return LoginResult{}, apperrors.ErrAccountDisabled
+	}
+	if user.EmailVerificationPending {
+		return LoginResult{}, apperrors.ErrEmailNotVerified
+	}
+
+	user, memberships, err := as.startMembership(user)
+	if err != nil {
+		return LoginResult{}, err
+	}
+
+	// Users with two-factor authentication, or whose role requires it, get a challenge
+	// to complete with VerifyTwoFactor instead of tokens
+	if TwoFactorEnabled(user) || as.TwoFactor.Required(user) {
+		challengeToken, expiresAt, err := as.TwoFactor.StartChallenge(user)
+		if err != nil {
+			return LoginResult{}, apperrors.ErrInternal.Wrap(err)
+		}
+		return LoginResult{User: user, Challenge: &TwoFactorChallenge{
+			Token:              challengeToken,
+			ExpiresAt:          expiresAt,
+			EnrollmentRequired: !TwoFactorEnabled(user),
+		}}, nil
+	}
+
+	return as.startSession(ctx, user, memberships, clientIP)
+}
+
+// VerifyTwoFactor completes a login with a code from the authenticator app or a recovery
+// code. Wrong codes count as failed logins.
+func (as *AuthService) VerifyTwoFactor(ctx context.Context, challengeToken, code, recoveryCode, clientIP string) (LoginResult, error) {
+	user, err := as.TwoFactor.ChallengeUser(challengeToken)
+	if err != nil {
+		return LoginResult{}, err
+	}
+	if err := as.LoginGuard.Check(ctx, user.Email, clientIP); err != nil {
+		return LoginResult{}, err
+	}
+
+	recoveryCodes, err := as.TwoFactor.CompleteChallenge(challengeToken, &user, code, recoveryCode)
+	if errors.Is(err, apperrors.ErrInvalidTwoFactorCode) {
+		as.recordLoginFailure(ctx, &user, user.Email, clientIP)
+	}
+	if err != nil {
+		return LoginResult{}, err
+	}
+
+	user, memberships, err := as.startMembership(user)
+	if err != nil {
+		return LoginResult{}, err
+	}
+
+	result, err := as.startSession(ctx, user, memberships, clientIP)
+	result.RecoveryCodes = recoveryCodes
+	return result, err
+}
+
+// SwitchRestaurant issues tokens for another restaurant the user is a member of. The
+// current tokens stay valid for the restaurant they were issued for.
+func (as *AuthService) SwitchRestaurant(ctx context.Context, user appModels.User, restaurantID uint) (LoginResult, error) {
+	membership, err := as.Memberships.FindMembership(user.ID, restaurantID)
+	if err != nil {
+		return LoginResult{}, err
+	}
+	user = ActAs(user, membership)
+
+	// A restaurant requiring two-factor authentication for the role cannot be entered
+	// by switching until the user has set it up
+	if as.TwoFactor.Required(user) && !TwoFactorEnabled(user) {
+		return LoginResult{}, apperrors.ErrTwoFactorRequired
+	}
+
+	memberships, err := as.Memberships.ListMemberships(user.ID)
+	if err != nil {
+		return LoginResult{}, apperrors.ErrInternal.Wrap(err)
+	}
+	tokens, err := as.Sessions.IssueTokens(user)
+	if err != nil {
+		return LoginResult{}, apperrors.ErrTokenIssueFail.Wrap(err)
+	}
+	return LoginResult{User: user, Memberships: memberships, Tokens: tokens}, nil
+}
+
+// RegisterRestaurant creates a restaurant for a Square account with its admin user, who
+// can log in once they verify their email. The restaurant and the admin are created
+// together, so a failure leaves no restaurant behind that nobody can log in to.
+func (as *AuthService) RegisterRestaurant(ctx context.Context, restaurantRequest requests.RegisterRestaurantRequest) (appModels.Restaurant, appModels.User, error) {
+	if err := as.UserService.EnsureUnique(restaurantRequest.UserName, restaurantRequest.AdminEmail, 0); err != nil {
+		return appModels.Restaurant{}, appModels.User{}, err
+	}
+
+	locationID, err := as.Square.FetchLocationID(ctx, restaurantRequest.SquareToken)
+	if err != nil {
+		return appModels.Restaurant{}, appModels.User{}, apperrors.ErrSquareLocationUnavailable.Wrap(err)
+	}
+
+	hashedPassword, err := utils.HashPassword(restaurantRequest.AdminPassword)
+	if err != nil {
+		return appModels.Restaurant{}, appModels.User{}, apperrors.ErrInternal.Wrap(err)
+	}
+
+	restaurant := appModels.Restaurant{
+		Name:        restaurantRequest.Name,
+		SquareAppID: restaurantRequest.SquareAppID,
+		SquareToken: restaurantRequest.SquareToken,
+		LocationID:  locationID,
+	}
+	adminUser := appModels.User{
+		Username:     restaurantRequest.UserName,
+		Email:        restaurantRequest.AdminEmail,
+		PasswordHash: hashedPassword,
+		Role:         "admin",
+		IsActive:     true,
+
+		// The admin can log in once they follow the link in the verification email
+		EmailVerificationPending: true,
+	}
+	err = as.DB.Transaction(func(tx *gorm.DB) error {
+		if err := repository.NewRestaurantRepository(tx).Create(&restaurant); err != nil {
+			if strings.Contains(err.Error(), "square_app_id") {
+				return apperrors.ErrSquareAppAlreadyRegistered.Wrap(err)
+			}
+			return err
+		}
+		adminUser.RestaurantID = restaurant.ID
+		return NewUserService(tx, as.Sessions).CreateUser(&adminUser)
+	})
+	if err != nil {
+		return appModels.Restaurant{}, appModels.User{}, apperrors.From(err)
+	}
+
+	// Orders can be taken at the registered location right away, the other locations and
+	// the details come from Square. A failed sync can be retried from the admin API.
+	if err := as.Locations.EnsurePrimaryLocation(restaurant); err != nil {
+		logging.FromContext(ctx).Error("Failed to record primary location", "subject_restaurant_id", restaurant.ID, "error", err)
+	}
+	if _, err := as.Square.SyncLocations(ctx, restaurant.ID); err != nil {
+		logging.FromContext(ctx).Warn("Location sync failed", "subject_restaurant_id", restaurant.ID, "error", err)
+	}
+
+	// A failed email is not fatal, the admin can ask for a new link
+	if err := as.Accounts.SendEmailVerification(adminUser); err != nil {
+		logging.FromContext(ctx).Error("Failed to send verification email", "subject_user_id", adminUser.ID, "error", err)
+	}
+
+	return restaurant, adminUser, nil
+}
+
+// CurrentUser loads an authenticated user acting in the restaurant of their membership with
+// its role
+func (as *AuthService) CurrentUser(ctx context.Context, userID uint, membership appModels.Membership) (appModels.User, error) {
+	user, err := as.Users.FindByID(userID)
+	if err != nil {
+		return user, apperrors.ErrUserNotFound.Wrap(err)
+	}
+	return ActAs(user, membership), nil
+}
+
+// ChangePassword replaces a user's password after checking the current one, and ends all
+// of their sessions
+func (as *AuthService) ChangePassword(ctx context.Context, userID uint, currentPassword, newPassword string) error {
+	user, err := as.Users.FindByID(userID)
+	if err != nil {
+		return apperrors.ErrUserNotFound.Wrap(err)
+	}
+	if !utils.VerifyPassword(user.PasswordHash, currentPassword) {
+		return apperrors.ErrInvalidCredentials
+	}
+	if err := as.UserService.setPassword(&user, newPassword); err != nil {
+		return apperrors.ErrInternal.Wrap(err)
+	}
+	return nil
+}
+
+// startMembership returns the user acting in the restaurant a login starts in, with all of
+// their active memberships
+func (as *AuthService) startMembership(user appModels.User) (appModels.User, []appModels.Membership, error) {
+	memberships, err := as.Memberships.ListMemberships(user.ID)
+	if err != nil {
+		return user, nil, apperrors.ErrInternal.Wrap(err)
+	}
+	membership, err := DefaultMembership(user, memberships)
+	if err != nil {
+		return user, nil, err
+	}
+	return ActAs(user, membership), memberships, nil
+}
+
+// startSession issues an access token and starts a refresh token family for a login
+func (as *AuthService) startSession(ctx context.Context, user appModels.User, memberships []appModels.Membership, clientIP string) (LoginResult, error) {
+	tokens, err := as.Sessions.IssueTokens(user)
+	if err != nil {
+		return LoginResult{}, apperrors.ErrTokenIssueFail.Wrap(err)
+	}
+
+	if err := as.LoginGuard.RecordSuccess(ctx, user, clientIP); err != nil {
+		logging.FromContext(ctx).Error("Failed to clear login attempts", "subject_user_id", user.ID, "error", err)
+	}
+	return LoginResult{User: user, Memberships: memberships, Tokens: tokens}, nil
+}
+
+// recordLoginFailure counts a failed login. The client still gets INVALID_CREDENTIALS if
+// the attempt store fails.
+func (as *AuthService) recordLoginFailure(ctx context.Context, user *appModels.User, email, clientIP string) {
+	if err := as.LoginGuard.RecordFailure(ctx, user, email, clientIP); err != nil {
+		logging.FromContext(ctx).Error("Failed to record login failure", "email", email, "client_ip", clientIP, "error", err)
+	}
+}
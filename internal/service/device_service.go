@@ -0,0 +1,237 @@
+package service
+
+import (
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+
+	"square-pos-integration/internal/apperrors"
+	appModels "square-pos-integration/internal/models"
+	"square-pos-integration/internal/signing"
+	"square-pos-integration/internal/tenant"
+	"square-pos-integration/internal/utils"
+)
+
+// MaxPinAttempts is how many wrong PINs a device or user may enter before being locked
+const MaxPinAttempts = 5
+
+// PinLockoutDuration is how long a device or user stays locked after too many wrong PINs
+const PinLockoutDuration = 15 * time.Minute
+
+// DeviceService enrolls shared POS devices and signs staff in on them with a PIN
+type DeviceService struct {
+	DB          *gorm.DB
+	Memberships *MembershipService
+	TwoFactor   *TwoFactorService
+	PINs        *utils.PINHasher
+	Keys        *signing.KeyRing // Signs the tokens of PIN logins
+}
+
+func NewDeviceService(db *gorm.DB, twoFactor *TwoFactorService, pins *utils.PINHasher, keys *signing.KeyRing) *DeviceService {
+	return &DeviceService{DB: db, Memberships: NewMembershipService(db), TwoFactor: twoFactor, PINs: pins, Keys: keys}
+}
+
+// ListDevices returns the devices enrolled for the restaurant
+func (ds *DeviceService) ListDevices(restaurantID uint) ([]appModels.Device, error) {
+	var devices []appModels.Device
+	err := tenant.Scoped(ds.DB, restaurantID).Order("id").Find(&devices).Error
+	return devices, err
+}
+
+// EnrollDevice registers a device for the restaurant and returns its credential.
+// The credential is only returned here, the database keeps its hash.
+func (ds *DeviceService) EnrollDevice(restaurantID uint, name string) (appModels.Device, string, error) {
+	credential, credentialHash, err := utils.GenerateOpaqueToken()
+	if err != nil {
+		return appModels.Device{}, "", err
+	}
+
+	device := appModels.Device{
+		RestaurantID:   restaurantID,
+		Name:           name,
+		CredentialHash: credentialHash,
+	}
+	if err := tenant.Scoped(ds.DB, restaurantID).Create(&device).Error; err != nil {
+		return appModels.Device{}, "", err
+	}
+	return device, credential, nil
+}
+
+// AuthenticateDevice returns the enrolled, unrevoked device the credential belongs to
+func (ds *DeviceService) AuthenticateDevice(credential string) (appModels.Device, error) {
+	if credential == "" {
+		return appModels.Device{}, apperrors.ErrInvalidDevice
+	}
+
+	// The credential is the only thing identifying the device, and so its restaurant
+	var device appModels.Device
+	err := tenant.System(ds.DB).Where("credential_hash = ?", utils.HashToken(credential)).First(&device).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return appModels.Device{}, apperrors.ErrInvalidDevice
+	}
+	if err != nil {
+		return appModels.Device{}, err
+	}
+	if device.RevokedAt != nil {
+		return appModels.Device{}, apperrors.ErrInvalidDevice
+	}
+	return device, nil
+}
+
+// RevokeDevice stops the device from signing anyone in and ends the PIN sessions started on it
+func (ds *DeviceService) RevokeDevice(restaurantID uint, deviceID string) error {
+	result := tenant.Scoped(ds.DB, restaurantID).Model(&appModels.Device{}).
+		Where("id = ? AND revoked_at IS NULL", deviceID).
+		Update("revoked_at", time.Now())
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return apperrors.ErrDeviceNotFound
+	}
+	return nil
+}
+
+// SetPin sets or replaces a user's PIN, which must be unique within their home restaurant.
+// The PIN signs them in on the devices of every restaurant they are an active member of.
+func (ds *DeviceService) SetPin(user *appModels.User, pin string) error {
+	if user.EmailVerificationPending {
+		return apperrors.ErrEmailNotVerified
+	}
+	if _, err := ds.Memberships.FindMembership(user.ID, user.RestaurantID); err != nil {
+		return err
+	}
+
+	pinHash := ds.PINs.Hash(user.RestaurantID, pin)
+
+	var taken int64
+	if err := ds.DB.Model(&appModels.User{}).
+		Where("restaurant_id = ? AND pin_hash = ? AND id <> ?", user.RestaurantID, pinHash, user.ID).
+		Count(&taken).Error; err != nil {
+		return err
+	}
+	if taken > 0 {
+		return apperrors.ErrPinAlreadyInUse
+	}
+
+	user.PinHash = &pinHash
+	return ds.DB.Model(user).Updates(map[string]interface{}{
+		"pin_hash":            pinHash,
+		"failed_pin_attempts": 0,
+		"pin_locked_until":    nil,
+	}).Error
+}
+
+// PinLogin signs a staff member in on the device, acting in the device's restaurant with
+// their membership of it. When userID is zero the user is identified by the PIN alone, which
+// only finds users whose home restaurant is the device's; members from other restaurants
+// name their user ID. Wrong PINs count against the device and, when known, the user, and
+// either is locked for PinLockoutDuration after MaxPinAttempts failures. A PIN is not a
+// second factor, so users with two-factor authentication, or whose role requires it, must
+// log in with their password.
+func (ds *DeviceService) PinLogin(device appModels.Device, userID uint, pin string) (appModels.User, string, error) {
+	now := time.Now()
+	if device.LockedUntil != nil && now.Before(*device.LockedUntil) {
+		return appModels.User{}, "", apperrors.ErrDeviceLocked
+	}
+
+	db := tenant.Scoped(ds.DB, device.RestaurantID)
+	query := ds.DB.Model(&appModels.User{})
+	if userID != 0 {
+		query = query.Where("id = ?", userID)
+	} else {
+		query = query.Where("restaurant_id = ? AND pin_hash = ?", device.RestaurantID, ds.PINs.Hash(device.RestaurantID, pin))
+	}
+
+	var user appModels.User
+	err := query.First(&user).Error
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return appModels.User{}, "", err
+	}
+	found := err == nil
+
+	// Users who are not members of the device's restaurant are treated as unknown
+	var membership appModels.Membership
+	if found {
+		membership, err = ds.Memberships.FindMembership(user.ID, device.RestaurantID)
+		if err != nil && !errors.Is(err, apperrors.ErrNotAMember) {
+			return appModels.User{}, "", err
+		}
+		found = err == nil
+	}
+
+	if found && user.PinLockedUntil != nil && now.Before(*user.PinLockedUntil) {
+		return appModels.User{}, "", apperrors.ErrPinLocked
+	}
+
+	// PINs are hashed with the user's home restaurant, see SetPin
+	if !found || user.PinHash == nil || !ds.PINs.Verify(user.RestaurantID, *user.PinHash, pin) {
+		if err := recordFailure(db, &appModels.Device{}, device.ID, "locked_until"); err != nil {
+			return appModels.User{}, "", err
+		}
+		if found {
+			if err := recordFailure(db, &appModels.User{}, user.ID, "pin_locked_until"); err != nil {
+				return appModels.User{}, "", err
+			}
+		}
+		return appModels.User{}, "", apperrors.ErrInvalidPin
+	}
+
+	if !user.IsActive {
+		return appModels.User{}, "", apperrors.ErrAccountDisabled
+	}
+	if user.EmailVerificationPending {
+		return appModels.User{}, "", apperrors.ErrEmailNotVerified
+	}
+	user = ActAs(user, membership)
+	if TwoFactorEnabled(user) || ds.TwoFactor.Required(user) {
+		return appModels.User{}, "", apperrors.ErrPinLoginNotAllowed
+	}
+
+	if err := db.Model(&appModels.Device{}).Where("id = ?", device.ID).Updates(map[string]interface{}{
+		"failed_pin_attempts": 0,
+		"locked_until":        nil,
+		"last_seen_at":        now,
+	}).Error; err != nil {
+		return appModels.User{}, "", err
+	}
+	if user.FailedPinAttempts > 0 {
+		if err := db.Model(&appModels.User{}).Where("id = ?", user.ID).Updates(map[string]interface{}{
+			"failed_pin_attempts": 0,
+			"pin_locked_until":    nil,
+		}).Error; err != nil {
+			return appModels.User{}, "", err
+		}
+	}
+
+	token, err := utils.GenerateDeviceJWT(ds.Keys, user, device.ID)
+	if err != nil {
+		return appModels.User{}, "", err
+	}
+	return user, token, nil
+}
+
+// recordFailure counts a wrong PIN on a device or user row and locks it once the limit is
+// reached. The count is raised before it is read back, so the row stays locked until the
+// transaction ends and concurrent failures are each counted.
+func recordFailure(db *gorm.DB, model interface{}, id uint, lockColumn string) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(model).Where("id = ?", id).
+			Update("failed_pin_attempts", gorm.Expr("failed_pin_attempts + 1")).Error; err != nil {
+			return err
+		}
+
+		var row struct{ FailedPinAttempts int }
+		if err := tx.Model(model).Select("failed_pin_attempts").Where("id = ?", id).Take(&row).Error; err != nil {
+			return err
+		}
+		if row.FailedPinAttempts < MaxPinAttempts {
+			return nil
+		}
+		return tx.Model(model).Where("id = ?", id).Updates(map[string]interface{}{
+			"failed_pin_attempts": 0,
+			lockColumn:            time.Now().Add(PinLockoutDuration),
+		}).Error
+	})
+}
@@ -0,0 +1,173 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"gorm.io/gorm"
+
+	"square-pos-integration/internal/migrate"
+	"square-pos-integration/migrations"
+)
+
+// HealthCheckTimeout bounds each readiness check, so a hung database fails the probe
+// instead of outlasting it
+const HealthCheckTimeout = 2 * time.Second
+
+// ErrDraining is reported by every readiness check once the server is shutting down
+var ErrDraining = errors.New("shutting down")
+
+// HealthService reports whether this instance can take traffic: the database answers, its
+// schema is up to date and, when a probe is set, Square is reachable
+type HealthService struct {
+	DB *gorm.DB
+	// Square is checked only when set, see SquareProbe
+	Square   *SquareProbe
+	draining atomic.Bool
+}
+
+func NewHealthService(db *gorm.DB) *HealthService {
+	return &HealthService{DB: db}
+}
+
+// Drain makes the instance report not ready from now on, so load balancers stop sending
+// it requests while running ones finish
+func (hs *HealthService) Drain() {
+	hs.draining.Store(true)
+}
+
+// Ready runs the readiness checks and returns each one's result, "ok" or what failed, and
+// whether all of them passed
+func (hs *HealthService) Ready(ctx context.Context) (map[string]string, bool) {
+	checks := map[string]func(context.Context) error{
+		"database":   hs.checkDatabase,
+		"migrations": hs.checkMigrations,
+	}
+	if hs.Square != nil {
+		checks["square"] = func(context.Context) error { return hs.Square.Err() }
+	}
+
+	results := make(map[string]string, len(checks))
+	ready := true
+	for name, check := range checks {
+		err := ErrDraining
+		if !hs.draining.Load() {
+			checkCtx, cancel := context.WithTimeout(ctx, HealthCheckTimeout)
+			err = check(checkCtx)
+			cancel()
+		}
+		if err != nil {
+			results[name] = err.Error()
+			ready = false
+		} else {
+			results[name] = "ok"
+		}
+	}
+	return results, ready
+}
+
+func (hs *HealthService) checkDatabase(ctx context.Context) error {
+	sqlDB, err := hs.DB.DB()
+	if err != nil {
+		return err
+	}
+	return sqlDB.PingContext(ctx)
+}
+
+// checkMigrations fails while migrations are pending, as they are when another instance is
+// still applying them or MIGRATE_ON_START is check
+func (hs *HealthService) checkMigrations(ctx context.Context) error {
+	fsys, err := migrations.For(hs.DB.Dialector.Name())
+	if err != nil {
+		return err
+	}
+	migrator, err := migrate.New(hs.DB.WithContext(ctx), fsys)
+	if err != nil {
+		return err
+	}
+	pending, err := migrator.Pending()
+	if err != nil {
+		return err
+	}
+	if len(pending) > 0 {
+		return fmt.Errorf("%d pending, starting with %s", len(pending), pending[0])
+	}
+	return nil
+}
+
+// SquareProbe checks whether the Square API answers in the background and keeps the
+// result, so readiness probes neither wait on Square nor send it a request each
+type SquareProbe struct {
+	URL    string
+	Client *http.Client
+
+	mu  sync.Mutex
+	err error
+}
+
+// NewSquareProbe returns a probe for the Square API at baseURL. It reports an error until
+// its first check.
+func NewSquareProbe(baseURL string, timeout time.Duration) *SquareProbe {
+	return &SquareProbe{
+		URL:    strings.TrimSuffix(baseURL, "/") + "/v2/locations",
+		Client: &http.Client{Timeout: timeout},
+		err:    errors.New("not checked yet"),
+	}
+}
+
+// Check calls Square once and keeps the result. Any answer short of a server error counts
+// as reachable; the probe sends no token, so Square answers 401.
+func (sp *SquareProbe) Check(ctx context.Context) error {
+	err := sp.call(ctx)
+	sp.mu.Lock()
+	sp.err = err
+	sp.mu.Unlock()
+	return err
+}
+
+func (sp *SquareProbe) call(ctx context.Context) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, sp.URL, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := sp.Client.Do(req)
+	if err != nil {
+		return fmt.Errorf("unreachable: %w", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode >= http.StatusInternalServerError {
+		return fmt.Errorf("answered %s", resp.Status)
+	}
+	return nil
+}
+
+// Err returns the result of the last check
+func (sp *SquareProbe) Err() error {
+	sp.mu.Lock()
+	defer sp.mu.Unlock()
+	return sp.err
+}
+
+// Run checks Square right away and then every interval until stop is closed
+func (sp *SquareProbe) Run(interval time.Duration, stop <-chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		if err := sp.Check(context.Background()); err != nil {
+			slog.Warn("Square reachability check failed", "error", err)
+		}
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,191 @@
+package service
+
+import (
+	"errors"
+	"log/slog"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	appModels "square-pos-integration/internal/models"
+	"square-pos-integration/internal/signing"
+	"square-pos-integration/internal/utils"
+)
+
+// KeyRotationInterval is how long a signing key signs tokens before it is replaced
+const KeyRotationInterval = 30 * 24 * time.Hour
+
+// KeyPublishAhead is how long a new key is published in the JWKS before it signs, so
+// services that cache the JWKS know it before they see tokens signed with it
+const KeyPublishAhead = time.Hour
+
+// KeyVerifyAfterRotation is how long a replaced key keeps verifying tokens. It must be
+// longer than the lifetime of an access token.
+const KeyVerifyAfterRotation = time.Hour
+
+// Keys are the secrets the services sign access tokens, hash PINs and encrypt stored
+// secrets with. The server builds them once from its configuration.
+type Keys struct {
+	Signing *signing.KeyRing
+	PINs    *utils.PINHasher
+	Secrets *utils.SecretBox
+}
+
+// NewKeys returns Keys with an empty signing ring, which KeyService.Load fills
+func NewKeys(pinSecret, encryptionKey string) Keys {
+	return Keys{
+		Signing: signing.NewKeyRing(),
+		PINs:    utils.NewPINHasher(pinSecret),
+		Secrets: utils.NewSecretBox(encryptionKey),
+	}
+}
+
+// KeyService keeps the signing keys in the database, loads them into a key ring and
+// rotates them on a schedule. The private keys are stored encrypted with Secrets.
+type KeyService struct {
+	DB               *gorm.DB
+	Ring             *signing.KeyRing
+	Secrets          *utils.SecretBox
+	Algorithm        string // RS256 or EdDSA
+	RotationInterval time.Duration
+}
+
+func NewKeyService(db *gorm.DB, ring *signing.KeyRing, secrets *utils.SecretBox) *KeyService {
+	return &KeyService{DB: db, Ring: ring, Secrets: secrets, Algorithm: signing.AlgorithmRS256, RotationInterval: KeyRotationInterval}
+}
+
+// Load replaces the ring's keys with the keys in the database that have not retired.
+// The first start creates a key that signs immediately.
+func (ks *KeyService) Load() error {
+	var records []appModels.SigningKey
+	if err := ks.DB.Where("retires_at IS NULL OR retires_at > ?", time.Now()).Find(&records).Error; err != nil {
+		return err
+	}
+	if len(records) == 0 {
+		return ks.Rotate(time.Now())
+	}
+
+	keys := make([]signing.Key, 0, len(records))
+	for _, record := range records {
+		key, err := ks.toSigningKey(record)
+		if err != nil {
+			return err
+		}
+		keys = append(keys, key)
+	}
+	ks.Ring.Replace(keys)
+	return nil
+}
+
+// RotateIfDue publishes a new key once the newest key has been signing for RotationInterval.
+// Replicas racing to rotate wait on the newest key's row, so only one of them adds a key.
+func (ks *KeyService) RotateIfDue() error {
+	var activatesAt *time.Time
+	err := ks.DB.Transaction(func(tx *gorm.DB) error {
+		var newest appModels.SigningKey
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Order("activates_at DESC").First(&newest).Error
+		if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+			return err
+		}
+		if err == nil && time.Since(newest.ActivatesAt) < ks.RotationInterval {
+			return nil
+		}
+
+		// Without any key there is nothing to keep signing in the meantime
+		start := time.Now()
+		if err == nil {
+			start = start.Add(KeyPublishAhead)
+		}
+		activatesAt = &start
+		return ks.rotate(tx, start)
+	})
+	if err != nil || activatesAt == nil {
+		return err
+	}
+	slog.Info("Signing key rotated", "activates_at", activatesAt.Format(time.RFC3339))
+	return ks.Load()
+}
+
+// Rotate adds a key that signs from activatesAt and retires the current keys shortly
+// after, then reloads the ring
+func (ks *KeyService) Rotate(activatesAt time.Time) error {
+	if err := ks.DB.Transaction(func(tx *gorm.DB) error {
+		return ks.rotate(tx, activatesAt)
+	}); err != nil {
+		return err
+	}
+	return ks.Load()
+}
+
+// Run rotates keys when due and reloads them every interval until stop is closed, so
+// every replica picks up keys added by the others
+func (ks *KeyService) Run(interval time.Duration, stop <-chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			if err := ks.RotateIfDue(); err != nil {
+				slog.Error("Signing key rotation failed", "error", err)
+			}
+			if err := ks.Load(); err != nil {
+				slog.Error("Signing key reload failed", "error", err)
+			}
+		}
+	}
+}
+
+// rotate stores a new key and schedules the retirement of the keys it replaces. Keys
+// retired long ago are deleted so their private keys are not kept around.
+func (ks *KeyService) rotate(tx *gorm.DB, activatesAt time.Time) error {
+	key, err := signing.GenerateKey(ks.Algorithm, activatesAt)
+	if err != nil {
+		return err
+	}
+	pemBytes, err := key.MarshalPrivateKey()
+	if err != nil {
+		return err
+	}
+	encrypted, err := ks.Secrets.Encrypt(string(pemBytes))
+	if err != nil {
+		return err
+	}
+
+	if err := tx.Where("retires_at < ?", time.Now()).Delete(&appModels.SigningKey{}).Error; err != nil {
+		return err
+	}
+	if err := tx.Model(&appModels.SigningKey{}).
+		Where("retires_at IS NULL").
+		Update("retires_at", activatesAt.Add(KeyVerifyAfterRotation)).Error; err != nil {
+		return err
+	}
+	return tx.Create(&appModels.SigningKey{
+		KID:         key.ID,
+		Algorithm:   key.Algorithm,
+		PrivateKey:  encrypted,
+		ActivatesAt: activatesAt,
+	}).Error
+}
+
+// toSigningKey decrypts a stored key
+func (ks *KeyService) toSigningKey(record appModels.SigningKey) (signing.Key, error) {
+	pemBytes, err := ks.Secrets.Decrypt(record.PrivateKey)
+	if err != nil {
+		return signing.Key{}, err
+	}
+	privateKey, err := signing.ParsePrivateKey([]byte(pemBytes))
+	if err != nil {
+		return signing.Key{}, err
+	}
+	return signing.Key{
+		ID:          record.KID,
+		Algorithm:   record.Algorithm,
+		PrivateKey:  privateKey,
+		ActivatesAt: record.ActivatesAt,
+		RetiresAt:   record.RetiresAt,
+	}, nil
+}
@@ -0,0 +1,102 @@
+package service
+
+import (
+	"errors"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"square-pos-integration/internal/apperrors"
+	appModels "square-pos-integration/internal/models"
+	"square-pos-integration/internal/tenant"
+)
+
+// LocationService answers which of a restaurant's Square locations exist and which ones a
+// member may work at. Locations are pulled from Square by SquareService.SyncLocations.
+type LocationService struct {
+	DB *gorm.DB
+}
+
+func NewLocationService(db *gorm.DB) *LocationService {
+	return &LocationService{DB: db}
+}
+
+// ListLocations returns every location of the restaurant, including inactive ones
+func (ls *LocationService) ListLocations(restaurantID uint) ([]appModels.Location, error) {
+	var locations []appModels.Location
+	err := tenant.Scoped(ls.DB, restaurantID).Order("name").Find(&locations).Error
+	return locations, err
+}
+
+// AllowedLocations returns the active locations of the membership's restaurant that the
+// member is assigned to
+func (ls *LocationService) AllowedLocations(membership appModels.Membership) ([]appModels.Location, error) {
+	query := tenant.Scoped(ls.DB, membership.RestaurantID).Where("status = ?", appModels.LocationStatusActive)
+	if allowed := membership.AllowedLocations(); len(allowed) > 0 {
+		query = query.Where("square_location_id IN ?", allowed)
+	}
+
+	var locations []appModels.Location
+	err := query.Order("name").Find(&locations).Error
+	return locations, err
+}
+
+// ResolveLocation returns the location of the membership's restaurant with the Square
+// location ID, once it is known to be active and the member is assigned to it
+func (ls *LocationService) ResolveLocation(membership appModels.Membership, squareLocationID string) (appModels.Location, error) {
+	var location appModels.Location
+	err := tenant.Scoped(ls.DB, membership.RestaurantID).Where("square_location_id = ?", squareLocationID).First(&location).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return location, apperrors.ErrLocationNotFound
+	}
+	if err != nil {
+		return location, err
+	}
+
+	if location.Status != appModels.LocationStatusActive {
+		return location, apperrors.ErrLocationInactive
+	}
+	if !membership.AllowsLocation(squareLocationID) {
+		return location, apperrors.ErrLocationNotAllowed
+	}
+	return location, nil
+}
+
+// EnsureLocations checks that every Square location ID belongs to the restaurant, so
+// members are only assigned to its own locations
+func (ls *LocationService) EnsureLocations(restaurantID uint, squareLocationIDs []string) error {
+	if len(squareLocationIDs) == 0 {
+		return nil
+	}
+
+	unique := map[string]bool{}
+	for _, id := range squareLocationIDs {
+		unique[id] = true
+	}
+
+	var count int64
+	err := tenant.Scoped(ls.DB, restaurantID).Model(&appModels.Location{}).
+		Where("square_location_id IN ?", squareLocationIDs).
+		Count(&count).Error
+	if err != nil {
+		return err
+	}
+	if int(count) != len(unique) {
+		return apperrors.ErrLocationNotFound
+	}
+	return nil
+}
+
+// EnsurePrimaryLocation records the location picked when the restaurant registered, so
+// orders can be taken there before the first sync with Square fills in its details
+func (ls *LocationService) EnsurePrimaryLocation(restaurant appModels.Restaurant) error {
+	if restaurant.LocationID == "" {
+		return nil
+	}
+	return tenant.Scoped(ls.DB, restaurant.ID).Clauses(clause.OnConflict{DoNothing: true}).Create(&appModels.Location{
+		RestaurantID:     restaurant.ID,
+		SquareLocationID: restaurant.LocationID,
+		Name:             restaurant.Name,
+		Status:           appModels.LocationStatusActive,
+	}).Error
+}
@@ -0,0 +1,173 @@
+package service
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+
+	"square-pos-integration/internal/apperrors"
+	"square-pos-integration/internal/lockout"
+	"square-pos-integration/internal/logging"
+	appModels "square-pos-integration/internal/models"
+)
+
+// AttemptLimit sets how many failed logins a key may have within the failure window
+type AttemptLimit struct {
+	FreeFailures int // Failures allowed before each retry has to wait
+	MaxFailures  int // Failures that lock the key
+}
+
+// LoginPolicy controls how failed password logins are slowed down and locked out
+type LoginPolicy struct {
+	Account         AttemptLimit
+	IP              AttemptLimit
+	FailureWindow   time.Duration // Failures older than this are forgotten
+	LockoutDuration time.Duration
+	BaseDelay       time.Duration // Wait after the first failure past FreeFailures, doubled for each one after
+	MaxDelay        time.Duration
+}
+
+// DefaultLoginPolicy locks an account after 5 failures and an IP, which may be shared by a
+// whole restaurant, after 20
+var DefaultLoginPolicy = LoginPolicy{
+	Account:         AttemptLimit{FreeFailures: 2, MaxFailures: 5},
+	IP:              AttemptLimit{FreeFailures: 10, MaxFailures: 20},
+	FailureWindow:   15 * time.Minute,
+	LockoutDuration: 15 * time.Minute,
+	BaseDelay:       time.Second,
+	MaxDelay:        30 * time.Second,
+}
+
+// LoginGuard tracks failed password logins per account and per client IP, and records
+// login events in the audit log. Accounts are keyed by email so unknown emails are
+// throttled the same way as real ones.
+type LoginGuard struct {
+	DB     *gorm.DB
+	Store  lockout.Store
+	Policy LoginPolicy
+}
+
+func NewLoginGuard(db *gorm.DB, store lockout.Store) *LoginGuard {
+	return &LoginGuard{DB: db, Store: store, Policy: DefaultLoginPolicy}
+}
+
+// Check returns an error when the account or IP is locked, or when the attempt comes
+// before the progressive delay since the last failure has passed
+func (g *LoginGuard) Check(ctx context.Context, email, ip string) error {
+	now := time.Now()
+
+	account, err := g.Store.Get(accountKey(email))
+	if err != nil {
+		return err
+	}
+	if account.Locked(now) {
+		g.audit(ctx, appModels.AuditLoginThrottled, nil, nil, email, ip)
+		return apperrors.ErrAccountLocked.RetryAfter(account.LockedUntil.Sub(now))
+	}
+
+	address, err := g.Store.Get(ipKey(ip))
+	if err != nil {
+		return err
+	}
+	if address.Locked(now) {
+		g.audit(ctx, appModels.AuditLoginThrottled, nil, nil, email, ip)
+		return apperrors.ErrTooManyLoginAttempts.RetryAfter(address.LockedUntil.Sub(now))
+	}
+
+	wait := g.wait(account, g.Policy.Account, now)
+	if ipWait := g.wait(address, g.Policy.IP, now); ipWait > wait {
+		wait = ipWait
+	}
+	if wait > 0 {
+		g.audit(ctx, appModels.AuditLoginThrottled, nil, nil, email, ip)
+		return apperrors.ErrLoginThrottled.RetryAfter(wait)
+	}
+	return nil
+}
+
+// RecordFailure counts a wrong password against the account and IP and locks either once
+// it reaches its limit. user is nil when no account has the email.
+func (g *LoginGuard) RecordFailure(ctx context.Context, user *appModels.User, email, ip string) error {
+	now := time.Now()
+	g.audit(ctx, appModels.AuditLoginFailed, user, nil, email, ip)
+
+	account, err := g.Store.RecordFailure(accountKey(email), now, g.Policy.FailureWindow)
+	if err != nil {
+		return err
+	}
+	if account.Failures >= g.Policy.Account.MaxFailures {
+		if err := g.Store.Lock(accountKey(email), now.Add(g.Policy.LockoutDuration)); err != nil {
+			return err
+		}
+		g.audit(ctx, appModels.AuditAccountLocked, user, nil, email, ip)
+		logging.FromContext(ctx).Warn("Account login locked", "email", email, "failures", account.Failures)
+	}
+
+	address, err := g.Store.RecordFailure(ipKey(ip), now, g.Policy.FailureWindow)
+	if err != nil {
+		return err
+	}
+	if address.Failures >= g.Policy.IP.MaxFailures {
+		if err := g.Store.Lock(ipKey(ip), now.Add(g.Policy.LockoutDuration)); err != nil {
+			return err
+		}
+		logging.FromContext(ctx).Warn("Logins from IP locked", "client_ip", ip, "failures", address.Failures)
+	}
+	return nil
+}
+
+// RecordSuccess clears the account's failures. The IP keeps its count so one valid
+// account cannot be used to keep guessing the passwords of others.
+func (g *LoginGuard) RecordSuccess(ctx context.Context, user appModels.User, ip string) error {
+	g.audit(ctx, appModels.AuditLoginSucceeded, &user, nil, user.Email, ip)
+	return g.Store.Reset(accountKey(user.Email))
+}
+
+// Unlock lifts an account lockout on behalf of an admin
+func (g *LoginGuard) Unlock(ctx context.Context, user appModels.User, actorID uint, ip string) error {
+	if err := g.Store.Reset(accountKey(user.Email)); err != nil {
+		return err
+	}
+	g.audit(ctx, appModels.AuditAccountUnlocked, &user, &actorID, user.Email, ip)
+	return nil
+}
+
+// wait returns how long the key must wait after its last failure before trying again
+func (g *LoginGuard) wait(attempts lockout.Attempts, limit AttemptLimit, now time.Time) time.Duration {
+	if attempts.Failures <= limit.FreeFailures || now.Sub(attempts.LastFailureAt) > g.Policy.FailureWindow {
+		return 0
+	}
+
+	delay := g.Policy.BaseDelay << (attempts.Failures - limit.FreeFailures - 1)
+	if delay <= 0 || delay > g.Policy.MaxDelay {
+		delay = g.Policy.MaxDelay
+	}
+	return attempts.LastFailureAt.Add(delay).Sub(now)
+}
+
+// audit records a login event. A failed write is logged rather than failing the login.
+func (g *LoginGuard) audit(ctx context.Context, event string, user *appModels.User, actorID *uint, email, ip string) {
+	entry := appModels.AuditLog{
+		ActorID:   actorID,
+		Event:     event,
+		Email:     email,
+		IPAddress: ip,
+	}
+	if user != nil {
+		entry.UserID = &user.ID
+		entry.RestaurantID = &user.RestaurantID
+	}
+	if err := g.DB.Create(&entry).Error; err != nil {
+		logging.FromContext(ctx).Error("Failed to write audit entry", "event", event, "email", email, "error", err)
+	}
+}
+
+func accountKey(email string) string {
+	return "account:" + strings.ToLower(strings.TrimSpace(email))
+}
+
+func ipKey(ip string) string {
+	return "ip:" + ip
+}
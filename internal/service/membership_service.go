@@ -0,0 +1,209 @@
+package service
+
+import (
+	"errors"
+	"strings"
+
+	"gorm.io/gorm"
+
+	"square-pos-integration/internal/apperrors"
+	"square-pos-integration/internal/authz"
+	appModels "square-pos-integration/internal/models"
+	"square-pos-integration/internal/requests"
+)
+
+// MembershipService manages which restaurants a user can act in and with which role
+type MembershipService struct {
+	DB        *gorm.DB
+	Roles     *RoleService
+	Locations *LocationService
+}
+
+func NewMembershipService(db *gorm.DB) *MembershipService {
+	return &MembershipService{DB: db, Roles: NewRoleService(db), Locations: NewLocationService(db)}
+}
+
+// ListMemberships returns the user's active memberships with their restaurants
+func (ms *MembershipService) ListMemberships(userID uint) ([]appModels.Membership, error) {
+	var memberships []appModels.Membership
+	err := ms.DB.Preload("Restaurant").Where("user_id = ? AND is_active = ?", userID, true).Order("id").Find(&memberships).Error
+	return memberships, err
+}
+
+// FindMembership returns the user's active membership of the restaurant, with the restaurant
+func (ms *MembershipService) FindMembership(userID, restaurantID uint) (appModels.Membership, error) {
+	var membership appModels.Membership
+	err := ms.DB.Preload("Restaurant").
+		Where("user_id = ? AND restaurant_id = ? AND is_active = ?", userID, restaurantID, true).
+		First(&membership).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return membership, apperrors.ErrNotAMember
+	}
+	return membership, err
+}
+
+// DefaultMembership picks the membership a login acts in: the user's home restaurant while
+// they are still an active member of it, otherwise their oldest active membership
+func DefaultMembership(user appModels.User, memberships []appModels.Membership) (appModels.Membership, error) {
+	if len(memberships) == 0 {
+		return appModels.Membership{}, apperrors.ErrNoActiveMembership
+	}
+	for _, membership := range memberships {
+		if membership.RestaurantID == user.RestaurantID {
+			return membership, nil
+		}
+	}
+	return memberships[0], nil
+}
+
+// ActAs returns the user acting in the membership's restaurant with its role. Tokens and
+// two-factor requirements are issued for this user rather than the stored one.
+func ActAs(user appModels.User, membership appModels.Membership) appModels.User {
+	user.RestaurantID = membership.RestaurantID
+	user.Role = membership.Role
+	user.Restaurant = membership.Restaurant
+	return user
+}
+
+// ListMembers returns every membership of the restaurant with its user
+func (ms *MembershipService) ListMembers(restaurantID uint) ([]appModels.Membership, error) {
+	var memberships []appModels.Membership
+	err := ms.DB.Preload("User").Where("restaurant_id = ?", restaurantID).Order("id").Find(&memberships).Error
+	return memberships, err
+}
+
+// AddMember gives an existing user, found by email, access to the restaurant
+func (ms *MembershipService) AddMember(actor authz.Set, restaurantID uint, addRequest requests.AddMemberRequest) (appModels.Membership, error) {
+	if addRequest.Role == "" {
+		addRequest.Role = "staff"
+	}
+	if err := ms.Roles.EnsureRoleExists(restaurantID, addRequest.Role); err != nil {
+		return appModels.Membership{}, err
+	}
+	if err := ms.Roles.EnsureCanGrant(actor, restaurantID, addRequest.Role); err != nil {
+		return appModels.Membership{}, err
+	}
+	if err := ms.Locations.EnsureLocations(restaurantID, addRequest.LocationIDs); err != nil {
+		return appModels.Membership{}, err
+	}
+
+	var user appModels.User
+	err := ms.DB.Where("email = ?", addRequest.Email).First(&user).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return appModels.Membership{}, apperrors.ErrUserNotFound
+	}
+	if err != nil {
+		return appModels.Membership{}, err
+	}
+
+	var count int64
+	if err := ms.DB.Model(&appModels.Membership{}).Where("user_id = ? AND restaurant_id = ?", user.ID, restaurantID).Count(&count).Error; err != nil {
+		return appModels.Membership{}, err
+	}
+	if count > 0 {
+		return appModels.Membership{}, apperrors.ErrMemberAlreadyExists
+	}
+
+	membership := appModels.Membership{
+		UserID:       user.ID,
+		RestaurantID: restaurantID,
+		Role:         addRequest.Role,
+		LocationIDs:  strings.Join(addRequest.LocationIDs, ","),
+		IsActive:     true,
+	}
+	if err := ms.DB.Create(&membership).Error; err != nil {
+		return appModels.Membership{}, err
+	}
+	membership.User = user
+	return membership, nil
+}
+
+// UpdateMember changes the role, allowed locations or active flag of a membership of the
+// restaurant. A user's home membership can only be deactivated through the user endpoints,
+// and its role is kept in step with the user's.
+func (ms *MembershipService) UpdateMember(actor authz.Set, restaurantID uint, userID string, updateRequest requests.UpdateMemberRequest) (appModels.Membership, error) {
+	membership, err := ms.findMember(restaurantID, userID)
+	if err != nil {
+		return membership, err
+	}
+	if err := ms.Roles.EnsureCanGrant(actor, restaurantID, membership.Role); err != nil {
+		return membership, err
+	}
+	home := membership.User.RestaurantID == restaurantID
+	if home && updateRequest.IsActive != nil {
+		return membership, apperrors.ErrHomeMembership
+	}
+
+	updates := map[string]interface{}{}
+	roleChanged := updateRequest.Role != "" && updateRequest.Role != membership.Role
+	if roleChanged {
+		if err := ms.Roles.EnsureRoleExists(restaurantID, updateRequest.Role); err != nil {
+			return membership, err
+		}
+		if err := ms.Roles.EnsureCanGrant(actor, restaurantID, updateRequest.Role); err != nil {
+			return membership, err
+		}
+		updates["role"] = updateRequest.Role
+	}
+	if updateRequest.LocationIDs != nil {
+		if err := ms.Locations.EnsureLocations(restaurantID, *updateRequest.LocationIDs); err != nil {
+			return membership, err
+		}
+		updates["location_ids"] = strings.Join(*updateRequest.LocationIDs, ",")
+	}
+	deactivated := updateRequest.IsActive != nil && !*updateRequest.IsActive && membership.IsActive
+	if updateRequest.IsActive != nil {
+		updates["is_active"] = *updateRequest.IsActive
+	}
+	if len(updates) == 0 {
+		return membership, nil
+	}
+
+	err = ms.DB.Transaction(func(tx *gorm.DB) error {
+		if roleChanged || deactivated {
+			if err := ensureAdminRemains(tx, membership); err != nil {
+				return err
+			}
+		}
+		if err := tx.Model(&membership).Updates(updates).Error; err != nil {
+			return err
+		}
+		if home && roleChanged {
+			return tx.Model(&membership.User).Update("role", updateRequest.Role).Error
+		}
+		return nil
+	})
+	return membership, err
+}
+
+// RemoveMember takes a user's access to the restaurant away. Users cannot be removed from
+// their home restaurant, they are deactivated instead.
+func (ms *MembershipService) RemoveMember(actor authz.Set, restaurantID uint, userID string) error {
+	membership, err := ms.findMember(restaurantID, userID)
+	if err != nil {
+		return err
+	}
+	if err := ms.Roles.EnsureCanGrant(actor, restaurantID, membership.Role); err != nil {
+		return err
+	}
+	if membership.User.RestaurantID == restaurantID {
+		return apperrors.ErrHomeMembership
+	}
+
+	return ms.DB.Transaction(func(tx *gorm.DB) error {
+		if err := ensureAdminRemains(tx, membership); err != nil {
+			return err
+		}
+		return tx.Delete(&membership).Error
+	})
+}
+
+// findMember returns the membership of a user of the restaurant, with the user
+func (ms *MembershipService) findMember(restaurantID uint, userID string) (appModels.Membership, error) {
+	var membership appModels.Membership
+	err := ms.DB.Preload("User").Where("restaurant_id = ? AND user_id = ?", restaurantID, userID).First(&membership).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return membership, apperrors.ErrMemberNotFound
+	}
+	return membership, err
+}
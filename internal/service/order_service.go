@@ -0,0 +1,199 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+	square "github.com/square/square-go-sdk/v2"
+	"gorm.io/datatypes"
+	"gorm.io/gorm"
+
+	"square-pos-integration/internal/apperrors"
+	"square-pos-integration/internal/metrics"
+	appModels "square-pos-integration/internal/models"
+	"square-pos-integration/internal/repository"
+	"square-pos-integration/internal/requests"
+	"square-pos-integration/internal/utils"
+)
+
+// IOrderService creates, reads and cancels orders, keeping Square and the local copy in step
+type IOrderService interface {
+	CreateOrder(ctx context.Context, membership appModels.Membership, userID uint, orderRequest requests.CreateOrderRequest) (appModels.Order, *square.Order, error)
+	PreviewOrder(ctx context.Context, membership appModels.Membership, orderRequest requests.CreateOrderRequest) (*square.Order, error)
+	GetOrder(ctx context.Context, restaurantID uint, orderID string) (appModels.Order, error)
+	ListTableOrders(ctx context.Context, restaurantID uint, tableNumber string) ([]appModels.Order, error)
+	CancelOrder(ctx context.Context, order *appModels.Order) (*square.Order, error)
+	RefreshTotals(ctx context.Context, order *appModels.Order) (*square.Order, error)
+}
+
+// ILocationService checks that a member may work at a location
+type ILocationService interface {
+	ResolveLocation(membership appModels.Membership, squareLocationID string) (appModels.Location, error)
+}
+
+type OrderService struct {
+	Orders    repository.OrderRepository
+	Payments  repository.PaymentRepository
+	Locations ILocationService
+	Square    ISquareService
+}
+
+func NewOrderService(db *gorm.DB, squareService ISquareService) *OrderService {
+	return &OrderService{
+		Orders:    repository.NewOrderRepository(db),
+		Payments:  repository.NewPaymentRepository(db),
+		Locations: NewLocationService(db),
+		Square:    squareService,
+	}
+}
+
+// CreateOrder creates the order in Square at one of the member's locations, then stores
+// it with its line items
+func (ors *OrderService) CreateOrder(ctx context.Context, membership appModels.Membership, userID uint, orderRequest requests.CreateOrderRequest) (appModels.Order, *square.Order, error) {
+	if _, err := ors.Locations.ResolveLocation(membership, orderRequest.LocationID); err != nil {
+		return appModels.Order{}, nil, err
+	}
+
+	squareOrder, err := ors.Square.CreateOrder(ctx, membership.RestaurantID, orderRequest, "order-"+uuid.NewString())
+	if err != nil {
+		return appModels.Order{}, nil, err
+	}
+
+	jsonBytes, err := json.Marshal(squareOrder)
+	if err != nil {
+		return appModels.Order{}, nil, apperrors.ErrInternal.Wrap(err)
+	}
+	order := appModels.Order{
+		SquareOrderID: utils.SafeString(squareOrder.ID),
+		RestaurantID:  membership.RestaurantID,
+		UserID:        userID,
+		TableNumber:   orderRequest.TableNumber,
+		Status:        "pending",
+		TotalAmount:   utils.SafeInt64(squareOrder.TotalMoney.Amount),
+		Currency:      utils.SafeCurrency(squareOrder.TotalMoney.Currency),
+		LocationID:    orderRequest.LocationID,
+		RawSquareData: datatypes.JSON(jsonBytes), // Store complete Square response
+		OpenedAt:      time.Now(),
+		Totals:        utils.BuildOrderTotals(squareOrder, 0, 0), // Square prices the order on create
+	}
+
+	var items []appModels.OrderItem
+	for _, lineItem := range squareOrder.LineItems {
+		items = append(items, appModels.OrderItem{
+			Name:         utils.SafeString(lineItem.Name),
+			UnitPrice:    utils.SafeInt64(lineItem.BasePriceMoney.Amount),
+			Quantity:     utils.ParseQuantity(lineItem.Quantity),
+			Amount:       int(utils.SafeInt64(lineItem.TotalMoney.Amount)),
+			SquareItemID: utils.SafeString(lineItem.CatalogObjectID),
+			SquareUID:    utils.SafeString(lineItem.UID),
+		})
+	}
+
+	if err := ors.Orders.Create(&order, items); err != nil {
+		return order, nil, apperrors.ErrInternal.Wrap(err)
+	}
+	metrics.OrderCreated(order.RestaurantID)
+	return order, squareOrder, nil
+}
+
+// PreviewOrder prices an order at one of the member's locations without creating it
+func (ors *OrderService) PreviewOrder(ctx context.Context, membership appModels.Membership, orderRequest requests.CreateOrderRequest) (*square.Order, error) {
+	if _, err := ors.Locations.ResolveLocation(membership, orderRequest.LocationID); err != nil {
+		return nil, err
+	}
+	return ors.Square.PreviewOrder(ctx, membership.RestaurantID, orderRequest)
+}
+
+// GetOrder returns an order of the restaurant with its items
+func (ors *OrderService) GetOrder(ctx context.Context, restaurantID uint, orderID string) (appModels.Order, error) {
+	order, err := ors.Orders.FindByID(restaurantID, orderID)
+	if err != nil {
+		return order, apperrors.ErrOrderNotFound.Wrap(err)
+	}
+	return order, nil
+}
+
+// ListTableOrders returns the restaurant's orders for a table
+func (ors *OrderService) ListTableOrders(ctx context.Context, restaurantID uint, tableNumber string) ([]appModels.Order, error) {
+	orders, err := ors.Orders.ListByTable(restaurantID, tableNumber)
+	if err != nil {
+		return nil, apperrors.ErrInternal.Wrap(err)
+	}
+	return orders, nil
+}
+
+// CancelOrder cancels a pending order in Square and locally
+func (ors *OrderService) CancelOrder(ctx context.Context, order *appModels.Order) (*square.Order, error) {
+	if order.Status != "pending" {
+		return nil, apperrors.ErrOrderNotCancellable
+	}
+
+	squareOrder, err := ors.Square.CancelOrder(ctx, order.RestaurantID, order.SquareOrderID)
+	if err != nil {
+		return nil, err
+	}
+
+	order.Status = "cancelled"
+	if err := ors.Orders.Update(order, map[string]interface{}{"status": order.Status}); err != nil {
+		return nil, apperrors.ErrInternal.Wrap(err)
+	}
+	return squareOrder, nil
+}
+
+// RefreshTotals recalculates an order with Square, combines it with the locally recorded
+// payments and refunds, and stores the result in the order's totals
+func (ors *OrderService) RefreshTotals(ctx context.Context, order *appModels.Order) (*square.Order, error) {
+	squareOrder, err := ors.Square.GetOrderDetails(ctx, order.RestaurantID, order.SquareOrderID)
+	if err != nil {
+		return nil, err
+	}
+
+	// Calculate expects the priced contents of the order, not its read-only state
+	calculated, err := ors.Square.CalculateOrder(ctx, order.RestaurantID, &square.Order{
+		LocationID:     squareOrder.LocationID,
+		LineItems:      squareOrder.LineItems,
+		Discounts:      squareOrder.Discounts,
+		Taxes:          squareOrder.Taxes,
+		ServiceCharges: squareOrder.ServiceCharges,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to calculate order: %w", err)
+	}
+
+	payments, err := ors.Payments.ListCompleted(order.RestaurantID, strconv.FormatUint(uint64(order.ID), 10))
+	if err != nil {
+		return nil, fmt.Errorf("failed to load payments: %w", err)
+	}
+	var paid, tips int64
+	for _, payment := range payments {
+		paid += int64(payment.BillAmount - payment.RefundedAmount)
+		tips += int64(payment.TipAmount)
+	}
+
+	order.Totals = utils.BuildOrderTotals(calculated, paid, tips)
+	order.TotalAmount = int64(order.Totals.Total)
+	order.PayedAmount = paid
+	order.TipAmount = tips
+
+	err = ors.Orders.Update(order, map[string]interface{}{
+		"total_amount":   order.TotalAmount,
+		"payed_amount":   order.PayedAmount,
+		"tip_amount":     order.TipAmount,
+		"discounts":      order.Totals.Discounts,
+		"due":            order.Totals.Due,
+		"tax":            order.Totals.Tax,
+		"service_charge": order.Totals.ServiceCharge,
+		"paid":           order.Totals.Paid,
+		"tips":           order.Totals.Tips,
+		"total":          order.Totals.Total,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to save order totals: %w", err)
+	}
+
+	return calculated, nil
+}
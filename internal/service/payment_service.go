@@ -0,0 +1,196 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"strconv"
+	"time"
+
+	square "github.com/square/square-go-sdk/v2"
+	"gorm.io/datatypes"
+	"gorm.io/gorm"
+
+	"square-pos-integration/internal/apperrors"
+	"square-pos-integration/internal/logging"
+	"square-pos-integration/internal/metrics"
+	appModels "square-pos-integration/internal/models"
+	"square-pos-integration/internal/repository"
+	"square-pos-integration/internal/requests"
+	"square-pos-integration/internal/utils"
+)
+
+// IPaymentService takes payments for orders through Square and records them locally
+type IPaymentService interface {
+	CreatePaymentIntent(ctx context.Context, membership appModels.Membership, orderID string, paymentRequest requests.SubmitPaymentRequest) (appModels.Payment, error)
+	CompletePayment(ctx context.Context, restaurantID uint, squarePaymentID string, tipAmount float64) (appModels.Order, *square.Order, error)
+	RefundPayment(ctx context.Context, restaurantID uint, paymentID string, refundRequest requests.RefundPaymentRequest) (RefundResult, error)
+}
+
+// RefundResult is a refund submitted to Square with the payment and order it changed
+type RefundResult struct {
+	Refund  *square.PaymentRefund
+	Payment appModels.Payment
+	Order   appModels.Order
+}
+
+type PaymentService struct {
+	Payments     repository.PaymentRepository
+	Orders       repository.OrderRepository
+	OrderService IOrderService
+	Locations    ILocationService
+	Square       ISquareService
+}
+
+func NewPaymentService(db *gorm.DB, squareService ISquareService) *PaymentService {
+	return &PaymentService{
+		Payments:     repository.NewPaymentRepository(db),
+		Orders:       repository.NewOrderRepository(db),
+		OrderService: NewOrderService(db, squareService),
+		Locations:    NewLocationService(db),
+		Square:       squareService,
+	}
+}
+
+// CreatePaymentIntent creates an uncaptured Square payment for the order at its location,
+// which the member must be assigned to, and marks the order pending
+func (ps *PaymentService) CreatePaymentIntent(ctx context.Context, membership appModels.Membership, orderID string, paymentRequest requests.SubmitPaymentRequest) (appModels.Payment, error) {
+	order, err := ps.Orders.FindByID(membership.RestaurantID, orderID)
+	if err != nil {
+		return appModels.Payment{}, apperrors.ErrOrderNotFound.Wrap(err)
+	}
+
+	if _, err := ps.Locations.ResolveLocation(membership, paymentRequest.LocationID); err != nil {
+		return appModels.Payment{}, err
+	}
+	if order.LocationID != "" && order.LocationID != paymentRequest.LocationID {
+		return appModels.Payment{}, apperrors.ErrLocationMismatch
+	}
+
+	// Create payment intent on Square side (not actual payment)
+	paymentIntent, err := ps.Square.CreatePaymentIntent(ctx, order.RestaurantID, order.SquareOrderID, paymentRequest)
+	if err != nil {
+		return appModels.Payment{}, err
+	}
+
+	parsedCreatedAt, err := time.Parse(time.RFC3339, utils.SafeString(paymentIntent.CreatedAt))
+	if err != nil {
+		parsedCreatedAt = time.Time{}
+	}
+	jsonBytes, err := json.Marshal(paymentIntent)
+	if err != nil {
+		return appModels.Payment{}, apperrors.ErrInternal.Wrap(err)
+	}
+
+	payment := appModels.Payment{
+		OrderID:         strconv.FormatUint(uint64(order.ID), 10),
+		RestaurantID:    order.RestaurantID,
+		SquarePaymentID: utils.SafeString(paymentIntent.ID),
+		BillAmount:      int(utils.SafeInt64(paymentIntent.AmountMoney.Amount)),
+		Currency:        utils.SafeCurrency(paymentIntent.AmountMoney.Currency),
+		Status:          "pending",
+		PaymentMethod:   paymentRequest.PaymentMethod,
+		ProcessedAt:     parsedCreatedAt,
+		RawSquareData:   datatypes.JSON(jsonBytes),
+	}
+	if err := ps.Payments.Create(&payment); err != nil {
+		return payment, apperrors.ErrInternal.Wrap(err)
+	}
+
+	// Link the payment record to the order
+	err = ps.Orders.Update(&order, map[string]interface{}{
+		"status":     "pending",
+		"payment_id": strconv.FormatUint(uint64(payment.ID), 10),
+	})
+	if err != nil {
+		return payment, apperrors.ErrInternal.Wrap(err)
+	}
+	return payment, nil
+}
+
+// CompletePayment adds the tip to a pending payment, captures it in Square and marks its
+// order paid. The Square order is nil when the totals could not be refreshed.
+func (ps *PaymentService) CompletePayment(ctx context.Context, restaurantID uint, squarePaymentID string, tipAmount float64) (appModels.Order, *square.Order, error) {
+	payment, err := ps.Payments.FindBySquareID(restaurantID, squarePaymentID)
+	if err != nil {
+		return appModels.Order{}, nil, apperrors.ErrPaymentNotFound.Wrap(err)
+	}
+	if payment.Status != "pending" {
+		return appModels.Order{}, nil, apperrors.ErrPaymentAlreadyCompleted
+	}
+
+	completedPayment, err := ps.Square.CompletePayment(ctx, payment.RestaurantID, payment.SquarePaymentID, tipAmount, payment.Currency)
+	if err != nil {
+		return appModels.Order{}, nil, err
+	}
+
+	processedAt, _ := time.Parse(time.RFC3339, utils.SafeString(completedPayment.UpdatedAt))
+	jsonBytes, _ := json.Marshal(completedPayment)
+
+	payment.Status = utils.SafeString(completedPayment.Status)
+	payment.BillAmount = int(utils.SafeMoney(completedPayment.AmountMoney))
+	payment.TipAmount = int(utils.SafeMoney(completedPayment.TipMoney))
+	payment.TotalAmount = int(utils.SafeMoney(completedPayment.TotalMoney))
+	payment.ProcessedAt = processedAt
+	payment.RawSquareData = datatypes.JSON(jsonBytes)
+	if err := ps.Payments.Save(&payment); err != nil {
+		return appModels.Order{}, nil, apperrors.ErrInternal.Wrap(err)
+	}
+	metrics.PaymentCompleted(payment.RestaurantID, payment.TipAmount)
+
+	order, err := ps.Orders.FindByPaymentID(restaurantID, strconv.FormatUint(uint64(payment.ID), 10))
+	if err != nil {
+		return order, nil, apperrors.ErrOrderNotFound.Wrap(err)
+	}
+	order.Status = "paid"
+	if err := ps.Orders.Update(&order, map[string]interface{}{"status": order.Status}); err != nil {
+		return order, nil, apperrors.ErrInternal.Wrap(err)
+	}
+
+	// Recalculate and store the order totals now that the payment is recorded. The payment
+	// is captured by now, so a failure only leaves the stored totals stale until the next
+	// refresh and must not make the client retry the payment.
+	refreshed := order
+	squareOrder, err := ps.OrderService.RefreshTotals(ctx, &refreshed)
+	if err != nil {
+		logging.FromContext(ctx).Error("Failed to refresh order totals after payment", "order_id", order.ID, "payment_id", payment.ID, "error", err)
+		return order, nil, nil
+	}
+	return refreshed, squareOrder, nil
+}
+
+// RefundPayment refunds part or all of a completed payment and updates the order totals
+func (ps *PaymentService) RefundPayment(ctx context.Context, restaurantID uint, paymentID string, refundRequest requests.RefundPaymentRequest) (RefundResult, error) {
+	payment, err := ps.Payments.FindByID(restaurantID, paymentID)
+	if err != nil {
+		return RefundResult{}, apperrors.ErrPaymentNotFound.Wrap(err)
+	}
+	if payment.Status != "COMPLETED" {
+		return RefundResult{}, apperrors.ErrPaymentNotCompleted
+	}
+
+	refundAmount := utils.ToCents(refundRequest.Amount)
+	if refundAmount > int64(payment.TotalAmount-payment.RefundedAmount) {
+		return RefundResult{}, apperrors.ErrRefundExceedsBalance
+	}
+
+	refund, err := ps.Square.RefundPayment(ctx, payment.RestaurantID, payment.SquarePaymentID, refundAmount, payment.Currency, refundRequest.Reason)
+	if err != nil {
+		return RefundResult{}, err
+	}
+
+	payment.RefundedAmount += int(utils.SafeMoney(refund.AmountMoney))
+	if err := ps.Payments.Update(&payment, map[string]interface{}{"refunded_amount": payment.RefundedAmount}); err != nil {
+		return RefundResult{}, apperrors.ErrInternal.Wrap(err)
+	}
+	metrics.PaymentRefunded(payment.RestaurantID)
+
+	order, err := ps.Orders.FindByID(restaurantID, payment.OrderID)
+	if err != nil {
+		return RefundResult{}, apperrors.ErrOrderNotFound.Wrap(err)
+	}
+	if _, err := ps.OrderService.RefreshTotals(ctx, &order); err != nil {
+		return RefundResult{}, err
+	}
+
+	return RefundResult{Refund: refund, Payment: payment, Order: order}, nil
+}
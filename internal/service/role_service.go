@@ -0,0 +1,197 @@
+package service
+
+import (
+	"errors"
+
+	"gorm.io/gorm"
+
+	"square-pos-integration/internal/apperrors"
+	"square-pos-integration/internal/authz"
+	appModels "square-pos-integration/internal/models"
+	"square-pos-integration/internal/requests"
+)
+
+// RoleService manages a restaurant's custom roles
+type RoleService struct {
+	DB *gorm.DB
+}
+
+func NewRoleService(db *gorm.DB) *RoleService {
+	return &RoleService{DB: db}
+}
+
+// ListRoles returns the restaurant's custom roles with their permissions
+func (rs *RoleService) ListRoles(restaurantID uint) ([]appModels.Role, error) {
+	var roles []appModels.Role
+	err := rs.DB.Preload("Permissions").Where("restaurant_id = ?", restaurantID).Order("name").Find(&roles).Error
+	return roles, err
+}
+
+// CreateRole adds a custom role to the restaurant. The actor must hold every permission
+// they grant the role.
+func (rs *RoleService) CreateRole(actor authz.Set, restaurantID uint, roleRequest requests.CreateRoleRequest) (appModels.Role, error) {
+	if _, builtin := authz.BuiltinRole(roleRequest.Name); builtin {
+		return appModels.Role{}, apperrors.ErrRoleAlreadyExists
+	}
+	permissions, err := rolePermissions(roleRequest.Permissions)
+	if err != nil {
+		return appModels.Role{}, err
+	}
+	if err := ensureCovers(actor, permissionSet(permissions)); err != nil {
+		return appModels.Role{}, err
+	}
+
+	var count int64
+	if err := rs.DB.Model(&appModels.Role{}).Where("restaurant_id = ? AND name = ?", restaurantID, roleRequest.Name).Count(&count).Error; err != nil {
+		return appModels.Role{}, err
+	}
+	if count > 0 {
+		return appModels.Role{}, apperrors.ErrRoleAlreadyExists
+	}
+
+	role := appModels.Role{
+		RestaurantID: restaurantID,
+		Name:         roleRequest.Name,
+		Description:  roleRequest.Description,
+		Permissions:  permissions,
+	}
+	if err := rs.DB.Create(&role).Error; err != nil {
+		return appModels.Role{}, err
+	}
+	return role, nil
+}
+
+// UpdateRole changes a custom role's description or replaces its permissions. Users with the
+// role get the new permissions on their next request. The actor must hold every permission
+// of the role, before and after the change.
+func (rs *RoleService) UpdateRole(actor authz.Set, restaurantID uint, roleID string, roleRequest requests.UpdateRoleRequest) (appModels.Role, error) {
+	role, err := rs.findRole(restaurantID, roleID)
+	if err != nil {
+		return appModels.Role{}, err
+	}
+	if err := ensureCovers(actor, permissionSet(role.Permissions)); err != nil {
+		return appModels.Role{}, err
+	}
+
+	err = rs.DB.Transaction(func(tx *gorm.DB) error {
+		if roleRequest.Description != nil {
+			role.Description = *roleRequest.Description
+			if err := tx.Model(&role).Update("description", role.Description).Error; err != nil {
+				return err
+			}
+		}
+		if roleRequest.Permissions != nil {
+			permissions, err := rolePermissions(roleRequest.Permissions)
+			if err != nil {
+				return err
+			}
+			if err := ensureCovers(actor, permissionSet(permissions)); err != nil {
+				return err
+			}
+			if err := tx.Where("role_id = ?", role.ID).Delete(&appModels.RolePermission{}).Error; err != nil {
+				return err
+			}
+			for i := range permissions {
+				permissions[i].RoleID = role.ID
+			}
+			if err := tx.Create(&permissions).Error; err != nil {
+				return err
+			}
+			role.Permissions = permissions
+		}
+		return nil
+	})
+	return role, err
+}
+
+// DeleteRole removes a custom role that no user is assigned to
+func (rs *RoleService) DeleteRole(restaurantID uint, roleID string) error {
+	role, err := rs.findRole(restaurantID, roleID)
+	if err != nil {
+		return err
+	}
+
+	var assigned int64
+	if err := rs.DB.Model(&appModels.Membership{}).Where("restaurant_id = ? AND role = ?", restaurantID, role.Name).Count(&assigned).Error; err != nil {
+		return err
+	}
+	if assigned > 0 {
+		return apperrors.ErrRoleInUse
+	}
+
+	return rs.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("role_id = ?", role.ID).Delete(&appModels.RolePermission{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&role).Error
+	})
+}
+
+// EnsureRoleExists checks that a role name is built in or defined by the restaurant
+func (rs *RoleService) EnsureRoleExists(restaurantID uint, name string) error {
+	if _, builtin := authz.BuiltinRole(name); builtin {
+		return nil
+	}
+
+	var count int64
+	if err := rs.DB.Model(&appModels.Role{}).Where("restaurant_id = ? AND name = ?", restaurantID, name).Count(&count).Error; err != nil {
+		return err
+	}
+	if count == 0 {
+		return apperrors.ErrUnknownRole
+	}
+	return nil
+}
+
+// EnsureCanGrant checks that the actor holds every permission of the role, so users can
+// neither grant a role above their own nor manage the users who hold one
+func (rs *RoleService) EnsureCanGrant(actor authz.Set, restaurantID uint, name string) error {
+	permissions, err := authz.NewResolver(rs.DB).Permissions(restaurantID, name)
+	if err != nil {
+		return err
+	}
+	return ensureCovers(actor, permissions)
+}
+
+func (rs *RoleService) findRole(restaurantID uint, roleID string) (appModels.Role, error) {
+	var role appModels.Role
+	err := rs.DB.Preload("Permissions").Where("id = ? AND restaurant_id = ?", roleID, restaurantID).First(&role).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return role, apperrors.ErrCustomRoleNotFound
+	}
+	return role, err
+}
+
+// rolePermissions validates requested permission names, dropping duplicates
+func rolePermissions(names []string) ([]appModels.RolePermission, error) {
+	seen := map[string]bool{}
+	permissions := make([]appModels.RolePermission, 0, len(names))
+	for _, name := range names {
+		if !authz.Valid(authz.Permission(name)) {
+			return nil, apperrors.ErrUnknownPermission.WithDetails(map[string]string{"permission": name})
+		}
+		if seen[name] {
+			continue
+		}
+		seen[name] = true
+		permissions = append(permissions, appModels.RolePermission{Permission: name})
+	}
+	return permissions, nil
+}
+
+// permissionSet returns the permissions granted by a custom role's rows
+func permissionSet(permissions []appModels.RolePermission) authz.Set {
+	set := make(authz.Set, len(permissions))
+	for _, granted := range permissions {
+		set[authz.Permission(granted.Permission)] = true
+	}
+	return set
+}
+
+// ensureCovers fails with the missing permissions unless the actor holds all of them
+func ensureCovers(actor, permissions authz.Set) error {
+	if missing := actor.Missing(permissions); len(missing) > 0 {
+		return apperrors.ErrRoleExceedsPermissions.WithDetails(map[string][]authz.Permission{"missing_permissions": missing})
+	}
+	return nil
+}
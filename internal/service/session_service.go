@@ -0,0 +1,231 @@
+package service
+
+import (
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+
+	"square-pos-integration/internal/apperrors"
+	appModels "square-pos-integration/internal/models"
+	"square-pos-integration/internal/signing"
+	"square-pos-integration/internal/tenant"
+	"square-pos-integration/internal/utils"
+)
+
+// TokenPair is a short-lived access token and the refresh token that renews it
+type TokenPair struct {
+	AccessToken  string
+	RefreshToken string
+	ExpiresAt    time.Time
+}
+
+// SessionService issues, rotates and revokes user sessions
+type SessionService struct {
+	DB          *gorm.DB
+	Memberships *MembershipService
+	Keys        *signing.KeyRing // Signs and verifies the access tokens
+}
+
+func NewSessionService(db *gorm.DB, keys *signing.KeyRing) *SessionService {
+	return &SessionService{DB: db, Memberships: NewMembershipService(db), Keys: keys}
+}
+
+// IssueTokens starts a new session for the user in the restaurant they act in, see ActAs
+func (ss *SessionService) IssueTokens(user appModels.User) (TokenPair, error) {
+	return ss.issueTokens(user, uuid.NewString())
+}
+
+// issueTokens signs an access token and stores a new refresh token in the given family
+func (ss *SessionService) issueTokens(user appModels.User, familyID string) (TokenPair, error) {
+	accessToken, err := utils.GenerateJWT(ss.Keys, user)
+	if err != nil {
+		return TokenPair{}, err
+	}
+
+	refreshToken, tokenHash, err := utils.GenerateOpaqueToken()
+	if err != nil {
+		return TokenPair{}, err
+	}
+
+	record := appModels.RefreshToken{
+		UserID:       user.ID,
+		RestaurantID: user.RestaurantID,
+		TokenHash:    tokenHash,
+		FamilyID:     familyID,
+		ExpiresAt:    time.Now().Add(utils.RefreshTokenExpiration),
+	}
+	if err := ss.DB.Create(&record).Error; err != nil {
+		return TokenPair{}, err
+	}
+
+	return TokenPair{
+		AccessToken:  accessToken,
+		RefreshToken: refreshToken,
+		ExpiresAt:    time.Now().Add(utils.JWTExpiration),
+	}, nil
+}
+
+// Refresh exchanges a refresh token for a new token pair in the same restaurant, as long as
+// the user is still a member of it. The presented token is revoked, and presenting an
+// already rotated token revokes its whole family since it means the token was copied.
+func (ss *SessionService) Refresh(refreshToken string) (appModels.User, TokenPair, error) {
+	var record appModels.RefreshToken
+	err := ss.DB.Preload("User").Where("token_hash = ?", utils.HashToken(refreshToken)).First(&record).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return appModels.User{}, TokenPair{}, apperrors.ErrInvalidRefreshToken
+	}
+	if err != nil {
+		return appModels.User{}, TokenPair{}, err
+	}
+
+	if record.RevokedAt != nil {
+		if err := ss.revokeFamily(record.FamilyID); err != nil {
+			return appModels.User{}, TokenPair{}, err
+		}
+		return appModels.User{}, TokenPair{}, apperrors.ErrRefreshTokenReused
+	}
+	if time.Now().After(record.ExpiresAt) {
+		return appModels.User{}, TokenPair{}, apperrors.ErrInvalidRefreshToken
+	}
+	if !record.User.IsActive {
+		return appModels.User{}, TokenPair{}, apperrors.ErrAccountDisabled
+	}
+
+	restaurantID := record.RestaurantID
+	if restaurantID == 0 {
+		restaurantID = record.User.RestaurantID
+	}
+	membership, err := ss.Memberships.FindMembership(record.UserID, restaurantID)
+	if err != nil {
+		return appModels.User{}, TokenPair{}, err
+	}
+	user := ActAs(record.User, membership)
+
+	// Only the request that actually flips revoked_at may rotate, a concurrent
+	// request with the same token is treated as reuse
+	result := ss.DB.Model(&appModels.RefreshToken{}).
+		Where("id = ? AND revoked_at IS NULL", record.ID).
+		Update("revoked_at", time.Now())
+	if result.Error != nil {
+		return appModels.User{}, TokenPair{}, result.Error
+	}
+	if result.RowsAffected == 0 {
+		if err := ss.revokeFamily(record.FamilyID); err != nil {
+			return appModels.User{}, TokenPair{}, err
+		}
+		return appModels.User{}, TokenPair{}, apperrors.ErrRefreshTokenReused
+	}
+
+	tokens, err := ss.issueTokens(user, record.FamilyID)
+	if err != nil {
+		return appModels.User{}, TokenPair{}, err
+	}
+	return user, tokens, nil
+}
+
+// Logout revokes the access token in use and, when given, the refresh token of the same session
+func (ss *SessionService) Logout(userID uint, tokenID string, tokenExpiresAt time.Time, refreshToken string) error {
+	if err := ss.RevokeAccessToken(userID, tokenID, tokenExpiresAt); err != nil {
+		return err
+	}
+	if refreshToken == "" {
+		return nil
+	}
+
+	var record appModels.RefreshToken
+	err := ss.DB.Where("token_hash = ? AND user_id = ?", utils.HashToken(refreshToken), userID).First(&record).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	return ss.revokeFamily(record.FamilyID)
+}
+
+// RevokeAccessToken adds an access token to the revocation list until it expires
+func (ss *SessionService) RevokeAccessToken(userID uint, tokenID string, expiresAt time.Time) error {
+	if tokenID == "" {
+		return nil
+	}
+
+	// Entries for tokens that have expired on their own are no longer needed
+	if err := ss.DB.Where("expires_at < ?", time.Now()).Delete(&appModels.RevokedToken{}).Error; err != nil {
+		return err
+	}
+
+	return ss.DB.Create(&appModels.RevokedToken{
+		JTI:       tokenID,
+		UserID:    userID,
+		ExpiresAt: expiresAt,
+	}).Error
+}
+
+// RevokeAllSessions invalidates every access and refresh token issued to the user.
+// It is called when a user is deactivated or changes their password.
+func (ss *SessionService) RevokeAllSessions(userID uint) error {
+	return ss.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&appModels.User{}).Where("id = ?", userID).
+			Update("token_version", gorm.Expr("token_version + 1")).Error; err != nil {
+			return err
+		}
+		return tx.Model(&appModels.RefreshToken{}).
+			Where("user_id = ? AND revoked_at IS NULL", userID).
+			Update("revoked_at", time.Now()).Error
+	})
+}
+
+// Authenticate checks that validated access token claims still belong to a live session
+// and returns the user they were issued to
+func (ss *SessionService) Authenticate(claims *utils.JWTClaims) (appModels.User, error) {
+	if claims.ID != "" {
+		var revoked int64
+		if err := ss.DB.Model(&appModels.RevokedToken{}).Where("jti = ?", claims.ID).Count(&revoked).Error; err != nil {
+			return appModels.User{}, err
+		}
+		if revoked > 0 {
+			return appModels.User{}, apperrors.ErrTokenRevoked
+		}
+	}
+
+	var user appModels.User
+	err := ss.DB.First(&user, claims.UserID).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return appModels.User{}, apperrors.ErrInvalidToken
+	}
+	if err != nil {
+		return appModels.User{}, err
+	}
+
+	if !user.IsActive {
+		return appModels.User{}, apperrors.ErrAccountDisabled
+	}
+	if user.TokenVersion != claims.TokenVersion {
+		return appModels.User{}, apperrors.ErrTokenRevoked
+	}
+
+	// PIN sessions end as soon as their device is revoked
+	if claims.DeviceID != 0 {
+		var device appModels.Device
+		err := tenant.Scoped(ss.DB, claims.RestaurantID).Where("id = ?", claims.DeviceID).First(&device).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return appModels.User{}, apperrors.ErrTokenRevoked
+		}
+		if err != nil {
+			return appModels.User{}, err
+		}
+		if device.RevokedAt != nil {
+			return appModels.User{}, apperrors.ErrTokenRevoked
+		}
+	}
+	return user, nil
+}
+
+// revokeFamily revokes every refresh token rotated from the same login
+func (ss *SessionService) revokeFamily(familyID string) error {
+	return ss.DB.Model(&appModels.RefreshToken{}).
+		Where("family_id = ? AND revoked_at IS NULL", familyID).
+		Update("revoked_at", time.Now()).Error
+}
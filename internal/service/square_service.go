@@ -2,8 +2,10 @@ package service
 
 import (
 	"context"
+	"errors"
 	"fmt"
-	"strconv"
+	"net/http"
+	"time"
 
 	"github.com/google/uuid"
 	"gorm.io/gorm"
@@ -12,23 +14,93 @@ import (
 	"github.com/square/square-go-sdk/v2/client"
 	"github.com/square/square-go-sdk/v2/option"
 
+	"square-pos-integration/internal/logging"
+	"square-pos-integration/internal/metrics"
 	appModels "square-pos-integration/internal/models"
 	"square-pos-integration/internal/requests"
+	"square-pos-integration/internal/tenant"
 	"square-pos-integration/internal/utils"
 )
 
-// ISquareService defines the interface for Square-related operations.
+// ISquareService covers every call the application makes to Square, so domain services
+// and handlers can be tested against a mock or a fake Square server
 type ISquareService interface {
-	FetchLocationID(token string) (string, error)
-	// Add other method signatures as needed
+	FetchLocationID(ctx context.Context, token string) (string, error)
+	SyncLocations(ctx context.Context, restaurantID uint) ([]appModels.Location, error)
+
+	CreateOrder(ctx context.Context, restaurantID uint, orderRequest requests.CreateOrderRequest, idempotencyKey string) (*square.Order, error)
+	PreviewOrder(ctx context.Context, restaurantID uint, orderRequest requests.CreateOrderRequest) (*square.Order, error)
+	GetOrderDetails(ctx context.Context, restaurantID uint, squareOrderID string) (*square.Order, error)
+	CalculateOrder(ctx context.Context, restaurantID uint, order *square.Order) (*square.Order, error)
+	CancelOrder(ctx context.Context, restaurantID uint, squareOrderID string) (*square.Order, error)
+
+	CreatePaymentIntent(ctx context.Context, restaurantID uint, squareOrderID string, paymentRequest requests.SubmitPaymentRequest) (*square.Payment, error)
+	CompletePayment(ctx context.Context, restaurantID uint, squarePaymentID string, tipAmount float64, currency string) (*square.Payment, error)
+	RefundPayment(ctx context.Context, restaurantID uint, squarePaymentID string, amount int64, currency, reason string) (*square.PaymentRefund, error)
+
+	SyncCatalogTaxes(ctx context.Context, restaurantID uint) ([]appModels.TaxRule, error)
+	PushTaxRule(ctx context.Context, restaurantID uint, rule *appModels.TaxRule) error
 }
 
+var _ ISquareService = (*SquareService)(nil)
+
 type SquareService struct {
 	DB *gorm.DB
+	// BaseURL is the Square API to call, the sandbox unless set otherwise
+	BaseURL string
+	// APIVersion is sent as the Square-Version header, the SDK's version when empty
+	APIVersion string
+	// Timeout limits each request to Square, MaxAttempts is how often a request that
+	// fails with a retryable error is tried
+	Timeout     time.Duration
+	MaxAttempts uint
 }
 
 func NewSquareService(db *gorm.DB) *SquareService {
-	return &SquareService{DB: db}
+	return &SquareService{DB: db, BaseURL: square.Environments.Sandbox, Timeout: 30 * time.Second, MaxAttempts: 2}
+}
+
+// newClient returns a Square client for an access token, configured by the service's fields
+func (ss *SquareService) newClient(token string) *client.Client {
+	return client.NewClient(
+		option.WithToken(token),
+		option.WithBaseURL(ss.BaseURL),
+		option.WithHTTPClient(&http.Client{
+			Timeout:   ss.Timeout,
+			Transport: squareTransport{version: ss.APIVersion, next: http.DefaultTransport},
+		}),
+		option.WithMaxAttempts(ss.MaxAttempts),
+	)
+}
+
+// squareTransport sends the request ID of the call's context as X-Request-ID, so a
+// request can be matched with the Square calls it made, pins the Square-Version header
+// and records every call in the Square metrics. The SDK sets its own version on every
+// request, so the header is replaced on the way out rather than passed as an option.
+type squareTransport struct {
+	version string
+	next    http.RoundTripper
+}
+
+func (t squareTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	req = req.Clone(req.Context())
+	if t.version != "" {
+		req.Header.Set("Square-Version", t.version)
+	}
+	if id := logging.RequestID(req.Context()); id != "" {
+		req.Header.Set("X-Request-ID", id)
+	}
+
+	started := time.Now()
+	resp, err := t.next.RoundTrip(req)
+	metrics.ObserveSquareCall(req, resp, err, time.Since(started))
+	logger := logging.FromContext(req.Context())
+	if err != nil {
+		logger.Warn("Square call failed", "method", req.Method, "path", req.URL.Path, "duration", time.Since(started), "error", err)
+		return nil, err
+	}
+	logger.Debug("Square call", "method", req.Method, "path", req.URL.Path, "status", resp.StatusCode, "duration", time.Since(started))
+	return resp, nil
 }
 
 // getSquareClient returns configured Square client for restaurant
@@ -39,26 +111,20 @@ func (ss *SquareService) getSquareClient(restaurantID uint) (*client.Client, err
 	}
 
 	// Create Square client using the restaurant's access token
-	sqClient := client.NewClient(
-		option.WithToken(restaurant.SquareToken),
-		option.WithBaseURL(square.Environments.Sandbox), // or Production for live
-	)
-
-	return sqClient, nil
+	return ss.newClient(restaurant.SquareToken), nil
 }
 
 func (ss *SquareService) getSquareClientByToken(token string) *client.Client {
-	return client.NewClient(
-		option.WithToken(token),
-		option.WithBaseURL(square.Environments.Sandbox), // Use Production for live
-	)
+	return ss.newClient(token)
 }
 
-// CreateOrder creates order in Square
-func (ss *SquareService) CreateOrder(restaurantID uint, orderRequest requests.CreateOrderRequest, idempotencyKey string) (*square.Order, error) {
-	sqClient, err := ss.getSquareClient(restaurantID)
+// buildOrder builds the Square order for a create order request, including the
+// restaurant's tax and service charge rules, with all money in the currency of the order's
+// location. It is shared by CreateOrder and PreviewOrder.
+func (ss *SquareService) buildOrder(restaurantID uint, orderRequest requests.CreateOrderRequest) (*square.Order, error) {
+	currency, err := ss.locationCurrency(restaurantID, orderRequest.LocationID)
 	if err != nil {
-		return nil, err
+		return nil, fmt.Errorf("failed to load location: %w", err)
 	}
 
 	// Build line items
@@ -73,7 +139,7 @@ func (ss *SquareService) CreateOrder(restaurantID uint, orderRequest requests.Cr
 				Name: square.String(m.Name),
 				BasePriceMoney: &square.Money{
 					Amount:   square.Int64(int64(m.UnitPrice)),
-					Currency: square.Currency("USD").Ptr(),
+					Currency: square.Currency(currency).Ptr(),
 				},
 			})
 		}
@@ -87,7 +153,7 @@ func (ss *SquareService) CreateOrder(restaurantID uint, orderRequest requests.Cr
 				Name: square.String(d.Name),
 				AmountMoney: &square.Money{
 					Amount:   square.Int64(int64(d.Value)),
-					Currency: square.Currency("USD").Ptr(),
+					Currency: square.Currency(currency).Ptr(),
 				},
 				Scope: square.OrderLineItemDiscountScope("LINE_ITEM").Ptr(),
 				Type:  square.OrderLineItemDiscountType("FIXED_AMOUNT").Ptr(),
@@ -99,23 +165,62 @@ func (ss *SquareService) CreateOrder(restaurantID uint, orderRequest requests.Cr
 
 		lineItems = append(lineItems, &square.OrderLineItem{
 			Quantity:        fmt.Sprintf("%d", item.Quantity),
-			CatalogObjectID: square.String(*item.CatalogObjectID),
+			CatalogObjectID: item.CatalogObjectID,              // optional, ad hoc items have none
 			VariationName:   square.String(item.VariationName), // optional, if provided
 			Name:            square.String(item.Name),          // optional, if provided
 			BasePriceMoney: &square.Money{
 				Amount:   square.Int64(int64(item.UnitPrice)),
-				Currency: square.Currency("USD").Ptr(),
+				Currency: square.Currency(currency).Ptr(),
 			},
 			Modifiers:        modifiers,
 			AppliedDiscounts: appliedDiscounts,
 		})
 	}
 
-	order := &square.Order{
-		LocationID:  orderRequest.LocationID,
-		LineItems:   lineItems,
-		Discounts:   orderDiscounts,
-		ReferenceID: square.String(fmt.Sprintf("table-%d", orderRequest.TableNumber)),
+	// Apply the restaurant's tax and service charge rules
+	taxRules, err := ss.applicableTaxRules(restaurantID, orderRequest.LocationID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load tax rules: %w", err)
+	}
+	serviceChargeRules, err := ss.applicableServiceChargeRules(restaurantID, orderRequest)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load service charge rules: %w", err)
+	}
+
+	return &square.Order{
+		LocationID:     orderRequest.LocationID,
+		LineItems:      lineItems,
+		Discounts:      orderDiscounts,
+		Taxes:          buildOrderTaxes(taxRules),
+		ServiceCharges: buildOrderServiceCharges(serviceChargeRules, currency),
+		ReferenceID:    square.String(fmt.Sprintf("table-%d", orderRequest.TableNumber)),
+	}, nil
+}
+
+// locationCurrency returns the currency of one of the restaurant's locations, as stored by
+// the last location sync. Locations not synced yet are assumed to use USD.
+func (ss *SquareService) locationCurrency(restaurantID uint, squareLocationID string) (string, error) {
+	var location appModels.Location
+	err := tenant.Scoped(ss.DB, restaurantID).Where("square_location_id = ?", squareLocationID).First(&location).Error
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return "", err
+	}
+	if location.Currency == "" {
+		return "USD", nil
+	}
+	return location.Currency, nil
+}
+
+// CreateOrder creates order in Square
+func (ss *SquareService) CreateOrder(ctx context.Context, restaurantID uint, orderRequest requests.CreateOrderRequest, idempotencyKey string) (*square.Order, error) {
+	sqClient, err := ss.getSquareClient(restaurantID)
+	if err != nil {
+		return nil, err
+	}
+
+	order, err := ss.buildOrder(restaurantID, orderRequest)
+	if err != nil {
+		return nil, err
 	}
 
 	// Create order request
@@ -124,7 +229,7 @@ func (ss *SquareService) CreateOrder(restaurantID uint, orderRequest requests.Cr
 		IdempotencyKey: square.String(idempotencyKey),
 	}
 
-	response, err := sqClient.Orders.Create(context.TODO(), req)
+	response, err := sqClient.Orders.Create(ctx, req)
 	if err != nil {
 		return nil, err
 	}
@@ -132,11 +237,21 @@ func (ss *SquareService) CreateOrder(restaurantID uint, orderRequest requests.Cr
 	return response.Order, nil
 }
 
+// PreviewOrder prices a create order request through Square without creating anything
+func (ss *SquareService) PreviewOrder(ctx context.Context, restaurantID uint, orderRequest requests.CreateOrderRequest) (*square.Order, error) {
+	order, err := ss.buildOrder(restaurantID, orderRequest)
+	if err != nil {
+		return nil, err
+	}
+
+	return ss.CalculateOrder(ctx, restaurantID, order)
+}
+
 // FetchLocationID retrieves the location ID for a given token
-func (ss *SquareService) FetchLocationID(token string) (string, error) {
+func (ss *SquareService) FetchLocationID(ctx context.Context, token string) (string, error) {
 	sqClient := ss.getSquareClientByToken(token)
 
-	resp, err := sqClient.Locations.List(context.TODO())
+	resp, err := sqClient.Locations.List(ctx)
 	if err != nil {
 		return "", err
 	}
@@ -148,14 +263,90 @@ func (ss *SquareService) FetchLocationID(token string) (string, error) {
 	return *resp.Locations[0].ID, nil
 }
 
+// SyncLocations pulls the restaurant's locations from Square's Locations API. Locations
+// Square no longer lists are kept but marked inactive, since orders still refer to them.
+func (ss *SquareService) SyncLocations(ctx context.Context, restaurantID uint) ([]appModels.Location, error) {
+	sqClient, err := ss.getSquareClient(restaurantID)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := sqClient.Locations.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	syncedAt := time.Now()
+	var synced []appModels.Location
+	var seen []string
+	for _, squareLocation := range resp.Locations {
+		if squareLocation == nil || squareLocation.ID == nil {
+			continue
+		}
+		location, err := ss.upsertLocation(restaurantID, squareLocation, syncedAt)
+		if err != nil {
+			return nil, err
+		}
+		synced = append(synced, location)
+		seen = append(seen, location.SquareLocationID)
+	}
+
+	missing := tenant.Scoped(ss.DB, restaurantID).Model(&appModels.Location{})
+	if len(seen) > 0 {
+		missing = missing.Where("square_location_id NOT IN ?", seen)
+	}
+	if err := missing.Update("status", appModels.LocationStatusInactive).Error; err != nil {
+		return nil, err
+	}
+
+	return synced, nil
+}
+
+// upsertLocation creates or updates the local location mirroring a Square location
+func (ss *SquareService) upsertLocation(restaurantID uint, squareLocation *square.Location, syncedAt time.Time) (appModels.Location, error) {
+	db := tenant.Scoped(ss.DB, restaurantID)
+
+	var location appModels.Location
+	err := db.Where("square_location_id = ?", *squareLocation.ID).First(&location).Error
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return location, err
+	}
+
+	location.RestaurantID = restaurantID
+	location.SquareLocationID = *squareLocation.ID
+	location.Name = utils.SafeString(squareLocation.Name)
+	location.Timezone = utils.SafeString(squareLocation.Timezone)
+	location.Currency = utils.SafeCurrency(squareLocation.Currency)
+	location.Status = appModels.LocationStatusInactive
+	if squareLocation.Status != nil {
+		location.Status = string(*squareLocation.Status)
+	}
+	if address := squareLocation.Address; address != nil {
+		location.AddressLine1 = utils.SafeString(address.AddressLine1)
+		location.AddressLine2 = utils.SafeString(address.AddressLine2)
+		location.Locality = utils.SafeString(address.Locality)
+		location.Region = utils.SafeString(address.AdministrativeDistrictLevel1)
+		location.PostalCode = utils.SafeString(address.PostalCode)
+		if address.Country != nil {
+			location.Country = string(*address.Country)
+		}
+	}
+	location.SyncedAt = &syncedAt
+
+	if err := db.Save(&location).Error; err != nil {
+		return location, err
+	}
+	return location, nil
+}
+
 // GetOrderDetails retrieves order details from Square
-func (ss *SquareService) GetOrderDetails(restaurantID uint, squareOrderID string) (*square.Order, error) {
+func (ss *SquareService) GetOrderDetails(ctx context.Context, restaurantID uint, squareOrderID string) (*square.Order, error) {
 	sqClient, err := ss.getSquareClient(restaurantID)
 	if err != nil {
 		return nil, err
 	}
 
-	response, err := sqClient.Orders.Get(context.Background(), &square.GetOrdersRequest{
+	response, err := sqClient.Orders.Get(ctx, &square.GetOrdersRequest{
 		OrderID: squareOrderID,
 	})
 	if err != nil {
@@ -165,24 +356,69 @@ func (ss *SquareService) GetOrderDetails(restaurantID uint, squareOrderID string
 	return response.Order, nil
 }
 
-// CreatePaymentIntent creates a payment intent in Square
-func (ss *SquareService) CreatePaymentIntent(restaurantID, squareOrderID string, paymentRequest requests.SubmitPaymentRequest) (*square.Payment, error) {
+// CancelOrder moves an open Square order to the CANCELED state
+func (ss *SquareService) CancelOrder(ctx context.Context, restaurantID uint, squareOrderID string) (*square.Order, error) {
+	sqClient, err := ss.getSquareClient(restaurantID)
+	if err != nil {
+		return nil, err
+	}
 
-	restaurantIDUint, err := strconv.ParseUint(restaurantID, 10, 64)
+	// Square rejects updates that do not name the current version of the order
+	current, err := ss.GetOrderDetails(ctx, restaurantID, squareOrderID)
 	if err != nil {
-		return nil, fmt.Errorf("invalid restaurant ID: %w", err)
+		return nil, err
 	}
-	sqClient, err := ss.getSquareClient(uint(restaurantIDUint))
+
+	response, err := sqClient.Orders.Update(ctx, &square.UpdateOrderRequest{
+		OrderID: squareOrderID,
+		Order: &square.Order{
+			LocationID: current.LocationID,
+			Version:    current.Version,
+			State:      square.OrderState("CANCELED").Ptr(),
+		},
+		IdempotencyKey: square.String("cancel-" + uuid.NewString()),
+	})
 	if err != nil {
 		return nil, err
 	}
+
+	return response.Order, nil
+}
+
+// CalculateOrder runs an order through Square's pricing engine without creating or changing it
+func (ss *SquareService) CalculateOrder(ctx context.Context, restaurantID uint, order *square.Order) (*square.Order, error) {
+	sqClient, err := ss.getSquareClient(restaurantID)
+	if err != nil {
+		return nil, err
+	}
+
+	response, err := sqClient.Orders.Calculate(ctx, &square.CalculateOrderRequest{
+		Order: order,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return response.Order, nil
+}
+
+// CreatePaymentIntent creates a payment intent in Square, in the currency of the payment's location
+func (ss *SquareService) CreatePaymentIntent(ctx context.Context, restaurantID uint, squareOrderID string, paymentRequest requests.SubmitPaymentRequest) (*square.Payment, error) {
+	sqClient, err := ss.getSquareClient(restaurantID)
+	if err != nil {
+		return nil, err
+	}
+	currency, err := ss.locationCurrency(restaurantID, paymentRequest.LocationID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load location: %w", err)
+	}
 	idempotencyKey := "pay-" + uuid.NewString()
 
 	createPaymentRequest := &square.CreatePaymentRequest{
 		SourceID: utils.SafeString(&paymentRequest.SourceID),
 		AmountMoney: &square.Money{
-			Amount:   square.Int64(int64(paymentRequest.Amount * 100)),
-			Currency: square.Currency("USD").Ptr(),
+			Amount:   square.Int64(utils.ToCents(paymentRequest.Amount)),
+			Currency: square.Currency(currency).Ptr(),
 		},
 		OrderID:        &squareOrderID,
 		IdempotencyKey: idempotencyKey,
@@ -190,7 +426,7 @@ func (ss *SquareService) CreatePaymentIntent(restaurantID, squareOrderID string,
 		Autocomplete:   square.Bool(false),
 	}
 
-	response, err := sqClient.Payments.Create(context.Background(), createPaymentRequest)
+	response, err := sqClient.Payments.Create(ctx, createPaymentRequest)
 	if err != nil {
 		return nil, err
 	}
@@ -198,7 +434,9 @@ func (ss *SquareService) CreatePaymentIntent(restaurantID, squareOrderID string,
 	return response.Payment, nil
 }
 
-func (ss *SquareService) CompletePayment(restaurantID uint, squarePaymentID string, tipAmount float64) (*square.Payment, error) {
+// CompletePayment adds the tip to an approved payment and captures it. currency is the
+// payment's, which Square requires the tip to match.
+func (ss *SquareService) CompletePayment(ctx context.Context, restaurantID uint, squarePaymentID string, tipAmount float64, currency string) (*square.Payment, error) {
 	sqClient, err := ss.getSquareClient(restaurantID)
 	if err != nil {
 		return nil, err
@@ -212,14 +450,14 @@ func (ss *SquareService) CompletePayment(restaurantID uint, squarePaymentID stri
 			PaymentID: squarePaymentID,
 			Payment: &square.Payment{
 				TipMoney: &square.Money{
-					Amount:   square.Int64(int64(tipAmount * 100)),
-					Currency: square.Currency("USD").Ptr(),
+					Amount:   square.Int64(utils.ToCents(tipAmount)),
+					Currency: square.Currency(currency).Ptr(),
 				},
 			},
 			IdempotencyKey: idempotencyKey,
 		}
 
-		_, err := sqClient.Payments.Update(context.Background(), updateRequest)
+		_, err := sqClient.Payments.Update(ctx, updateRequest)
 		if err != nil {
 			return nil, fmt.Errorf("failed to update payment with tip: %w", err)
 		}
@@ -230,7 +468,7 @@ func (ss *SquareService) CompletePayment(restaurantID uint, squarePaymentID stri
 		PaymentID: squarePaymentID,
 	}
 
-	response, err := sqClient.Payments.Complete(context.Background(), completeRequest)
+	response, err := sqClient.Payments.Complete(ctx, completeRequest)
 	if err != nil {
 		return nil, err
 	}
@@ -238,8 +476,36 @@ func (ss *SquareService) CompletePayment(restaurantID uint, squarePaymentID stri
 	return response.Payment, nil
 }
 
+// RefundPayment refunds part or all of a completed payment. amount is in the smallest unit of
+// the payment's currency.
+func (ss *SquareService) RefundPayment(ctx context.Context, restaurantID uint, squarePaymentID string, amount int64, currency, reason string) (*square.PaymentRefund, error) {
+	sqClient, err := ss.getSquareClient(restaurantID)
+	if err != nil {
+		return nil, err
+	}
+
+	refundRequest := &square.RefundPaymentRequest{
+		IdempotencyKey: "refund-" + uuid.NewString(),
+		PaymentID:      square.String(squarePaymentID),
+		AmountMoney: &square.Money{
+			Amount:   square.Int64(amount),
+			Currency: square.Currency(currency).Ptr(),
+		},
+	}
+	if reason != "" {
+		refundRequest.Reason = square.String(reason)
+	}
+
+	response, err := sqClient.Refunds.RefundPayment(ctx, refundRequest)
+	if err != nil {
+		return nil, err
+	}
+
+	return response.Refund, nil
+}
+
 // CompletePayment completes a payment using Square's Payments API
-// func (ss *SquareService) CompletePayment(restaurantID uint, squarePaymentID string) (*square.Payment, error) {
+// func (ss *SquareService) CompletePayment(ctx context.Context, restaurantID uint, squarePaymentID string) (*square.Payment, error) {
 // 	sqClient, err := ss.getSquareClient(restaurantID)
 // 	if err != nil {
 // 		return nil, err
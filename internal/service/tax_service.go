@@ -0,0 +1,216 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+
+	square "github.com/square/square-go-sdk/v2"
+	"github.com/square/square-go-sdk/v2/catalog"
+
+	appModels "square-pos-integration/internal/models"
+	"square-pos-integration/internal/requests"
+	"square-pos-integration/internal/tenant"
+	"square-pos-integration/internal/utils"
+)
+
+// applicableTaxRules returns the enabled tax rules for a restaurant location
+func (ss *SquareService) applicableTaxRules(restaurantID uint, locationID string) ([]appModels.TaxRule, error) {
+	var rules []appModels.TaxRule
+	err := tenant.Scoped(ss.DB, restaurantID).
+		Where("enabled = ? AND (location_id = '' OR location_id = ?)", true, locationID).
+		Order("id").
+		Find(&rules).Error
+	return rules, err
+}
+
+// applicableServiceChargeRules returns the service charge rules that match the order being created
+func (ss *SquareService) applicableServiceChargeRules(restaurantID uint, orderRequest requests.CreateOrderRequest) ([]appModels.ServiceChargeRule, error) {
+	var rules []appModels.ServiceChargeRule
+	err := tenant.Scoped(ss.DB, restaurantID).
+		Where("enabled = ? AND (location_id = '' OR location_id = ?)", true, orderRequest.LocationID).
+		Order("id").
+		Find(&rules).Error
+	if err != nil {
+		return nil, err
+	}
+
+	var matched []appModels.ServiceChargeRule
+	for _, rule := range rules {
+		if rule.AppliesTo(orderRequest.GuestCount, orderRequest.OrderType) {
+			matched = append(matched, rule)
+		}
+	}
+	return matched, nil
+}
+
+// buildOrderTaxes converts tax rules into order-scoped Square taxes
+func buildOrderTaxes(rules []appModels.TaxRule) []*square.OrderLineItemTax {
+	var taxes []*square.OrderLineItemTax
+	for _, rule := range rules {
+		tax := &square.OrderLineItemTax{
+			UID:   square.String("tax-" + strconv.FormatUint(uint64(rule.ID), 10)),
+			Scope: square.OrderLineItemTaxScope("ORDER").Ptr(),
+		}
+		if rule.SquareCatalogObjectID != "" {
+			// Square fills in name, rate and inclusion type from the catalog
+			tax.CatalogObjectID = square.String(rule.SquareCatalogObjectID)
+		} else {
+			tax.Name = square.String(rule.Name)
+			tax.Percentage = square.String(rule.Percentage)
+			tax.Type = square.OrderLineItemTaxType(rule.InclusionType).Ptr()
+		}
+		taxes = append(taxes, tax)
+	}
+	return taxes
+}
+
+// buildOrderServiceCharges converts service charge rules into Square service charges. Fixed
+// amounts are in the currency of the order's location.
+func buildOrderServiceCharges(rules []appModels.ServiceChargeRule, currency string) []*square.OrderServiceCharge {
+	var charges []*square.OrderServiceCharge
+	for _, rule := range rules {
+		charge := &square.OrderServiceCharge{
+			UID:              square.String("service-charge-" + strconv.FormatUint(uint64(rule.ID), 10)),
+			Name:             square.String(rule.Name),
+			CalculationPhase: square.OrderServiceChargeCalculationPhase(rule.CalculationPhase).Ptr(),
+			Taxable:          square.Bool(rule.Taxable),
+		}
+		if rule.Percentage != "" {
+			charge.Percentage = square.String(rule.Percentage)
+		} else {
+			charge.AmountMoney = &square.Money{
+				Amount:   square.Int64(rule.Amount),
+				Currency: square.Currency(currency).Ptr(),
+			}
+		}
+		charges = append(charges, charge)
+	}
+	return charges
+}
+
+// SyncCatalogTaxes pulls TAX objects from the restaurant's Square catalog into local tax rules
+func (ss *SquareService) SyncCatalogTaxes(ctx context.Context, restaurantID uint) ([]appModels.TaxRule, error) {
+	sqClient, err := ss.getSquareClient(restaurantID)
+	if err != nil {
+		return nil, err
+	}
+
+	page, err := sqClient.Catalog.List(ctx, &square.ListCatalogRequest{
+		Types: square.String("TAX"),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var synced []appModels.TaxRule
+	iter := page.Iterator()
+	for iter.Next(ctx) {
+		object := iter.Current()
+		if object == nil || object.Tax == nil || object.Tax.TaxData == nil {
+			continue
+		}
+
+		locationIDs := []string{""}
+		if object.Tax.PresentAtAllLocations != nil && !*object.Tax.PresentAtAllLocations {
+			locationIDs = object.Tax.PresentAtLocationIDs
+		}
+
+		for _, locationID := range locationIDs {
+			rule, err := ss.upsertCatalogTax(restaurantID, locationID, object.Tax)
+			if err != nil {
+				return nil, err
+			}
+			synced = append(synced, rule)
+		}
+	}
+	if err := iter.Err(); err != nil {
+		return nil, err
+	}
+
+	return synced, nil
+}
+
+// upsertCatalogTax creates or updates the local tax rule mirroring a Square catalog tax
+func (ss *SquareService) upsertCatalogTax(restaurantID uint, locationID string, object *square.CatalogObjectTax) (appModels.TaxRule, error) {
+	db := tenant.Scoped(ss.DB, restaurantID)
+
+	var rule appModels.TaxRule
+	err := db.Where("square_catalog_object_id = ? AND location_id = ?", object.ID, locationID).
+		First(&rule).Error
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return rule, err
+	}
+
+	data := object.TaxData
+	rule.RestaurantID = restaurantID
+	rule.LocationID = locationID
+	rule.Name = utils.SafeString(data.Name)
+	rule.Percentage = utils.SafeString(data.Percentage)
+	rule.InclusionType = "ADDITIVE"
+	if data.InclusionType != nil {
+		rule.InclusionType = string(*data.InclusionType)
+	}
+	rule.Enabled = (data.Enabled == nil || *data.Enabled) && (object.IsDeleted == nil || !*object.IsDeleted)
+	rule.SquareCatalogObjectID = object.ID
+	rule.SquareCatalogVersion = utils.SafeInt64(object.Version)
+
+	if err := db.Save(&rule).Error; err != nil {
+		return rule, fmt.Errorf("failed to save tax rule: %w", err)
+	}
+	return rule, nil
+}
+
+// PushTaxRule creates or updates the rule as a TAX object in the restaurant's Square catalog
+func (ss *SquareService) PushTaxRule(ctx context.Context, restaurantID uint, rule *appModels.TaxRule) error {
+	sqClient, err := ss.getSquareClient(restaurantID)
+	if err != nil {
+		return err
+	}
+
+	tax := &square.CatalogObjectTax{
+		ID: rule.SquareCatalogObjectID,
+		TaxData: &square.CatalogTax{
+			Name:                   square.String(rule.Name),
+			Percentage:             square.String(rule.Percentage),
+			InclusionType:          square.TaxInclusionType(rule.InclusionType).Ptr(),
+			CalculationPhase:       square.TaxCalculationPhase("TAX_SUBTOTAL_PHASE").Ptr(),
+			AppliesToCustomAmounts: square.Bool(true),
+			Enabled:                square.Bool(rule.Enabled),
+		},
+	}
+	if tax.ID == "" {
+		// Temporary IDs starting with # tell Square to create a new object
+		tax.ID = "#tax-" + strconv.FormatUint(uint64(rule.ID), 10)
+	} else {
+		tax.Version = square.Int64(rule.SquareCatalogVersion)
+	}
+	if rule.LocationID == "" {
+		tax.PresentAtAllLocations = square.Bool(true)
+	} else {
+		tax.PresentAtAllLocations = square.Bool(false)
+		tax.PresentAtLocationIDs = []string{rule.LocationID}
+	}
+
+	response, err := sqClient.Catalog.Object.Upsert(ctx, &catalog.UpsertCatalogObjectRequest{
+		IdempotencyKey: "tax-" + uuid.NewString(),
+		Object:         &square.CatalogObject{Type: "TAX", Tax: tax},
+	})
+	if err != nil {
+		return err
+	}
+	if response.CatalogObject == nil || response.CatalogObject.Tax == nil {
+		return fmt.Errorf("square returned no catalog object")
+	}
+
+	rule.SquareCatalogObjectID = response.CatalogObject.Tax.ID
+	rule.SquareCatalogVersion = utils.SafeInt64(response.CatalogObject.Tax.Version)
+	return tenant.Scoped(ss.DB, restaurantID).Model(rule).Updates(map[string]interface{}{
+		"square_catalog_object_id": rule.SquareCatalogObjectID,
+		"square_catalog_version":   rule.SquareCatalogVersion,
+	}).Error
+}
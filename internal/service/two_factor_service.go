@@ -0,0 +1,313 @@
+package service
+
+import (
+	"errors"
+	"sort"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+
+	"square-pos-integration/internal/apperrors"
+	appModels "square-pos-integration/internal/models"
+	"square-pos-integration/internal/utils"
+)
+
+// LoginChallengeExpiration is how long the second step of a two-factor login can take
+const LoginChallengeExpiration = 5 * time.Minute
+
+// RecoveryCodeCount is how many recovery codes a user gets at a time
+const RecoveryCodeCount = 10
+
+// TwoFactorService enrolls users in TOTP two-factor authentication and runs the second
+// step of their logins
+type TwoFactorService struct {
+	DB       *gorm.DB
+	Accounts *AccountService
+	Sessions *SessionService
+	Secrets  *utils.SecretBox // Encrypts the TOTP secrets
+	Issuer   string           // Shown by authenticator apps
+}
+
+func NewTwoFactorService(db *gorm.DB, accounts *AccountService, secrets *utils.SecretBox) *TwoFactorService {
+	return &TwoFactorService{DB: db, Accounts: accounts, Sessions: accounts.Sessions, Secrets: secrets, Issuer: "Square POS"}
+}
+
+// TwoFactorEnabled reports whether the user has confirmed two-factor enrollment
+func TwoFactorEnabled(user appModels.User) bool {
+	return user.TOTPEnabledAt != nil
+}
+
+// Required reports whether the user's restaurant requires two-factor authentication for
+// the user's role. The restaurant must be preloaded.
+func (ts *TwoFactorService) Required(user appModels.User) bool {
+	for _, role := range TwoFactorRoles(user.Restaurant) {
+		if role == user.Role {
+			return true
+		}
+	}
+	return false
+}
+
+// TwoFactorRoles returns the roles the restaurant requires two-factor authentication for
+func TwoFactorRoles(restaurant appModels.Restaurant) []string {
+	roles := []string{}
+	for _, role := range strings.Split(restaurant.TwoFactorRoles, ",") {
+		if role = strings.TrimSpace(role); role != "" {
+			roles = append(roles, role)
+		}
+	}
+	return roles
+}
+
+// SetRequiredRoles replaces the roles the restaurant requires two-factor authentication for
+func (ts *TwoFactorService) SetRequiredRoles(restaurant *appModels.Restaurant, roles []string) error {
+	unique := map[string]bool{}
+	for _, role := range roles {
+		if err := NewRoleService(ts.DB).EnsureRoleExists(restaurant.ID, role); err != nil {
+			return err
+		}
+		unique[role] = true
+	}
+	names := make([]string, 0, len(unique))
+	for role := range unique {
+		names = append(names, role)
+	}
+	sort.Strings(names)
+
+	restaurant.TwoFactorRoles = strings.Join(names, ",")
+	return ts.DB.Model(restaurant).Update("two_factor_roles", restaurant.TwoFactorRoles).Error
+}
+
+// StartChallenge returns a single-use token for the second step of a login with a
+// correct password
+func (ts *TwoFactorService) StartChallenge(user appModels.User) (string, time.Time, error) {
+	token, err := ts.Accounts.issueToken(user.ID, appModels.TokenPurposeLoginChallenge, LoginChallengeExpiration)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	return token, time.Now().Add(LoginChallengeExpiration), nil
+}
+
+// ChallengeUser returns the user a login challenge was issued to, with their restaurant,
+// without using up the challenge
+func (ts *TwoFactorService) ChallengeUser(challengeToken string) (appModels.User, error) {
+	record, err := ts.Accounts.findToken(challengeToken, appModels.TokenPurposeLoginChallenge)
+	if errors.Is(err, errTokenUnusable) {
+		return appModels.User{}, apperrors.ErrInvalidLoginChallenge
+	}
+	if err != nil {
+		return appModels.User{}, err
+	}
+
+	var user appModels.User
+	if err := ts.DB.Preload("Restaurant").First(&user, record.UserID).Error; err != nil {
+		return appModels.User{}, err
+	}
+	if !user.IsActive {
+		return appModels.User{}, apperrors.ErrAccountDisabled
+	}
+	return user, nil
+}
+
+// CompleteChallenge checks the second factor for a login challenge and uses the challenge
+// up. A user enrolling during login confirms their first code here, in which case their
+// new recovery codes are returned.
+func (ts *TwoFactorService) CompleteChallenge(challengeToken string, user *appModels.User, code, recoveryCode string) ([]string, error) {
+	var recoveryCodes []string
+	switch {
+	case TwoFactorEnabled(*user) && recoveryCode != "":
+		if err := ts.useRecoveryCode(user.ID, recoveryCode); err != nil {
+			return nil, err
+		}
+	case TwoFactorEnabled(*user):
+		if err := ts.verifyCode(user, code); err != nil {
+			return nil, err
+		}
+	default:
+		codes, err := ts.Activate(user, code)
+		if err != nil {
+			return nil, err
+		}
+		recoveryCodes = codes
+	}
+
+	if _, err := ts.Accounts.consumeToken(challengeToken, appModels.TokenPurposeLoginChallenge); err != nil {
+		if errors.Is(err, errTokenUnusable) {
+			return nil, apperrors.ErrInvalidLoginChallenge
+		}
+		return nil, err
+	}
+	return recoveryCodes, nil
+}
+
+// Enroll stores a new TOTP secret for the user and returns it with its provisioning URI.
+// Two-factor authentication is only enabled once Activate confirms a code.
+func (ts *TwoFactorService) Enroll(user *appModels.User) (string, string, error) {
+	if TwoFactorEnabled(*user) {
+		return "", "", apperrors.ErrTwoFactorAlreadyEnabled
+	}
+
+	secret, err := utils.GenerateTOTPSecret()
+	if err != nil {
+		return "", "", err
+	}
+	encrypted, err := ts.Secrets.Encrypt(secret)
+	if err != nil {
+		return "", "", err
+	}
+
+	user.TOTPSecret = &encrypted
+	if err := ts.DB.Model(user).Updates(map[string]interface{}{
+		"totp_secret":    encrypted,
+		"totp_last_step": 0,
+	}).Error; err != nil {
+		return "", "", err
+	}
+	return secret, utils.TOTPProvisioningURI(secret, ts.Issuer, user.Email), nil
+}
+
+// Activate enables two-factor authentication once the user proves their app produces
+// valid codes, and returns their recovery codes
+func (ts *TwoFactorService) Activate(user *appModels.User, code string) ([]string, error) {
+	if TwoFactorEnabled(*user) {
+		return nil, apperrors.ErrTwoFactorAlreadyEnabled
+	}
+	if user.TOTPSecret == nil {
+		return nil, apperrors.ErrTwoFactorNotEnrolled
+	}
+	if err := ts.verifyCode(user, code); err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	user.TOTPEnabledAt = &now
+	if err := ts.DB.Model(user).Update("totp_enabled_at", now).Error; err != nil {
+		return nil, err
+	}
+	return ts.replaceRecoveryCodes(user.ID)
+}
+
+// RegenerateRecoveryCodes replaces the user's recovery codes after checking a current code
+func (ts *TwoFactorService) RegenerateRecoveryCodes(user *appModels.User, code string) ([]string, error) {
+	if !TwoFactorEnabled(*user) {
+		return nil, apperrors.ErrTwoFactorNotEnabled
+	}
+	if err := ts.verifyCode(user, code); err != nil {
+		return nil, err
+	}
+	return ts.replaceRecoveryCodes(user.ID)
+}
+
+// Disable turns two-factor authentication off after checking a current code, unless the
+// user's role requires it
+func (ts *TwoFactorService) Disable(user *appModels.User, code string) error {
+	if !TwoFactorEnabled(*user) {
+		return apperrors.ErrTwoFactorNotEnabled
+	}
+	if ts.Required(*user) {
+		return apperrors.ErrTwoFactorRequired
+	}
+	if err := ts.verifyCode(user, code); err != nil {
+		return err
+	}
+	return ts.clear(user)
+}
+
+// Reset turns two-factor authentication off for a user who lost their device, on behalf
+// of an admin, and ends the user's sessions. A role that requires it makes the user
+// enroll again on their next login.
+func (ts *TwoFactorService) Reset(user *appModels.User) error {
+	if err := ts.clear(user); err != nil {
+		return err
+	}
+	return ts.Sessions.RevokeAllSessions(user.ID)
+}
+
+// verifyCode checks a TOTP code against the user's secret and records its time step so
+// the same code cannot be used twice
+func (ts *TwoFactorService) verifyCode(user *appModels.User, code string) error {
+	if user.TOTPSecret == nil {
+		return apperrors.ErrTwoFactorNotEnrolled
+	}
+	secret, err := ts.Secrets.Decrypt(*user.TOTPSecret)
+	if err != nil {
+		return err
+	}
+
+	step, ok := utils.VerifyTOTP(secret, code, time.Now())
+	if !ok || step <= user.TOTPLastStep {
+		return apperrors.ErrInvalidTwoFactorCode
+	}
+
+	// A concurrent request with the same code only succeeds once
+	result := ts.DB.Model(&appModels.User{}).
+		Where("id = ? AND totp_last_step < ?", user.ID, step).
+		Update("totp_last_step", step)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return apperrors.ErrInvalidTwoFactorCode
+	}
+	user.TOTPLastStep = step
+	return nil
+}
+
+// useRecoveryCode marks one of the user's recovery codes as used
+func (ts *TwoFactorService) useRecoveryCode(userID uint, code string) error {
+	result := ts.DB.Model(&appModels.RecoveryCode{}).
+		Where("user_id = ? AND code_hash = ? AND used_at IS NULL", userID, utils.HashToken(utils.NormalizeRecoveryCode(code))).
+		Update("used_at", time.Now())
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return apperrors.ErrInvalidTwoFactorCode
+	}
+	return nil
+}
+
+// replaceRecoveryCodes stores a new set of recovery codes, dropping the previous ones
+func (ts *TwoFactorService) replaceRecoveryCodes(userID uint) ([]string, error) {
+	codes, err := utils.GenerateRecoveryCodes(RecoveryCodeCount)
+	if err != nil {
+		return nil, err
+	}
+
+	records := make([]appModels.RecoveryCode, 0, len(codes))
+	for _, code := range codes {
+		records = append(records, appModels.RecoveryCode{UserID: userID, CodeHash: utils.HashToken(code)})
+	}
+	err = ts.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("user_id = ?", userID).Delete(&appModels.RecoveryCode{}).Error; err != nil {
+			return err
+		}
+		return tx.Create(&records).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return codes, nil
+}
+
+// clear removes the user's secret and recovery codes
+func (ts *TwoFactorService) clear(user *appModels.User) error {
+	err := ts.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(user).Updates(map[string]interface{}{
+			"totp_secret":     nil,
+			"totp_enabled_at": nil,
+			"totp_last_step":  0,
+		}).Error; err != nil {
+			return err
+		}
+		return tx.Where("user_id = ?", user.ID).Delete(&appModels.RecoveryCode{}).Error
+	})
+	if err != nil {
+		return err
+	}
+	user.TOTPSecret = nil
+	user.TOTPEnabledAt = nil
+	user.TOTPLastStep = 0
+	return nil
+}
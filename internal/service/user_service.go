@@ -0,0 +1,244 @@
+package service
+
+import (
+	"errors"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"square-pos-integration/internal/apperrors"
+	"square-pos-integration/internal/authz"
+	appModels "square-pos-integration/internal/models"
+	"square-pos-integration/internal/requests"
+	"square-pos-integration/internal/utils"
+)
+
+// UserService manages the users of a restaurant
+type UserService struct {
+	DB       *gorm.DB
+	Sessions *SessionService
+	Roles    *RoleService
+}
+
+func NewUserService(db *gorm.DB, sessions *SessionService) *UserService {
+	return &UserService{DB: db, Sessions: sessions, Roles: NewRoleService(db)}
+}
+
+// EnsureUnique checks that no other user already has the username or email
+func (us *UserService) EnsureUnique(username, email string, excludeID uint) error {
+	if username != "" {
+		var count int64
+		if err := us.DB.Model(&appModels.User{}).Where("username = ? AND id <> ?", username, excludeID).Count(&count).Error; err != nil {
+			return err
+		}
+		if count > 0 {
+			return apperrors.ErrUsernameAlreadyExists
+		}
+	}
+	if email != "" {
+		var count int64
+		if err := us.DB.Model(&appModels.User{}).Where("email = ? AND id <> ?", email, excludeID).Count(&count).Error; err != nil {
+			return err
+		}
+		if count > 0 {
+			return apperrors.ErrEmailAlreadyExists
+		}
+	}
+	return nil
+}
+
+// CreateUser adds a user together with their membership of their home restaurant
+func (us *UserService) CreateUser(user *appModels.User) error {
+	return us.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(user).Error; err != nil {
+			return err
+		}
+		return tx.Create(&appModels.Membership{
+			UserID:       user.ID,
+			RestaurantID: user.RestaurantID,
+			Role:         user.Role,
+			IsActive:     true,
+		}).Error
+	})
+}
+
+// RegisterUser creates a user in the restaurant with a password chosen by the actor. The
+// role defaults to staff and must be one the actor can grant.
+func (us *UserService) RegisterUser(actor authz.Set, restaurantID uint, registerRequest requests.RegisterUserRequest) (appModels.User, error) {
+	if registerRequest.Role == "" {
+		registerRequest.Role = "staff"
+	}
+	if err := us.Roles.EnsureRoleExists(restaurantID, registerRequest.Role); err != nil {
+		return appModels.User{}, err
+	}
+	if err := us.Roles.EnsureCanGrant(actor, restaurantID, registerRequest.Role); err != nil {
+		return appModels.User{}, err
+	}
+	if err := us.EnsureUnique(registerRequest.Username, registerRequest.Email, 0); err != nil {
+		return appModels.User{}, err
+	}
+
+	hashedPassword, err := utils.HashPassword(registerRequest.Password)
+	if err != nil {
+		return appModels.User{}, err
+	}
+	user := appModels.User{
+		Email:        registerRequest.Email,
+		PasswordHash: hashedPassword,
+		RestaurantID: restaurantID,
+		Role:         registerRequest.Role,
+		Username:     registerRequest.Username,
+		IsActive:     true,
+	}
+	if err := us.CreateUser(&user); err != nil {
+		return appModels.User{}, err
+	}
+	return user, nil
+}
+
+// FindUser returns a user of the restaurant
+func (us *UserService) FindUser(restaurantID uint, userID string) (appModels.User, error) {
+	var user appModels.User
+	err := us.DB.Where("id = ? AND restaurant_id = ?", userID, restaurantID).First(&user).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return user, apperrors.ErrUserNotFound
+	}
+	return user, err
+}
+
+// EnsureCanManage checks that the actor holds every permission of the user's role, so
+// managers cannot take over the accounts of users above them
+func (us *UserService) EnsureCanManage(actor authz.Set, user appModels.User) error {
+	return us.Roles.EnsureCanGrant(actor, user.RestaurantID, user.Role)
+}
+
+// UpdateUser applies an admin's changes to a user. Role changes and deactivation end the
+// user's sessions so tokens carrying the old role stop working.
+func (us *UserService) UpdateUser(actor authz.Set, user *appModels.User, updateRequest requests.UpdateUserRequest) error {
+	if err := us.EnsureCanManage(actor, *user); err != nil {
+		return err
+	}
+	if err := us.EnsureUnique(updateRequest.Username, updateRequest.Email, user.ID); err != nil {
+		return err
+	}
+
+	updates := map[string]interface{}{}
+	if updateRequest.Username != "" {
+		updates["username"] = updateRequest.Username
+	}
+	if updateRequest.Email != "" {
+		updates["email"] = updateRequest.Email
+	}
+	roleChanged := updateRequest.Role != "" && updateRequest.Role != user.Role
+	if roleChanged {
+		if err := us.Roles.EnsureRoleExists(user.RestaurantID, updateRequest.Role); err != nil {
+			return err
+		}
+		if err := us.Roles.EnsureCanGrant(actor, user.RestaurantID, updateRequest.Role); err != nil {
+			return err
+		}
+		updates["role"] = updateRequest.Role
+	}
+	deactivated := updateRequest.IsActive != nil && !*updateRequest.IsActive && user.IsActive
+	if updateRequest.IsActive != nil {
+		updates["is_active"] = *updateRequest.IsActive
+	}
+	if len(updates) == 0 {
+		return nil
+	}
+
+	err := us.DB.Transaction(func(tx *gorm.DB) error {
+		if roleChanged || deactivated {
+			if err := ensureAdminRemains(tx, homeMembership(*user)); err != nil {
+				return err
+			}
+		}
+		if err := tx.Model(user).Updates(updates).Error; err != nil {
+			return err
+		}
+		if roleChanged {
+			return tx.Model(&appModels.Membership{}).
+				Where("user_id = ? AND restaurant_id = ?", user.ID, user.RestaurantID).
+				Update("role", updateRequest.Role).Error
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	if roleChanged || deactivated {
+		return us.Sessions.RevokeAllSessions(user.ID)
+	}
+	return nil
+}
+
+// DeactivateUser disables a user and ends all of their sessions
+func (us *UserService) DeactivateUser(actor authz.Set, user *appModels.User) error {
+	if err := us.EnsureCanManage(actor, *user); err != nil {
+		return err
+	}
+	err := us.DB.Transaction(func(tx *gorm.DB) error {
+		if err := ensureAdminRemains(tx, homeMembership(*user)); err != nil {
+			return err
+		}
+		return tx.Model(user).Update("is_active", false).Error
+	})
+	if err != nil {
+		return err
+	}
+	return us.Sessions.RevokeAllSessions(user.ID)
+}
+
+// ResetPassword sets a new password chosen by an admin and ends all of the user's sessions
+func (us *UserService) ResetPassword(actor authz.Set, user *appModels.User, newPassword string) error {
+	if err := us.EnsureCanManage(actor, *user); err != nil {
+		return err
+	}
+	return us.setPassword(user, newPassword)
+}
+
+// setPassword stores a new password for the user and ends all of their sessions
+func (us *UserService) setPassword(user *appModels.User, newPassword string) error {
+	hashedPassword, err := utils.HashPassword(newPassword)
+	if err != nil {
+		return err
+	}
+	if err := us.DB.Model(user).Update("password_hash", hashedPassword).Error; err != nil {
+		return err
+	}
+	return us.Sessions.RevokeAllSessions(user.ID)
+}
+
+// homeMembership describes the user's membership of their home restaurant, whose role is
+// kept in step with the user's
+func homeMembership(user appModels.User) appModels.Membership {
+	return appModels.Membership{
+		UserID:       user.ID,
+		RestaurantID: user.RestaurantID,
+		Role:         user.Role,
+		IsActive:     user.IsActive,
+	}
+}
+
+// ensureAdminRemains fails when the membership is the restaurant's last active admin. The
+// other admins are locked so two admins cannot demote each other at the same time.
+func ensureAdminRemains(tx *gorm.DB, membership appModels.Membership) error {
+	if membership.Role != "admin" || !membership.IsActive {
+		return nil
+	}
+
+	var otherAdmins []appModels.Membership
+	err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Joins("JOIN users ON users.id = memberships.user_id AND users.is_active = ? AND users.deleted_at IS NULL", true).
+		Where("memberships.restaurant_id = ? AND memberships.role = ? AND memberships.is_active = ? AND memberships.user_id <> ?",
+			membership.RestaurantID, "admin", true, membership.UserID).
+		Find(&otherAdmins).Error
+	if err != nil {
+		return err
+	}
+	if len(otherAdmins) == 0 {
+		return apperrors.ErrLastAdmin
+	}
+	return nil
+}
@@ -0,0 +1,85 @@
+package signing
+
+import (
+	"errors"
+	"sort"
+	"sync"
+	"time"
+)
+
+// ErrNoSigningKey is returned when no key in the ring is active
+var ErrNoSigningKey = errors.New("no active signing key")
+
+// KeyRing holds the keys tokens are signed and verified with. The newest active key
+// signs, every key that has not retired verifies and is published, including keys
+// that are published ahead of their activation so verifiers can cache them first.
+type KeyRing struct {
+	mu   sync.RWMutex
+	keys []Key
+}
+
+func NewKeyRing(keys ...Key) *KeyRing {
+	ring := &KeyRing{}
+	ring.Replace(keys)
+	return ring
+}
+
+// NewEphemeralKeyRing returns a ring with one in-memory EdDSA key, for tests and tools
+// that sign tokens without a database. Tokens it signs cannot be verified elsewhere.
+func NewEphemeralKeyRing() *KeyRing {
+	key, err := GenerateKey(AlgorithmEdDSA, time.Now())
+	if err != nil {
+		panic("failed to generate ephemeral signing key: " + err.Error())
+	}
+	return NewKeyRing(key)
+}
+
+// Replace swaps the ring's keys, e.g. after they were reloaded from the database
+func (r *KeyRing) Replace(keys []Key) {
+	sorted := append([]Key(nil), keys...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].ActivatesAt.After(sorted[j].ActivatesAt) })
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.keys = sorted
+}
+
+// SigningKey returns the most recently activated key that has not retired
+func (r *KeyRing) SigningKey(now time.Time) (Key, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	for _, key := range r.keys {
+		if !key.ActivatesAt.After(now) && !key.Retired(now) {
+			return key, nil
+		}
+	}
+	return Key{}, ErrNoSigningKey
+}
+
+// VerificationKey returns the key with the given ID if it has not retired
+func (r *KeyRing) VerificationKey(id string, now time.Time) (Key, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	for _, key := range r.keys {
+		if key.ID == id && !key.Retired(now) {
+			return key, true
+		}
+	}
+	return Key{}, false
+}
+
+// JWKS returns the public keys of every key that has not retired, newest first
+func (r *KeyRing) JWKS(now time.Time) JWKSet {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	set := JWKSet{Keys: []JWK{}}
+	for _, key := range r.keys {
+		if !key.Retired(now) {
+			set.Keys = append(set.Keys, key.JWK())
+		}
+	}
+	return set
+}
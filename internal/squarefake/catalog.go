@@ -0,0 +1,228 @@
+package squarefake
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	square "github.com/square/square-go-sdk/v2"
+)
+
+// catalogObject is a catalog object kept as its JSON fields, so objects of every type are
+// stored alike and come back exactly as they were sent
+type catalogObject map[string]interface{}
+
+func (o catalogObject) id() string {
+	id, _ := o["id"].(string)
+	return id
+}
+
+func (o catalogObject) kind() string {
+	kind, _ := o["type"].(string)
+	return kind
+}
+
+func (o catalogObject) version() int64 {
+	version, _ := o["version"].(float64)
+	return int64(version)
+}
+
+// lookup returns the value at a path of nested fields, or nil
+func (o catalogObject) lookup(path ...string) interface{} {
+	var value interface{} = map[string]interface{}(o)
+	for _, key := range path {
+		fields, ok := value.(map[string]interface{})
+		if !ok {
+			return nil
+		}
+		value = fields[key]
+	}
+	return value
+}
+
+// money returns the amount of the Money at a path of nested fields
+func (o catalogObject) money(path ...string) (int64, bool) {
+	amount, ok := o.lookup(append(path, "amount")...).(float64)
+	return int64(amount), ok
+}
+
+// catalogObject returns the merchant's catalog object with the given ID and type, or nil
+func (m *merchant) catalogObject(id, kind string) catalogObject {
+	object, ok := m.catalog[id]
+	if !ok || object.kind() != kind {
+		return nil
+	}
+	return object
+}
+
+func (s *Server) listCatalog(m *merchant, r *http.Request) (interface{}, error) {
+	query := r.URL.Query()
+	types := map[string]bool{}
+	for _, kind := range strings.Split(query.Get("types"), ",") {
+		if kind = strings.TrimSpace(kind); kind != "" {
+			types[strings.ToUpper(kind)] = true
+		}
+	}
+
+	var ids []string
+	for _, id := range m.catalogIDs {
+		if len(types) == 0 || types[m.catalog[id].kind()] {
+			ids = append(ids, id)
+		}
+	}
+
+	selected, cursor := page(ids, query.Get("cursor"), 100)
+	objects := []catalogObject{}
+	for _, id := range selected {
+		objects = append(objects, m.catalog[id])
+	}
+	resp := map[string]interface{}{"objects": objects}
+	if cursor != nil {
+		resp["cursor"] = *cursor
+	}
+	return resp, nil
+}
+
+// upsertCatalogObject creates objects with a temporary ID starting with # and updates the
+// others. Updates must name the current version. Variations inside an item are stored as
+// objects of their own, like in Square.
+func (s *Server) upsertCatalogObject(m *merchant, r *http.Request) (interface{}, error) {
+	var req struct {
+		IdempotencyKey string        `json:"idempotency_key"`
+		Object         catalogObject `json:"object"`
+	}
+	body, err := decode(r, &req)
+	if err != nil {
+		return nil, err
+	}
+	if req.IdempotencyKey == "" {
+		return nil, missing("idempotency_key")
+	}
+	if req.Object == nil {
+		return nil, missing("object")
+	}
+
+	return s.idempotent(m, req.IdempotencyKey, "POST /v2/catalog/object", body, func() (interface{}, error) {
+		object := copyObject(req.Object)
+		mappings := []*square.CatalogIDMapping{}
+		if err := s.saveCatalogObject(m, object, "object", &mappings); err != nil {
+			return nil, err
+		}
+
+		if variations, ok := object.lookup("item_data", "variations").([]interface{}); ok {
+			for i, value := range variations {
+				fields, _ := value.(map[string]interface{})
+				variation := catalogObject(fields)
+				if data, ok := variation["item_variation_data"].(map[string]interface{}); ok {
+					data["item_id"] = object.id()
+				}
+				if err := s.saveCatalogObject(m, variation, fmt.Sprintf("object.item_data.variations[%d]", i), &mappings); err != nil {
+					return nil, err
+				}
+			}
+		}
+
+		return map[string]interface{}{"catalog_object": object, "id_mappings": mappings}, nil
+	})
+}
+
+// saveCatalogObject validates one object, gives it its ID, version and timestamps and stores it
+func (s *Server) saveCatalogObject(m *merchant, object catalogObject, field string, mappings *[]*square.CatalogIDMapping) error {
+	id := object.id()
+	if object.kind() == "" {
+		return missing(field + ".type")
+	}
+	if id == "" {
+		return missing(field + ".id")
+	}
+
+	if strings.HasPrefix(id, "#") {
+		object["id"] = s.newID("C")
+		*mappings = append(*mappings, &square.CatalogIDMapping{ClientObjectID: square.String(id), ObjectID: square.String(object.id())})
+		m.catalogIDs = append(m.catalogIDs, object.id())
+	} else {
+		current, ok := m.catalog[id]
+		if !ok {
+			return badRequest("NOT_FOUND", field+".id", fmt.Sprintf("Catalog object with id `%s` not found.", id))
+		}
+		if current.kind() != object.kind() {
+			return badRequest("INVALID_VALUE", field+".type", "The type of a catalog object cannot be changed.")
+		}
+		if object.version() != current.version() {
+			return badRequest("VERSION_MISMATCH", field+".version",
+				fmt.Sprintf("Object version does not match for object: %s", id))
+		}
+	}
+
+	object["version"] = float64(s.catalogVersion(m))
+	object["updated_at"] = *timestamp()
+	object["is_deleted"] = false
+	if _, ok := object["present_at_all_locations"]; !ok {
+		object["present_at_all_locations"] = true
+	}
+	m.catalog[object.id()] = object
+	return nil
+}
+
+// catalogVersion returns the next catalog version. Like in Square, versions are increasing
+// timestamps in milliseconds.
+func (s *Server) catalogVersion(m *merchant) int64 {
+	version := time.Now().UnixMilli()
+	if version <= m.lastCatalogVersion {
+		version = m.lastCatalogVersion + 1
+	}
+	m.lastCatalogVersion = version
+	return version
+}
+
+func (s *Server) getCatalogObject(m *merchant, r *http.Request) (interface{}, error) {
+	id := r.PathValue("object_id")
+	object, ok := m.catalog[id]
+	if !ok {
+		return nil, notFound("catalog object", id)
+	}
+	return map[string]interface{}{"object": object}, nil
+}
+
+// deleteCatalogObject deletes an object, deleting an item also deletes its variations
+func (s *Server) deleteCatalogObject(m *merchant, r *http.Request) (interface{}, error) {
+	id := r.PathValue("object_id")
+	object, ok := m.catalog[id]
+	if !ok {
+		return nil, notFound("catalog object", id)
+	}
+
+	deleted := []string{id}
+	if object.kind() == "ITEM" {
+		for _, otherID := range m.catalogIDs {
+			if m.catalog[otherID].lookup("item_variation_data", "item_id") == id {
+				deleted = append(deleted, otherID)
+			}
+		}
+	}
+	for _, deletedID := range deleted {
+		delete(m.catalog, deletedID)
+		m.catalogIDs = filterIDs(m.catalogIDs, deletedID)
+	}
+	return map[string]interface{}{"deleted_object_ids": deleted, "deleted_at": *timestamp()}, nil
+}
+
+// copyObject deep-copies a catalog object
+func copyObject(object catalogObject) catalogObject {
+	encoded, _ := json.Marshal(object)
+	var copied catalogObject
+	json.Unmarshal(encoded, &copied)
+	return copied
+}
+
+func filterIDs(ids []string, without string) []string {
+	var kept []string
+	for _, id := range ids {
+		if id != without {
+			kept = append(kept, id)
+		}
+	}
+	return kept
+}
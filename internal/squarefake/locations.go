@@ -0,0 +1,60 @@
+package squarefake
+
+import (
+	"net/http"
+
+	square "github.com/square/square-go-sdk/v2"
+)
+
+// addLocation adds an active US location to the merchant
+func (s *Server) addLocation(m *merchant, name string) *square.Location {
+	location := &square.Location{
+		ID:           square.String(s.newID("L")),
+		Name:         square.String(name),
+		BusinessName: square.String("Fake Restaurant"),
+		MerchantID:   square.String(m.id),
+		Status:       square.LocationStatus("ACTIVE").Ptr(),
+		Type:         square.LocationType("PHYSICAL").Ptr(),
+		Country:      square.Country("US").Ptr(),
+		Currency:     square.Currency("USD").Ptr(),
+		LanguageCode: square.String("en-US"),
+		Timezone:     square.String("America/Los_Angeles"),
+		Capabilities: []square.LocationCapability{"CREDIT_CARD_PROCESSING"},
+		Address: &square.Address{
+			AddressLine1:                 square.String("1 Market St"),
+			Locality:                     square.String("San Francisco"),
+			AdministrativeDistrictLevel1: square.String("CA"),
+			PostalCode:                   square.String("94105"),
+			Country:                      square.Country("US").Ptr(),
+		},
+		CreatedAt: timestamp(),
+	}
+	m.locations = append(m.locations, location)
+	return location
+}
+
+// location returns the merchant's location with the given ID, or nil
+func (m *merchant) location(id string) *square.Location {
+	for _, location := range m.locations {
+		if *location.ID == id {
+			return location
+		}
+	}
+	return nil
+}
+
+func (s *Server) listLocations(m *merchant, r *http.Request) (interface{}, error) {
+	return map[string]interface{}{"locations": m.locations}, nil
+}
+
+func (s *Server) getLocation(m *merchant, r *http.Request) (interface{}, error) {
+	id := r.PathValue("location_id")
+	location := m.location(id)
+	if id == "main" && len(m.locations) > 0 {
+		location = m.locations[0]
+	}
+	if location == nil {
+		return nil, notFound("location", id)
+	}
+	return map[string]interface{}{"location": location}, nil
+}
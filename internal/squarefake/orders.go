@@ -0,0 +1,299 @@
+package squarefake
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	square "github.com/square/square-go-sdk/v2"
+)
+
+func (s *Server) createOrder(m *merchant, r *http.Request) (interface{}, error) {
+	var req square.CreateOrderRequest
+	body, err := decode(r, &req)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.idempotent(m, stringOf(req.IdempotencyKey), "POST /v2/orders", body, func() (interface{}, error) {
+		if req.Order == nil {
+			return nil, missing("order")
+		}
+		order := clone(req.Order)
+		if order.LocationID == "" {
+			return nil, missing("order.location_id")
+		}
+		if m.location(order.LocationID) == nil {
+			return nil, badRequest("NOT_FOUND", "order.location_id", fmt.Sprintf("Location `%s` not found.", order.LocationID))
+		}
+		if order.State == nil {
+			order.State = square.OrderState("OPEN").Ptr()
+		} else if *order.State != "OPEN" && *order.State != "DRAFT" {
+			return nil, badRequest("INVALID_VALUE", "order.state", "Orders can only be created in the OPEN or DRAFT state.")
+		}
+
+		order.ID = square.String(s.newID("ORD"))
+		order.Version = square.Int(1)
+		order.Tenders = nil
+		order.Refunds = nil
+		order.CreatedAt = timestamp()
+		order.UpdatedAt = order.CreatedAt
+		order.ClosedAt = nil
+		if err := s.price(m, order); err != nil {
+			return nil, err
+		}
+
+		m.orders[*order.ID] = order
+		m.orderIDs = append(m.orderIDs, *order.ID)
+		return map[string]interface{}{"order": clone(order)}, nil
+	})
+}
+
+func (s *Server) getOrder(m *merchant, r *http.Request) (interface{}, error) {
+	id := r.PathValue("order_id")
+	order, ok := m.orders[id]
+	if !ok {
+		return nil, notFound("order", id)
+	}
+	return map[string]interface{}{"order": clone(order)}, nil
+}
+
+// updateOrder applies a sparse update. Line items, taxes, discounts and service charges
+// replace the entry with the same UID or are added, fields_to_clear removes whole fields.
+func (s *Server) updateOrder(m *merchant, r *http.Request) (interface{}, error) {
+	id := r.PathValue("order_id")
+	var req square.UpdateOrderRequest
+	body, err := decode(r, &req)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.idempotent(m, stringOf(req.IdempotencyKey), "PUT "+r.URL.Path, body, func() (interface{}, error) {
+		current, ok := m.orders[id]
+		if !ok {
+			return nil, notFound("order", id)
+		}
+		if req.Order == nil {
+			return nil, missing("order")
+		}
+		changes := req.Order
+		if changes.Version == nil {
+			return nil, missing("order.version")
+		}
+		if *changes.Version != *current.Version {
+			return nil, badRequest("VERSION_MISMATCH", "order.version",
+				fmt.Sprintf("Version %d does not match the current version %d of the order.", *changes.Version, *current.Version))
+		}
+		if state := *current.State; state != "OPEN" && state != "DRAFT" {
+			return nil, badRequest("BAD_REQUEST", "order.state", fmt.Sprintf("An order in the %s state cannot be updated.", state))
+		}
+		if changes.LocationID != "" && changes.LocationID != current.LocationID {
+			return nil, badRequest("INVALID_VALUE", "order.location_id", "The location of an order cannot be changed.")
+		}
+
+		updated := clone(current)
+		for _, field := range req.FieldsToClear {
+			if err := clearOrderField(updated, field); err != nil {
+				return nil, err
+			}
+		}
+		if changes.ReferenceID != nil {
+			updated.ReferenceID = changes.ReferenceID
+		}
+		if changes.TicketName != nil {
+			updated.TicketName = changes.TicketName
+		}
+		for key, value := range changes.Metadata {
+			if updated.Metadata == nil {
+				updated.Metadata = map[string]*string{}
+			}
+			updated.Metadata[key] = value
+		}
+		updated.LineItems = mergeByUID(updated.LineItems, changes.LineItems, func(v *square.OrderLineItem) *string { return v.UID })
+		updated.Taxes = mergeByUID(updated.Taxes, changes.Taxes, func(v *square.OrderLineItemTax) *string { return v.UID })
+		updated.Discounts = mergeByUID(updated.Discounts, changes.Discounts, func(v *square.OrderLineItemDiscount) *string { return v.UID })
+		updated.ServiceCharges = mergeByUID(updated.ServiceCharges, changes.ServiceCharges, func(v *square.OrderServiceCharge) *string { return v.UID })
+		if err := s.price(m, updated); err != nil {
+			return nil, err
+		}
+
+		if changes.State != nil && *changes.State != *updated.State {
+			switch *changes.State {
+			case "CANCELED":
+				if len(updated.Tenders) > 0 || m.hasApprovedPayments(id) {
+					return nil, badRequest("BAD_REQUEST", "order.state", "An order with payments cannot be canceled.")
+				}
+			case "COMPLETED":
+				if amountOf(updated.NetAmountDueMoney) > 0 {
+					return nil, badRequest("BAD_REQUEST", "order.state", "An order must be fully paid before it can be completed.")
+				}
+			case "OPEN":
+			default:
+				return nil, badRequest("INVALID_VALUE", "order.state", fmt.Sprintf("Cannot move an order to the %s state.", *changes.State))
+			}
+			updated.State = changes.State
+			if *updated.State != "OPEN" {
+				updated.ClosedAt = timestamp()
+			}
+		}
+
+		updated.Version = square.Int(*updated.Version + 1)
+		updated.UpdatedAt = timestamp()
+		m.orders[id] = updated
+		return map[string]interface{}{"order": clone(updated)}, nil
+	})
+}
+
+// clearOrderField removes one of the fields named in fields_to_clear. Entries of a list
+// are named by UID, for example line_items[uid].
+func clearOrderField(order *square.Order, field string) error {
+	name, uid, _ := strings.Cut(strings.TrimSuffix(field, "]"), "[")
+	without := func(v *string) bool { return uid != "" && stringOf(v) != uid }
+	switch {
+	case name == "reference_id" && uid == "":
+		order.ReferenceID = nil
+	case name == "ticket_name" && uid == "":
+		order.TicketName = nil
+	case name == "metadata" && uid == "":
+		order.Metadata = nil
+	case name == "line_items":
+		order.LineItems = filter(order.LineItems, func(v *square.OrderLineItem) bool { return without(v.UID) })
+	case name == "taxes":
+		order.Taxes = filter(order.Taxes, func(v *square.OrderLineItemTax) bool { return without(v.UID) })
+		for _, item := range order.LineItems {
+			item.AppliedTaxes = filter(item.AppliedTaxes, func(v *square.OrderLineItemAppliedTax) bool { return without(&v.TaxUID) })
+		}
+	case name == "discounts":
+		order.Discounts = filter(order.Discounts, func(v *square.OrderLineItemDiscount) bool { return without(v.UID) })
+		for _, item := range order.LineItems {
+			item.AppliedDiscounts = filter(item.AppliedDiscounts, func(v *square.OrderLineItemAppliedDiscount) bool { return without(&v.DiscountUID) })
+		}
+	case name == "service_charges":
+		order.ServiceCharges = filter(order.ServiceCharges, func(v *square.OrderServiceCharge) bool { return without(v.UID) })
+	default:
+		return badRequest("INVALID_VALUE", "fields_to_clear", fmt.Sprintf("Unsupported field `%s`.", field))
+	}
+	return nil
+}
+
+func (s *Server) calculateOrder(m *merchant, r *http.Request) (interface{}, error) {
+	var req square.CalculateOrderRequest
+	if _, err := decode(r, &req); err != nil {
+		return nil, err
+	}
+	if req.Order == nil {
+		return nil, missing("order")
+	}
+	order := clone(req.Order)
+	if order.LocationID == "" {
+		return nil, missing("order.location_id")
+	}
+	if m.location(order.LocationID) == nil {
+		return nil, badRequest("NOT_FOUND", "order.location_id", fmt.Sprintf("Location `%s` not found.", order.LocationID))
+	}
+	if order.State == nil {
+		order.State = square.OrderState("OPEN").Ptr()
+	}
+	if err := s.price(m, order); err != nil {
+		return nil, err
+	}
+	return map[string]interface{}{"order": order}, nil
+}
+
+func (s *Server) searchOrders(m *merchant, r *http.Request) (interface{}, error) {
+	var req square.SearchOrdersRequest
+	if _, err := decode(r, &req); err != nil {
+		return nil, err
+	}
+	if len(req.LocationIDs) == 0 {
+		return nil, missing("location_ids")
+	}
+	if len(req.LocationIDs) > 10 {
+		return nil, badRequest("INVALID_VALUE", "location_ids", "At most 10 location IDs can be searched at once.")
+	}
+	limit := 500
+	if req.Limit != nil {
+		if *req.Limit < 1 || *req.Limit > 1000 {
+			return nil, badRequest("INVALID_VALUE", "limit", "Limit must be between 1 and 1000.")
+		}
+		limit = *req.Limit
+	}
+
+	locations := map[string]bool{}
+	for _, id := range req.LocationIDs {
+		locations[id] = true
+	}
+	states := map[square.OrderState]bool{}
+	descending := true
+	if query := req.Query; query != nil {
+		if query.Filter != nil && query.Filter.StateFilter != nil {
+			for _, state := range query.Filter.StateFilter.States {
+				states[state] = true
+			}
+		}
+		if query.Sort != nil && query.Sort.SortOrder != nil {
+			descending = *query.Sort.SortOrder != "ASC"
+		}
+	}
+
+	var ids []string
+	for _, id := range m.orderIDs {
+		order := m.orders[id]
+		if locations[order.LocationID] && (len(states) == 0 || states[*order.State]) {
+			ids = append(ids, id)
+		}
+	}
+	if descending {
+		reverse(ids)
+	}
+
+	selected, cursor := page(ids, stringOf(req.Cursor), limit)
+	resp := map[string]interface{}{}
+	if cursor != nil {
+		resp["cursor"] = *cursor
+	}
+	if req.ReturnEntries != nil && *req.ReturnEntries {
+		entries := []*square.OrderEntry{}
+		for _, id := range selected {
+			order := m.orders[id]
+			entries = append(entries, &square.OrderEntry{OrderID: order.ID, Version: order.Version, LocationID: square.String(order.LocationID)})
+		}
+		resp["order_entries"] = entries
+		return resp, nil
+	}
+	orders := []*square.Order{}
+	for _, id := range selected {
+		orders = append(orders, clone(m.orders[id]))
+	}
+	resp["orders"] = orders
+	return resp, nil
+}
+
+// mergeByUID replaces the entries of current that share a UID with an entry of changes and
+// appends the rest of changes
+func mergeByUID[T any](current, changes []*T, uid func(*T) *string) []*T {
+	for _, change := range changes {
+		replaced := false
+		for i, entry := range current {
+			if id := uid(change); id != nil && stringOf(uid(entry)) == *id {
+				current[i] = change
+				replaced = true
+				break
+			}
+		}
+		if !replaced {
+			current = append(current, change)
+		}
+	}
+	return current
+}
+
+func filter[T any](entries []*T, keep func(*T) bool) []*T {
+	var kept []*T
+	for _, entry := range entries {
+		if keep(entry) {
+			kept = append(kept, entry)
+		}
+	}
+	return kept
+}
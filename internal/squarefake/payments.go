@@ -0,0 +1,500 @@
+package squarefake
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	square "github.com/square/square-go-sdk/v2"
+)
+
+// Test nonces. Like in the Square sandbox, cnon:card-nonce-ok pays with a Visa card and
+// these nonces make the payment fail with the given card error.
+var declinedNonces = map[string]string{
+	"cnon:card-nonce-declined":            "GENERIC_DECLINE",
+	"cnon:card-nonce-rejected-cvv":        "CVV_FAILURE",
+	"cnon:card-nonce-rejected-postalcode": "ADDRESS_VERIFICATION_FAILURE",
+	"cnon:card-nonce-rejected-expiration": "INVALID_EXPIRATION",
+}
+
+func (s *Server) createPayment(m *merchant, r *http.Request) (interface{}, error) {
+	var req square.CreatePaymentRequest
+	body, err := decode(r, &req)
+	if err != nil {
+		return nil, err
+	}
+	if req.IdempotencyKey == "" {
+		return nil, missing("idempotency_key")
+	}
+
+	return s.idempotent(m, req.IdempotencyKey, "POST /v2/payments", body, func() (interface{}, error) {
+		if req.SourceID == "" {
+			return nil, missing("source_id")
+		}
+		if req.AmountMoney == nil {
+			return nil, missing("amount_money")
+		}
+		amount, tip := amountOf(req.AmountMoney), amountOf(req.TipMoney)
+		if amount <= 0 {
+			return nil, badRequest("VALUE_TOO_LOW", "amount_money.amount", "`amount_money.amount` must be greater than 0.")
+		}
+		if tip < 0 {
+			return nil, badRequest("VALUE_TOO_LOW", "tip_money.amount", "`tip_money.amount` must not be negative.")
+		}
+
+		location := m.locations[0]
+		if id := stringOf(req.LocationID); id != "" {
+			if location = m.location(id); location == nil {
+				return nil, badRequest("NOT_FOUND", "location_id", fmt.Sprintf("Location `%s` not found.", id))
+			}
+		}
+		currency := currencyOf(req.AmountMoney)
+		if currency != *location.Currency || (req.TipMoney != nil && currencyOf(req.TipMoney) != currency) {
+			return nil, badRequest("CURRENCY_MISMATCH", "amount_money.currency",
+				fmt.Sprintf("The payment currency must match the location currency %s.", *location.Currency))
+		}
+
+		if orderID := stringOf(req.OrderID); orderID != "" {
+			order, ok := m.orders[orderID]
+			if !ok {
+				return nil, badRequest("NOT_FOUND", "order_id", fmt.Sprintf("Order `%s` not found.", orderID))
+			}
+			if *order.State != "OPEN" {
+				return nil, badRequest("BAD_REQUEST", "order_id", fmt.Sprintf("Order `%s` is %s and cannot be paid.", orderID, *order.State))
+			}
+			if order.LocationID != *location.ID {
+				return nil, badRequest("LOCATION_MISMATCH", "location_id", "The payment location must match the location of the order.")
+			}
+			if amount > m.amountPayable(order) {
+				return nil, badRequest("PAYMENT_AMOUNT_MISMATCH", "amount_money",
+					"The payment amount is greater than the amount due on the order.")
+			}
+		}
+
+		sourceType := "CARD"
+		switch {
+		case req.SourceID == "CASH":
+			sourceType = "CASH"
+		case req.SourceID == "EXTERNAL":
+			sourceType = "EXTERNAL"
+		case declinedNonces[req.SourceID] != "":
+			code := declinedNonces[req.SourceID]
+			return nil, &Error{Status: http.StatusPaymentRequired, Category: "PAYMENT_METHOD_ERROR", Code: code,
+				Detail: fmt.Sprintf("Authorization error: '%s'", code)}
+		case !strings.HasPrefix(req.SourceID, "cnon:") && !strings.HasPrefix(req.SourceID, "ccof:"):
+			return nil, badRequest("NOT_FOUND", "source_id", "Card nonce not found in this `sandbox` application environment.")
+		}
+
+		status := "COMPLETED"
+		if req.Autocomplete != nil && !*req.Autocomplete {
+			status = "APPROVED"
+		}
+		payment := &square.Payment{
+			ID:            square.String(s.newID("PAY")),
+			CreatedAt:     timestamp(),
+			AmountMoney:   money(amount, currency),
+			TotalMoney:    money(amount+tip, currency),
+			ApprovedMoney: money(amount+tip, currency),
+			Status:        square.String(status),
+			SourceType:    square.String(sourceType),
+			LocationID:    location.ID,
+			OrderID:       req.OrderID,
+			ReferenceID:   req.ReferenceID,
+			Note:          req.Note,
+			VersionToken:  square.String(s.newID("V")),
+		}
+		payment.UpdatedAt = payment.CreatedAt
+		if tip > 0 {
+			payment.TipMoney = money(tip, currency)
+		}
+		if status == "APPROVED" {
+			payment.DelayAction = square.String("CANCEL")
+			payment.DelayDuration = square.String("PT168H")
+		}
+		if sourceType == "CARD" {
+			payment.CardDetails = &square.CardPaymentDetails{
+				Status: square.String("AUTHORIZED"),
+				Card: &square.Card{
+					CardBrand:   square.CardBrand("VISA").Ptr(),
+					Last4:       square.String("1111"),
+					ExpMonth:    square.Int64(12),
+					ExpYear:     square.Int64(2030),
+					Fingerprint: square.String("sq-1-fake-fingerprint"),
+					CardType:    square.CardType("CREDIT").Ptr(),
+					Bin:         square.String("411111"),
+				},
+				EntryMethod:    square.String("KEYED"),
+				CvvStatus:      square.String("CVV_ACCEPTED"),
+				AvsStatus:      square.String("AVS_ACCEPTED"),
+				AuthResultCode: square.String("FAKE01"),
+			}
+		}
+		if status == "COMPLETED" {
+			s.capture(m, payment)
+		}
+
+		m.payments[*payment.ID] = payment
+		m.paymentIDs = append(m.paymentIDs, *payment.ID)
+		return map[string]interface{}{"payment": clone(payment)}, nil
+	})
+}
+
+func (s *Server) getPayment(m *merchant, r *http.Request) (interface{}, error) {
+	id := r.PathValue("payment_id")
+	payment, ok := m.payments[id]
+	if !ok {
+		return nil, notFound("payment", id)
+	}
+	return map[string]interface{}{"payment": clone(payment)}, nil
+}
+
+// updatePayment changes the amount or tip of a payment that is approved but not completed
+func (s *Server) updatePayment(m *merchant, r *http.Request) (interface{}, error) {
+	id := r.PathValue("payment_id")
+	var req square.UpdatePaymentRequest
+	body, err := decode(r, &req)
+	if err != nil {
+		return nil, err
+	}
+	if req.IdempotencyKey == "" {
+		return nil, missing("idempotency_key")
+	}
+
+	return s.idempotent(m, req.IdempotencyKey, "PUT "+r.URL.Path, body, func() (interface{}, error) {
+		payment, ok := m.payments[id]
+		if !ok {
+			return nil, notFound("payment", id)
+		}
+		if req.Payment == nil {
+			return nil, missing("payment")
+		}
+		if *payment.Status != "APPROVED" {
+			return nil, badRequest("BAD_REQUEST", "payment",
+				fmt.Sprintf("Payment `%s` is %s and can no longer be updated.", id, *payment.Status))
+		}
+		if token := req.Payment.VersionToken; token != nil && *token != *payment.VersionToken {
+			return nil, badRequest("VERSION_MISMATCH", "payment.version_token", "The version token does not match the current version of the payment.")
+		}
+
+		currency := currencyOf(payment.AmountMoney)
+		amount, tip := amountOf(payment.AmountMoney), amountOf(payment.TipMoney)
+		if changed := req.Payment.AmountMoney; changed != nil {
+			if currencyOf(changed) != currency {
+				return nil, badRequest("CURRENCY_MISMATCH", "payment.amount_money.currency", "The currency of a payment cannot be changed.")
+			}
+			if amountOf(changed) <= 0 {
+				return nil, badRequest("VALUE_TOO_LOW", "payment.amount_money.amount", "`amount_money.amount` must be greater than 0.")
+			}
+			if order := m.orders[stringOf(payment.OrderID)]; order != nil && amountOf(changed) > m.amountPayable(order)+amount {
+				return nil, badRequest("PAYMENT_AMOUNT_MISMATCH", "payment.amount_money",
+					"The payment amount is greater than the amount due on the order.")
+			}
+			amount = amountOf(changed)
+		}
+		if changed := req.Payment.TipMoney; changed != nil {
+			if currencyOf(changed) != currency {
+				return nil, badRequest("CURRENCY_MISMATCH", "payment.tip_money.currency", "The tip currency must match the payment currency.")
+			}
+			if amountOf(changed) < 0 {
+				return nil, badRequest("VALUE_TOO_LOW", "payment.tip_money.amount", "`tip_money.amount` must not be negative.")
+			}
+			tip = amountOf(changed)
+		}
+
+		payment.AmountMoney = money(amount, currency)
+		payment.TipMoney = money(tip, currency)
+		payment.TotalMoney = money(amount+tip, currency)
+		payment.ApprovedMoney = money(amount+tip, currency)
+		payment.VersionToken = square.String(s.newID("V"))
+		payment.UpdatedAt = timestamp()
+		return map[string]interface{}{"payment": clone(payment)}, nil
+	})
+}
+
+func (s *Server) completePayment(m *merchant, r *http.Request) (interface{}, error) {
+	id := r.PathValue("payment_id")
+	var req square.CompletePaymentRequest
+	if _, err := decode(r, &req); err != nil {
+		return nil, err
+	}
+	payment, ok := m.payments[id]
+	if !ok {
+		return nil, notFound("payment", id)
+	}
+	if *payment.Status != "APPROVED" {
+		return nil, badRequest("BAD_REQUEST", "payment_id", fmt.Sprintf("Payment `%s` is %s and cannot be completed.", id, *payment.Status))
+	}
+	if token := req.VersionToken; token != nil && *token != *payment.VersionToken {
+		return nil, badRequest("VERSION_MISMATCH", "version_token", "The version token does not match the current version of the payment.")
+	}
+	if order := m.orders[stringOf(payment.OrderID)]; order != nil && *order.State != "OPEN" {
+		return nil, badRequest("BAD_REQUEST", "payment_id", fmt.Sprintf("Order `%s` is %s and cannot be paid.", *order.ID, *order.State))
+	}
+
+	payment.Status = square.String("COMPLETED")
+	payment.DelayAction = nil
+	payment.DelayDuration = nil
+	payment.VersionToken = square.String(s.newID("V"))
+	payment.UpdatedAt = timestamp()
+	s.capture(m, payment)
+	return map[string]interface{}{"payment": clone(payment)}, nil
+}
+
+func (s *Server) cancelPayment(m *merchant, r *http.Request) (interface{}, error) {
+	id := r.PathValue("payment_id")
+	payment, ok := m.payments[id]
+	if !ok {
+		return nil, notFound("payment", id)
+	}
+	if *payment.Status != "APPROVED" {
+		return nil, badRequest("BAD_REQUEST", "payment_id", fmt.Sprintf("Payment `%s` is %s and cannot be canceled.", id, *payment.Status))
+	}
+
+	payment.Status = square.String("CANCELED")
+	payment.DelayAction = nil
+	payment.DelayDuration = nil
+	if payment.CardDetails != nil {
+		payment.CardDetails.Status = square.String("VOIDED")
+	}
+	payment.VersionToken = square.String(s.newID("V"))
+	payment.UpdatedAt = timestamp()
+	return map[string]interface{}{"payment": clone(payment)}, nil
+}
+
+func (s *Server) listPayments(m *merchant, r *http.Request) (interface{}, error) {
+	query := r.URL.Query()
+	limit, err := pageLimit(query.Get("limit"), 100)
+	if err != nil {
+		return nil, err
+	}
+
+	var ids []string
+	for _, id := range m.paymentIDs {
+		payment := m.payments[id]
+		if within(payment.LocationID, payment.CreatedAt, query.Get("location_id"), query.Get("begin_time"), query.Get("end_time")) {
+			ids = append(ids, id)
+		}
+	}
+	if query.Get("sort_order") != "ASC" {
+		reverse(ids)
+	}
+
+	selected, cursor := page(ids, query.Get("cursor"), limit)
+	payments := []*square.Payment{}
+	for _, id := range selected {
+		payments = append(payments, clone(m.payments[id]))
+	}
+	resp := map[string]interface{}{"payments": payments}
+	if cursor != nil {
+		resp["cursor"] = *cursor
+	}
+	return resp, nil
+}
+
+// capture adds a completed payment to its order as a tender. Like in Square, an order that
+// is fully paid is completed.
+func (s *Server) capture(m *merchant, payment *square.Payment) {
+	if payment.CardDetails != nil {
+		payment.CardDetails.Status = square.String("CAPTURED")
+	}
+	order := m.orders[stringOf(payment.OrderID)]
+	if order == nil {
+		return
+	}
+
+	tenderType := square.TenderType("CARD")
+	switch *payment.SourceType {
+	case "CASH":
+		tenderType = "CASH"
+	case "EXTERNAL":
+		tenderType = "OTHER"
+	}
+	order.Tenders = append(order.Tenders, &square.Tender{
+		ID:            payment.ID,
+		LocationID:    payment.LocationID,
+		TransactionID: order.ID,
+		CreatedAt:     payment.UpdatedAt,
+		AmountMoney:   payment.TotalMoney,
+		TipMoney:      payment.TipMoney,
+		Type:          tenderType,
+		PaymentID:     payment.ID,
+	})
+	s.price(m, order)
+	if amountOf(order.NetAmountDueMoney) == 0 && len(order.Fulfillments) == 0 {
+		order.State = square.OrderState("COMPLETED").Ptr()
+		order.ClosedAt = timestamp()
+	}
+	order.Version = square.Int(*order.Version + 1)
+	order.UpdatedAt = timestamp()
+}
+
+// amountPayable is the amount due on an order less the payments approved but not yet completed
+func (m *merchant) amountPayable(order *square.Order) int64 {
+	payable := amountOf(order.NetAmountDueMoney)
+	for _, payment := range m.payments {
+		if stringOf(payment.OrderID) == *order.ID && *payment.Status == "APPROVED" {
+			payable -= amountOf(payment.AmountMoney)
+		}
+	}
+	return payable
+}
+
+func (m *merchant) hasApprovedPayments(orderID string) bool {
+	for _, payment := range m.payments {
+		if stringOf(payment.OrderID) == orderID && *payment.Status == "APPROVED" {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *Server) refundPayment(m *merchant, r *http.Request) (interface{}, error) {
+	var req square.RefundPaymentRequest
+	body, err := decode(r, &req)
+	if err != nil {
+		return nil, err
+	}
+	if req.IdempotencyKey == "" {
+		return nil, missing("idempotency_key")
+	}
+
+	return s.idempotent(m, req.IdempotencyKey, "POST /v2/refunds", body, func() (interface{}, error) {
+		if req.AmountMoney == nil {
+			return nil, missing("amount_money")
+		}
+		paymentID := stringOf(req.PaymentID)
+		if paymentID == "" {
+			return nil, missing("payment_id")
+		}
+		payment, ok := m.payments[paymentID]
+		if !ok {
+			return nil, notFound("payment", paymentID)
+		}
+		if *payment.Status != "COMPLETED" {
+			return nil, &Error{Status: http.StatusBadRequest, Category: "REFUND_ERROR", Code: "REFUND_ERROR_PAYMENT_NEEDS_COMPLETION",
+				Detail: fmt.Sprintf("Payment `%s` is %s and must be completed before it can be refunded.", paymentID, *payment.Status)}
+		}
+
+		amount := amountOf(req.AmountMoney)
+		currency := currencyOf(payment.TotalMoney)
+		if amount <= 0 {
+			return nil, badRequest("VALUE_TOO_LOW", "amount_money.amount", "`amount_money.amount` must be greater than 0.")
+		}
+		if currencyOf(req.AmountMoney) != currency {
+			return nil, badRequest("CURRENCY_MISMATCH", "amount_money.currency", "The refund currency must match the payment currency.")
+		}
+		if amount > amountOf(payment.TotalMoney)-amountOf(payment.RefundedMoney) {
+			return nil, &Error{Status: http.StatusBadRequest, Category: "REFUND_ERROR", Code: "REFUND_AMOUNT_INVALID",
+				Detail: "The requested refund amount exceeds the amount available to refund."}
+		}
+
+		refund := &square.PaymentRefund{
+			ID:              paymentID + "_" + s.newID("REF"),
+			Status:          square.String("PENDING"),
+			LocationID:      payment.LocationID,
+			DestinationType: payment.SourceType,
+			AmountMoney:     money(amount, currency),
+			PaymentID:       payment.ID,
+			OrderID:         payment.OrderID,
+			Reason:          req.Reason,
+			CreatedAt:       timestamp(),
+		}
+		refund.UpdatedAt = refund.CreatedAt
+
+		payment.RefundedMoney = money(amountOf(payment.RefundedMoney)+amount, currency)
+		payment.RefundIDs = append(payment.RefundIDs, refund.ID)
+		payment.VersionToken = square.String(s.newID("V"))
+		payment.UpdatedAt = timestamp()
+
+		m.refunds[refund.ID] = refund
+		m.refundIDs = append(m.refundIDs, refund.ID)
+		return map[string]interface{}{"refund": clone(refund)}, nil
+	})
+}
+
+// getRefund returns a refund. Refunds are created PENDING and have completed by the time
+// they are read back, like card refunds in the Square sandbox.
+func (s *Server) getRefund(m *merchant, r *http.Request) (interface{}, error) {
+	id := r.PathValue("refund_id")
+	refund, ok := m.refunds[id]
+	if !ok {
+		return nil, notFound("refund", id)
+	}
+	settle(refund)
+	return map[string]interface{}{"refund": clone(refund)}, nil
+}
+
+func (s *Server) listRefunds(m *merchant, r *http.Request) (interface{}, error) {
+	query := r.URL.Query()
+	limit, err := pageLimit(query.Get("limit"), 100)
+	if err != nil {
+		return nil, err
+	}
+
+	var ids []string
+	for _, id := range m.refundIDs {
+		refund := m.refunds[id]
+		settle(refund)
+		if !within(refund.LocationID, refund.CreatedAt, query.Get("location_id"), query.Get("begin_time"), query.Get("end_time")) {
+			continue
+		}
+		if status := query.Get("status"); status != "" && *refund.Status != status {
+			continue
+		}
+		ids = append(ids, id)
+	}
+	if query.Get("sort_order") != "ASC" {
+		reverse(ids)
+	}
+
+	selected, cursor := page(ids, query.Get("cursor"), limit)
+	refunds := []*square.PaymentRefund{}
+	for _, id := range selected {
+		refunds = append(refunds, clone(m.refunds[id]))
+	}
+	resp := map[string]interface{}{"refunds": refunds}
+	if cursor != nil {
+		resp["cursor"] = *cursor
+	}
+	return resp, nil
+}
+
+func settle(refund *square.PaymentRefund) {
+	if *refund.Status == "PENDING" {
+		refund.Status = square.String("COMPLETED")
+		refund.UpdatedAt = timestamp()
+	}
+}
+
+// within reports whether a record at location created at createdAt matches the optional
+// location and time range filters of a list request
+func within(location, createdAt *string, locationID, beginTime, endTime string) bool {
+	if locationID != "" && stringOf(location) != locationID {
+		return false
+	}
+	if beginTime != "" && stringOf(createdAt) < beginTime {
+		return false
+	}
+	if endTime != "" && stringOf(createdAt) >= endTime {
+		return false
+	}
+	return true
+}
+
+func pageLimit(value string, max int) (int, error) {
+	if value == "" {
+		return max, nil
+	}
+	limit, err := strconv.Atoi(value)
+	if err != nil || limit < 1 || limit > max {
+		return 0, badRequest("INVALID_VALUE", "limit", fmt.Sprintf("Limit must be between 1 and %d.", max))
+	}
+	return limit, nil
+}
+
+func reverse(ids []string) {
+	for i, j := 0, len(ids)-1; i < j; i, j = i+1, j-1 {
+		ids[i], ids[j] = ids[j], ids[i]
+	}
+}
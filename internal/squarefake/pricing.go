@@ -0,0 +1,495 @@
+package squarefake
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+
+	square "github.com/square/square-go-sdk/v2"
+)
+
+// price runs an order through the pricing engine. It resolves catalog items, taxes and
+// discounts, assigns missing UIDs and fills in every applied and total amount.
+//
+// Like Square, it works in three phases: discounts reduce each line item, subtotal phase
+// service charges and taxes apply to the discounted amounts, then total phase service
+// charges apply to the taxed total. Additive taxes are added to the total, inclusive taxes
+// are already part of the price. Rounding is half to even.
+func (s *Server) price(m *merchant, order *square.Order) error {
+	currency := square.Currency("USD")
+	if location := m.location(order.LocationID); location != nil && location.Currency != nil {
+		currency = *location.Currency
+	}
+
+	net := make([]int64, len(order.LineItems))
+	for i, item := range order.LineItems {
+		gross, err := s.priceLineItem(m, item, i, currency)
+		if err != nil {
+			return err
+		}
+		net[i] = gross
+	}
+
+	if err := s.applyDiscounts(m, order, net, currency); err != nil {
+		return err
+	}
+
+	// Subtotal phase service charges are based on the discounted line items
+	var subtotal int64
+	for _, amount := range net {
+		subtotal += amount
+	}
+	charges := make([]int64, len(order.ServiceCharges))
+	for i, charge := range order.ServiceCharges {
+		if charge.UID == nil {
+			charge.UID = square.String(s.newID("SC"))
+		}
+		if charge.CalculationPhase == nil {
+			charge.CalculationPhase = square.OrderServiceChargeCalculationPhase("SUBTOTAL_PHASE").Ptr()
+		}
+		field := fmt.Sprintf("order.service_charges[%d]", i)
+		if charge.Name == nil && charge.CatalogObjectID == nil {
+			return missing(field + ".name")
+		}
+		if (charge.Percentage == nil) == (charge.AmountMoney == nil) {
+			return badRequest("INVALID_VALUE", field, "Exactly one of percentage or amount_money must be set.")
+		}
+		if *charge.CalculationPhase == "TOTAL_PHASE" {
+			if charge.Taxable != nil && *charge.Taxable {
+				return badRequest("INVALID_VALUE", field+".taxable", "Service charges in the TOTAL_PHASE cannot be taxable.")
+			}
+			continue
+		}
+		amount, err := chargeAmount(charge, subtotal, field)
+		if err != nil {
+			return err
+		}
+		charges[i] = amount
+		charge.AppliedMoney = money(amount, currency)
+	}
+
+	lineTax, chargeTax, err := s.applyTaxes(m, order, net, charges, currency)
+	if err != nil {
+		return err
+	}
+
+	var total, totalTax, totalDiscount, totalCharges int64
+	for i, item := range order.LineItems {
+		gross := amountOf(item.GrossSalesMoney)
+		item.TotalDiscountMoney = money(gross-net[i], currency)
+		item.TotalTaxMoney = money(lineTax[i].total, currency)
+		item.TotalMoney = money(net[i]+lineTax[i].additive, currency)
+		item.TotalServiceChargeMoney = money(0, currency)
+		total += net[i] + lineTax[i].additive
+		totalDiscount += gross - net[i]
+		totalTax += lineTax[i].total
+	}
+	for i, charge := range order.ServiceCharges {
+		if *charge.CalculationPhase == "TOTAL_PHASE" {
+			continue
+		}
+		charge.TotalTaxMoney = money(chargeTax[i].total, currency)
+		charge.TotalMoney = money(charges[i]+chargeTax[i].additive, currency)
+		total += charges[i] + chargeTax[i].additive
+		totalTax += chargeTax[i].total
+		totalCharges += charges[i]
+	}
+
+	// Total phase service charges are based on everything else, taxes included
+	base := total
+	for i, charge := range order.ServiceCharges {
+		if *charge.CalculationPhase != "TOTAL_PHASE" {
+			continue
+		}
+		amount, err := chargeAmount(charge, base, fmt.Sprintf("order.service_charges[%d]", i))
+		if err != nil {
+			return err
+		}
+		charge.AppliedMoney = money(amount, currency)
+		charge.TotalTaxMoney = money(0, currency)
+		charge.TotalMoney = money(amount, currency)
+		total += amount
+		totalCharges += amount
+	}
+
+	var tips, tendered int64
+	for _, tender := range order.Tenders {
+		tips += amountOf(tender.TipMoney)
+		tendered += amountOf(tender.AmountMoney)
+	}
+	due := total + tips - tendered
+	if due < 0 {
+		due = 0
+	}
+
+	order.TotalMoney = money(total+tips, currency)
+	order.TotalTaxMoney = money(totalTax, currency)
+	order.TotalDiscountMoney = money(totalDiscount, currency)
+	order.TotalServiceChargeMoney = money(totalCharges, currency)
+	order.TotalTipMoney = money(tips, currency)
+	order.NetAmountDueMoney = money(due, currency)
+	order.NetAmounts = &square.OrderMoneyAmounts{
+		TotalMoney:         money(total+tips, currency),
+		TaxMoney:           money(totalTax, currency),
+		DiscountMoney:      money(totalDiscount, currency),
+		TipMoney:           money(tips, currency),
+		ServiceChargeMoney: money(totalCharges, currency),
+	}
+	return nil
+}
+
+// priceLineItem fills in the price of a line item and its modifiers and returns its gross sales
+func (s *Server) priceLineItem(m *merchant, item *square.OrderLineItem, i int, currency square.Currency) (int64, error) {
+	field := fmt.Sprintf("order.line_items[%d]", i)
+	if item.UID == nil {
+		item.UID = square.String(s.newID("LI"))
+	}
+	if item.Quantity == "" {
+		return 0, missing(field + ".quantity")
+	}
+	quantity, err := strconv.ParseFloat(item.Quantity, 64)
+	if err != nil || quantity <= 0 {
+		return 0, badRequest("INVALID_VALUE", field+".quantity", "Quantity must be a positive decimal number.")
+	}
+
+	if item.CatalogObjectID != nil {
+		variation := m.catalogObject(*item.CatalogObjectID, "ITEM_VARIATION")
+		if variation == nil {
+			return 0, badRequest("NOT_FOUND", field+".catalog_object_id",
+				fmt.Sprintf("Item variation with catalog object ID `%s` not found.", *item.CatalogObjectID))
+		}
+		item.CatalogVersion = square.Int64(variation.version())
+		if price, ok := variation.money("item_variation_data", "price_money"); ok {
+			item.BasePriceMoney = money(price, currency)
+		}
+		if name, ok := variation.lookup("item_variation_data", "name").(string); ok && item.VariationName == nil {
+			item.VariationName = square.String(name)
+		}
+		if itemID, ok := variation.lookup("item_variation_data", "item_id").(string); ok && item.Name == nil {
+			if parent := m.catalogObject(itemID, "ITEM"); parent != nil {
+				if name, ok := parent.lookup("item_data", "name").(string); ok {
+					item.Name = square.String(name)
+				}
+			}
+		}
+	} else if item.Name == nil {
+		return 0, missing(field + ".name")
+	}
+	if item.BasePriceMoney == nil {
+		return 0, missing(field + ".base_price_money")
+	}
+	if currencyOf(item.BasePriceMoney) != currency {
+		return 0, badRequest("CURRENCY_MISMATCH", field+".base_price_money.currency",
+			fmt.Sprintf("Line item currency must match the location currency %s.", currency))
+	}
+	if item.ItemType == nil {
+		item.ItemType = square.OrderLineItemItemType("ITEM").Ptr()
+	}
+
+	variationTotal := round(float64(amountOf(item.BasePriceMoney)) * quantity)
+	gross := variationTotal
+	for j, modifier := range item.Modifiers {
+		if modifier.UID == nil {
+			modifier.UID = square.String(s.newID("MOD"))
+		}
+		modifierQuantity := 1.0
+		if modifier.Quantity != nil {
+			modifierQuantity, err = strconv.ParseFloat(*modifier.Quantity, 64)
+			if err != nil || modifierQuantity <= 0 {
+				return 0, badRequest("INVALID_VALUE", fmt.Sprintf("%s.modifiers[%d].quantity", field, j), "Quantity must be a positive decimal number.")
+			}
+		}
+		modifierTotal := round(float64(amountOf(modifier.BasePriceMoney)) * modifierQuantity * quantity)
+		modifier.TotalPriceMoney = money(modifierTotal, currency)
+		gross += modifierTotal
+	}
+
+	item.VariationTotalPriceMoney = money(variationTotal, currency)
+	item.GrossSalesMoney = money(gross, currency)
+	return gross, nil
+}
+
+// applyDiscounts reduces net, the amount of each line item, by the discounts applied to it.
+// Order scoped discounts are applied to every line item in proportion to its amount.
+func (s *Server) applyDiscounts(m *merchant, order *square.Order, net []int64, currency square.Currency) error {
+	byUID := map[string]*square.OrderLineItemDiscount{}
+	referenced := map[string]bool{}
+	for i, discount := range order.Discounts {
+		field := fmt.Sprintf("order.discounts[%d]", i)
+		if discount.UID == nil {
+			discount.UID = square.String(s.newID("DIS"))
+		}
+		if discount.CatalogObjectID != nil {
+			object := m.catalogObject(*discount.CatalogObjectID, "DISCOUNT")
+			if object == nil {
+				return badRequest("NOT_FOUND", field+".catalog_object_id",
+					fmt.Sprintf("Discount with catalog object ID `%s` not found.", *discount.CatalogObjectID))
+			}
+			discount.CatalogVersion = square.Int64(object.version())
+			if name, ok := object.lookup("discount_data", "name").(string); ok {
+				discount.Name = square.String(name)
+			}
+			if kind, ok := object.lookup("discount_data", "discount_type").(string); ok {
+				discount.Type = square.OrderLineItemDiscountType(kind).Ptr()
+			}
+			if percentage, ok := object.lookup("discount_data", "percentage").(string); ok {
+				discount.Percentage = square.String(percentage)
+			}
+			if amount, ok := object.money("discount_data", "amount_money"); ok {
+				discount.AmountMoney = money(amount, currency)
+			}
+		}
+		if discount.Type == nil {
+			return missing(field + ".type")
+		}
+		byUID[*discount.UID] = discount
+	}
+	for i, item := range order.LineItems {
+		for j, applied := range item.AppliedDiscounts {
+			if byUID[applied.DiscountUID] == nil {
+				return badRequest("INVALID_VALUE", fmt.Sprintf("order.line_items[%d].applied_discounts[%d].discount_uid", i, j),
+					fmt.Sprintf("No discount with UID `%s` in the order.", applied.DiscountUID))
+			}
+			referenced[applied.DiscountUID] = true
+		}
+	}
+
+	for i, discount := range order.Discounts {
+		field := fmt.Sprintf("order.discounts[%d]", i)
+		uid := *discount.UID
+		if discount.Scope == nil {
+			scope := "ORDER"
+			if referenced[uid] {
+				scope = "LINE_ITEM"
+			}
+			discount.Scope = square.OrderLineItemDiscountScope(scope).Ptr()
+		}
+
+		// Line items the discount applies to
+		var targets []int
+		var targetsTotal int64
+		for j, item := range order.LineItems {
+			if *discount.Scope == "ORDER" && !appliesDiscount(item, uid) {
+				item.AppliedDiscounts = append(item.AppliedDiscounts, &square.OrderLineItemAppliedDiscount{
+					UID: square.String(s.newID("AD")), DiscountUID: uid,
+				})
+			}
+			if appliesDiscount(item, uid) {
+				targets = append(targets, j)
+				targetsTotal += net[j]
+			}
+		}
+
+		amounts := make([]int64, len(targets))
+		switch *discount.Type {
+		case "FIXED_PERCENTAGE", "VARIABLE_PERCENTAGE":
+			percentage, err := parsePercentage(discount.Percentage, field+".percentage")
+			if err != nil {
+				return err
+			}
+			for k, j := range targets {
+				amounts[k] = round(float64(net[j]) * percentage / 100)
+			}
+		case "FIXED_AMOUNT", "VARIABLE_AMOUNT":
+			if discount.AmountMoney == nil {
+				return missing(field + ".amount_money")
+			}
+			amount := amountOf(discount.AmountMoney)
+			if *discount.Scope == "LINE_ITEM" {
+				for k := range targets {
+					amounts[k] = amount
+				}
+				break
+			}
+			// Spread an order discount over the line items, the last one takes the remainder
+			if amount > targetsTotal {
+				amount = targetsTotal
+			}
+			remaining := amount
+			for k, j := range targets {
+				if k == len(targets)-1 {
+					amounts[k] = remaining
+					break
+				}
+				share := round(float64(amount) * float64(net[j]) / float64(targetsTotal))
+				amounts[k] = share
+				remaining -= share
+			}
+		default:
+			return badRequest("INVALID_VALUE", field+".type", fmt.Sprintf("Unsupported discount type `%s`.", *discount.Type))
+		}
+
+		var applied int64
+		for k, j := range targets {
+			amount := amounts[k]
+			if amount > net[j] {
+				amount = net[j]
+			}
+			net[j] -= amount
+			applied += amount
+			for _, entry := range order.LineItems[j].AppliedDiscounts {
+				if entry.DiscountUID == uid {
+					if entry.UID == nil {
+						entry.UID = square.String(s.newID("AD"))
+					}
+					entry.AppliedMoney = money(amount, currency)
+				}
+			}
+		}
+		discount.AppliedMoney = money(applied, currency)
+	}
+	return nil
+}
+
+// taxTotals are the taxes on one line item or service charge. total includes inclusive
+// taxes, additive only the taxes added on top of the price.
+type taxTotals struct {
+	total    int64
+	additive int64
+}
+
+// applyTaxes fills in the taxes applied to each line item and taxable subtotal phase
+// service charge. Order scoped taxes apply to all of them.
+func (s *Server) applyTaxes(m *merchant, order *square.Order, net, charges []int64, currency square.Currency) ([]taxTotals, []taxTotals, error) {
+	lineTax := make([]taxTotals, len(order.LineItems))
+	chargeTax := make([]taxTotals, len(order.ServiceCharges))
+
+	referenced := map[string]bool{}
+	for _, item := range order.LineItems {
+		for _, applied := range item.AppliedTaxes {
+			referenced[applied.TaxUID] = true
+		}
+	}
+
+	for i, tax := range order.Taxes {
+		field := fmt.Sprintf("order.taxes[%d]", i)
+		if tax.UID == nil {
+			tax.UID = square.String(s.newID("TAX"))
+		}
+		if tax.CatalogObjectID != nil {
+			object := m.catalogObject(*tax.CatalogObjectID, "TAX")
+			if object == nil {
+				return nil, nil, badRequest("NOT_FOUND", field+".catalog_object_id",
+					fmt.Sprintf("Tax with catalog object ID `%s` not found.", *tax.CatalogObjectID))
+			}
+			tax.CatalogVersion = square.Int64(object.version())
+			if name, ok := object.lookup("tax_data", "name").(string); ok {
+				tax.Name = square.String(name)
+			}
+			if percentage, ok := object.lookup("tax_data", "percentage").(string); ok {
+				tax.Percentage = square.String(percentage)
+			}
+			inclusion := "ADDITIVE"
+			if value, ok := object.lookup("tax_data", "inclusion_type").(string); ok {
+				inclusion = value
+			}
+			tax.Type = square.OrderLineItemTaxType(inclusion).Ptr()
+		}
+		if tax.Type == nil {
+			tax.Type = square.OrderLineItemTaxType("ADDITIVE").Ptr()
+		}
+		if tax.Scope == nil {
+			scope := "ORDER"
+			if referenced[*tax.UID] {
+				scope = "LINE_ITEM"
+			}
+			tax.Scope = square.OrderLineItemTaxScope(scope).Ptr()
+		}
+		percentage, err := parsePercentage(tax.Percentage, field+".percentage")
+		if err != nil {
+			return nil, nil, err
+		}
+
+		taxOn := func(base int64) int64 {
+			if *tax.Type == "INCLUSIVE" {
+				return round(float64(base) * percentage / (100 + percentage))
+			}
+			return round(float64(base) * percentage / 100)
+		}
+		var applied int64
+		for j, item := range order.LineItems {
+			entry := appliedTax(item.AppliedTaxes, *tax.UID)
+			if entry == nil {
+				if *tax.Scope != "ORDER" {
+					continue
+				}
+				entry = &square.OrderLineItemAppliedTax{TaxUID: *tax.UID}
+				item.AppliedTaxes = append(item.AppliedTaxes, entry)
+			}
+			if entry.UID == nil {
+				entry.UID = square.String(s.newID("AT"))
+			}
+			amount := taxOn(net[j])
+			entry.AppliedMoney = money(amount, currency)
+			lineTax[j].total += amount
+			if *tax.Type != "INCLUSIVE" {
+				lineTax[j].additive += amount
+			}
+			applied += amount
+		}
+		for j, charge := range order.ServiceCharges {
+			if *charge.CalculationPhase == "TOTAL_PHASE" || charge.Taxable == nil || !*charge.Taxable || *tax.Scope != "ORDER" {
+				continue
+			}
+			entry := appliedTax(charge.AppliedTaxes, *tax.UID)
+			if entry == nil {
+				entry = &square.OrderLineItemAppliedTax{UID: square.String(s.newID("AT")), TaxUID: *tax.UID}
+				charge.AppliedTaxes = append(charge.AppliedTaxes, entry)
+			}
+			amount := taxOn(charges[j])
+			entry.AppliedMoney = money(amount, currency)
+			chargeTax[j].total += amount
+			if *tax.Type != "INCLUSIVE" {
+				chargeTax[j].additive += amount
+			}
+			applied += amount
+		}
+		tax.AppliedMoney = money(applied, currency)
+	}
+	return lineTax, chargeTax, nil
+}
+
+// chargeAmount is the amount of a service charge with the given base amount
+func chargeAmount(charge *square.OrderServiceCharge, base int64, field string) (int64, error) {
+	if charge.AmountMoney != nil {
+		return amountOf(charge.AmountMoney), nil
+	}
+	percentage, err := parsePercentage(charge.Percentage, field+".percentage")
+	if err != nil {
+		return 0, err
+	}
+	return round(float64(base) * percentage / 100), nil
+}
+
+func appliesDiscount(item *square.OrderLineItem, uid string) bool {
+	for _, applied := range item.AppliedDiscounts {
+		if applied.DiscountUID == uid {
+			return true
+		}
+	}
+	return false
+}
+
+func appliedTax(entries []*square.OrderLineItemAppliedTax, uid string) *square.OrderLineItemAppliedTax {
+	for _, entry := range entries {
+		if entry.TaxUID == uid {
+			return entry
+		}
+	}
+	return nil
+}
+
+func parsePercentage(value *string, field string) (float64, error) {
+	if value == nil {
+		return 0, missing(field)
+	}
+	percentage, err := strconv.ParseFloat(*value, 64)
+	if err != nil || percentage < 0 || percentage > 100 {
+		return 0, badRequest("INVALID_VALUE", field, fmt.Sprintf("Invalid percentage `%s`.", *value))
+	}
+	return percentage, nil
+}
+
+func round(amount float64) int64 {
+	return int64(math.RoundToEven(amount))
+}
@@ -0,0 +1,355 @@
+// Package squarefake is an in-memory stand-in for the parts of the Square API the
+// application uses: Locations, Orders, Payments, Refunds and the Catalog. Point a Square
+// client at Server.URL to run tests or local development without a Square account.
+//
+// Every access token is a separate merchant with its own locations, orders, payments and
+// catalog, so restaurants registered with different tokens never see each other's data.
+// Errors use Square's {"errors": [...]} envelope with the categories and codes the real
+// API returns, so they go through the same mapping as production errors.
+package squarefake
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"time"
+
+	square "github.com/square/square-go-sdk/v2"
+)
+
+// Server is a fake Square API served over HTTP
+type Server struct {
+	*httptest.Server
+
+	mu        sync.Mutex
+	merchants map[string]*merchant
+	revoked   map[string]bool
+	failures  map[string][]*Error
+	seq       int
+}
+
+// merchant holds the state behind one access token. Slices keep creation order for listing.
+type merchant struct {
+	id          string
+	locations   []*square.Location
+	orders      map[string]*square.Order
+	orderIDs    []string
+	payments    map[string]*square.Payment
+	paymentIDs  []string
+	refunds     map[string]*square.PaymentRefund
+	refundIDs   []string
+	catalog     map[string]catalogObject
+	catalogIDs  []string
+	idempotency map[string]replay
+
+	lastCatalogVersion int64
+}
+
+// replay is the stored outcome of a request made with an idempotency key
+type replay struct {
+	route string
+	body  []byte
+	resp  json.RawMessage
+}
+
+// Error is a Square API error. Status is the HTTP status it is served with.
+type Error struct {
+	Status   int
+	Category string
+	Code     string
+	Detail   string
+	Field    string
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("%d %s %s: %s", e.Status, e.Category, e.Code, e.Detail)
+}
+
+// NewServer starts a fake Square server on a local port. Close it when done.
+func NewServer() *Server {
+	s := &Server{
+		merchants: map[string]*merchant{},
+		revoked:   map[string]bool{},
+		failures:  map[string][]*Error{},
+	}
+	s.Server = httptest.NewServer(s.Handler())
+	return s
+}
+
+// Handler routes requests to the fake Square endpoints
+func (s *Server) Handler() http.Handler {
+	mux := http.NewServeMux()
+
+	s.handle(mux, "GET /v2/locations", s.listLocations)
+	s.handle(mux, "GET /v2/locations/{location_id}", s.getLocation)
+
+	s.handle(mux, "POST /v2/orders", s.createOrder)
+	s.handle(mux, "POST /v2/orders/calculate", s.calculateOrder)
+	s.handle(mux, "POST /v2/orders/search", s.searchOrders)
+	s.handle(mux, "GET /v2/orders/{order_id}", s.getOrder)
+	s.handle(mux, "PUT /v2/orders/{order_id}", s.updateOrder)
+
+	s.handle(mux, "GET /v2/payments", s.listPayments)
+	s.handle(mux, "POST /v2/payments", s.createPayment)
+	s.handle(mux, "GET /v2/payments/{payment_id}", s.getPayment)
+	s.handle(mux, "PUT /v2/payments/{payment_id}", s.updatePayment)
+	s.handle(mux, "POST /v2/payments/{payment_id}/complete", s.completePayment)
+	s.handle(mux, "POST /v2/payments/{payment_id}/cancel", s.cancelPayment)
+
+	s.handle(mux, "GET /v2/refunds", s.listRefunds)
+	s.handle(mux, "POST /v2/refunds", s.refundPayment)
+	s.handle(mux, "GET /v2/refunds/{refund_id}", s.getRefund)
+
+	s.handle(mux, "GET /v2/catalog/list", s.listCatalog)
+	s.handle(mux, "POST /v2/catalog/object", s.upsertCatalogObject)
+	s.handle(mux, "GET /v2/catalog/object/{object_id}", s.getCatalogObject)
+	s.handle(mux, "DELETE /v2/catalog/object/{object_id}", s.deleteCatalogObject)
+
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		writeError(w, &Error{Status: http.StatusNotFound, Category: "INVALID_REQUEST_ERROR", Code: "NOT_FOUND",
+			Detail: fmt.Sprintf("API endpoint for URL path `%s` and HTTP method `%s` is not found.", r.URL.Path, r.Method)})
+	})
+	return mux
+}
+
+// endpoint handles one API call for the merchant owning the request's access token and
+// returns the response body or an *Error
+type endpoint func(m *merchant, r *http.Request) (interface{}, error)
+
+func (s *Server) handle(mux *http.ServeMux, pattern string, fn endpoint) {
+	mux.HandleFunc(pattern, func(w http.ResponseWriter, r *http.Request) {
+		if err := s.nextFailure(r.Method + " " + r.URL.Path); err != nil {
+			writeError(w, err)
+			return
+		}
+
+		token := strings.TrimSpace(strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer"))
+		if token == "" {
+			writeError(w, &Error{Status: http.StatusUnauthorized, Category: "AUTHENTICATION_ERROR", Code: "UNAUTHORIZED",
+				Detail: "This request could not be authorized."})
+			return
+		}
+
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		if s.revoked[token] {
+			writeError(w, &Error{Status: http.StatusUnauthorized, Category: "AUTHENTICATION_ERROR", Code: "ACCESS_TOKEN_REVOKED",
+				Detail: "The access token has been revoked."})
+			return
+		}
+
+		body, err := fn(s.merchant(token), r)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(body)
+	})
+}
+
+// Fail makes the next calls to route fail with errs, one call per error. route is a method
+// and path such as "POST /v2/payments". The Square client retries 408, 429 and
+// 5xx responses, so queue one error per attempt to see such a failure surface.
+func (s *Server) Fail(route string, errs ...*Error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.failures[route] = append(s.failures[route], errs...)
+}
+
+func (s *Server) nextFailure(route string) *Error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	queued := s.failures[route]
+	if len(queued) == 0 {
+		return nil
+	}
+	s.failures[route] = queued[1:]
+	return queued[0]
+}
+
+// RevokeToken makes every later call with token fail with ACCESS_TOKEN_REVOKED
+func (s *Server) RevokeToken(token string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.revoked[token] = true
+}
+
+// AddLocation adds an active location to the merchant behind token and returns its ID.
+// Every merchant starts with one location named "Main".
+func (s *Server) AddLocation(token, name string) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	m := s.merchant(token)
+	return *s.addLocation(m, name).ID
+}
+
+// SetLocationCurrency changes the currency of one of the locations of the merchant behind
+// token, for testing merchants outside the US
+func (s *Server) SetLocationCurrency(token, locationID, currency string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if location := s.merchant(token).location(locationID); location != nil {
+		location.Currency = square.Currency(currency).Ptr()
+	}
+}
+
+// merchant returns the merchant behind token, creating it on first use
+func (s *Server) merchant(token string) *merchant {
+	if m, ok := s.merchants[token]; ok {
+		return m
+	}
+	m := &merchant{
+		id:          s.newID("MLR"),
+		orders:      map[string]*square.Order{},
+		payments:    map[string]*square.Payment{},
+		refunds:     map[string]*square.PaymentRefund{},
+		catalog:     map[string]catalogObject{},
+		idempotency: map[string]replay{},
+	}
+	s.addLocation(m, "Main")
+	s.merchants[token] = m
+	return m
+}
+
+// newID returns a unique uppercase ID with the given prefix
+func (s *Server) newID(prefix string) string {
+	s.seq++
+	return fmt.Sprintf("%s%010dFAKE", prefix, s.seq)
+}
+
+// idempotent runs fn once per idempotency key. Repeating a request with the same key and
+// body replays the first response, reusing the key for a different request is an error.
+func (s *Server) idempotent(m *merchant, key, route string, body []byte, fn func() (interface{}, error)) (interface{}, error) {
+	if key == "" {
+		return fn()
+	}
+	if previous, ok := m.idempotency[key]; ok {
+		if previous.route != route || !bytes.Equal(previous.body, body) {
+			return nil, badRequest("IDEMPOTENCY_KEY_REUSED", "idempotency_key",
+				"The idempotency key can only be retried with the same request data.")
+		}
+		return previous.resp, nil
+	}
+
+	resp, err := fn()
+	if err != nil {
+		return nil, err
+	}
+	encoded, err := json.Marshal(resp)
+	if err != nil {
+		return nil, err
+	}
+	m.idempotency[key] = replay{route: route, body: body, resp: encoded}
+	return json.RawMessage(encoded), nil
+}
+
+// decode reads a JSON request body into v and returns the raw body
+func decode(r *http.Request, v interface{}) ([]byte, error) {
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		return nil, err
+	}
+	if len(bytes.TrimSpace(body)) == 0 {
+		body = []byte("{}")
+	}
+	if err := json.Unmarshal(body, v); err != nil {
+		return nil, &Error{Status: http.StatusBadRequest, Category: "INVALID_REQUEST_ERROR", Code: "INVALID_BODY",
+			Detail: "Invalid JSON in request body: " + err.Error()}
+	}
+	return body, nil
+}
+
+func writeError(w http.ResponseWriter, err error) {
+	apiErr, ok := err.(*Error)
+	if !ok {
+		apiErr = &Error{Status: http.StatusInternalServerError, Category: "API_ERROR", Code: "INTERNAL_SERVER_ERROR", Detail: err.Error()}
+	}
+
+	entry := map[string]string{"category": apiErr.Category, "code": apiErr.Code}
+	if apiErr.Detail != "" {
+		entry["detail"] = apiErr.Detail
+	}
+	if apiErr.Field != "" {
+		entry["field"] = apiErr.Field
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(apiErr.Status)
+	json.NewEncoder(w).Encode(map[string]interface{}{"errors": []map[string]string{entry}})
+}
+
+// badRequest is an INVALID_REQUEST_ERROR about field
+func badRequest(code, field, detail string) *Error {
+	return &Error{Status: http.StatusBadRequest, Category: "INVALID_REQUEST_ERROR", Code: code, Field: field, Detail: detail}
+}
+
+func missing(field string) *Error {
+	return badRequest("MISSING_REQUIRED_PARAMETER", field, "Field must be set")
+}
+
+func notFound(kind, id string) *Error {
+	return &Error{Status: http.StatusNotFound, Category: "INVALID_REQUEST_ERROR", Code: "NOT_FOUND",
+		Detail: fmt.Sprintf("Could not find %s with id `%s`.", kind, id)}
+}
+
+// clone deep-copies an SDK value so callers never share state with the store
+func clone[T any](v *T) *T {
+	encoded, err := json.Marshal(v)
+	if err != nil {
+		panic(err)
+	}
+	out := new(T)
+	if err := json.Unmarshal(encoded, out); err != nil {
+		panic(err)
+	}
+	return out
+}
+
+func timestamp() *string {
+	return square.String(time.Now().UTC().Format("2006-01-02T15:04:05.000Z"))
+}
+
+func money(amount int64, currency square.Currency) *square.Money {
+	return &square.Money{Amount: square.Int64(amount), Currency: currency.Ptr()}
+}
+
+func amountOf(m *square.Money) int64 {
+	if m == nil || m.Amount == nil {
+		return 0
+	}
+	return *m.Amount
+}
+
+func currencyOf(m *square.Money) square.Currency {
+	if m == nil || m.Currency == nil {
+		return square.Currency("USD")
+	}
+	return *m.Currency
+}
+
+func stringOf(s *string) string {
+	if s == nil {
+		return ""
+	}
+	return *s
+}
+
+// page returns the part of ids selected by a numeric cursor and the cursor of the next page
+func page(ids []string, cursor string, limit int) ([]string, *string) {
+	start := 0
+	fmt.Sscanf(cursor, "%d", &start)
+	if start < 0 || start > len(ids) {
+		start = len(ids)
+	}
+	end := start + limit
+	if end >= len(ids) {
+		return ids[start:], nil
+	}
+	return ids[start:end], square.String(fmt.Sprint(end))
+}
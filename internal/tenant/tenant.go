@@ -0,0 +1,168 @@
+package tenant
+
+import (
+	"context"
+	"errors"
+	"reflect"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"gorm.io/gorm/schema"
+)
+
+// Owned is implemented by models whose rows belong to one restaurant. Every query, update
+// and delete on them is limited to the restaurant of the scope, and creates are stamped
+// with it. Users, memberships and roles are not Owned: login and restaurant switching
+// look them up across restaurants.
+type Owned interface {
+	TenantOwned()
+}
+
+var (
+	// ErrMissingScope means a restaurant-owned table was used without Scoped or System
+	ErrMissingScope = errors.New("tenant: restaurant-owned table accessed without a tenant scope")
+	// ErrCrossTenant means a record of another restaurant was written through a scope
+	ErrCrossTenant = errors.New("tenant: record belongs to another restaurant")
+)
+
+type contextKey int
+
+const (
+	restaurantKey contextKey = iota
+	systemKey
+)
+
+// Scoped returns a session limited to the restaurant's rows
+func Scoped(db *gorm.DB, restaurantID uint) *gorm.DB {
+	return db.WithContext(context.WithValue(db.Statement.Context, restaurantKey, restaurantID))
+}
+
+// System returns a session that may read and write every restaurant's rows, for the few
+// lookups that happen before the tenant is known, such as device credentials
+func System(db *gorm.DB) *gorm.DB {
+	return db.WithContext(context.WithValue(db.Statement.Context, systemKey, true))
+}
+
+// RestaurantID returns the restaurant the context is scoped to
+func RestaurantID(ctx context.Context) (uint, bool) {
+	restaurantID, ok := ctx.Value(restaurantKey).(uint)
+	return restaurantID, ok && restaurantID != 0
+}
+
+// Plugin registers the callbacks enforcing the scope. Raw SQL is not checked.
+type Plugin struct{}
+
+func (Plugin) Name() string {
+	return "tenant"
+}
+
+func (Plugin) Initialize(db *gorm.DB) error {
+	callbacks := db.Callback()
+	if err := callbacks.Query().Before("gorm:query").Register("tenant:query", scopeQuery); err != nil {
+		return err
+	}
+	if err := callbacks.Update().Before("gorm:update").Register("tenant:update", scopeQuery); err != nil {
+		return err
+	}
+	if err := callbacks.Delete().Before("gorm:delete").Register("tenant:delete", scopeQuery); err != nil {
+		return err
+	}
+	return callbacks.Create().Before("gorm:create").Register("tenant:create", stampCreate)
+}
+
+// scopeQuery limits a query, update or delete to the restaurant's rows. Updating or
+// deleting a loaded record of another restaurant fails instead of matching nothing.
+func scopeQuery(db *gorm.DB) {
+	field, restaurantID, ok := scope(db)
+	if !ok {
+		return
+	}
+	if err := stampRecords(db, field, restaurantID, false); err != nil {
+		db.AddError(err)
+		return
+	}
+	db.Statement.AddClause(clause.Where{Exprs: []clause.Expression{
+		clause.Eq{Column: clause.Column{Table: clause.CurrentTable, Name: field.DBName}, Value: restaurantID},
+	}})
+}
+
+// stampCreate sets the restaurant on new records and rejects records of another restaurant
+func stampCreate(db *gorm.DB) {
+	field, restaurantID, ok := scope(db)
+	if !ok {
+		return
+	}
+	if err := stampRecords(db, field, restaurantID, true); err != nil {
+		db.AddError(err)
+	}
+}
+
+// scope returns the tenant field and restaurant of a statement on an Owned model. It
+// reports false when there is nothing to enforce, or when the scope is missing, which is
+// recorded as the statement's error.
+func scope(db *gorm.DB) (*schema.Field, uint, bool) {
+	field, owned := tenantField(db.Statement.Schema)
+	if !owned || db.Error != nil {
+		return nil, 0, false
+	}
+	if system, _ := db.Statement.Context.Value(systemKey).(bool); system {
+		return nil, 0, false
+	}
+
+	restaurantID, ok := RestaurantID(db.Statement.Context)
+	if !ok {
+		db.AddError(ErrMissingScope)
+		return nil, 0, false
+	}
+	return field, restaurantID, true
+}
+
+// tenantField returns the restaurant_id field of an Owned model
+func tenantField(s *schema.Schema) (*schema.Field, bool) {
+	if s == nil {
+		return nil, false
+	}
+	if _, ok := reflect.New(s.ModelType).Interface().(Owned); !ok {
+		return nil, false
+	}
+	field := s.LookUpField("RestaurantID")
+	return field, field != nil
+}
+
+// stampRecords rejects records of another restaurant and, when set is true, gives records
+// without a restaurant this one
+func stampRecords(db *gorm.DB, field *schema.Field, restaurantID uint, set bool) error {
+	if !db.Statement.ReflectValue.IsValid() {
+		return nil
+	}
+
+	ctx := db.Statement.Context
+	stamp := func(record reflect.Value) error {
+		record = reflect.Indirect(record)
+		if record.Kind() != reflect.Struct || record.Type() != field.Schema.ModelType {
+			return nil
+		}
+		value, zero := field.ValueOf(ctx, record)
+		if zero {
+			if set {
+				return field.Set(ctx, record, restaurantID)
+			}
+			return nil
+		}
+		if value != restaurantID {
+			return ErrCrossTenant
+		}
+		return nil
+	}
+
+	records := reflect.Indirect(db.Statement.ReflectValue)
+	if records.Kind() == reflect.Slice || records.Kind() == reflect.Array {
+		for i := 0; i < records.Len(); i++ {
+			if err := stamp(records.Index(i)); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	return stamp(records)
+}
@@ -1,16 +1,28 @@
 package utils
 
 import(
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
 	"time"
+	"github.com/google/uuid"
 	"github.com/golang-jwt/jwt/v5"
 	"golang.org/x/crypto/bcrypt"
-	"os"
 	"fmt"
 	"square-pos-integration/internal/models"
+	"square-pos-integration/internal/signing"
 
 
 )
-var jwtSecret = []byte(os.Getenv("JWT_SECRET"))
+// JWTExpiration is how long an issued access token stays valid
+const JWTExpiration = 15 * time.Minute
+
+// RefreshTokenExpiration is how long a refresh token can be exchanged for a new access token
+const RefreshTokenExpiration = 30 * 24 * time.Hour
+
+// DeviceJWTExpiration is how long a token from a PIN login on a shared device stays valid
+const DeviceJWTExpiration = 10 * time.Minute
 
 // JWTClaims represents the JWT token claims
 type JWTClaims struct {
@@ -18,31 +30,59 @@ type JWTClaims struct {
 	Email        string `json:"email"`
 	RestaurantID uint   `json:"restaurant_id"`
 	Role         string `json:"role"`
+	TokenVersion int    `json:"ver"`
+	DeviceID     uint   `json:"device_id,omitempty"` // Set when the user signed in with a PIN on a shared device
 	jwt.RegisteredClaims
 }
 
-// GenerateJWT creates a new JWT token for the user
-var GenerateJWT = func(user models.User) (string, error) {
+// GenerateJWT creates a new JWT token for the user, signed with the current key of keys
+var GenerateJWT = func(keys *signing.KeyRing, user models.User) (string, error) {
+	return generateJWT(keys, user, 0, JWTExpiration)
+}
+
+// GenerateDeviceJWT creates a short-lived JWT token for a user signed in on a shared device
+var GenerateDeviceJWT = func(keys *signing.KeyRing, user models.User, deviceID uint) (string, error) {
+	return generateJWT(keys, user, deviceID, DeviceJWTExpiration)
+}
+
+func generateJWT(keys *signing.KeyRing, user models.User, deviceID uint, expiration time.Duration) (string, error) {
 	claims := JWTClaims{
 		UserID:       user.ID,
 		Email:        user.Email,
 		RestaurantID: user.RestaurantID,
 		Role:         user.Role,
+		TokenVersion: user.TokenVersion,
+		DeviceID:     deviceID,
 		RegisteredClaims: jwt.RegisteredClaims{
-			ExpiresAt: jwt.NewNumericDate(time.Now().Add(24 * time.Hour)), // 1 day expiration
+			ID:        uuid.NewString(), // Lets a single token be revoked on logout
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(expiration)),
 			IssuedAt:  jwt.NewNumericDate(time.Now()),
 		},
 	}
 
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	return token.SignedString(jwtSecret)
+	key, err := keys.SigningKey(time.Now())
+	if err != nil {
+		return "", err
+	}
+
+	token := jwt.NewWithClaims(key.SigningMethod(), claims)
+	token.Header["kid"] = key.ID
+	return token.SignedString(key.PrivateKey)
 }
 
-// ValidateJWT validates a JWT token and returns the claims
-func ValidateJWT(tokenString string) (*JWTClaims, error) {
+// ValidateJWT validates a JWT token against the key of keys named by its kid header and returns the claims
+func ValidateJWT(keys *signing.KeyRing, tokenString string) (*JWTClaims, error) {
 	token, err := jwt.ParseWithClaims(tokenString, &JWTClaims{}, func(token *jwt.Token) (interface{}, error) {
-		return jwtSecret, nil
-	})
+		kid, _ := token.Header["kid"].(string)
+		key, ok := keys.VerificationKey(kid, time.Now())
+		if !ok {
+			return nil, fmt.Errorf("unknown signing key %q", kid)
+		}
+		if token.Method.Alg() != key.Algorithm {
+			return nil, fmt.Errorf("token algorithm %s does not match key %q", token.Method.Alg(), kid)
+		}
+		return key.PublicKey(), nil
+	}, jwt.WithValidMethods([]string{signing.AlgorithmRS256, signing.AlgorithmEdDSA}))
 
 	if err != nil {
 		return nil, err
@@ -55,6 +95,22 @@ func ValidateJWT(tokenString string) (*JWTClaims, error) {
 	return nil, fmt.Errorf("invalid token")
 }
 
+// GenerateOpaqueToken returns a random bearer credential, such as a refresh token, and the hash to store for it
+func GenerateOpaqueToken() (string, string, error) {
+	bytes := make([]byte, 32)
+	if _, err := rand.Read(bytes); err != nil {
+		return "", "", err
+	}
+	token := base64.RawURLEncoding.EncodeToString(bytes)
+	return token, HashToken(token), nil
+}
+
+// HashToken hashes an opaque token for storage and lookup
+func HashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
 // HashPassword hashes a password using bcrypt
 func HashPassword(password string) (string, error) {
 	bytes, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
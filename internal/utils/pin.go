@@ -0,0 +1,31 @@
+package utils
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"strconv"
+)
+
+// PINHasher hashes staff PINs with a secret key so a leaked users table cannot be brute
+// forced offline
+type PINHasher struct {
+	secret []byte
+}
+
+func NewPINHasher(secret string) *PINHasher {
+	return &PINHasher{secret: []byte(secret)}
+}
+
+// Hash returns a deterministic keyed hash of a staff PIN. Unlike bcrypt it allows
+// looking a user up by PIN, which keeps PINs unique within a restaurant.
+func (h *PINHasher) Hash(restaurantID uint, pin string) string {
+	mac := hmac.New(sha256.New, h.secret)
+	mac.Write([]byte(strconv.FormatUint(uint64(restaurantID), 10) + ":" + pin))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// Verify compares a PIN against its stored hash in constant time
+func (h *PINHasher) Verify(restaurantID uint, pinHash, pin string) bool {
+	return hmac.Equal([]byte(pinHash), []byte(h.Hash(restaurantID, pin)))
+}
@@ -0,0 +1,64 @@
+package utils
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+)
+
+// SecretBox encrypts secrets that must be read back, such as TOTP secrets, so a leaked
+// users table does not let anyone generate codes
+type SecretBox struct {
+	key []byte
+}
+
+// NewSecretBox returns a SecretBox with an AES-256 key derived from secret
+func NewSecretBox(secret string) *SecretBox {
+	sum := sha256.Sum256([]byte(secret))
+	return &SecretBox{key: sum[:]}
+}
+
+// Encrypt encrypts a value with AES-GCM and returns it base64 encoded
+func (b *SecretBox) Encrypt(plaintext string) (string, error) {
+	gcm, err := b.cipher()
+	if err != nil {
+		return "", err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+	sealed := gcm.Seal(nonce, nonce, []byte(plaintext), nil)
+	return base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+// Decrypt reverses Encrypt
+func (b *SecretBox) Decrypt(encrypted string) (string, error) {
+	gcm, err := b.cipher()
+	if err != nil {
+		return "", err
+	}
+	sealed, err := base64.StdEncoding.DecodeString(encrypted)
+	if err != nil {
+		return "", err
+	}
+	if len(sealed) < gcm.NonceSize() {
+		return "", errors.New("encrypted secret is too short")
+	}
+	plaintext, err := gcm.Open(nil, sealed[:gcm.NonceSize()], sealed[gcm.NonceSize():], nil)
+	if err != nil {
+		return "", err
+	}
+	return string(plaintext), nil
+}
+
+func (b *SecretBox) cipher() (cipher.AEAD, error) {
+	block, err := aes.NewCipher(b.key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
@@ -2,10 +2,10 @@ package utils
 
 import (
 
+	"math"
 	"strconv"
 	"github.com/square/square-go-sdk/v2"
-	"github.com/gin-gonic/gin"
-	
+	"square-pos-integration/internal/models"
 )
 
 func SafeString(s *string) string {
@@ -22,6 +22,20 @@ func SafeInt64(i *int64) int64 {
 	return 0
 }
 
+// SafeMoney returns the amount of a Square money object, treating a missing object as zero
+func SafeMoney(m *square.Money) int64 {
+	if m != nil {
+		return SafeInt64(m.Amount)
+	}
+	return 0
+}
+
+// ToCents converts an amount in the currency's main unit to its smallest unit. It rounds to
+// the nearest cent, as 0.29 * 100 is just below 29 in floating point.
+func ToCents(amount float64) int64 {
+	return int64(math.Round(amount * 100))
+}
+
 func SafeCurrency(c *square.Currency) string {
 	if c != nil {
 		return string(*c) // Convert enum to string value like "USD"
@@ -57,92 +71,22 @@ func Float64ToInt64Ptr(f *float64) *int64 {
     return &i
 }
 
-func BuildOrderItems(squareOrder *square.Order) []gin.H {
-	var items []gin.H
-	
-	if squareOrder.LineItems == nil {
-		return items
+// BuildOrderTotals builds the stored totals for an order from Square's calculated amounts and
+// the amounts paid and tipped so far. All values are in the smallest currency unit.
+func BuildOrderTotals(squareOrder *square.Order, paid int64, tips int64) models.OrderTotals {
+	total := SafeMoney(squareOrder.TotalMoney)
+	due := total - paid
+	if due < 0 {
+		due = 0
 	}
 
-	for _, lineItem := range squareOrder.LineItems {
-		item := gin.H{
-			"name":       SafeString(lineItem.Name),
-			"comment":    "", // Add comment if available in your model
-			"unit_price": int(SafeInt64(lineItem.BasePriceMoney.Amount)) / 100,
-			"quantity":   ParseQuantity(SafeString(&lineItem.Quantity)),
-			"discounts":  BuildItemDiscounts(lineItem.AppliedDiscounts, squareOrder.Discounts),
-			"modifiers":  BuildItemModifiers(lineItem.Modifiers),
-			"amount":     int(SafeInt64(lineItem.TotalMoney.Amount)) / 100,
-		}
-		items = append(items, item)
-	}
-	
-	return items
-}
-
-// BuildItemDiscounts builds the discounts array for an order item
-func BuildItemDiscounts(appliedDiscounts []*square.OrderLineItemAppliedDiscount, orderDiscounts []*square.OrderLineItemDiscount) []gin.H {
-	var discounts []gin.H
-	
-	if appliedDiscounts == nil || orderDiscounts == nil {
-		return discounts
-	}
-
-	for _, applied := range appliedDiscounts {
-		for _, discount := range orderDiscounts {
-			if SafeString(discount.UID) == applied.DiscountUID {
-				discounts = append(discounts, gin.H{
-					"name":          SafeString(discount.Name),
-					"is_percentage": discount.Type != nil && *discount.Type == "PERCENTAGE",
-					"value":         int(SafeInt64(discount.AmountMoney.Amount)) / 100,
-					"amount":        int(SafeInt64(discount.AmountMoney.Amount)) / 100,
-				})
-				break
-			}
-		}
+	return models.OrderTotals{
+		Discounts:     int(SafeMoney(squareOrder.TotalDiscountMoney)),
+		Due:           int(due),
+		Tax:           int(SafeMoney(squareOrder.TotalTaxMoney)),
+		ServiceCharge: int(SafeMoney(squareOrder.TotalServiceChargeMoney)),
+		Paid:          int(paid),
+		Tips:          int(tips),
+		Total:         int(total),
 	}
-	
-	return discounts
 }
-
-// BuildItemModifiers builds the modifiers array for an order item
-func BuildItemModifiers(modifiers []*square.OrderLineItemModifier) []gin.H {
-	var mods []gin.H
-	
-	if modifiers == nil {
-		return mods
-	}
-
-	for _, modifier := range modifiers {
-		mods = append(mods, gin.H{
-			"name":       SafeString(modifier.Name),
-			"unit_price": int(SafeInt64(modifier.BasePriceMoney.Amount)) / 100,
-			"quantity":   1, // Default to 1 if not specified
-			"amount":     int(SafeInt64(modifier.TotalPriceMoney.Amount)) / 100,
-		})
-	}
-	
-	return mods
-}
-
-// BuildOrderTotals builds the totals object for the order response
-func BuildOrderTotals(squareOrder *square.Order, tipAmount float64) gin.H {
-	totalMoney := SafeInt64(squareOrder.TotalMoney.Amount)
-	totalDiscounts := SafeInt64(squareOrder.TotalDiscountMoney.Amount)
-	totalTax := SafeInt64(squareOrder.TotalTaxMoney.Amount)
-	totalServiceCharge := SafeInt64(squareOrder.TotalServiceChargeMoney.Amount)
-	
-	return gin.H{
-		"discounts":      int(totalDiscounts) / 100,
-		"due":           int(totalMoney) / 100,
-		"tax":           int(totalTax) / 100,
-		"service_charge": int(totalServiceCharge) / 100,
-		"paid":          int(totalMoney) / 100, 
-		"tips":          int(tipAmount * 100) / 100,
-		"total":         int(totalMoney) / 100,
-	}
-}
-
-
-
-
@@ -1,36 +1,129 @@
 package main
 
 import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
 	"os"
-    "log"
+	"os/signal"
+	"syscall"
+    "log/slog"
+    "time"
     "square-pos-integration/internal/config"
+    "square-pos-integration/internal/migrate"
     "square-pos-integration/internal/routes"
+    "square-pos-integration/internal/server"
+    "square-pos-integration/internal/service"
+    "square-pos-integration/internal/squarefake"
+    "square-pos-integration/migrations"
     "github.com/gin-gonic/gin"
     "github.com/joho/godotenv"
+    "gorm.io/gorm"
 )
 
 func main() {
+    if err := run(os.Args[1:]); err != nil {
+        slog.Error("Exiting", "error", err)
+        os.Exit(1)
+    }
+}
+
+// run starts the server, or runs the migrate command, until it stops. It returns rather
+// than exits on failure so the deferred cleanup runs.
+func run(arguments []string) error {
     // Load environment variables
-    if err := godotenv.Load(); err != nil {
-        log.Printf("Warning: .env file not found: %v", err)
+    envErr := godotenv.Load()
+
+    // Settings come from defaults, a config file, the environment and flags, see config.Load
+    cfg, args, err := config.Load(arguments, os.Stderr)
+    if errors.Is(err, flag.ErrHelp) {
+        return nil
+    }
+    if err != nil {
+        return err
+    }
+
+    // Everything logs through slog at LOG_LEVEL in LOG_FORMAT, with secrets redacted
+    logger, err := cfg.Log.Logger(os.Stderr)
+    if err != nil {
+        return err
+    }
+    slog.SetDefault(logger)
+    if envErr != nil {
+        slog.Warn(".env file not found", "error", envErr)
+    }
+
+    // `migrate up|down|status|create` manages the schema instead of starting the server
+    if len(args) > 0 && args[0] == "migrate" {
+        open := func() (*gorm.DB, error) { return cfg.Database.Open() }
+        if err := migrate.Run(args[1:], migrations.For, open, os.Stdout); err != nil {
+            return fmt.Errorf("migration failed: %w", err)
+        }
+        return nil
+    }
+
+    // Configuration problems are listed one per line, so they are printed rather than logged
+    if err := cfg.Validate(); err != nil {
+        fmt.Fprintln(os.Stderr, err)
+        return errors.New("invalid configuration")
+    }
+    db, err := cfg.Database.Open()
+    if err != nil {
+        return fmt.Errorf("failed to connect to DB: %w", err)
+    }
+    if sqlDB, err := db.DB(); err == nil {
+        defer sqlDB.Close()
+    }
+    if err := cfg.Database.Migrate(db); err != nil {
+        return fmt.Errorf("database migrations failed: %w", err)
+    }
+
+    // SQUARE_ENV=fake serves an in-memory Square API from this process, any access token works
+    if cfg.Square.Environment == "fake" {
+        fake := squarefake.NewServer()
+        defer fake.Close()
+        cfg.Square.BaseURL = fake.URL
+        slog.Info("Using the fake Square API", "url", fake.URL)
     }
 
-    // Initialize configuration and DB
-    appCfg := config.Init()
+    // Load the token signing keys and keep rotating them in the background
+    keys := service.NewKeys(cfg.Secrets())
+    keyService := service.NewKeyService(db, keys.Signing, keys.Secrets)
+    keyService.Algorithm = cfg.JWT.SigningAlgorithm
+    keyService.RotationInterval = time.Duration(cfg.JWT.KeyRotationDays) * 24 * time.Hour
+    if err := keyService.Load(); err != nil {
+        return fmt.Errorf("failed to load signing keys: %w", err)
+    }
+
+    // Readiness checks the database and schema, and Square when a probe interval is set
+    health := service.NewHealthService(db)
+    if cfg.Square.ProbeInterval > 0 {
+        health.Square = service.NewSquareProbe(cfg.Square.URL(), cfg.Square.Timeout)
+    }
 
     // Initialize Gin router
-    router := gin.Default()
+    router := gin.New()
+    router.Use(gin.Recovery())
 
     // Setup routes with dependencies
-    routes.SetupRoutes(router, appCfg.DB, appCfg)
+    routes.SetupRoutes(router, db, cfg, keys)
+    routes.SetupHealthRoutes(router, health)
 
-    // Start server
-    port := os.Getenv("PORT")
-    if port == "" {
-        port = "8080"
+    // Serve until SIGINT or SIGTERM, then drain requests and stop the background workers
+    ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+    defer stop()
+    srv := server.New(cfg.Server, router)
+    srv.OnShutdown = health.Drain
+    srv.Workers = append(srv.Workers, func(stop <-chan struct{}) { keyService.Run(time.Minute, stop) })
+    if health.Square != nil {
+        srv.Workers = append(srv.Workers, func(stop <-chan struct{}) { health.Square.Run(cfg.Square.ProbeInterval, stop) })
     }
-    log.Printf("Server starting on port %s", port)
-    if err := router.Run(":" + port); err != nil {
-        log.Fatal("Failed to start server:", err)
+
+    slog.Info("Server starting", "port", cfg.Server.Port)
+    if err := srv.Run(ctx); err != nil {
+        return fmt.Errorf("server stopped: %w", err)
     }
-}
\ No newline at end of file
+    slog.Info("Server stopped")
+    return nil
+}
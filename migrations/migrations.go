@@ -0,0 +1,26 @@
+// Package migrations holds the SQL migrations of the database schema, applied in order by
+// internal/migrate. Each database has a directory of its own with the same versions, and
+// each version has a <version>_<name>.up.sql file and a .down.sql file that reverts it.
+package migrations
+
+import (
+	"embed"
+	"fmt"
+	"io/fs"
+)
+
+//go:embed mysql postgres sqlite
+var files embed.FS
+
+// Dialects are the databases with migrations, named like GORM's dialectors
+var Dialects = []string{"mysql", "postgres", "sqlite"}
+
+// For returns the migrations of a database, by its GORM dialector name
+func For(dialect string) (fs.FS, error) {
+	for _, name := range Dialects {
+		if name == dialect {
+			return fs.Sub(files, name)
+		}
+	}
+	return nil, fmt.Errorf("no migrations for database %q", dialect)
+}
@@ -0,0 +1,8 @@
+DROP TABLE IF EXISTS `payments`;
+DROP TABLE IF EXISTS `order_item_modifiers`;
+DROP TABLE IF EXISTS `order_item_discounts`;
+DROP TABLE IF EXISTS `order_items`;
+DROP TABLE IF EXISTS `orders`;
+DROP TABLE IF EXISTS `tables`;
+DROP TABLE IF EXISTS `users`;
+DROP TABLE IF EXISTS `restaurants`;
@@ -0,0 +1,171 @@
+-- Tables as GORM's AutoMigrate created them before migrations existed. IF NOT EXISTS lets
+-- databases set up that way adopt the migration history, 0003_upgrade_automigrate_schema
+-- then brings them up to date.
+
+CREATE TABLE IF NOT EXISTS `restaurants` (
+  `id` bigint unsigned AUTO_INCREMENT,
+  `created_at` datetime(3) NULL,
+  `updated_at` datetime(3) NULL,
+  `deleted_at` datetime(3) NULL,
+  `name` longtext NOT NULL,
+  `square_app_id` varchar(255),
+  `square_token` longtext NOT NULL,
+  `merchant_id` longtext NOT NULL,
+  `location_id` longtext NOT NULL,
+  PRIMARY KEY (`id`),
+  INDEX `idx_restaurants_deleted_at` (`deleted_at`),
+  UNIQUE INDEX `idx_restaurants_square_app_id` (`square_app_id`)
+);
+
+CREATE TABLE IF NOT EXISTS `users` (
+  `id` bigint unsigned AUTO_INCREMENT,
+  `created_at` datetime(3) NULL,
+  `updated_at` datetime(3) NULL,
+  `deleted_at` datetime(3) NULL,
+  `username` varchar(100) NOT NULL,
+  `email` varchar(255) NOT NULL,
+  `password_hash` varchar(255) NOT NULL,
+  `restaurant_id` bigint unsigned NOT NULL,
+  `role` varchar(50) NOT NULL DEFAULT 'staff',
+  `is_active` boolean DEFAULT true,
+  PRIMARY KEY (`id`),
+  INDEX `idx_users_deleted_at` (`deleted_at`),
+  UNIQUE INDEX `idx_users_username` (`username`),
+  UNIQUE INDEX `idx_users_email` (`email`),
+  INDEX `idx_users_restaurant_id` (`restaurant_id`),
+  CONSTRAINT `fk_restaurants_users` FOREIGN KEY (`restaurant_id`) REFERENCES `restaurants`(`id`) ON DELETE CASCADE ON UPDATE CASCADE
+);
+
+CREATE TABLE IF NOT EXISTS `tables` (
+  `id` bigint unsigned AUTO_INCREMENT,
+  `created_at` datetime(3) NULL,
+  `updated_at` datetime(3) NULL,
+  `deleted_at` datetime(3) NULL,
+  `restaurant_id` bigint unsigned NOT NULL,
+  `table_number` longtext,
+  `capacity` bigint,
+  `status` longtext,
+  PRIMARY KEY (`id`),
+  INDEX `idx_tables_deleted_at` (`deleted_at`),
+  INDEX `idx_tables_restaurant_id` (`restaurant_id`),
+  CONSTRAINT `fk_tables_restaurant` FOREIGN KEY (`restaurant_id`) REFERENCES `restaurants`(`id`)
+);
+
+CREATE TABLE IF NOT EXISTS `orders` (
+  `id` bigint unsigned AUTO_INCREMENT,
+  `created_at` datetime(3) NULL,
+  `updated_at` datetime(3) NULL,
+  `deleted_at` datetime(3) NULL,
+  `restaurant_id` bigint unsigned NOT NULL,
+  `square_order_id` varchar(255),
+  `table_id` bigint unsigned,
+  `payment_id` char(36),
+  `table_number` bigint,
+  `opened_at` datetime(3) NOT NULL,
+  `is_closed` boolean DEFAULT false,
+  `status` varchar(100) DEFAULT 'open',
+  `user_id` bigint unsigned NOT NULL,
+  `total_amount` bigint NOT NULL DEFAULT 0,
+  `currency` varchar(3) NOT NULL DEFAULT 'USD',
+  `location_id` varchar(255) NOT NULL,
+  `raw_square_data` JSON,
+  `payed_amount` bigint DEFAULT 0,
+  `tip_amount` bigint DEFAULT 0,
+  `discounts` bigint DEFAULT 0,
+  `due` bigint DEFAULT 0,
+  `tax` bigint DEFAULT 0,
+  `service_charge` bigint DEFAULT 0,
+  `paid` bigint DEFAULT 0,
+  `tips` bigint DEFAULT 0,
+  `total` bigint DEFAULT 0,
+  PRIMARY KEY (`id`),
+  INDEX `idx_orders_deleted_at` (`deleted_at`),
+  INDEX `idx_orders_restaurant_id` (`restaurant_id`),
+  INDEX `idx_orders_square_order_id` (`square_order_id`),
+  INDEX `idx_orders_table_id` (`table_id`),
+  INDEX `idx_orders_payment_id` (`payment_id`),
+  INDEX `idx_orders_is_closed` (`is_closed`),
+  INDEX `idx_orders_user_id` (`user_id`),
+  CONSTRAINT `fk_orders_restaurant` FOREIGN KEY (`restaurant_id`) REFERENCES `restaurants`(`id`),
+  CONSTRAINT `fk_tables_orders` FOREIGN KEY (`table_id`) REFERENCES `tables`(`id`) ON DELETE SET NULL
+);
+
+CREATE TABLE IF NOT EXISTS `order_items` (
+  `id` bigint unsigned AUTO_INCREMENT,
+  `created_at` datetime(3) NULL,
+  `updated_at` datetime(3) NULL,
+  `deleted_at` datetime(3) NULL,
+  `order_id` bigint unsigned NOT NULL,
+  `name` varchar(255) NOT NULL,
+  `comment` varchar(500),
+  `unit_price` bigint NOT NULL,
+  `quantity` bigint NOT NULL,
+  `amount` bigint NOT NULL,
+  `square_item_id` varchar(255),
+  `square_uid` varchar(255),
+  PRIMARY KEY (`id`),
+  INDEX `idx_order_items_deleted_at` (`deleted_at`),
+  INDEX `idx_order_items_order_id` (`order_id`),
+  CONSTRAINT `fk_orders_items` FOREIGN KEY (`order_id`) REFERENCES `orders`(`id`) ON DELETE CASCADE
+);
+
+CREATE TABLE IF NOT EXISTS `order_item_discounts` (
+  `id` bigint unsigned AUTO_INCREMENT,
+  `created_at` datetime(3) NULL,
+  `updated_at` datetime(3) NULL,
+  `deleted_at` datetime(3) NULL,
+  `order_item_id` bigint unsigned NOT NULL,
+  `name` varchar(255) NOT NULL,
+  `is_percentage` boolean DEFAULT false,
+  `value` bigint NOT NULL,
+  `amount` bigint NOT NULL,
+  `square_discount_uid` varchar(255),
+  PRIMARY KEY (`id`),
+  INDEX `idx_order_item_discounts_deleted_at` (`deleted_at`),
+  INDEX `idx_order_item_discounts_order_item_id` (`order_item_id`),
+  CONSTRAINT `fk_order_items_discounts` FOREIGN KEY (`order_item_id`) REFERENCES `order_items`(`id`) ON DELETE CASCADE ON UPDATE CASCADE
+);
+
+CREATE TABLE IF NOT EXISTS `order_item_modifiers` (
+  `id` bigint unsigned AUTO_INCREMENT,
+  `created_at` datetime(3) NULL,
+  `updated_at` datetime(3) NULL,
+  `deleted_at` datetime(3) NULL,
+  `order_item_id` bigint unsigned NOT NULL,
+  `name` varchar(255) NOT NULL,
+  `unit_price` bigint NOT NULL,
+  `quantity` bigint NOT NULL,
+  `amount` bigint NOT NULL,
+  `square_modifier_uid` varchar(255),
+  PRIMARY KEY (`id`),
+  INDEX `idx_order_item_modifiers_deleted_at` (`deleted_at`),
+  INDEX `idx_order_item_modifiers_order_item_id` (`order_item_id`),
+  CONSTRAINT `fk_order_items_modifiers` FOREIGN KEY (`order_item_id`) REFERENCES `order_items`(`id`) ON DELETE CASCADE ON UPDATE CASCADE
+);
+
+CREATE TABLE IF NOT EXISTS `payments` (
+  `id` bigint unsigned AUTO_INCREMENT,
+  `created_at` datetime(3) NULL,
+  `updated_at` datetime(3) NULL,
+  `deleted_at` datetime(3) NULL,
+  `order_id` bigint unsigned NOT NULL,
+  `restaurant_id` bigint unsigned NOT NULL,
+  `bill_amount` bigint NOT NULL,
+  `tip_amount` bigint DEFAULT 0,
+  `total_amount` bigint NOT NULL,
+  `status` varchar(100) DEFAULT 'pending',
+  `payment_method` varchar(50),
+  `processed_at` datetime(3) NULL,
+  `raw_square_data` JSON,
+  `square_payment_id` varchar(255),
+  `square_location_id` varchar(255),
+  `currency` varchar(10) DEFAULT 'USD',
+  `transaction_fee` bigint DEFAULT 0,
+  `net_amount` bigint DEFAULT 0,
+  PRIMARY KEY (`id`),
+  INDEX `idx_payments_deleted_at` (`deleted_at`),
+  INDEX `idx_payments_order_id` (`order_id`),
+  INDEX `idx_payments_restaurant_id` (`restaurant_id`),
+  CONSTRAINT `fk_payments_restaurant` FOREIGN KEY (`restaurant_id`) REFERENCES `restaurants`(`id`),
+  CONSTRAINT `fk_orders_payments` FOREIGN KEY (`order_id`) REFERENCES `orders`(`id`) ON DELETE CASCADE
+);
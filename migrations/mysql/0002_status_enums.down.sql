@@ -0,0 +1,2 @@
+ALTER TABLE `orders` MODIFY `status` varchar(100) DEFAULT 'open';
+ALTER TABLE `payments` MODIFY `status` varchar(100) DEFAULT 'pending';
@@ -0,0 +1,3 @@
+-- users.role stays a plain column so restaurants can assign custom roles
+ALTER TABLE `orders` MODIFY `status` ENUM('open', 'closed', 'cancelled', 'pending') DEFAULT 'open';
+ALTER TABLE `payments` MODIFY `status` ENUM('pending', 'paid', 'failed') DEFAULT 'pending';
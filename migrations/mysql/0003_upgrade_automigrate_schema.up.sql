@@ -0,0 +1,243 @@
+-- Brings databases that GORM's AutoMigrate created, see 0001_initial_schema, up to
+-- the current models: the columns added to existing tables and the tables added since
+
+-- users.role was an ENUM of the built-in roles, which custom roles do not fit
+ALTER TABLE `users` MODIFY `role` varchar(50) NOT NULL DEFAULT 'staff';
+
+ALTER TABLE `restaurants` ADD COLUMN `discount_limit_percent` bigint NOT NULL DEFAULT 20;
+ALTER TABLE `restaurants` ADD COLUMN `two_factor_roles` varchar(255);
+
+ALTER TABLE `users` ADD COLUMN `token_version` bigint NOT NULL DEFAULT 0;
+ALTER TABLE `users` ADD COLUMN `email_verification_pending` boolean NOT NULL DEFAULT false;
+ALTER TABLE `users` ADD COLUMN `email_verified_at` datetime(3) NULL;
+ALTER TABLE `users` ADD COLUMN `pin_hash` varchar(64);
+ALTER TABLE `users` ADD COLUMN `failed_pin_attempts` bigint NOT NULL DEFAULT 0;
+ALTER TABLE `users` ADD COLUMN `pin_locked_until` datetime(3) NULL;
+ALTER TABLE `users` ADD COLUMN `totp_secret` varchar(255);
+ALTER TABLE `users` ADD COLUMN `totp_enabled_at` datetime(3) NULL;
+ALTER TABLE `users` ADD COLUMN `totp_last_step` bigint NOT NULL DEFAULT 0;
+ALTER TABLE `users` ADD UNIQUE INDEX `idx_users_restaurant_pin` (`restaurant_id`,`pin_hash`);
+
+ALTER TABLE `payments` ADD COLUMN `refunded_amount` bigint DEFAULT 0;
+CREATE TABLE `tax_rules` (
+  `id` bigint unsigned AUTO_INCREMENT,
+  `created_at` datetime(3) NULL,
+  `updated_at` datetime(3) NULL,
+  `deleted_at` datetime(3) NULL,
+  `restaurant_id` bigint unsigned NOT NULL,
+  `location_id` varchar(255),
+  `name` varchar(255) NOT NULL,
+  `percentage` varchar(20) NOT NULL,
+  `inclusion_type` varchar(20) NOT NULL DEFAULT 'ADDITIVE',
+  `enabled` boolean,
+  `square_catalog_object_id` varchar(255),
+  `square_catalog_version` bigint DEFAULT 0,
+  PRIMARY KEY (`id`),
+  INDEX `idx_tax_rules_deleted_at` (`deleted_at`),
+  INDEX `idx_tax_rules_restaurant_id` (`restaurant_id`),
+  INDEX `idx_tax_rules_location_id` (`location_id`),
+  INDEX `idx_tax_rules_enabled` (`enabled`),
+  INDEX `idx_tax_rules_square_catalog_object_id` (`square_catalog_object_id`),
+  CONSTRAINT `fk_tax_rules_restaurant` FOREIGN KEY (`restaurant_id`) REFERENCES `restaurants`(`id`)
+);
+
+CREATE TABLE `service_charge_rules` (
+  `id` bigint unsigned AUTO_INCREMENT,
+  `created_at` datetime(3) NULL,
+  `updated_at` datetime(3) NULL,
+  `deleted_at` datetime(3) NULL,
+  `restaurant_id` bigint unsigned NOT NULL,
+  `location_id` varchar(255),
+  `name` varchar(255) NOT NULL,
+  `percentage` varchar(20),
+  `amount` bigint DEFAULT 0,
+  `calculation_phase` varchar(50) NOT NULL DEFAULT 'SUBTOTAL_PHASE',
+  `taxable` boolean DEFAULT false,
+  `enabled` boolean,
+  `min_guest_count` bigint DEFAULT 0,
+  `order_type` varchar(50),
+  PRIMARY KEY (`id`),
+  INDEX `idx_service_charge_rules_deleted_at` (`deleted_at`),
+  INDEX `idx_service_charge_rules_restaurant_id` (`restaurant_id`),
+  INDEX `idx_service_charge_rules_location_id` (`location_id`),
+  INDEX `idx_service_charge_rules_enabled` (`enabled`),
+  CONSTRAINT `fk_service_charge_rules_restaurant` FOREIGN KEY (`restaurant_id`) REFERENCES `restaurants`(`id`)
+);
+
+CREATE TABLE `refresh_tokens` (
+  `id` bigint unsigned AUTO_INCREMENT,
+  `created_at` datetime(3) NULL,
+  `updated_at` datetime(3) NULL,
+  `deleted_at` datetime(3) NULL,
+  `user_id` bigint unsigned NOT NULL,
+  `restaurant_id` bigint unsigned NOT NULL DEFAULT 0,
+  `token_hash` varchar(64) NOT NULL,
+  `family_id` varchar(36) NOT NULL,
+  `expires_at` datetime(3) NOT NULL,
+  `revoked_at` datetime(3) NULL,
+  PRIMARY KEY (`id`),
+  INDEX `idx_refresh_tokens_deleted_at` (`deleted_at`),
+  INDEX `idx_refresh_tokens_user_id` (`user_id`),
+  UNIQUE INDEX `idx_refresh_tokens_token_hash` (`token_hash`),
+  INDEX `idx_refresh_tokens_family_id` (`family_id`),
+  CONSTRAINT `fk_refresh_tokens_user` FOREIGN KEY (`user_id`) REFERENCES `users`(`id`)
+);
+
+CREATE TABLE `revoked_tokens` (
+  `id` bigint unsigned AUTO_INCREMENT,
+  `jti` varchar(36) NOT NULL,
+  `user_id` bigint unsigned NOT NULL,
+  `expires_at` datetime(3) NOT NULL,
+  `created_at` datetime(3) NULL,
+  PRIMARY KEY (`id`),
+  UNIQUE INDEX `idx_revoked_tokens_jti` (`jti`),
+  INDEX `idx_revoked_tokens_user_id` (`user_id`),
+  INDEX `idx_revoked_tokens_expires_at` (`expires_at`)
+);
+
+CREATE TABLE `devices` (
+  `id` bigint unsigned AUTO_INCREMENT,
+  `created_at` datetime(3) NULL,
+  `updated_at` datetime(3) NULL,
+  `deleted_at` datetime(3) NULL,
+  `restaurant_id` bigint unsigned NOT NULL,
+  `name` varchar(100) NOT NULL,
+  `credential_hash` varchar(64) NOT NULL,
+  `last_seen_at` datetime(3) NULL,
+  `revoked_at` datetime(3) NULL,
+  `failed_pin_attempts` bigint NOT NULL DEFAULT 0,
+  `locked_until` datetime(3) NULL,
+  PRIMARY KEY (`id`),
+  INDEX `idx_devices_deleted_at` (`deleted_at`),
+  INDEX `idx_devices_restaurant_id` (`restaurant_id`),
+  UNIQUE INDEX `idx_devices_credential_hash` (`credential_hash`),
+  CONSTRAINT `fk_devices_restaurant` FOREIGN KEY (`restaurant_id`) REFERENCES `restaurants`(`id`)
+);
+
+CREATE TABLE `roles` (
+  `id` bigint unsigned AUTO_INCREMENT,
+  `created_at` datetime(3) NULL,
+  `updated_at` datetime(3) NULL,
+  `deleted_at` datetime(3) NULL,
+  `restaurant_id` bigint unsigned NOT NULL,
+  `name` varchar(50) NOT NULL,
+  `description` varchar(255),
+  PRIMARY KEY (`id`),
+  INDEX `idx_roles_deleted_at` (`deleted_at`),
+  UNIQUE INDEX `idx_roles_restaurant_name` (`restaurant_id`,`name`),
+  CONSTRAINT `fk_roles_restaurant` FOREIGN KEY (`restaurant_id`) REFERENCES `restaurants`(`id`)
+);
+
+CREATE TABLE `role_permissions` (
+  `id` bigint unsigned AUTO_INCREMENT,
+  `role_id` bigint unsigned NOT NULL,
+  `permission` varchar(64) NOT NULL,
+  PRIMARY KEY (`id`),
+  UNIQUE INDEX `idx_role_permissions_role_permission` (`role_id`,`permission`),
+  CONSTRAINT `fk_roles_permissions` FOREIGN KEY (`role_id`) REFERENCES `roles`(`id`) ON DELETE CASCADE
+);
+
+CREATE TABLE `user_tokens` (
+  `id` bigint unsigned AUTO_INCREMENT,
+  `user_id` bigint unsigned NOT NULL,
+  `purpose` varchar(32) NOT NULL,
+  `token_hash` varchar(64) NOT NULL,
+  `expires_at` datetime(3) NOT NULL,
+  `used_at` datetime(3) NULL,
+  `created_at` datetime(3) NULL,
+  PRIMARY KEY (`id`),
+  INDEX `idx_user_tokens_user_id` (`user_id`),
+  INDEX `idx_user_tokens_purpose` (`purpose`),
+  UNIQUE INDEX `idx_user_tokens_token_hash` (`token_hash`),
+  CONSTRAINT `fk_user_tokens_user` FOREIGN KEY (`user_id`) REFERENCES `users`(`id`)
+);
+
+CREATE TABLE `login_attempts` (
+  `attempt_key` varchar(191),
+  `failures` bigint NOT NULL DEFAULT 0,
+  `last_failure_at` datetime(3) NULL,
+  `locked_until` datetime(3) NULL,
+  PRIMARY KEY (`attempt_key`)
+);
+
+CREATE TABLE `audit_logs` (
+  `id` bigint unsigned AUTO_INCREMENT,
+  `restaurant_id` bigint unsigned,
+  `user_id` bigint unsigned,
+  `actor_id` bigint unsigned,
+  `event` varchar(50) NOT NULL,
+  `email` varchar(255),
+  `ip_address` varchar(45),
+  `created_at` datetime(3) NULL,
+  PRIMARY KEY (`id`),
+  INDEX `idx_audit_logs_restaurant_id` (`restaurant_id`),
+  INDEX `idx_audit_logs_user_id` (`user_id`),
+  INDEX `idx_audit_logs_event` (`event`),
+  INDEX `idx_audit_logs_created_at` (`created_at`)
+);
+
+CREATE TABLE `recovery_codes` (
+  `id` bigint unsigned AUTO_INCREMENT,
+  `user_id` bigint unsigned NOT NULL,
+  `code_hash` varchar(64) NOT NULL,
+  `used_at` datetime(3) NULL,
+  `created_at` datetime(3) NULL,
+  PRIMARY KEY (`id`),
+  INDEX `idx_recovery_codes_user_id` (`user_id`),
+  UNIQUE INDEX `idx_recovery_codes_code_hash` (`code_hash`),
+  CONSTRAINT `fk_recovery_codes_user` FOREIGN KEY (`user_id`) REFERENCES `users`(`id`)
+);
+
+CREATE TABLE `signing_keys` (
+  `id` bigint unsigned AUTO_INCREMENT,
+  `kid` varchar(64) NOT NULL,
+  `algorithm` varchar(16) NOT NULL,
+  `private_key` text NOT NULL,
+  `activates_at` datetime(3) NOT NULL,
+  `retires_at` datetime(3) NULL,
+  `created_at` datetime(3) NULL,
+  PRIMARY KEY (`id`),
+  UNIQUE INDEX `idx_signing_keys_k_id` (`kid`),
+  INDEX `idx_signing_keys_activates_at` (`activates_at`),
+  INDEX `idx_signing_keys_retires_at` (`retires_at`)
+);
+
+CREATE TABLE `memberships` (
+  `id` bigint unsigned AUTO_INCREMENT,
+  `user_id` bigint unsigned NOT NULL,
+  `restaurant_id` bigint unsigned NOT NULL,
+  `role` varchar(50) NOT NULL DEFAULT 'staff',
+  `location_ids` varchar(1000),
+  `is_active` boolean NOT NULL DEFAULT true,
+  `created_at` datetime(3) NULL,
+  `updated_at` datetime(3) NULL,
+  PRIMARY KEY (`id`),
+  UNIQUE INDEX `idx_memberships_user_restaurant` (`user_id`,`restaurant_id`),
+  INDEX `idx_memberships_restaurant_id` (`restaurant_id`),
+  CONSTRAINT `fk_memberships_user` FOREIGN KEY (`user_id`) REFERENCES `users`(`id`),
+  CONSTRAINT `fk_memberships_restaurant` FOREIGN KEY (`restaurant_id`) REFERENCES `restaurants`(`id`)
+);
+
+CREATE TABLE `locations` (
+  `id` bigint unsigned AUTO_INCREMENT,
+  `created_at` datetime(3) NULL,
+  `updated_at` datetime(3) NULL,
+  `deleted_at` datetime(3) NULL,
+  `restaurant_id` bigint unsigned NOT NULL,
+  `square_location_id` varchar(64) NOT NULL,
+  `name` varchar(255) NOT NULL,
+  `address_line1` varchar(255),
+  `address_line2` varchar(255),
+  `locality` varchar(100),
+  `region` varchar(100),
+  `postal_code` varchar(20),
+  `country` varchar(2),
+  `timezone` varchar(64),
+  `currency` varchar(3),
+  `status` varchar(20) NOT NULL DEFAULT 'ACTIVE',
+  `synced_at` datetime(3) NULL,
+  PRIMARY KEY (`id`),
+  INDEX `idx_locations_deleted_at` (`deleted_at`),
+  UNIQUE INDEX `idx_locations_restaurant_square` (`restaurant_id`,`square_location_id`),
+  CONSTRAINT `fk_locations_restaurant` FOREIGN KEY (`restaurant_id`) REFERENCES `restaurants`(`id`)
+);
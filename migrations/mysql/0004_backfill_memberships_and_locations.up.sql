@@ -0,0 +1,12 @@
+-- Users created before memberships existed get one for their home restaurant
+INSERT INTO memberships (user_id, restaurant_id, role, location_ids, is_active, created_at, updated_at)
+SELECT u.id, u.restaurant_id, u.role, '', TRUE, NOW(), NOW() FROM users u
+WHERE u.deleted_at IS NULL AND NOT EXISTS (
+  SELECT 1 FROM memberships m WHERE m.user_id = u.id AND m.restaurant_id = u.restaurant_id);
+
+-- Restaurants registered before locations existed keep taking orders at their
+-- registered location until the first sync with Square
+INSERT INTO locations (restaurant_id, square_location_id, name, status, created_at, updated_at)
+SELECT r.id, r.location_id, r.name, 'ACTIVE', NOW(), NOW() FROM restaurants r
+WHERE r.deleted_at IS NULL AND r.location_id <> '' AND NOT EXISTS (
+  SELECT 1 FROM locations l WHERE l.restaurant_id = r.id AND l.square_location_id = r.location_id);
@@ -0,0 +1,2 @@
+ALTER TABLE `orders` MODIFY `status` ENUM('open', 'closed', 'cancelled', 'pending') DEFAULT 'open';
+ALTER TABLE `payments` MODIFY `status` ENUM('pending', 'paid', 'failed') DEFAULT 'pending';
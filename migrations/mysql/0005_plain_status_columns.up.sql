@@ -0,0 +1,4 @@
+-- Orders are marked paid and payments keep Square's status (COMPLETED, ...), neither of
+-- which the enums allow. Plain columns also match the other databases.
+ALTER TABLE `orders` MODIFY `status` varchar(100) DEFAULT 'open';
+ALTER TABLE `payments` MODIFY `status` varchar(100) DEFAULT 'pending';
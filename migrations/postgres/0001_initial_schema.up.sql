@@ -0,0 +1,169 @@
+-- The tables of mysql/0001_initial_schema.up.sql in this database's types
+
+CREATE TABLE IF NOT EXISTS "restaurants" (
+  "id" bigserial,
+  "created_at" timestamptz,
+  "updated_at" timestamptz,
+  "deleted_at" timestamptz,
+  "name" text NOT NULL,
+  "square_app_id" varchar(255),
+  "square_token" text NOT NULL,
+  "merchant_id" text NOT NULL,
+  "location_id" text NOT NULL,
+  PRIMARY KEY ("id")
+);
+CREATE INDEX IF NOT EXISTS "idx_restaurants_deleted_at" ON "restaurants" ("deleted_at");
+CREATE UNIQUE INDEX IF NOT EXISTS "idx_restaurants_square_app_id" ON "restaurants" ("square_app_id");
+
+CREATE TABLE IF NOT EXISTS "users" (
+  "id" bigserial,
+  "created_at" timestamptz,
+  "updated_at" timestamptz,
+  "deleted_at" timestamptz,
+  "username" varchar(100) NOT NULL,
+  "email" varchar(255) NOT NULL,
+  "password_hash" varchar(255) NOT NULL,
+  "restaurant_id" bigint NOT NULL,
+  "role" varchar(50) NOT NULL DEFAULT 'staff',
+  "is_active" boolean DEFAULT true,
+  PRIMARY KEY ("id"),
+  CONSTRAINT "fk_restaurants_users" FOREIGN KEY ("restaurant_id") REFERENCES "restaurants"("id") ON DELETE CASCADE ON UPDATE CASCADE
+);
+CREATE INDEX IF NOT EXISTS "idx_users_deleted_at" ON "users" ("deleted_at");
+CREATE INDEX IF NOT EXISTS "idx_users_restaurant_id" ON "users" ("restaurant_id");
+CREATE UNIQUE INDEX IF NOT EXISTS "idx_users_email" ON "users" ("email");
+CREATE UNIQUE INDEX IF NOT EXISTS "idx_users_username" ON "users" ("username");
+
+CREATE TABLE IF NOT EXISTS "tables" (
+  "id" bigserial,
+  "created_at" timestamptz,
+  "updated_at" timestamptz,
+  "deleted_at" timestamptz,
+  "restaurant_id" bigint NOT NULL,
+  "table_number" text,
+  "capacity" bigint,
+  "status" text,
+  PRIMARY KEY ("id"),
+  CONSTRAINT "fk_tables_restaurant" FOREIGN KEY ("restaurant_id") REFERENCES "restaurants"("id")
+);
+CREATE INDEX IF NOT EXISTS "idx_tables_deleted_at" ON "tables" ("deleted_at");
+CREATE INDEX IF NOT EXISTS "idx_tables_restaurant_id" ON "tables" ("restaurant_id");
+
+CREATE TABLE IF NOT EXISTS "orders" (
+  "id" bigserial,
+  "created_at" timestamptz,
+  "updated_at" timestamptz,
+  "deleted_at" timestamptz,
+  "restaurant_id" bigint NOT NULL,
+  "square_order_id" varchar(255),
+  "table_id" bigint,
+  "payment_id" varchar(36),
+  "table_number" bigint,
+  "opened_at" timestamptz NOT NULL,
+  "is_closed" boolean DEFAULT false,
+  "status" varchar(100) DEFAULT 'open',
+  "user_id" bigint NOT NULL,
+  "total_amount" bigint NOT NULL DEFAULT 0,
+  "currency" varchar(3) NOT NULL DEFAULT 'USD',
+  "location_id" varchar(255) NOT NULL,
+  "raw_square_data" JSONB,
+  "payed_amount" bigint DEFAULT 0,
+  "tip_amount" bigint DEFAULT 0,
+  "discounts" bigint DEFAULT 0,
+  "due" bigint DEFAULT 0,
+  "tax" bigint DEFAULT 0,
+  "service_charge" bigint DEFAULT 0,
+  "paid" bigint DEFAULT 0,
+  "tips" bigint DEFAULT 0,
+  "total" bigint DEFAULT 0,
+  PRIMARY KEY ("id"),
+  CONSTRAINT "fk_tables_orders" FOREIGN KEY ("table_id") REFERENCES "tables"("id") ON DELETE SET NULL,
+  CONSTRAINT "fk_orders_restaurant" FOREIGN KEY ("restaurant_id") REFERENCES "restaurants"("id")
+);
+CREATE INDEX IF NOT EXISTS "idx_orders_deleted_at" ON "orders" ("deleted_at");
+CREATE INDEX IF NOT EXISTS "idx_orders_is_closed" ON "orders" ("is_closed");
+CREATE INDEX IF NOT EXISTS "idx_orders_payment_id" ON "orders" ("payment_id");
+CREATE INDEX IF NOT EXISTS "idx_orders_restaurant_id" ON "orders" ("restaurant_id");
+CREATE INDEX IF NOT EXISTS "idx_orders_square_order_id" ON "orders" ("square_order_id");
+CREATE INDEX IF NOT EXISTS "idx_orders_table_id" ON "orders" ("table_id");
+CREATE INDEX IF NOT EXISTS "idx_orders_user_id" ON "orders" ("user_id");
+
+CREATE TABLE IF NOT EXISTS "order_items" (
+  "id" bigserial,
+  "created_at" timestamptz,
+  "updated_at" timestamptz,
+  "deleted_at" timestamptz,
+  "order_id" bigint NOT NULL,
+  "name" varchar(255) NOT NULL,
+  "comment" varchar(500),
+  "unit_price" bigint NOT NULL,
+  "quantity" bigint NOT NULL,
+  "amount" bigint NOT NULL,
+  "square_item_id" varchar(255),
+  "square_uid" varchar(255),
+  PRIMARY KEY ("id"),
+  CONSTRAINT "fk_orders_items" FOREIGN KEY ("order_id") REFERENCES "orders"("id") ON DELETE CASCADE
+);
+CREATE INDEX IF NOT EXISTS "idx_order_items_deleted_at" ON "order_items" ("deleted_at");
+CREATE INDEX IF NOT EXISTS "idx_order_items_order_id" ON "order_items" ("order_id");
+
+CREATE TABLE IF NOT EXISTS "order_item_discounts" (
+  "id" bigserial,
+  "created_at" timestamptz,
+  "updated_at" timestamptz,
+  "deleted_at" timestamptz,
+  "order_item_id" bigint NOT NULL,
+  "name" varchar(255) NOT NULL,
+  "is_percentage" boolean DEFAULT false,
+  "value" bigint NOT NULL,
+  "amount" bigint NOT NULL,
+  "square_discount_uid" varchar(255),
+  PRIMARY KEY ("id"),
+  CONSTRAINT "fk_order_items_discounts" FOREIGN KEY ("order_item_id") REFERENCES "order_items"("id") ON DELETE CASCADE ON UPDATE CASCADE
+);
+CREATE INDEX IF NOT EXISTS "idx_order_item_discounts_deleted_at" ON "order_item_discounts" ("deleted_at");
+CREATE INDEX IF NOT EXISTS "idx_order_item_discounts_order_item_id" ON "order_item_discounts" ("order_item_id");
+
+CREATE TABLE IF NOT EXISTS "order_item_modifiers" (
+  "id" bigserial,
+  "created_at" timestamptz,
+  "updated_at" timestamptz,
+  "deleted_at" timestamptz,
+  "order_item_id" bigint NOT NULL,
+  "name" varchar(255) NOT NULL,
+  "unit_price" bigint NOT NULL,
+  "quantity" bigint NOT NULL,
+  "amount" bigint NOT NULL,
+  "square_modifier_uid" varchar(255),
+  PRIMARY KEY ("id"),
+  CONSTRAINT "fk_order_items_modifiers" FOREIGN KEY ("order_item_id") REFERENCES "order_items"("id") ON DELETE CASCADE ON UPDATE CASCADE
+);
+CREATE INDEX IF NOT EXISTS "idx_order_item_modifiers_deleted_at" ON "order_item_modifiers" ("deleted_at");
+CREATE INDEX IF NOT EXISTS "idx_order_item_modifiers_order_item_id" ON "order_item_modifiers" ("order_item_id");
+
+CREATE TABLE IF NOT EXISTS "payments" (
+  "id" bigserial,
+  "created_at" timestamptz,
+  "updated_at" timestamptz,
+  "deleted_at" timestamptz,
+  "order_id" bigint NOT NULL,
+  "restaurant_id" bigint NOT NULL,
+  "bill_amount" bigint NOT NULL,
+  "tip_amount" bigint DEFAULT 0,
+  "total_amount" bigint NOT NULL,
+  "status" varchar(100) DEFAULT 'pending',
+  "payment_method" varchar(50),
+  "processed_at" timestamptz,
+  "raw_square_data" JSONB,
+  "square_payment_id" varchar(255),
+  "square_location_id" varchar(255),
+  "currency" varchar(10) DEFAULT 'USD',
+  "transaction_fee" bigint DEFAULT 0,
+  "net_amount" bigint DEFAULT 0,
+  PRIMARY KEY ("id"),
+  CONSTRAINT "fk_payments_restaurant" FOREIGN KEY ("restaurant_id") REFERENCES "restaurants"("id"),
+  CONSTRAINT "fk_orders_payments" FOREIGN KEY ("order_id") REFERENCES "orders"("id") ON DELETE CASCADE
+);
+CREATE INDEX IF NOT EXISTS "idx_payments_deleted_at" ON "payments" ("deleted_at");
+CREATE INDEX IF NOT EXISTS "idx_payments_order_id" ON "payments" ("order_id");
+CREATE INDEX IF NOT EXISTS "idx_payments_restaurant_id" ON "payments" ("restaurant_id");
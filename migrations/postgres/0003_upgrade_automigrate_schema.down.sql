@@ -0,0 +1,27 @@
+DROP TABLE IF EXISTS "locations";
+DROP TABLE IF EXISTS "memberships";
+DROP TABLE IF EXISTS "signing_keys";
+DROP TABLE IF EXISTS "recovery_codes";
+DROP TABLE IF EXISTS "audit_logs";
+DROP TABLE IF EXISTS "login_attempts";
+DROP TABLE IF EXISTS "user_tokens";
+DROP TABLE IF EXISTS "role_permissions";
+DROP TABLE IF EXISTS "roles";
+DROP TABLE IF EXISTS "devices";
+DROP TABLE IF EXISTS "revoked_tokens";
+DROP TABLE IF EXISTS "refresh_tokens";
+DROP TABLE IF EXISTS "service_charge_rules";
+DROP TABLE IF EXISTS "tax_rules";
+DROP INDEX IF EXISTS "idx_users_restaurant_pin";
+ALTER TABLE "payments" DROP COLUMN "refunded_amount";
+ALTER TABLE "users" DROP COLUMN "totp_last_step";
+ALTER TABLE "users" DROP COLUMN "totp_enabled_at";
+ALTER TABLE "users" DROP COLUMN "totp_secret";
+ALTER TABLE "users" DROP COLUMN "pin_locked_until";
+ALTER TABLE "users" DROP COLUMN "failed_pin_attempts";
+ALTER TABLE "users" DROP COLUMN "pin_hash";
+ALTER TABLE "users" DROP COLUMN "email_verified_at";
+ALTER TABLE "users" DROP COLUMN "email_verification_pending";
+ALTER TABLE "users" DROP COLUMN "token_version";
+ALTER TABLE "restaurants" DROP COLUMN "two_factor_roles";
+ALTER TABLE "restaurants" DROP COLUMN "discount_limit_percent";
@@ -0,0 +1,239 @@
+-- The changes of mysql/0003_upgrade_automigrate_schema.up.sql in this database's types
+
+ALTER TABLE "restaurants" ADD COLUMN "discount_limit_percent" bigint NOT NULL DEFAULT 20;
+ALTER TABLE "restaurants" ADD COLUMN "two_factor_roles" varchar(255);
+
+ALTER TABLE "users" ADD COLUMN "token_version" bigint NOT NULL DEFAULT 0;
+ALTER TABLE "users" ADD COLUMN "email_verification_pending" boolean NOT NULL DEFAULT false;
+ALTER TABLE "users" ADD COLUMN "email_verified_at" timestamptz;
+ALTER TABLE "users" ADD COLUMN "pin_hash" varchar(64);
+ALTER TABLE "users" ADD COLUMN "failed_pin_attempts" bigint NOT NULL DEFAULT 0;
+ALTER TABLE "users" ADD COLUMN "pin_locked_until" timestamptz;
+ALTER TABLE "users" ADD COLUMN "totp_secret" varchar(255);
+ALTER TABLE "users" ADD COLUMN "totp_enabled_at" timestamptz;
+ALTER TABLE "users" ADD COLUMN "totp_last_step" bigint NOT NULL DEFAULT 0;
+CREATE UNIQUE INDEX "idx_users_restaurant_pin" ON "users" ("restaurant_id","pin_hash");
+
+ALTER TABLE "payments" ADD COLUMN "refunded_amount" bigint DEFAULT 0;
+CREATE TABLE "tax_rules" (
+  "id" bigserial,
+  "created_at" timestamptz,
+  "updated_at" timestamptz,
+  "deleted_at" timestamptz,
+  "restaurant_id" bigint NOT NULL,
+  "location_id" varchar(255),
+  "name" varchar(255) NOT NULL,
+  "percentage" varchar(20) NOT NULL,
+  "inclusion_type" varchar(20) NOT NULL DEFAULT 'ADDITIVE',
+  "enabled" boolean,
+  "square_catalog_object_id" varchar(255),
+  "square_catalog_version" bigint DEFAULT 0,
+  PRIMARY KEY ("id"),
+  CONSTRAINT "fk_tax_rules_restaurant" FOREIGN KEY ("restaurant_id") REFERENCES "restaurants"("id")
+);
+CREATE INDEX "idx_tax_rules_deleted_at" ON "tax_rules" ("deleted_at");
+CREATE INDEX "idx_tax_rules_enabled" ON "tax_rules" ("enabled");
+CREATE INDEX "idx_tax_rules_location_id" ON "tax_rules" ("location_id");
+CREATE INDEX "idx_tax_rules_restaurant_id" ON "tax_rules" ("restaurant_id");
+CREATE INDEX "idx_tax_rules_square_catalog_object_id" ON "tax_rules" ("square_catalog_object_id");
+
+CREATE TABLE "service_charge_rules" (
+  "id" bigserial,
+  "created_at" timestamptz,
+  "updated_at" timestamptz,
+  "deleted_at" timestamptz,
+  "restaurant_id" bigint NOT NULL,
+  "location_id" varchar(255),
+  "name" varchar(255) NOT NULL,
+  "percentage" varchar(20),
+  "amount" bigint DEFAULT 0,
+  "calculation_phase" varchar(50) NOT NULL DEFAULT 'SUBTOTAL_PHASE',
+  "taxable" boolean DEFAULT false,
+  "enabled" boolean,
+  "min_guest_count" bigint DEFAULT 0,
+  "order_type" varchar(50),
+  PRIMARY KEY ("id"),
+  CONSTRAINT "fk_service_charge_rules_restaurant" FOREIGN KEY ("restaurant_id") REFERENCES "restaurants"("id")
+);
+CREATE INDEX "idx_service_charge_rules_deleted_at" ON "service_charge_rules" ("deleted_at");
+CREATE INDEX "idx_service_charge_rules_enabled" ON "service_charge_rules" ("enabled");
+CREATE INDEX "idx_service_charge_rules_location_id" ON "service_charge_rules" ("location_id");
+CREATE INDEX "idx_service_charge_rules_restaurant_id" ON "service_charge_rules" ("restaurant_id");
+
+CREATE TABLE "refresh_tokens" (
+  "id" bigserial,
+  "created_at" timestamptz,
+  "updated_at" timestamptz,
+  "deleted_at" timestamptz,
+  "user_id" bigint NOT NULL,
+  "restaurant_id" bigint NOT NULL DEFAULT 0,
+  "token_hash" varchar(64) NOT NULL,
+  "family_id" varchar(36) NOT NULL,
+  "expires_at" timestamptz NOT NULL,
+  "revoked_at" timestamptz,
+  PRIMARY KEY ("id"),
+  CONSTRAINT "fk_refresh_tokens_user" FOREIGN KEY ("user_id") REFERENCES "users"("id")
+);
+CREATE INDEX "idx_refresh_tokens_deleted_at" ON "refresh_tokens" ("deleted_at");
+CREATE INDEX "idx_refresh_tokens_family_id" ON "refresh_tokens" ("family_id");
+CREATE INDEX "idx_refresh_tokens_user_id" ON "refresh_tokens" ("user_id");
+CREATE UNIQUE INDEX "idx_refresh_tokens_token_hash" ON "refresh_tokens" ("token_hash");
+
+CREATE TABLE "revoked_tokens" (
+  "id" bigserial,
+  "jti" varchar(36) NOT NULL,
+  "user_id" bigint NOT NULL,
+  "expires_at" timestamptz NOT NULL,
+  "created_at" timestamptz,
+  PRIMARY KEY ("id")
+);
+CREATE INDEX "idx_revoked_tokens_expires_at" ON "revoked_tokens" ("expires_at");
+CREATE INDEX "idx_revoked_tokens_user_id" ON "revoked_tokens" ("user_id");
+CREATE UNIQUE INDEX "idx_revoked_tokens_jti" ON "revoked_tokens" ("jti");
+
+CREATE TABLE "devices" (
+  "id" bigserial,
+  "created_at" timestamptz,
+  "updated_at" timestamptz,
+  "deleted_at" timestamptz,
+  "restaurant_id" bigint NOT NULL,
+  "name" varchar(100) NOT NULL,
+  "credential_hash" varchar(64) NOT NULL,
+  "last_seen_at" timestamptz,
+  "revoked_at" timestamptz,
+  "failed_pin_attempts" bigint NOT NULL DEFAULT 0,
+  "locked_until" timestamptz,
+  PRIMARY KEY ("id"),
+  CONSTRAINT "fk_devices_restaurant" FOREIGN KEY ("restaurant_id") REFERENCES "restaurants"("id")
+);
+CREATE INDEX "idx_devices_deleted_at" ON "devices" ("deleted_at");
+CREATE INDEX "idx_devices_restaurant_id" ON "devices" ("restaurant_id");
+CREATE UNIQUE INDEX "idx_devices_credential_hash" ON "devices" ("credential_hash");
+
+CREATE TABLE "roles" (
+  "id" bigserial,
+  "created_at" timestamptz,
+  "updated_at" timestamptz,
+  "deleted_at" timestamptz,
+  "restaurant_id" bigint NOT NULL,
+  "name" varchar(50) NOT NULL,
+  "description" varchar(255),
+  PRIMARY KEY ("id"),
+  CONSTRAINT "fk_roles_restaurant" FOREIGN KEY ("restaurant_id") REFERENCES "restaurants"("id")
+);
+CREATE INDEX "idx_roles_deleted_at" ON "roles" ("deleted_at");
+CREATE UNIQUE INDEX "idx_roles_restaurant_name" ON "roles" ("restaurant_id","name");
+
+CREATE TABLE "role_permissions" (
+  "id" bigserial,
+  "role_id" bigint NOT NULL,
+  "permission" varchar(64) NOT NULL,
+  PRIMARY KEY ("id"),
+  CONSTRAINT "fk_roles_permissions" FOREIGN KEY ("role_id") REFERENCES "roles"("id") ON DELETE CASCADE
+);
+CREATE UNIQUE INDEX "idx_role_permissions_role_permission" ON "role_permissions" ("role_id","permission");
+
+CREATE TABLE "user_tokens" (
+  "id" bigserial,
+  "user_id" bigint NOT NULL,
+  "purpose" varchar(32) NOT NULL,
+  "token_hash" varchar(64) NOT NULL,
+  "expires_at" timestamptz NOT NULL,
+  "used_at" timestamptz,
+  "created_at" timestamptz,
+  PRIMARY KEY ("id"),
+  CONSTRAINT "fk_user_tokens_user" FOREIGN KEY ("user_id") REFERENCES "users"("id")
+);
+CREATE INDEX "idx_user_tokens_purpose" ON "user_tokens" ("purpose");
+CREATE INDEX "idx_user_tokens_user_id" ON "user_tokens" ("user_id");
+CREATE UNIQUE INDEX "idx_user_tokens_token_hash" ON "user_tokens" ("token_hash");
+
+CREATE TABLE "login_attempts" (
+  "attempt_key" varchar(191),
+  "failures" bigint NOT NULL DEFAULT 0,
+  "last_failure_at" timestamptz,
+  "locked_until" timestamptz,
+  PRIMARY KEY ("attempt_key")
+);
+
+CREATE TABLE "audit_logs" (
+  "id" bigserial,
+  "restaurant_id" bigint,
+  "user_id" bigint,
+  "actor_id" bigint,
+  "event" varchar(50) NOT NULL,
+  "email" varchar(255),
+  "ip_address" varchar(45),
+  "created_at" timestamptz,
+  PRIMARY KEY ("id")
+);
+CREATE INDEX "idx_audit_logs_created_at" ON "audit_logs" ("created_at");
+CREATE INDEX "idx_audit_logs_event" ON "audit_logs" ("event");
+CREATE INDEX "idx_audit_logs_restaurant_id" ON "audit_logs" ("restaurant_id");
+CREATE INDEX "idx_audit_logs_user_id" ON "audit_logs" ("user_id");
+
+CREATE TABLE "recovery_codes" (
+  "id" bigserial,
+  "user_id" bigint NOT NULL,
+  "code_hash" varchar(64) NOT NULL,
+  "used_at" timestamptz,
+  "created_at" timestamptz,
+  PRIMARY KEY ("id"),
+  CONSTRAINT "fk_recovery_codes_user" FOREIGN KEY ("user_id") REFERENCES "users"("id")
+);
+CREATE INDEX "idx_recovery_codes_user_id" ON "recovery_codes" ("user_id");
+CREATE UNIQUE INDEX "idx_recovery_codes_code_hash" ON "recovery_codes" ("code_hash");
+
+CREATE TABLE "signing_keys" (
+  "id" bigserial,
+  "kid" varchar(64) NOT NULL,
+  "algorithm" varchar(16) NOT NULL,
+  "private_key" text NOT NULL,
+  "activates_at" timestamptz NOT NULL,
+  "retires_at" timestamptz,
+  "created_at" timestamptz,
+  PRIMARY KEY ("id")
+);
+CREATE INDEX "idx_signing_keys_activates_at" ON "signing_keys" ("activates_at");
+CREATE INDEX "idx_signing_keys_retires_at" ON "signing_keys" ("retires_at");
+CREATE UNIQUE INDEX "idx_signing_keys_k_id" ON "signing_keys" ("kid");
+
+CREATE TABLE "memberships" (
+  "id" bigserial,
+  "user_id" bigint NOT NULL,
+  "restaurant_id" bigint NOT NULL,
+  "role" varchar(50) NOT NULL DEFAULT 'staff',
+  "location_ids" varchar(1000),
+  "is_active" boolean NOT NULL DEFAULT true,
+  "created_at" timestamptz,
+  "updated_at" timestamptz,
+  PRIMARY KEY ("id"),
+  CONSTRAINT "fk_memberships_user" FOREIGN KEY ("user_id") REFERENCES "users"("id"),
+  CONSTRAINT "fk_memberships_restaurant" FOREIGN KEY ("restaurant_id") REFERENCES "restaurants"("id")
+);
+CREATE INDEX "idx_memberships_restaurant_id" ON "memberships" ("restaurant_id");
+CREATE UNIQUE INDEX "idx_memberships_user_restaurant" ON "memberships" ("user_id","restaurant_id");
+
+CREATE TABLE "locations" (
+  "id" bigserial,
+  "created_at" timestamptz,
+  "updated_at" timestamptz,
+  "deleted_at" timestamptz,
+  "restaurant_id" bigint NOT NULL,
+  "square_location_id" varchar(64) NOT NULL,
+  "name" varchar(255) NOT NULL,
+  "address_line1" varchar(255),
+  "address_line2" varchar(255),
+  "locality" varchar(100),
+  "region" varchar(100),
+  "postal_code" varchar(20),
+  "country" varchar(2),
+  "timezone" varchar(64),
+  "currency" varchar(3),
+  "status" varchar(20) NOT NULL DEFAULT 'ACTIVE',
+  "synced_at" timestamptz,
+  PRIMARY KEY ("id"),
+  CONSTRAINT "fk_locations_restaurant" FOREIGN KEY ("restaurant_id") REFERENCES "restaurants"("id")
+);
+CREATE INDEX "idx_locations_deleted_at" ON "locations" ("deleted_at");
+CREATE UNIQUE INDEX "idx_locations_restaurant_square" ON "locations" ("restaurant_id","square_location_id");
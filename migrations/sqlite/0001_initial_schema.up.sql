@@ -0,0 +1,161 @@
+-- The tables of mysql/0001_initial_schema.up.sql in this database's types
+
+CREATE TABLE IF NOT EXISTS `restaurants` (
+  `id` integer PRIMARY KEY AUTOINCREMENT,
+  `created_at` datetime,
+  `updated_at` datetime,
+  `deleted_at` datetime,
+  `name` text NOT NULL,
+  `square_app_id` varchar(255),
+  `square_token` text NOT NULL,
+  `merchant_id` text NOT NULL,
+  `location_id` text NOT NULL
+);
+CREATE INDEX IF NOT EXISTS `idx_restaurants_deleted_at` ON `restaurants` (`deleted_at`);
+CREATE UNIQUE INDEX IF NOT EXISTS `idx_restaurants_square_app_id` ON `restaurants` (`square_app_id`);
+
+CREATE TABLE IF NOT EXISTS `users` (
+  `id` integer PRIMARY KEY AUTOINCREMENT,
+  `created_at` datetime,
+  `updated_at` datetime,
+  `deleted_at` datetime,
+  `username` text NOT NULL,
+  `email` text NOT NULL,
+  `password_hash` text NOT NULL,
+  `restaurant_id` integer NOT NULL,
+  `role` text NOT NULL DEFAULT 'staff',
+  `is_active` numeric DEFAULT true,
+  CONSTRAINT `fk_restaurants_users` FOREIGN KEY (`restaurant_id`) REFERENCES `restaurants`(`id`) ON DELETE CASCADE ON UPDATE CASCADE
+);
+CREATE INDEX IF NOT EXISTS `idx_users_deleted_at` ON `users` (`deleted_at`);
+CREATE INDEX IF NOT EXISTS `idx_users_restaurant_id` ON `users` (`restaurant_id`);
+CREATE UNIQUE INDEX IF NOT EXISTS `idx_users_email` ON `users` (`email`);
+CREATE UNIQUE INDEX IF NOT EXISTS `idx_users_username` ON `users` (`username`);
+
+CREATE TABLE IF NOT EXISTS `tables` (
+  `id` integer PRIMARY KEY AUTOINCREMENT,
+  `created_at` datetime,
+  `updated_at` datetime,
+  `deleted_at` datetime,
+  `restaurant_id` integer NOT NULL,
+  `table_number` text,
+  `capacity` integer,
+  `status` text,
+  CONSTRAINT `fk_tables_restaurant` FOREIGN KEY (`restaurant_id`) REFERENCES `restaurants`(`id`)
+);
+CREATE INDEX IF NOT EXISTS `idx_tables_deleted_at` ON `tables` (`deleted_at`);
+CREATE INDEX IF NOT EXISTS `idx_tables_restaurant_id` ON `tables` (`restaurant_id`);
+
+CREATE TABLE IF NOT EXISTS `orders` (
+  `id` integer PRIMARY KEY AUTOINCREMENT,
+  `created_at` datetime,
+  `updated_at` datetime,
+  `deleted_at` datetime,
+  `restaurant_id` integer NOT NULL,
+  `square_order_id` text,
+  `table_id` integer,
+  `payment_id` text,
+  `table_number` integer,
+  `opened_at` datetime NOT NULL,
+  `is_closed` numeric DEFAULT false,
+  `status` text DEFAULT 'open',
+  `user_id` integer NOT NULL,
+  `total_amount` integer NOT NULL DEFAULT 0,
+  `currency` text NOT NULL DEFAULT 'USD',
+  `location_id` text NOT NULL,
+  `raw_square_data` JSON,
+  `payed_amount` integer DEFAULT 0,
+  `tip_amount` integer DEFAULT 0,
+  `discounts` integer DEFAULT 0,
+  `due` integer DEFAULT 0,
+  `tax` integer DEFAULT 0,
+  `service_charge` integer DEFAULT 0,
+  `paid` integer DEFAULT 0,
+  `tips` integer DEFAULT 0,
+  `total` integer DEFAULT 0,
+  CONSTRAINT `fk_orders_restaurant` FOREIGN KEY (`restaurant_id`) REFERENCES `restaurants`(`id`),
+  CONSTRAINT `fk_tables_orders` FOREIGN KEY (`table_id`) REFERENCES `tables`(`id`) ON DELETE SET NULL
+);
+CREATE INDEX IF NOT EXISTS `idx_orders_deleted_at` ON `orders` (`deleted_at`);
+CREATE INDEX IF NOT EXISTS `idx_orders_is_closed` ON `orders` (`is_closed`);
+CREATE INDEX IF NOT EXISTS `idx_orders_payment_id` ON `orders` (`payment_id`);
+CREATE INDEX IF NOT EXISTS `idx_orders_restaurant_id` ON `orders` (`restaurant_id`);
+CREATE INDEX IF NOT EXISTS `idx_orders_square_order_id` ON `orders` (`square_order_id`);
+CREATE INDEX IF NOT EXISTS `idx_orders_table_id` ON `orders` (`table_id`);
+CREATE INDEX IF NOT EXISTS `idx_orders_user_id` ON `orders` (`user_id`);
+
+CREATE TABLE IF NOT EXISTS `order_items` (
+  `id` integer PRIMARY KEY AUTOINCREMENT,
+  `created_at` datetime,
+  `updated_at` datetime,
+  `deleted_at` datetime,
+  `order_id` integer NOT NULL,
+  `name` text NOT NULL,
+  `comment` text,
+  `unit_price` integer NOT NULL,
+  `quantity` integer NOT NULL,
+  `amount` integer NOT NULL,
+  `square_item_id` text,
+  `square_uid` text,
+  CONSTRAINT `fk_orders_items` FOREIGN KEY (`order_id`) REFERENCES `orders`(`id`) ON DELETE CASCADE
+);
+CREATE INDEX IF NOT EXISTS `idx_order_items_deleted_at` ON `order_items` (`deleted_at`);
+CREATE INDEX IF NOT EXISTS `idx_order_items_order_id` ON `order_items` (`order_id`);
+
+CREATE TABLE IF NOT EXISTS `order_item_discounts` (
+  `id` integer PRIMARY KEY AUTOINCREMENT,
+  `created_at` datetime,
+  `updated_at` datetime,
+  `deleted_at` datetime,
+  `order_item_id` integer NOT NULL,
+  `name` text NOT NULL,
+  `is_percentage` numeric DEFAULT false,
+  `value` integer NOT NULL,
+  `amount` integer NOT NULL,
+  `square_discount_uid` text,
+  CONSTRAINT `fk_order_items_discounts` FOREIGN KEY (`order_item_id`) REFERENCES `order_items`(`id`) ON DELETE CASCADE ON UPDATE CASCADE
+);
+CREATE INDEX IF NOT EXISTS `idx_order_item_discounts_deleted_at` ON `order_item_discounts` (`deleted_at`);
+CREATE INDEX IF NOT EXISTS `idx_order_item_discounts_order_item_id` ON `order_item_discounts` (`order_item_id`);
+
+CREATE TABLE IF NOT EXISTS `order_item_modifiers` (
+  `id` integer PRIMARY KEY AUTOINCREMENT,
+  `created_at` datetime,
+  `updated_at` datetime,
+  `deleted_at` datetime,
+  `order_item_id` integer NOT NULL,
+  `name` text NOT NULL,
+  `unit_price` integer NOT NULL,
+  `quantity` integer NOT NULL,
+  `amount` integer NOT NULL,
+  `square_modifier_uid` text,
+  CONSTRAINT `fk_order_items_modifiers` FOREIGN KEY (`order_item_id`) REFERENCES `order_items`(`id`) ON DELETE CASCADE ON UPDATE CASCADE
+);
+CREATE INDEX IF NOT EXISTS `idx_order_item_modifiers_deleted_at` ON `order_item_modifiers` (`deleted_at`);
+CREATE INDEX IF NOT EXISTS `idx_order_item_modifiers_order_item_id` ON `order_item_modifiers` (`order_item_id`);
+
+CREATE TABLE IF NOT EXISTS `payments` (
+  `id` integer PRIMARY KEY AUTOINCREMENT,
+  `created_at` datetime,
+  `updated_at` datetime,
+  `deleted_at` datetime,
+  `order_id` integer NOT NULL,
+  `restaurant_id` integer NOT NULL,
+  `bill_amount` integer NOT NULL,
+  `tip_amount` integer DEFAULT 0,
+  `total_amount` integer NOT NULL,
+  `status` text DEFAULT 'pending',
+  `payment_method` text,
+  `processed_at` datetime,
+  `raw_square_data` JSON,
+  `square_payment_id` text,
+  `square_location_id` text,
+  `currency` text DEFAULT 'USD',
+  `transaction_fee` integer DEFAULT 0,
+  `net_amount` integer DEFAULT 0,
+  CONSTRAINT `fk_payments_restaurant` FOREIGN KEY (`restaurant_id`) REFERENCES `restaurants`(`id`),
+  CONSTRAINT `fk_orders_payments` FOREIGN KEY (`order_id`) REFERENCES `orders`(`id`) ON DELETE CASCADE
+);
+CREATE INDEX IF NOT EXISTS `idx_payments_deleted_at` ON `payments` (`deleted_at`);
+CREATE INDEX IF NOT EXISTS `idx_payments_order_id` ON `payments` (`order_id`);
+CREATE INDEX IF NOT EXISTS `idx_payments_restaurant_id` ON `payments` (`restaurant_id`);
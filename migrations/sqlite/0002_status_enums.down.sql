@@ -0,0 +1 @@
+-- Nothing to revert, see 0002_status_enums.up.sql
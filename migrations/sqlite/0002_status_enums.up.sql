@@ -0,0 +1 @@
+-- Status enums were only ever created on MySQL, see 0005_plain_status_columns
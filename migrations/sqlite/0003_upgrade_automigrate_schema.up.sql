@@ -0,0 +1,226 @@
+-- The changes of mysql/0003_upgrade_automigrate_schema.up.sql in this database's types
+
+ALTER TABLE `restaurants` ADD COLUMN `discount_limit_percent` integer NOT NULL DEFAULT 20;
+ALTER TABLE `restaurants` ADD COLUMN `two_factor_roles` text;
+
+ALTER TABLE `users` ADD COLUMN `token_version` integer NOT NULL DEFAULT 0;
+ALTER TABLE `users` ADD COLUMN `email_verification_pending` numeric NOT NULL DEFAULT false;
+ALTER TABLE `users` ADD COLUMN `email_verified_at` datetime;
+ALTER TABLE `users` ADD COLUMN `pin_hash` text;
+ALTER TABLE `users` ADD COLUMN `failed_pin_attempts` integer NOT NULL DEFAULT 0;
+ALTER TABLE `users` ADD COLUMN `pin_locked_until` datetime;
+ALTER TABLE `users` ADD COLUMN `totp_secret` text;
+ALTER TABLE `users` ADD COLUMN `totp_enabled_at` datetime;
+ALTER TABLE `users` ADD COLUMN `totp_last_step` integer NOT NULL DEFAULT 0;
+CREATE UNIQUE INDEX `idx_users_restaurant_pin` ON `users` (`restaurant_id`,`pin_hash`);
+
+ALTER TABLE `payments` ADD COLUMN `refunded_amount` integer DEFAULT 0;
+CREATE TABLE `tax_rules` (
+  `id` integer PRIMARY KEY AUTOINCREMENT,
+  `created_at` datetime,
+  `updated_at` datetime,
+  `deleted_at` datetime,
+  `restaurant_id` integer NOT NULL,
+  `location_id` text,
+  `name` text NOT NULL,
+  `percentage` text NOT NULL,
+  `inclusion_type` text NOT NULL DEFAULT 'ADDITIVE',
+  `enabled` numeric,
+  `square_catalog_object_id` text,
+  `square_catalog_version` integer DEFAULT 0,
+  CONSTRAINT `fk_tax_rules_restaurant` FOREIGN KEY (`restaurant_id`) REFERENCES `restaurants`(`id`)
+);
+CREATE INDEX `idx_tax_rules_deleted_at` ON `tax_rules` (`deleted_at`);
+CREATE INDEX `idx_tax_rules_enabled` ON `tax_rules` (`enabled`);
+CREATE INDEX `idx_tax_rules_location_id` ON `tax_rules` (`location_id`);
+CREATE INDEX `idx_tax_rules_restaurant_id` ON `tax_rules` (`restaurant_id`);
+CREATE INDEX `idx_tax_rules_square_catalog_object_id` ON `tax_rules` (`square_catalog_object_id`);
+
+CREATE TABLE `service_charge_rules` (
+  `id` integer PRIMARY KEY AUTOINCREMENT,
+  `created_at` datetime,
+  `updated_at` datetime,
+  `deleted_at` datetime,
+  `restaurant_id` integer NOT NULL,
+  `location_id` text,
+  `name` text NOT NULL,
+  `percentage` text,
+  `amount` integer DEFAULT 0,
+  `calculation_phase` text NOT NULL DEFAULT 'SUBTOTAL_PHASE',
+  `taxable` numeric DEFAULT false,
+  `enabled` numeric,
+  `min_guest_count` integer DEFAULT 0,
+  `order_type` text,
+  CONSTRAINT `fk_service_charge_rules_restaurant` FOREIGN KEY (`restaurant_id`) REFERENCES `restaurants`(`id`)
+);
+CREATE INDEX `idx_service_charge_rules_deleted_at` ON `service_charge_rules` (`deleted_at`);
+CREATE INDEX `idx_service_charge_rules_enabled` ON `service_charge_rules` (`enabled`);
+CREATE INDEX `idx_service_charge_rules_location_id` ON `service_charge_rules` (`location_id`);
+CREATE INDEX `idx_service_charge_rules_restaurant_id` ON `service_charge_rules` (`restaurant_id`);
+
+CREATE TABLE `refresh_tokens` (
+  `id` integer PRIMARY KEY AUTOINCREMENT,
+  `created_at` datetime,
+  `updated_at` datetime,
+  `deleted_at` datetime,
+  `user_id` integer NOT NULL,
+  `restaurant_id` integer NOT NULL DEFAULT 0,
+  `token_hash` text NOT NULL,
+  `family_id` text NOT NULL,
+  `expires_at` datetime NOT NULL,
+  `revoked_at` datetime,
+  CONSTRAINT `fk_refresh_tokens_user` FOREIGN KEY (`user_id`) REFERENCES `users`(`id`)
+);
+CREATE INDEX `idx_refresh_tokens_deleted_at` ON `refresh_tokens` (`deleted_at`);
+CREATE INDEX `idx_refresh_tokens_family_id` ON `refresh_tokens` (`family_id`);
+CREATE INDEX `idx_refresh_tokens_user_id` ON `refresh_tokens` (`user_id`);
+CREATE UNIQUE INDEX `idx_refresh_tokens_token_hash` ON `refresh_tokens` (`token_hash`);
+
+CREATE TABLE `revoked_tokens` (
+  `id` integer PRIMARY KEY AUTOINCREMENT,
+  `jti` text NOT NULL,
+  `user_id` integer NOT NULL,
+  `expires_at` datetime NOT NULL,
+  `created_at` datetime
+);
+CREATE INDEX `idx_revoked_tokens_expires_at` ON `revoked_tokens` (`expires_at`);
+CREATE INDEX `idx_revoked_tokens_user_id` ON `revoked_tokens` (`user_id`);
+CREATE UNIQUE INDEX `idx_revoked_tokens_jti` ON `revoked_tokens` (`jti`);
+
+CREATE TABLE `devices` (
+  `id` integer PRIMARY KEY AUTOINCREMENT,
+  `created_at` datetime,
+  `updated_at` datetime,
+  `deleted_at` datetime,
+  `restaurant_id` integer NOT NULL,
+  `name` text NOT NULL,
+  `credential_hash` text NOT NULL,
+  `last_seen_at` datetime,
+  `revoked_at` datetime,
+  `failed_pin_attempts` integer NOT NULL DEFAULT 0,
+  `locked_until` datetime,
+  CONSTRAINT `fk_devices_restaurant` FOREIGN KEY (`restaurant_id`) REFERENCES `restaurants`(`id`)
+);
+CREATE INDEX `idx_devices_deleted_at` ON `devices` (`deleted_at`);
+CREATE INDEX `idx_devices_restaurant_id` ON `devices` (`restaurant_id`);
+CREATE UNIQUE INDEX `idx_devices_credential_hash` ON `devices` (`credential_hash`);
+
+CREATE TABLE `roles` (
+  `id` integer PRIMARY KEY AUTOINCREMENT,
+  `created_at` datetime,
+  `updated_at` datetime,
+  `deleted_at` datetime,
+  `restaurant_id` integer NOT NULL,
+  `name` text NOT NULL,
+  `description` text,
+  CONSTRAINT `fk_roles_restaurant` FOREIGN KEY (`restaurant_id`) REFERENCES `restaurants`(`id`)
+);
+CREATE INDEX `idx_roles_deleted_at` ON `roles` (`deleted_at`);
+CREATE UNIQUE INDEX `idx_roles_restaurant_name` ON `roles` (`restaurant_id`,`name`);
+
+CREATE TABLE `role_permissions` (
+  `id` integer PRIMARY KEY AUTOINCREMENT,
+  `role_id` integer NOT NULL,
+  `permission` text NOT NULL,
+  CONSTRAINT `fk_roles_permissions` FOREIGN KEY (`role_id`) REFERENCES `roles`(`id`) ON DELETE CASCADE
+);
+CREATE UNIQUE INDEX `idx_role_permissions_role_permission` ON `role_permissions` (`role_id`,`permission`);
+
+CREATE TABLE `user_tokens` (
+  `id` integer PRIMARY KEY AUTOINCREMENT,
+  `user_id` integer NOT NULL,
+  `purpose` text NOT NULL,
+  `token_hash` text NOT NULL,
+  `expires_at` datetime NOT NULL,
+  `used_at` datetime,
+  `created_at` datetime,
+  CONSTRAINT `fk_user_tokens_user` FOREIGN KEY (`user_id`) REFERENCES `users`(`id`)
+);
+CREATE INDEX `idx_user_tokens_purpose` ON `user_tokens` (`purpose`);
+CREATE INDEX `idx_user_tokens_user_id` ON `user_tokens` (`user_id`);
+CREATE UNIQUE INDEX `idx_user_tokens_token_hash` ON `user_tokens` (`token_hash`);
+
+CREATE TABLE `login_attempts` (
+  `attempt_key` text,
+  `failures` integer NOT NULL DEFAULT 0,
+  `last_failure_at` datetime,
+  `locked_until` datetime,
+  PRIMARY KEY (`attempt_key`)
+);
+
+CREATE TABLE `audit_logs` (
+  `id` integer PRIMARY KEY AUTOINCREMENT,
+  `restaurant_id` integer,
+  `user_id` integer,
+  `actor_id` integer,
+  `event` text NOT NULL,
+  `email` text,
+  `ip_address` text,
+  `created_at` datetime
+);
+CREATE INDEX `idx_audit_logs_created_at` ON `audit_logs` (`created_at`);
+CREATE INDEX `idx_audit_logs_event` ON `audit_logs` (`event`);
+CREATE INDEX `idx_audit_logs_restaurant_id` ON `audit_logs` (`restaurant_id`);
+CREATE INDEX `idx_audit_logs_user_id` ON `audit_logs` (`user_id`);
+
+CREATE TABLE `recovery_codes` (
+  `id` integer PRIMARY KEY AUTOINCREMENT,
+  `user_id` integer NOT NULL,
+  `code_hash` text NOT NULL,
+  `used_at` datetime,
+  `created_at` datetime,
+  CONSTRAINT `fk_recovery_codes_user` FOREIGN KEY (`user_id`) REFERENCES `users`(`id`)
+);
+CREATE INDEX `idx_recovery_codes_user_id` ON `recovery_codes` (`user_id`);
+CREATE UNIQUE INDEX `idx_recovery_codes_code_hash` ON `recovery_codes` (`code_hash`);
+
+CREATE TABLE `signing_keys` (
+  `id` integer PRIMARY KEY AUTOINCREMENT,
+  `kid` text NOT NULL,
+  `algorithm` text NOT NULL,
+  `private_key` text NOT NULL,
+  `activates_at` datetime NOT NULL,
+  `retires_at` datetime,
+  `created_at` datetime
+);
+CREATE INDEX `idx_signing_keys_activates_at` ON `signing_keys` (`activates_at`);
+CREATE INDEX `idx_signing_keys_retires_at` ON `signing_keys` (`retires_at`);
+CREATE UNIQUE INDEX `idx_signing_keys_k_id` ON `signing_keys` (`kid`);
+
+CREATE TABLE `memberships` (
+  `id` integer PRIMARY KEY AUTOINCREMENT,
+  `user_id` integer NOT NULL,
+  `restaurant_id` integer NOT NULL,
+  `role` text NOT NULL DEFAULT 'staff',
+  `location_ids` text,
+  `is_active` numeric NOT NULL DEFAULT true,
+  `created_at` datetime,
+  `updated_at` datetime,
+  CONSTRAINT `fk_memberships_user` FOREIGN KEY (`user_id`) REFERENCES `users`(`id`),
+  CONSTRAINT `fk_memberships_restaurant` FOREIGN KEY (`restaurant_id`) REFERENCES `restaurants`(`id`)
+);
+CREATE INDEX `idx_memberships_restaurant_id` ON `memberships` (`restaurant_id`);
+CREATE UNIQUE INDEX `idx_memberships_user_restaurant` ON `memberships` (`user_id`,`restaurant_id`);
+
+CREATE TABLE `locations` (
+  `id` integer PRIMARY KEY AUTOINCREMENT,
+  `created_at` datetime,
+  `updated_at` datetime,
+  `deleted_at` datetime,
+  `restaurant_id` integer NOT NULL,
+  `square_location_id` text NOT NULL,
+  `name` text NOT NULL,
+  `address_line1` text,
+  `address_line2` text,
+  `locality` text,
+  `region` text,
+  `postal_code` text,
+  `country` text,
+  `timezone` text,
+  `currency` text,
+  `status` text NOT NULL DEFAULT 'ACTIVE',
+  `synced_at` datetime,
+  CONSTRAINT `fk_locations_restaurant` FOREIGN KEY (`restaurant_id`) REFERENCES `restaurants`(`id`)
+);
+CREATE INDEX `idx_locations_deleted_at` ON `locations` (`deleted_at`);
+CREATE UNIQUE INDEX `idx_locations_restaurant_square` ON `locations` (`restaurant_id`,`square_location_id`);
@@ -0,0 +1 @@
+-- The backfilled rows cannot be told apart from later ones, so they are kept
@@ -0,0 +1 @@
+-- Nothing to revert, see 0005_plain_status_columns.up.sql
@@ -0,0 +1 @@
+-- Status columns are plain on this database from the start
@@ -0,0 +1,78 @@
+package apperrors
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/square/square-go-sdk/v2/core"
+	"github.com/stretchr/testify/assert"
+
+	"square-pos-integration/internal/apperrors"
+	"square-pos-integration/internal/middleware"
+)
+
+func squareError(status int, body string) error {
+	return fmt.Errorf("square call failed: %w", core.NewAPIError(status, errors.New(body)))
+}
+
+func TestFromSquare(t *testing.T) {
+	tests := []struct {
+		name         string
+		err          error
+		expectedCode apperrors.Code
+	}{
+		{
+			name:         "card declined code",
+			err:          squareError(http.StatusPaymentRequired, `{"errors":[{"category":"PAYMENT_METHOD_ERROR","code":"CARD_DECLINED"}]}`),
+			expectedCode: "SQUARE_CARD_DECLINED",
+		},
+		{
+			name:         "unknown code falls back to category",
+			err:          squareError(http.StatusBadRequest, `{"errors":[{"category":"REFUND_ERROR","code":"REFUND_AMOUNT_INVALID"}]}`),
+			expectedCode: "SQUARE_REFUND_ERROR",
+		},
+		{
+			name:         "unreadable body falls back to status",
+			err:          squareError(http.StatusUnauthorized, "unauthorized"),
+			expectedCode: "SQUARE_AUTHENTICATION_FAILED",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			appErr := apperrors.FromSquare(tt.err)
+			if assert.NotNil(t, appErr) {
+				assert.Equal(t, tt.expectedCode, appErr.Code)
+				assert.ErrorIs(t, appErr, tt.err)
+			}
+		})
+	}
+
+	assert.Nil(t, apperrors.FromSquare(errors.New("plain error")))
+}
+
+func TestErrorHandlerHidesInternalDetail(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	w := httptest.NewRecorder()
+	_, router := gin.CreateTestContext(w)
+	router.Use(middleware.ErrorHandler())
+	router.GET("/fail", func(c *gin.Context) {
+		c.Error(errors.New("dial tcp 10.0.0.5:3306: connection refused"))
+	})
+	router.GET("/missing", func(c *gin.Context) {
+		c.Error(apperrors.ErrOrderNotFound)
+	})
+
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/fail", nil))
+	assert.Equal(t, http.StatusInternalServerError, w.Code)
+	assert.JSONEq(t, `{"error":"An internal error occurred","code":"INTERNAL_ERROR"}`, w.Body.String())
+
+	w.Body.Reset()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/missing", nil))
+	assert.JSONEq(t, `{"error":"Order not found","code":"ORDER_NOT_FOUND"}`, w.Body.String())
+}
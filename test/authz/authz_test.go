@@ -0,0 +1,105 @@
+package authz
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+
+	"square-pos-integration/internal/apperrors"
+	"square-pos-integration/internal/authz"
+	"square-pos-integration/internal/middleware"
+	testservices "square-pos-integration/test/services"
+)
+
+func TestResolver_BuiltinRoles(t *testing.T) {
+	db, mock := testservices.SetupMockDB()
+	resolver := authz.NewResolver(db)
+
+	staff, err := resolver.Permissions(1, "staff")
+	assert.NoError(t, err)
+	assert.True(t, staff.Has(authz.OrdersCreate))
+	assert.False(t, staff.Has(authz.PaymentsRefund))
+
+	manager, err := resolver.Permissions(1, "manager")
+	assert.NoError(t, err)
+	assert.True(t, manager.Has(authz.PaymentsRefund))
+	assert.False(t, manager.Has(authz.UsersManage))
+
+	// Built-in roles never hit the database
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestResolver_CustomRole(t *testing.T) {
+	db, mock := testservices.SetupMockDB()
+	resolver := authz.NewResolver(db)
+
+	mock.ExpectQuery("^SELECT \\* FROM `roles` WHERE \\(restaurant_id = \\? AND name = \\?\\)").
+		WithArgs(uint(3), "bartender", 1).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "restaurant_id", "name"}).AddRow(4, 3, "bartender"))
+	mock.ExpectQuery("^SELECT \\* FROM `role_permissions` WHERE `role_permissions`.`role_id` = \\?").
+		WithArgs(4).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "role_id", "permission"}).
+			AddRow(1, 4, "orders.create").
+			AddRow(2, 4, "reports.view"))
+
+	permissions, err := resolver.Permissions(3, "bartender")
+	assert.NoError(t, err)
+	assert.Equal(t, []authz.Permission{authz.OrdersCreate, authz.ReportsView}, permissions.List())
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func contextWith(userID uint, permissions ...authz.Permission) *gin.Context {
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	c.Set("user_id", userID)
+	authz.SetPermissions(c, authz.NewSet(permissions...))
+	return c
+}
+
+func TestRequireOwned(t *testing.T) {
+	staff := contextWith(7, authz.OrdersCancel)
+	assert.NoError(t, authz.RequireOwned(staff, authz.OrdersCancel, authz.OrdersManageAny, 7))
+	assert.ErrorIs(t, authz.RequireOwned(staff, authz.OrdersCancel, authz.OrdersManageAny, 8), apperrors.ErrPermissionDenied)
+
+	manager := contextWith(2, authz.OrdersCancel, authz.OrdersManageAny)
+	assert.NoError(t, authz.RequireOwned(manager, authz.OrdersCancel, authz.OrdersManageAny, 8))
+
+	viewer := contextWith(7, authz.OrdersView)
+	assert.ErrorIs(t, authz.RequireOwned(viewer, authz.OrdersCancel, authz.OrdersManageAny, 7), apperrors.ErrPermissionDenied)
+}
+
+func TestRequirePermissionMiddleware(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(middleware.ErrorHandler())
+	router.Use(func(c *gin.Context) {
+		authz.SetPermissions(c, authz.NewSet(authz.OrdersView))
+	})
+	router.GET("/orders", middleware.RequirePermission(authz.OrdersView), func(c *gin.Context) {
+		c.Status(http.StatusOK)
+	})
+	router.POST("/refund", middleware.RequirePermission(authz.PaymentsRefund), func(c *gin.Context) {
+		c.Status(http.StatusOK)
+	})
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/orders", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/refund", nil))
+	assert.Equal(t, http.StatusForbidden, w.Code)
+	assert.JSONEq(t, `{"error":"You do not have permission to perform this action","code":"PERMISSION_DENIED","details":{"permission":"payments.refund"}}`, w.Body.String())
+}
+
+func TestSetMissing(t *testing.T) {
+	manager, _ := authz.BuiltinRole("manager")
+	admin, _ := authz.BuiltinRole("admin")
+	set := authz.NewSet(append(manager, authz.UsersManage)...)
+
+	assert.Equal(t, []authz.Permission{authz.DevicesManage, authz.RolesManage, authz.SettingsManage}, set.Missing(authz.NewSet(admin...)))
+	assert.Empty(t, set.Missing(authz.NewSet(manager...)))
+}
@@ -0,0 +1,153 @@
+package config
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"square-pos-integration/internal/config"
+)
+
+func writeFile(t *testing.T, name, content string) string {
+	path := filepath.Join(t.TempDir(), name)
+	require.NoError(t, os.WriteFile(path, []byte(content), 0o644))
+	return path
+}
+
+const yamlFile = `
+server:
+  port: 9000
+  base_url: https://pos.example.com
+database:
+  dsn: sqlite://pos.db
+  max_open_conns: 50
+square:
+  environment: production
+  api_version: 2025-05-21
+  timeout: 10s
+metrics:
+  restaurant_labels: 20
+`
+
+func TestLoadPrecedence(t *testing.T) {
+	path := writeFile(t, "pos.yaml", yamlFile)
+	// The environment beats the file, flags beat both
+	t.Setenv("PORT", "9100")
+	t.Setenv("SQUARE_TIMEOUT", "15s")
+	t.Setenv("LOG_LEVEL", "debug")
+
+	cfg, args, err := config.Load([]string{"-config", path, "-square.timeout", "20s", "migrate", "up"}, io.Discard)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"migrate", "up"}, args)
+
+	assert.Equal(t, 9100, cfg.Server.Port)
+	assert.Equal(t, "https://pos.example.com", cfg.Server.BaseURL)
+	assert.Equal(t, "sqlite://pos.db", cfg.Database.DSN)
+	assert.Equal(t, 50, cfg.Database.MaxOpenConns)
+	assert.Equal(t, "production", cfg.Square.Environment)
+	assert.Equal(t, "2025-05-21", cfg.Square.APIVersion)
+	assert.Equal(t, 20*time.Second, cfg.Square.Timeout)
+	assert.Equal(t, "debug", cfg.Log.Level)
+	assert.Equal(t, 20, cfg.Metrics.RestaurantLabels)
+	// Untouched settings keep their defaults
+	assert.False(t, cfg.Metrics.Enabled)
+	assert.Equal(t, 10, cfg.Database.MaxIdleConns)
+	assert.Equal(t, "RS256", cfg.JWT.SigningAlgorithm)
+}
+
+func TestLoadTOMLFromConfigFileVariable(t *testing.T) {
+	t.Setenv("CONFIG_FILE", writeFile(t, "pos.toml", `
+[square]
+api_version = 2025-05-21
+
+[jwt]
+secret = "file-secret"
+key_rotation_days = 7
+
+[security]
+login_attempt_store = "database"
+`))
+
+	cfg, _, err := config.Load(nil, io.Discard)
+	require.NoError(t, err)
+	assert.Equal(t, "2025-05-21", cfg.Square.APIVersion)
+	assert.Equal(t, "file-secret", cfg.JWT.Secret)
+	assert.Equal(t, 7, cfg.JWT.KeyRotationDays)
+	assert.Equal(t, "database", cfg.Security.LoginAttemptStore)
+}
+
+func TestLoadErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		args []string
+		env  map[string]string
+		err  string
+	}{
+		{"unknown file setting", []string{"-config", writeFile(t, "pos.yaml", "server:\n  prot: 80\n")}, nil, "unknown setting server.prot"},
+		{"unknown file format", []string{"-config", writeFile(t, "pos.ini", "")}, nil, "unknown format"},
+		{"bad environment value", nil, map[string]string{"DB_MAX_OPEN_CONNS": "lots"}, `environment variable DB_MAX_OPEN_CONNS: "lots" is not a whole number`},
+		{"bad flag value", []string{"-square.timeout", "soon"}, nil, `flag -square.timeout: "soon" is not a duration`},
+		{"bad switch value", nil, map[string]string{"METRICS_ENABLED": "maybe"}, `environment variable METRICS_ENABLED: "maybe" is not true or false`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for key, value := range tt.env {
+				t.Setenv(key, value)
+			}
+			_, _, err := config.Load(tt.args, io.Discard)
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), tt.err)
+		})
+	}
+}
+
+func TestValidate(t *testing.T) {
+	cfg := config.Default()
+	cfg.Database.DSN = "sqlite://pos.db"
+	cfg.JWT.Secret = "secret"
+	require.NoError(t, cfg.Validate())
+
+	// Every problem is reported at once, named by file key and environment variable
+	cfg.JWT.Secret = ""
+	cfg.Server.Port = 70000
+	cfg.Square.Environment = "staging"
+	cfg.Square.APIVersion = "latest"
+	cfg.Mail.Driver = "smtp"
+	cfg.Webhooks.SignatureKey = "key"
+	cfg.Server.WriteTimeout = 10 * time.Second
+	cfg.Metrics.Enabled = true
+	err := cfg.Validate()
+	require.Error(t, err)
+	for _, problem := range []string{
+		"server.port (PORT): 70000 is not a port number",
+		"server.write_timeout (SERVER_WRITE_TIMEOUT): 10s must be longer than square.timeout (30s)",
+		"jwt.secret (JWT_SECRET): is required",
+		`square.environment (SQUARE_ENV): "staging" is not one of sandbox, production, fake`,
+		`square.api_version (SQUARE_API_VERSION): "latest" is not a Square API version`,
+		"mail.smtp_host (SMTP_HOST): is required by the smtp driver",
+		"webhooks.signature_key (SQUARE_WEBHOOK_SIGNATURE_KEY): the signature key and notification URL are needed together",
+		"metrics.token (METRICS_TOKEN): is required when metrics are enabled",
+	} {
+		assert.Contains(t, err.Error(), problem)
+	}
+
+	cfg = config.Default()
+	cfg.JWT.Secret = "secret"
+	cfg.Database.DSN = "oracle://db"
+	assert.ErrorContains(t, cfg.Validate(), `unsupported database "oracle"`)
+}
+
+func TestSecretsFallBackToJWTSecret(t *testing.T) {
+	cfg := config.Default()
+	cfg.JWT.Secret = "jwt"
+	cfg.Security.PINSecret = "pin"
+
+	pin, encryption := cfg.Secrets()
+	assert.Equal(t, "pin", pin)
+	assert.Equal(t, "jwt", encryption)
+}
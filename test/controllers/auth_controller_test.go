@@ -6,8 +6,14 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"square-pos-integration/internal/controllers"
+	"square-pos-integration/internal/lockout"
+	"square-pos-integration/internal/mailer"
+	"square-pos-integration/internal/middleware"
 	"square-pos-integration/internal/models"
 	"square-pos-integration/internal/requests"
+	"square-pos-integration/internal/service"
+	"square-pos-integration/internal/signing"
+	"square-pos-integration/internal/tenant"
 	"square-pos-integration/internal/utils"
 	"testing"
 	"time"
@@ -21,6 +27,19 @@ import (
 	testservices "square-pos-integration/test/services"
 )
 
+// newTwoFactorService creates a two-factor service whose emails are only logged, with
+// in-memory keys
+func newTwoFactorService(db *gorm.DB) *service.TwoFactorService {
+	sessions := service.NewSessionService(db, signing.NewEphemeralKeyRing())
+	accounts := service.NewAccountService(db, &mailer.LogMailer{}, sessions)
+	return service.NewTwoFactorService(db, accounts, utils.NewSecretBox("encryption-key"))
+}
+
+// newAuthController creates an auth controller whose emails are only logged
+func newAuthController(db *gorm.DB, squareService service.ISquareService, loginGuard *service.LoginGuard) *controllers.AuthController {
+	return controllers.NewAuthController(service.NewAuthService(db, squareService, newTwoFactorService(db), loginGuard))
+}
+
 // setupMockDB creates a new mock database instance for testing.
 func setupMockDB() (*gorm.DB, sqlmock.Sqlmock) {
 	db, mock, err := sqlmock.New()
@@ -35,6 +54,9 @@ func setupMockDB() (*gorm.DB, sqlmock.Sqlmock) {
 	if err != nil {
 		panic("failed to open gorm database")
 	}
+	if err := gormDB.Use(tenant.Plugin{}); err != nil {
+		panic("failed to register tenant scoping")
+	}
 
 	return gormDB, mock
 }
@@ -56,12 +78,44 @@ func mockUserQuery(mock sqlmock.Sqlmock, user models.User, err error) {
 		WillReturnRows(rows)
 }
 
+// mockMembershipQuery mocks loading the user's memberships, with their restaurants, on login.
+func mockMembershipQuery(mock sqlmock.Sqlmock, user models.User) {
+	membershipRows := sqlmock.NewRows([]string{"id", "user_id", "restaurant_id", "role", "location_ids", "is_active"}).
+		AddRow(1, user.ID, user.RestaurantID, user.Role, "", true)
+
+	mock.ExpectQuery("^SELECT \\* FROM `memberships`").
+		WithArgs(user.ID, true).
+		WillReturnRows(membershipRows)
+
+	restaurantRows := sqlmock.NewRows([]string{"id", "created_at", "updated_at", "deleted_at", "name", "square_app_id", "square_token", "merchant_id", "location_id"}).
+		AddRow(user.RestaurantID, time.Now(), time.Now(), nil, "Test Restaurant", "app-id", "token", "merchant-id", "location-id")
+
+	mock.ExpectQuery("^SELECT \\* FROM `restaurants`").
+		WillReturnRows(restaurantRows)
+}
+
+// mockRefreshTokenInsert mocks storing the refresh token issued on login.
+func mockRefreshTokenInsert(mock sqlmock.Sqlmock) {
+	mock.ExpectBegin()
+	mock.ExpectExec("^INSERT INTO `refresh_tokens`").
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
+}
+
+// mockAuditInsert mocks writing a login event to the audit log.
+func mockAuditInsert(mock sqlmock.Sqlmock) {
+	mock.ExpectBegin()
+	mock.ExpectExec("^INSERT INTO `audit_logs`").
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
+}
+
 func TestAuthController_Login(t *testing.T) {
 	gin.SetMode(gin.TestMode)
 
 	// Mock JWT generation
 	originalGenerateJWT := utils.GenerateJWT
-	utils.GenerateJWT = func(user models.User) (string, error) {
+	utils.GenerateJWT = func(keys *signing.KeyRing, user models.User) (string, error) {
 		return "mock_jwt_token", nil
 	}
 	defer func() { utils.GenerateJWT = originalGenerateJWT }()
@@ -94,6 +148,9 @@ func TestAuthController_Login(t *testing.T) {
 				}
 				user.ID = 1
 				mockUserQuery(mock, user, nil)
+				mockMembershipQuery(mock, user)
+				mockRefreshTokenInsert(mock)
+				mockAuditInsert(mock)
 				return db, mock, &testservices.MockSquareService{}
 			},
 			expectedStatus: http.StatusOK,
@@ -112,11 +169,13 @@ func TestAuthController_Login(t *testing.T) {
 			setupMock: func() (*gorm.DB, sqlmock.Sqlmock, *testservices.MockSquareService) {
 				db, mock := setupMockDB()
 				mockUserQuery(mock, models.User{}, gorm.ErrRecordNotFound)
+				mockAuditInsert(mock)
 				return db, mock, &testservices.MockSquareService{}
 			},
 			expectedStatus: http.StatusUnauthorized,
 			expectedBody: map[string]interface{}{
 				"error": "Invalid credentials",
+				"code":  "INVALID_CREDENTIALS",
 			},
 		},
 		{
@@ -140,11 +199,13 @@ func TestAuthController_Login(t *testing.T) {
 				}
 				user.ID = 1
 				mockUserQuery(mock, user, nil)
+				mockAuditInsert(mock)
 				return db, mock, &testservices.MockSquareService{}
 			},
 			expectedStatus: http.StatusUnauthorized,
 			expectedBody: map[string]interface{}{
 				"error": "Invalid credentials",
+				"code":  "INVALID_CREDENTIALS",
 			},
 		},
 		{
@@ -162,26 +223,27 @@ func TestAuthController_Login(t *testing.T) {
 			expectedStatus: http.StatusBadRequest,
 			expectedBody: map[string]interface{}{
 				"error": "validation failed",
+				"code":  "VALIDATION_FAILED",
 			},
 		},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			db, _, mockSquareService := tt.setupMock()
+			db, mock, mockSquareService := tt.setupMock()
 			
 			// Create controller with mock service
-			controller := controllers.NewAuthController(db, mockSquareService)
+			controller := newAuthController(db, mockSquareService, service.NewLoginGuard(db, lockout.NewMemoryStore()))
 
 			body, _ := json.Marshal(tt.requestBody)
 			req := httptest.NewRequest(http.MethodPost, "/login", bytes.NewBuffer(body))
 			req.Header.Set("Content-Type", "application/json")
 
 			w := httptest.NewRecorder()
-			c, _ := gin.CreateTestContext(w)
-			c.Request = req
-
-			controller.Login(c)
+			_, router := gin.CreateTestContext(w)
+			router.Use(middleware.ErrorHandler())
+			router.POST("/login", controller.Login)
+			router.ServeHTTP(w, req)
 
 			assert.Equal(t, tt.expectedStatus, w.Code)
 
@@ -191,10 +253,70 @@ func TestAuthController_Login(t *testing.T) {
 			if tt.expectedStatus == http.StatusOK {
 				assert.Contains(t, response, "token")
 				assert.Equal(t, "mock_jwt_token", response["token"])
+				assert.Equal(t, "Test Restaurant", response["restaurant_name"])
+				assert.Len(t, response["memberships"], 1)
+				assert.NotEmpty(t, response["refresh_token"])
 			} else {
 				assert.Contains(t, response, "error")
-
+				assert.Equal(t, tt.expectedBody["code"], response["code"])
 			}
+			assert.NoError(t, mock.ExpectationsWereMet())
 		})
 	}
-}
\ No newline at end of file
+}
+
+func TestAuthController_LoginLockedAccount(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	db, mock := setupMockDB()
+
+	store := lockout.NewMemoryStore()
+	store.Lock("account:test@example.com", time.Now().Add(10*time.Minute))
+	controller := newAuthController(db, &testservices.MockSquareService{}, service.NewLoginGuard(db, store))
+
+	// The lockout is checked before the user is looked up
+	mockAuditInsert(mock)
+
+	body, _ := json.Marshal(requests.LoginRequest{
+		Username:     "testuser",
+		Email:        "Test@Example.com",
+		Password:     "password123",
+		RestaurantID: 1,
+	})
+	req := httptest.NewRequest(http.MethodPost, "/login", bytes.NewBuffer(body))
+	req.Header.Set("Content-Type", "application/json")
+
+	w := httptest.NewRecorder()
+	_, router := gin.CreateTestContext(w)
+	router.Use(middleware.ErrorHandler())
+	router.POST("/login", controller.Login)
+	router.ServeHTTP(w, req)
+
+	var response map[string]interface{}
+	json.Unmarshal(w.Body.Bytes(), &response)
+
+	assert.Equal(t, http.StatusTooManyRequests, w.Code)
+	assert.Equal(t, "ACCOUNT_LOCKED", response["code"])
+	assert.Equal(t, "600", w.Header().Get("Retry-After"))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestAuthController_ChangePasswordChecksCurrentPassword(t *testing.T) {
+	db, mock := setupMockDB()
+	controller := newAuthController(db, &testservices.MockSquareService{}, service.NewLoginGuard(db, lockout.NewMemoryStore()))
+	router := setupAdminRouter(http.MethodPost, "/auth/change-password", controller.ChangePassword)
+
+	hash, _ := utils.HashPassword("old-password")
+	mock.ExpectQuery("^SELECT \\* FROM `users` WHERE `users`.`id` = \\?").
+		WithArgs(uint(1), 1).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "email", "password_hash", "restaurant_id", "role", "is_active"}).
+			AddRow(1, "admin@example.com", hash, 1, "admin", true))
+
+	w, response := serve(router, http.MethodPost, "/auth/change-password", map[string]interface{}{
+		"current_password": "guessed-password",
+		"new_password":     "new-password",
+	})
+
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+	assert.Equal(t, "INVALID_CREDENTIALS", response["code"])
+	assert.NoError(t, mock.ExpectationsWereMet(), "the password is not changed")
+}
@@ -0,0 +1,40 @@
+package test
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
+
+	"square-pos-integration/internal/controllers"
+	"square-pos-integration/internal/service"
+	"square-pos-integration/internal/signing"
+	"square-pos-integration/internal/utils"
+)
+
+// newDeviceController creates a device controller with throwaway PIN and signing keys
+func newDeviceController(db *gorm.DB) *controllers.DeviceController {
+	twoFactor := newTwoFactorService(db)
+	devices := service.NewDeviceService(db, twoFactor, utils.NewPINHasher("pin-secret"), signing.NewEphemeralKeyRing())
+	return controllers.NewDeviceController(devices, service.NewUserService(db, twoFactor.Sessions))
+}
+
+func TestDeviceController_CustomRoleCannotSetAdminPin(t *testing.T) {
+	db, mock := setupMockDB()
+	controller := newDeviceController(db)
+	router := setupRoleRouter("shift_lead", shiftLeadPermissions(), http.MethodPut, "/admin/users/:id/pin", controller.SetUserPin)
+
+	mock.ExpectQuery("^SELECT \\* FROM `users` WHERE \\(id = \\? AND restaurant_id = \\?\\)").
+		WithArgs("2", uint(1), 1).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "created_at", "updated_at", "deleted_at", "username", "email", "restaurant_id", "role", "is_active"}).
+			AddRow(2, time.Now(), time.Now(), nil, "owner", "owner@example.com", 1, "admin", true))
+
+	w, response := serve(router, http.MethodPut, "/admin/users/2/pin", map[string]interface{}{"pin": "1234"})
+
+	assert.Equal(t, http.StatusForbidden, w.Code)
+	assert.Equal(t, "ROLE_EXCEEDS_PERMISSIONS", response["code"])
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
@@ -0,0 +1,87 @@
+package test
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+
+	"square-pos-integration/internal/controllers"
+	"square-pos-integration/internal/service"
+)
+
+// Restaurant 1's admin asks for records that only exist in another restaurant. Every
+// lookup is limited to restaurant 1, so the records are not found.
+
+func TestOrderController_GetOrderOfAnotherRestaurant(t *testing.T) {
+	db, mock := setupMockDB()
+	controller := controllers.NewOrderController(db, service.NewSquareService(db))
+	router := setupAdminRouter(http.MethodGet, "/orders/:id", controller.GetOrderByID)
+
+	mock.ExpectQuery("^SELECT \\* FROM `orders` WHERE id = \\? AND `orders`.`restaurant_id` = \\?").
+		WithArgs("42", uint(1), 1).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}))
+
+	w, response := serve(router, http.MethodGet, "/orders/42", nil)
+
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	assert.Equal(t, "ORDER_NOT_FOUND", response["code"])
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestPaymentController_PaymentIntentForOrderOfAnotherRestaurant(t *testing.T) {
+	db, mock := setupMockDB()
+	controller := controllers.NewPaymentController(db, service.NewSquareService(db))
+	router := setupAdminRouter(http.MethodPost, "/payment/:id/payment-intent", controller.CreatePaymentIntent)
+
+	mock.ExpectQuery("^SELECT \\* FROM `orders` WHERE id = \\? AND `orders`.`restaurant_id` = \\?").
+		WithArgs("42", uint(1), 1).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}))
+
+	w, response := serve(router, http.MethodPost, "/payment/42/payment-intent", map[string]interface{}{
+		"source_id":   "cnon:card-nonce-ok",
+		"amount":      28.62,
+		"currency":    "USD",
+		"location_id": "LOCATION1",
+	})
+
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	assert.Equal(t, "ORDER_NOT_FOUND", response["code"])
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestPaymentController_CompletePaymentOfAnotherRestaurant(t *testing.T) {
+	db, mock := setupMockDB()
+	controller := controllers.NewPaymentController(db, service.NewSquareService(db))
+	router := setupAdminRouter(http.MethodPost, "/payment/complete", controller.CompletePayment)
+
+	mock.ExpectQuery("^SELECT \\* FROM `payments` WHERE square_payment_id = \\? AND `payments`.`restaurant_id` = \\?").
+		WithArgs("SQ-PAY-1", uint(1), 1).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}))
+
+	w, response := serve(router, http.MethodPost, "/payment/complete", map[string]interface{}{
+		"billAmount": 28.62,
+		"paymentId":  "SQ-PAY-1",
+	})
+
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	assert.Equal(t, "PAYMENT_NOT_FOUND", response["code"])
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestPaymentController_RefundPaymentOfAnotherRestaurant(t *testing.T) {
+	db, mock := setupMockDB()
+	controller := controllers.NewPaymentController(db, service.NewSquareService(db))
+	router := setupAdminRouter(http.MethodPost, "/payment/:id/refund", controller.RefundPayment)
+
+	mock.ExpectQuery("^SELECT \\* FROM `payments` WHERE id = \\? AND `payments`.`restaurant_id` = \\?").
+		WithArgs("9", uint(1), 1).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}))
+
+	w, response := serve(router, http.MethodPost, "/payment/9/refund", map[string]interface{}{"amount": 10})
+
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	assert.Equal(t, "PAYMENT_NOT_FOUND", response["code"])
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
@@ -0,0 +1,184 @@
+package test
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
+
+	"square-pos-integration/internal/authz"
+	"square-pos-integration/internal/controllers"
+	"square-pos-integration/internal/lockout"
+	"square-pos-integration/internal/middleware"
+	"square-pos-integration/internal/models"
+	"square-pos-integration/internal/service"
+	testservices "square-pos-integration/test/services"
+)
+
+// setupAdminRouter serves handler as the admin with user ID 1 of restaurant 1
+func setupAdminRouter(method, path string, handler gin.HandlerFunc) *gin.Engine {
+	return setupRoleRouter("admin", authz.NewSet(authz.All...), method, path, handler)
+}
+
+// setupRoleRouter serves handler as user ID 1 of restaurant 1 holding role with its permissions
+func setupRoleRouter(role string, permissions authz.Set, method, path string, handler gin.HandlerFunc) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(middleware.ErrorHandler())
+	router.Use(func(c *gin.Context) {
+		c.Set("user_id", uint(1))
+		c.Set("restaurant_id", uint(1))
+		c.Set("user_role", role)
+		c.Set("membership", models.Membership{UserID: 1, RestaurantID: 1, Role: role})
+		authz.SetPermissions(c, permissions)
+	})
+	router.Handle(method, path, handler)
+	return router
+}
+
+// shiftLeadPermissions are those of a custom role with the manager's permissions plus users.manage
+func shiftLeadPermissions() authz.Set {
+	permissions, _ := authz.BuiltinRole("manager")
+	return authz.NewSet(append(permissions, authz.UsersManage)...)
+}
+
+// newUserController creates a user controller with in-memory login attempts
+func newUserController(db *gorm.DB) *controllers.UserController {
+	return controllers.NewUserController(db, service.NewLoginGuard(db, lockout.NewMemoryStore()), newTwoFactorService(db))
+}
+
+func serve(router *gin.Engine, method, path string, body interface{}) (*httptest.ResponseRecorder, map[string]interface{}) {
+	var reader *bytes.Buffer
+	if body != nil {
+		encoded, _ := json.Marshal(body)
+		reader = bytes.NewBuffer(encoded)
+	} else {
+		reader = bytes.NewBuffer(nil)
+	}
+	req := httptest.NewRequest(method, path, reader)
+	req.Header.Set("Content-Type", "application/json")
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	var response map[string]interface{}
+	json.Unmarshal(w.Body.Bytes(), &response)
+	return w, response
+}
+
+func TestUserController_GetUserFromAnotherRestaurant(t *testing.T) {
+	db, mock := setupMockDB()
+	controller := newUserController(db)
+	router := setupAdminRouter(http.MethodGet, "/admin/users/:id", controller.GetUser)
+
+	mock.ExpectQuery("^SELECT \\* FROM `users` WHERE \\(id = \\? AND restaurant_id = \\?\\)").
+		WithArgs("5", uint(1), 1).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}))
+
+	w, response := serve(router, http.MethodGet, "/admin/users/5", nil)
+
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	assert.Equal(t, "USER_NOT_FOUND", response["code"])
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestUserController_DeactivateLastAdmin(t *testing.T) {
+	db, mock := setupMockDB()
+	controller := newUserController(db)
+	router := setupAdminRouter(http.MethodPost, "/admin/users/:id/deactivate", controller.DeactivateUser)
+
+	mock.ExpectQuery("^SELECT \\* FROM `users`").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "created_at", "updated_at", "deleted_at", "username", "email", "restaurant_id", "role", "is_active"}).
+			AddRow(2, time.Now(), time.Now(), nil, "owner", "owner@example.com", 1, "admin", true))
+	mock.ExpectBegin()
+	mock.ExpectQuery("^SELECT .* FROM `memberships` JOIN users .* FOR UPDATE").
+		WithArgs(true, uint(1), "admin", true, uint(2)).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}))
+	mock.ExpectRollback()
+
+	w, response := serve(router, http.MethodPost, "/admin/users/2/deactivate", nil)
+
+	assert.Equal(t, http.StatusConflict, w.Code)
+	assert.Equal(t, "LAST_ADMIN", response["code"])
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestUserController_CustomRoleCannotGrantAdmin(t *testing.T) {
+	db, mock := setupMockDB()
+	controller := newUserController(db)
+	router := setupRoleRouter("shift_lead", shiftLeadPermissions(), http.MethodPatch, "/admin/users/:id", controller.UpdateUser)
+
+	mock.ExpectQuery("^SELECT \\* FROM `users`").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "created_at", "updated_at", "deleted_at", "username", "email", "restaurant_id", "role", "is_active"}).
+			AddRow(5, time.Now(), time.Now(), nil, "server", "server@example.com", 1, "staff", true))
+
+	w, response := serve(router, http.MethodPatch, "/admin/users/5", map[string]interface{}{"role": "admin"})
+
+	assert.Equal(t, http.StatusForbidden, w.Code)
+	assert.Equal(t, "ROLE_EXCEEDS_PERMISSIONS", response["code"])
+	assert.Contains(t, response["details"].(map[string]interface{})["missing_permissions"], "roles.manage")
+	assert.NoError(t, mock.ExpectationsWereMet(), "the role is not changed")
+}
+
+func TestUserController_CustomRoleCannotResetAdmin(t *testing.T) {
+	for _, path := range []string{"/admin/users/2/reset-password", "/admin/users/2/2fa/reset"} {
+		db, mock := setupMockDB()
+		controller := newUserController(db)
+		router := setupRoleRouter("shift_lead", shiftLeadPermissions(), http.MethodPost, "/admin/users/:id/reset-password", controller.ResetPassword)
+		router.POST("/admin/users/:id/2fa/reset", controller.ResetTwoFactor)
+
+		mock.ExpectQuery("^SELECT \\* FROM `users`").
+			WillReturnRows(sqlmock.NewRows([]string{"id", "created_at", "updated_at", "deleted_at", "username", "email", "restaurant_id", "role", "is_active"}).
+				AddRow(2, time.Now(), time.Now(), nil, "owner", "owner@example.com", 1, "admin", true))
+
+		w, response := serve(router, http.MethodPost, path, map[string]interface{}{"new_password": "taken-over-123"})
+
+		assert.Equal(t, http.StatusForbidden, w.Code, path)
+		assert.Equal(t, "ROLE_EXCEEDS_PERMISSIONS", response["code"], path)
+		assert.NoError(t, mock.ExpectationsWereMet(), path)
+	}
+}
+
+func TestAuthController_RegisterIgnoresBodyRestaurant(t *testing.T) {
+	db, mock := setupMockDB()
+	controller := newAuthController(db, &testservices.MockSquareService{}, service.NewLoginGuard(db, lockout.NewMemoryStore()))
+	router := setupAdminRouter(http.MethodPost, "/admin/users", controller.Register)
+
+	mock.ExpectQuery("^SELECT count\\(\\*\\) FROM `users` WHERE \\(username = \\?").
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
+	mock.ExpectQuery("^SELECT count\\(\\*\\) FROM `users` WHERE \\(email = \\?").
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
+	mock.ExpectBegin()
+	mock.ExpectExec("^INSERT INTO `users`").
+		WithArgs(
+			sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), // created_at, updated_at, deleted_at
+			"newserver", "server@example.com", sqlmock.AnyArg(),
+			uint(1), // restaurant_id comes from the token, not the body
+			"staff", true, sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(),
+			sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(),
+		).
+		WillReturnResult(sqlmock.NewResult(8, 1))
+	// The user becomes a member of the restaurant with the same role
+	mock.ExpectExec("^INSERT INTO `memberships`").
+		WithArgs(uint(8), uint(1), "staff", "", true, sqlmock.AnyArg(), sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
+
+	w, response := serve(router, http.MethodPost, "/admin/users", map[string]interface{}{
+		"username":      "newserver",
+		"email":         "server@example.com",
+		"password":      "password123",
+		"restaurant_id": 99,
+	})
+
+	assert.Equal(t, http.StatusCreated, w.Code)
+	assert.Equal(t, float64(1), response["data"].(map[string]interface{})["restaurant_id"])
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
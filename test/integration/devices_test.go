@@ -0,0 +1,44 @@
+package integration
+
+import (
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"square-pos-integration/internal/apperrors"
+	"square-pos-integration/internal/models"
+	"square-pos-integration/internal/service"
+	"square-pos-integration/internal/tenant"
+	"square-pos-integration/internal/utils"
+)
+
+func TestConcurrentWrongPinsLockDevice(t *testing.T) {
+	app := NewApp(t)
+	owner := app.RegisterRestaurant("Harbor Grill")
+	devices := service.NewDeviceService(app.DB, nil, utils.NewPINHasher("pin-secret"), nil)
+
+	device, _, err := devices.EnrollDevice(owner.RestaurantID, "Bar tablet")
+	require.NoError(t, err)
+
+	// Every attempt starts from the device as loaded before any of them failed
+	errs := make([]error, service.MaxPinAttempts)
+	var wg sync.WaitGroup
+	for i := range errs {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, _, errs[i] = devices.PinLogin(device, 0, "0000")
+		}()
+	}
+	wg.Wait()
+
+	for _, err := range errs {
+		assert.ErrorIs(t, err, apperrors.ErrInvalidPin)
+	}
+	var stored models.Device
+	require.NoError(t, tenant.Scoped(app.DB, owner.RestaurantID).First(&stored, device.ID).Error)
+	assert.NotNil(t, stored.LockedUntil, "every concurrent failure counts towards the lock")
+	assert.Zero(t, stored.FailedPinAttempts)
+}
@@ -0,0 +1,226 @@
+package integration
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"square-pos-integration/internal/models"
+	"square-pos-integration/internal/squarefake"
+	"square-pos-integration/internal/tenant"
+)
+
+func burgerOrder(locationID string) map[string]interface{} {
+	return map[string]interface{}{
+		"table_number": 12,
+		"location_id":  locationID,
+		"items": []map[string]interface{}{{
+			"name":       "Burger",
+			"unit_price": 1200,
+			"quantity":   2,
+			"modifiers": []map[string]interface{}{
+				{"name": "Extra cheese", "unit_price": 100, "quantity": 1},
+			},
+		}},
+	}
+}
+
+// createOrder creates the burger order for a tenant and returns its ID
+func createOrder(t *testing.T, app *App, tenant *Tenant) string {
+	t.Helper()
+	w, response := app.Do(http.MethodPost, "/api/v1/orders", tenant.Token, burgerOrder(tenant.LocationID))
+	require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+	return response["id"].(string)
+}
+
+// createPaymentIntent authorizes the full amount of an order and returns the Square payment ID
+func createPaymentIntent(t *testing.T, app *App, tenant *Tenant, orderID string, amount float64) string {
+	t.Helper()
+	w, response := app.Do(http.MethodPost, "/api/v1/payment/"+orderID+"/payment-intent", tenant.Token, map[string]interface{}{
+		"source_id":   "cnon:card-nonce-ok",
+		"amount":      amount,
+		"currency":    "USD",
+		"location_id": tenant.LocationID,
+	})
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	return response["payment_id"].(string)
+}
+
+func TestOrderToPaymentWithTip(t *testing.T) {
+	app := NewApp(t)
+	tenant := app.RegisterRestaurant("Harbor Grill")
+
+	orderID := createOrder(t, app, tenant)
+
+	w, order := app.Do(http.MethodGet, "/api/v1/orders/"+orderID, tenant.Token, nil)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	assert.Equal(t, false, order["is_closed"])
+	totals := order["totals"].(map[string]interface{})
+	assert.Equal(t, 26.0, totals["total"])
+
+	paymentID := createPaymentIntent(t, app, tenant, orderID, 26)
+
+	w, completed := app.Do(http.MethodPost, "/api/v1/payment/complete", tenant.Token, map[string]interface{}{
+		"billAmount": 26,
+		"tipAmount":  5,
+		"paymentId":  paymentID,
+	})
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	assert.Equal(t, orderID, completed["id"])
+	assert.Equal(t, true, completed["is_closed"])
+	totals = completed["totals"].(map[string]interface{})
+	assert.Equal(t, 5.0, totals["tips"])
+	assert.Equal(t, 26.0, totals["paid"])
+	assert.Equal(t, 0.0, totals["due"])
+
+	// A captured payment cannot be completed again
+	w, response := app.Do(http.MethodPost, "/api/v1/payment/complete", tenant.Token, map[string]interface{}{
+		"billAmount": 26,
+		"paymentId":  paymentID,
+	})
+	assert.Equal(t, http.StatusConflict, w.Code, w.Body.String())
+	assert.Equal(t, "PAYMENT_ALREADY_COMPLETED", response["code"])
+}
+
+func TestCapturedPaymentSucceedsWhenTotalsRefreshFails(t *testing.T) {
+	app := NewApp(t)
+	tenant := app.RegisterRestaurant("Harbor Grill")
+	orderID := createOrder(t, app, tenant)
+	paymentID := createPaymentIntent(t, app, tenant, orderID, 26)
+
+	app.Square.Fail("POST /v2/orders/calculate", &squarefake.Error{Status: http.StatusBadRequest, Category: "INVALID_REQUEST_ERROR", Code: "BAD_REQUEST"})
+	w, completed := app.Do(http.MethodPost, "/api/v1/payment/complete", tenant.Token, map[string]interface{}{
+		"billAmount": 26,
+		"paymentId":  paymentID,
+	})
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	assert.Equal(t, orderID, completed["id"])
+	assert.Equal(t, true, completed["is_closed"])
+
+	// The captured payment is recorded, so retrying it is refused
+	w, _ = app.Do(http.MethodPost, "/api/v1/payment/complete", tenant.Token, map[string]interface{}{
+		"billAmount": 26,
+		"paymentId":  paymentID,
+	})
+	assert.Equal(t, http.StatusConflict, w.Code, w.Body.String())
+}
+
+func TestPaymentInLocationCurrency(t *testing.T) {
+	app := NewApp(t)
+	owner := app.RegisterRestaurant("Harbor Grill")
+	app.Square.SetLocationCurrency(owner.SquareToken, owner.LocationID, "EUR")
+	w, _ := app.Do(http.MethodPost, "/api/v1/admin/locations/sync", owner.Token, nil)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+	orderID := createOrder(t, app, owner)
+	w, intent := app.Do(http.MethodPost, "/api/v1/payment/"+orderID+"/payment-intent", owner.Token, map[string]interface{}{
+		"source_id":   "cnon:card-nonce-ok",
+		"amount":      26,
+		"currency":    "EUR",
+		"location_id": owner.LocationID,
+	})
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var payment models.Payment
+	require.NoError(t, tenant.Scoped(app.DB, owner.RestaurantID).Where("square_payment_id = ?", intent["payment_id"]).First(&payment).Error)
+	assert.Equal(t, "EUR", payment.Currency)
+
+	// Square rejects tips in another currency than the payment's
+	w, completed := app.Do(http.MethodPost, "/api/v1/payment/complete", owner.Token, map[string]interface{}{
+		"billAmount": 26,
+		"tipAmount":  5,
+		"paymentId":  intent["payment_id"],
+	})
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	assert.Equal(t, 5.0, completed["totals"].(map[string]interface{})["tips"])
+}
+
+func TestDeclinedCardLeavesOrderOpen(t *testing.T) {
+	app := NewApp(t)
+	tenant := app.RegisterRestaurant("Harbor Grill")
+	orderID := createOrder(t, app, tenant)
+
+	w, response := app.Do(http.MethodPost, "/api/v1/payment/"+orderID+"/payment-intent", tenant.Token, map[string]interface{}{
+		"source_id":   "cnon:card-nonce-declined",
+		"amount":      26,
+		"currency":    "USD",
+		"location_id": tenant.LocationID,
+	})
+	assert.Equal(t, http.StatusPaymentRequired, w.Code, w.Body.String())
+	assert.Equal(t, "SQUARE_CARD_DECLINED", response["code"])
+
+	w, order := app.Do(http.MethodGet, "/api/v1/orders/"+orderID, tenant.Token, nil)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	assert.Equal(t, false, order["is_closed"])
+}
+
+func TestCrossTenantAccess(t *testing.T) {
+	app := NewApp(t)
+	harbor := app.RegisterRestaurant("Harbor Grill")
+	uptown := app.RegisterRestaurant("Uptown Diner")
+
+	orderID := createOrder(t, app, harbor)
+	paymentID := createPaymentIntent(t, app, harbor, orderID, 26)
+
+	tests := []struct {
+		name   string
+		method string
+		path   string
+		body   interface{}
+		code   string
+	}{
+		{"get order", http.MethodGet, "/api/v1/orders/" + orderID, nil, "ORDER_NOT_FOUND"},
+		{"cancel order", http.MethodPost, "/api/v1/orders/" + orderID + "/cancel", nil, "ORDER_NOT_FOUND"},
+		{"payment intent", http.MethodPost, "/api/v1/payment/" + orderID + "/payment-intent", map[string]interface{}{
+			"source_id": "cnon:card-nonce-ok", "amount": 26, "currency": "USD", "location_id": uptown.LocationID,
+		}, "ORDER_NOT_FOUND"},
+		{"complete payment", http.MethodPost, "/api/v1/payment/complete", map[string]interface{}{
+			"billAmount": 26, "tipAmount": 5, "paymentId": paymentID,
+		}, "PAYMENT_NOT_FOUND"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w, response := app.Do(tt.method, tt.path, uptown.Token, tt.body)
+			assert.Equal(t, http.StatusNotFound, w.Code, w.Body.String())
+			assert.Equal(t, tt.code, response["code"])
+		})
+	}
+
+	// The table listing only shows the caller's own orders
+	w, response := app.Do(http.MethodGet, "/api/v1/orders/table/12", uptown.Token, nil)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	assert.Empty(t, response["orders"])
+
+	// Harbor's location is not one of Uptown's
+	w, response = app.Do(http.MethodPost, "/api/v1/orders", uptown.Token, burgerOrder(harbor.LocationID))
+	assert.Equal(t, http.StatusNotFound, w.Code, w.Body.String())
+	assert.Equal(t, "LOCATION_NOT_FOUND", response["code"])
+
+	// The order is untouched and still payable by its own restaurant
+	w, _ = app.Do(http.MethodPost, "/api/v1/payment/complete", harbor.Token, map[string]interface{}{
+		"billAmount": 26, "tipAmount": 5, "paymentId": paymentID,
+	})
+	assert.Equal(t, http.StatusOK, w.Code, w.Body.String())
+}
+
+func TestRegisterRestaurantWithTakenEmail(t *testing.T) {
+	app := NewApp(t)
+	harbor := app.RegisterRestaurant("Harbor Grill")
+
+	w, response := app.Do(http.MethodPost, "/api/v1/register-restaurant", "", map[string]interface{}{
+		"name":           "Uptown Diner",
+		"square_app_id":  "app-uptown-diner",
+		"square_token":   "token-uptown-diner",
+		"admin_email":    harbor.Email,
+		"admin_password": "correct-horse",
+		"username":       "admin-uptown-diner",
+	})
+	assert.Equal(t, http.StatusConflict, w.Code, w.Body.String())
+	assert.Equal(t, "EMAIL_ALREADY_EXISTS", response["code"])
+
+	// No restaurant is left behind without an admin
+	var restaurants int64
+	require.NoError(t, app.DB.Model(&models.Restaurant{}).Count(&restaurants).Error)
+	assert.Equal(t, int64(1), restaurants)
+}
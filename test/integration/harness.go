@@ -0,0 +1,231 @@
+package integration
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math/rand"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+
+	"square-pos-integration/internal/config"
+	"square-pos-integration/internal/migrate"
+	"square-pos-integration/internal/routes"
+	"square-pos-integration/internal/service"
+	"square-pos-integration/internal/squarefake"
+	"square-pos-integration/migrations"
+)
+
+// App is the full API router running against a throwaway database and the fake Square
+// API. Emails are written to MailDir so tests can follow the links in them.
+type App struct {
+	t       *testing.T
+	Router  *gin.Engine
+	DB      *gorm.DB
+	Square  *squarefake.Server
+	Health  *service.HealthService
+	MailDir string
+}
+
+// Tenant is a registered restaurant and its logged in admin
+type Tenant struct {
+	RestaurantID uint
+	LocationID   string
+	SquareToken  string
+	Email        string
+	Username     string
+	Password     string
+	Token        string
+}
+
+// NewApp starts an App that is torn down when the test ends. configure may change the
+// configuration before the routes are set up.
+func NewApp(t *testing.T, configure ...func(*config.Config)) *App {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+
+	mailDir := t.TempDir()
+	db := openDatabase(t)
+	keys := service.NewKeys("pin-secret", "encryption-key")
+	require.NoError(t, service.NewKeyService(db, keys.Signing, keys.Secrets).Load())
+
+	fake := squarefake.NewServer()
+	t.Cleanup(fake.Close)
+
+	cfg := config.Default()
+	cfg.Server.BaseURL = "http://pos.test"
+	cfg.Square.Environment = "fake"
+	cfg.Square.BaseURL = fake.URL
+	cfg.Mail.Driver = "file"
+	cfg.Mail.Dir = mailDir
+	for _, change := range configure {
+		change(cfg)
+	}
+
+	router := gin.New()
+	routes.SetupRoutes(router, db, cfg, keys)
+	health := service.NewHealthService(db)
+	routes.SetupHealthRoutes(router, health)
+
+	return &App{t: t, Router: router, DB: db, Square: fake, Health: health, MailDir: mailDir}
+}
+
+// openDatabase returns a new, migrated database that is dropped when the test ends. It is a
+// SQLite file unless TEST_DB_DSN names a MySQL or Postgres server to create it on.
+func openDatabase(t *testing.T) *gorm.DB {
+	t.Helper()
+	dsn := os.Getenv("TEST_DB_DSN")
+	if dsn == "" {
+		dsn = "sqlite://" + filepath.Join(t.TempDir(), "pos.db")
+	} else {
+		dsn = createDatabase(t, dsn)
+	}
+
+	db, err := config.OpenDB(dsn)
+	require.NoError(t, err)
+	db.Logger = logger.Default.LogMode(logger.Silent)
+	sqlDB, err := db.DB()
+	require.NoError(t, err)
+	t.Cleanup(func() { sqlDB.Close() })
+
+	fsys, err := migrations.For(db.Dialector.Name())
+	require.NoError(t, err)
+	migrator, err := migrate.New(db, fsys)
+	require.NoError(t, err)
+	_, err = migrator.Up()
+	require.NoError(t, err)
+	return db
+}
+
+// createDatabase creates a database with a unique name on the server of dsn and returns
+// the DSN that connects to it
+func createDatabase(t *testing.T, dsn string) string {
+	t.Helper()
+	server, err := config.OpenDB(dsn)
+	require.NoError(t, err)
+	sqlDB, err := server.DB()
+	require.NoError(t, err)
+
+	name := fmt.Sprintf("pos_test_%d", rand.Int63())
+	require.NoError(t, server.Exec("CREATE DATABASE "+name).Error)
+	t.Cleanup(func() {
+		server.Exec("DROP DATABASE " + name)
+		sqlDB.Close()
+	})
+
+	// Both MySQL DSNs and Postgres URLs name the database after the last slash
+	base, query, _ := strings.Cut(dsn, "?")
+	base = base[:strings.LastIndex(base, "/")+1] + name
+	if query != "" {
+		base += "?" + query
+	}
+	return base
+}
+
+// Do sends a request to the router, with token as the bearer token when it is not empty,
+// and returns the response and its decoded JSON body
+func (a *App) Do(method, path, token string, body interface{}) (*httptest.ResponseRecorder, map[string]interface{}) {
+	a.t.Helper()
+	var reader *bytes.Reader
+	if body != nil {
+		encoded, err := json.Marshal(body)
+		require.NoError(a.t, err)
+		reader = bytes.NewReader(encoded)
+	} else {
+		reader = bytes.NewReader(nil)
+	}
+
+	req := httptest.NewRequest(method, path, reader)
+	req.Header.Set("Content-Type", "application/json")
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	w := httptest.NewRecorder()
+	a.Router.ServeHTTP(w, req)
+
+	var response map[string]interface{}
+	json.Unmarshal(w.Body.Bytes(), &response)
+	return w, response
+}
+
+var verifyLink = regexp.MustCompile(`/verify-email\?token=([^\s"<]+)`)
+
+// VerificationToken returns the token of the last verification email sent to an address
+func (a *App) VerificationToken(email string) string {
+	a.t.Helper()
+	entries, err := os.ReadDir(a.MailDir)
+	require.NoError(a.t, err)
+
+	var token string
+	for _, entry := range entries {
+		content, err := os.ReadFile(filepath.Join(a.MailDir, entry.Name()))
+		require.NoError(a.t, err)
+		if !strings.Contains(string(content), "To: "+email+"\r\n") {
+			continue
+		}
+		if match := verifyLink.FindStringSubmatch(string(content)); match != nil {
+			token, err = url.QueryUnescape(match[1])
+			require.NoError(a.t, err)
+		}
+	}
+	require.NotEmpty(a.t, token, "no verification email sent to %s", email)
+	return token
+}
+
+// RegisterRestaurant registers a restaurant with its own Square merchant, verifies the
+// admin's email and logs the admin in
+func (a *App) RegisterRestaurant(name string) *Tenant {
+	a.t.Helper()
+	slug := strings.ToLower(strings.ReplaceAll(name, " ", "-"))
+	tenant := &Tenant{
+		SquareToken: "token-" + slug,
+		Email:       "admin@" + slug + ".test",
+		Username:    "admin-" + slug,
+		Password:    "correct-horse",
+	}
+
+	w, response := a.Do(http.MethodPost, "/api/v1/register-restaurant", "", map[string]interface{}{
+		"name":           name,
+		"square_app_id":  "app-" + slug,
+		"square_token":   tenant.SquareToken,
+		"admin_email":    tenant.Email,
+		"admin_password": tenant.Password,
+		"username":       tenant.Username,
+	})
+	require.Equal(a.t, http.StatusCreated, w.Code, w.Body.String())
+	restaurant := response["restaurant"].(map[string]interface{})
+	tenant.RestaurantID = uint(restaurant["id"].(float64))
+	tenant.LocationID = restaurant["location_id"].(string)
+
+	w, _ = a.Do(http.MethodPost, "/api/v1/auth/verify-email", "", map[string]string{
+		"token": a.VerificationToken(tenant.Email),
+	})
+	require.Equal(a.t, http.StatusOK, w.Code, w.Body.String())
+
+	tenant.Token = a.Login(tenant)
+	return tenant
+}
+
+// Login logs a tenant's admin in and returns the access token
+func (a *App) Login(tenant *Tenant) string {
+	a.t.Helper()
+	w, response := a.Do(http.MethodPost, "/api/v1/login", "", map[string]interface{}{
+		"restaurant_id": tenant.RestaurantID,
+		"username":      tenant.Username,
+		"password":      tenant.Password,
+		"email":         tenant.Email,
+	})
+	require.Equal(a.t, http.StatusOK, w.Code, w.Body.String())
+	return response["token"].(string)
+}
@@ -0,0 +1,70 @@
+package integration
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"square-pos-integration/internal/config"
+)
+
+func TestProbes(t *testing.T) {
+	app := NewApp(t)
+
+	w, response := app.Do(http.MethodGet, "/healthz", "", nil)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "ok", response["status"])
+
+	w, response = app.Do(http.MethodGet, "/readyz", "", nil)
+	assert.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	assert.Equal(t, map[string]interface{}{"database": "ok", "migrations": "ok"}, response["checks"])
+
+	// Once shutdown starts the instance is taken out of rotation but stays alive
+	app.Health.Drain()
+	w, response = app.Do(http.MethodGet, "/readyz", "", nil)
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+	assert.Equal(t, "unavailable", response["status"])
+	w, _ = app.Do(http.MethodGet, "/healthz", "", nil)
+	assert.Equal(t, http.StatusOK, w.Code)
+}
+
+func TestMetricsAreOffByDefault(t *testing.T) {
+	app := NewApp(t)
+
+	w, _ := app.Do(http.MethodGet, "/metrics", "", nil)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
+
+func TestMetrics(t *testing.T) {
+	app := NewApp(t, func(cfg *config.Config) {
+		cfg.Metrics.Enabled = true
+		cfg.Metrics.Token = "scrape-token"
+	})
+	tenant := app.RegisterRestaurant("Harbor Grill")
+	orderID := createOrder(t, app, tenant)
+	paymentID := createPaymentIntent(t, app, tenant, orderID, 26)
+	w, _ := app.Do(http.MethodPost, "/api/v1/payment/complete", tenant.Token, map[string]interface{}{
+		"billAmount": 26,
+		"tipAmount":  5,
+		"paymentId":  paymentID,
+	})
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+	w, _ = app.Do(http.MethodGet, "/metrics", "", nil)
+	require.Equal(t, http.StatusUnauthorized, w.Code)
+	w, _ = app.Do(http.MethodGet, "/metrics", "scrape-token", nil)
+	require.Equal(t, http.StatusOK, w.Code)
+	body := w.Body.String()
+
+	// Counters are shared by the tests of this package, so only the series are checked
+	restaurant := fmt.Sprintf(`{restaurant="%d"}`, tenant.RestaurantID)
+	assert.Contains(t, body, "pos_orders_created_total"+restaurant)
+	assert.Contains(t, body, "pos_payments_completed_total"+restaurant)
+	assert.Contains(t, body, "pos_tips_cents_total"+restaurant)
+	assert.Contains(t, body, `http_request_duration_seconds_count{method="POST",route="/api/v1/payment/complete",status="200"}`)
+	assert.Contains(t, body, `square_requests_total{category="ok",endpoint="POST /v2/payments/{id}/complete"}`)
+	assert.Contains(t, body, `db_query_duration_seconds_count{operation="create",table="orders"}`)
+}
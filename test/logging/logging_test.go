@@ -0,0 +1,120 @@
+package logging
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"square-pos-integration/internal/logging"
+	"square-pos-integration/internal/middleware"
+)
+
+// decode returns the JSON log records written to buf
+func decode(t *testing.T, buf *bytes.Buffer) []map[string]any {
+	var records []map[string]any
+	for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+		if line == "" {
+			continue
+		}
+		var record map[string]any
+		require.NoError(t, json.Unmarshal([]byte(line), &record))
+		records = append(records, record)
+	}
+	return records
+}
+
+func TestNewRejectsUnknownLevelAndFormat(t *testing.T) {
+	_, err := logging.New("verbose", "text", &bytes.Buffer{})
+	assert.Error(t, err)
+	_, err = logging.New("info", "xml", &bytes.Buffer{})
+	assert.Error(t, err)
+}
+
+func TestNewLogsFromLevel(t *testing.T) {
+	var buf bytes.Buffer
+	logger, err := logging.New("warn", "json", &buf)
+	require.NoError(t, err)
+
+	logger.Info("skipped")
+	logger.Warn("kept")
+
+	records := decode(t, &buf)
+	require.Len(t, records, 1)
+	assert.Equal(t, "kept", records[0]["msg"])
+}
+
+func TestRedactsSecretsEmailsAndCardSources(t *testing.T) {
+	var buf bytes.Buffer
+	logger, err := logging.New("debug", "json", &buf)
+	require.NoError(t, err)
+
+	logger.Info("Login for owner@example.com",
+		"password", "hunter2",
+		"access_token", "EAAAabcdefghijklmnop",
+		"source_id", "cnon:card-nonce-ok",
+		"pin", "1234",
+		"error_code", "INVALID_CREDENTIALS",
+		"email", "owner@example.com",
+		"error", errors.New("Square rejected cnon:abc123 with Bearer EAAAabcdefghijklmnop"),
+	)
+
+	records := decode(t, &buf)
+	require.Len(t, records, 1)
+	record := records[0]
+	assert.Equal(t, "Login for o***@example.com", record["msg"])
+	assert.Equal(t, logging.Redacted, record["password"])
+	assert.Equal(t, logging.Redacted, record["access_token"])
+	assert.Equal(t, logging.Redacted, record["source_id"])
+	assert.Equal(t, logging.Redacted, record["pin"])
+	assert.Equal(t, "INVALID_CREDENTIALS", record["error_code"])
+	assert.Equal(t, "o***@example.com", record["email"])
+	assert.Equal(t, "Square rejected [REDACTED] with [REDACTED]", record["error"])
+}
+
+func TestRequestIDIsKeptOrGeneratedAndLogged(t *testing.T) {
+	var buf bytes.Buffer
+	logger, err := logging.New("info", "json", &buf)
+	require.NoError(t, err)
+	previous := slog.Default()
+	slog.SetDefault(logger)
+	defer slog.SetDefault(previous)
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(middleware.RequestID(), middleware.RequestLogger())
+	router.GET("/orders/:id", func(c *gin.Context) {
+		assert.Equal(t, c.Writer.Header().Get(middleware.RequestIDHeader), logging.RequestID(c.Request.Context()))
+		c.Status(http.StatusNoContent)
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/orders/7", nil)
+	req.Header.Set(middleware.RequestIDHeader, "client-id-1")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, "client-id-1", w.Header().Get(middleware.RequestIDHeader))
+
+	// IDs that could forge log lines are replaced
+	req = httptest.NewRequest(http.MethodGet, "/orders/7", nil)
+	req.Header.Set(middleware.RequestIDHeader, "bad id\nlevel=ERROR")
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	generated := w.Header().Get(middleware.RequestIDHeader)
+	assert.NotEmpty(t, generated)
+	assert.NotContains(t, generated, " ")
+
+	records := decode(t, &buf)
+	require.Len(t, records, 2)
+	assert.Equal(t, "client-id-1", records[0]["request_id"])
+	assert.Equal(t, "/orders/:id", records[0]["route"])
+	assert.Equal(t, float64(http.StatusNoContent), records[0]["status"])
+	assert.Equal(t, generated, records[1]["request_id"])
+}
@@ -0,0 +1,35 @@
+package mailer
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"square-pos-integration/internal/mailer"
+)
+
+func TestFileMailer_WritesMessage(t *testing.T) {
+	dir := t.TempDir()
+	m := &mailer.FileMailer{Dir: dir, From: "no-reply@example.com"}
+
+	err := m.Send(mailer.Message{
+		To:      "owner@example.com",
+		Subject: "Reset your password\r\nBcc: attacker@example.com",
+		Body:    "Reset it here",
+	})
+	assert.NoError(t, err)
+
+	files, _ := filepath.Glob(filepath.Join(dir, "*-owner@example.com.eml"))
+	assert.Len(t, files, 1)
+
+	content, err := os.ReadFile(files[0])
+	assert.NoError(t, err)
+	assert.Contains(t, string(content), "From: no-reply@example.com\r\n")
+	assert.Contains(t, string(content), "To: owner@example.com\r\n")
+	assert.Contains(t, string(content), "Reset it here")
+	// Line breaks in header values cannot add headers
+	assert.False(t, strings.Contains(string(content), "\r\nBcc:"))
+}
@@ -0,0 +1,257 @@
+package mappers
+
+import (
+	"encoding/json"
+	"flag"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	square "github.com/square/square-go-sdk/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/datatypes"
+	"gorm.io/gorm"
+
+	"square-pos-integration/internal/mappers"
+	"square-pos-integration/internal/models"
+)
+
+// Run `go test ./test/mappers -update` after an intentional change to the JSON contract
+var update = flag.Bool("update", false, "rewrite golden files")
+
+var fixedTime = time.Date(2025, 6, 1, 18, 30, 0, 0, time.UTC)
+
+// assertGolden compares the JSON encoding of value with testdata/<name>.golden.json
+func assertGolden(t *testing.T, name string, value interface{}) {
+	t.Helper()
+
+	actual, err := json.MarshalIndent(value, "", "  ")
+	require.NoError(t, err)
+	actual = append(actual, '\n')
+
+	path := filepath.Join("testdata", name+".golden.json")
+	if *update {
+		require.NoError(t, os.MkdirAll("testdata", 0o755))
+		require.NoError(t, os.WriteFile(path, actual, 0o644))
+	}
+
+	expected, err := os.ReadFile(path)
+	require.NoError(t, err, "missing golden file, run with -update to create it")
+	assert.Equal(t, string(expected), string(actual))
+}
+
+func sampleRestaurant() models.Restaurant {
+	restaurant := models.Restaurant{
+		Name:        "Harbor Grill",
+		SquareAppID: "sq0idp-app",
+		SquareToken: "EAAA-secret-token",
+		MerchantID:  "MERCHANT1",
+		LocationID:  "LOCATION1",
+	}
+	restaurant.ID = 3
+	return restaurant
+}
+
+func sampleUser() models.User {
+	user := models.User{
+		Username:     "jane",
+		Email:        "jane@example.com",
+		PasswordHash: "$2a$10$hash",
+		RestaurantID: 3,
+		Role:         "manager",
+		IsActive:     true,
+		Restaurant:   sampleRestaurant(),
+	}
+	user.ID = 7
+	return user
+}
+
+func sampleOrder() models.Order {
+	return models.Order{
+		Model:         &gorm.Model{ID: 42, CreatedAt: fixedTime, UpdatedAt: fixedTime},
+		RestaurantID:  3,
+		SquareOrderID: "SQ-ORDER-1",
+		TableNumber:   12,
+		OpenedAt:      fixedTime,
+		Status:        "paid",
+		UserID:        7,
+		TotalAmount:   2862,
+		Currency:      "USD",
+		LocationID:    "LOCATION1",
+		RawSquareData: datatypes.JSON(`{"id":"SQ-ORDER-1"}`),
+		Totals: models.OrderTotals{
+			Discounts:     200,
+			Due:           0,
+			Tax:           212,
+			ServiceCharge: 450,
+			Paid:          2862,
+			Tips:          500,
+			Total:         2862,
+		},
+		Restaurant: sampleRestaurant(),
+		Items: []models.OrderItem{
+			{
+				Model:     &gorm.Model{ID: 1},
+				OrderID:   "42",
+				Name:      "Burger",
+				Comment:   "No onions",
+				UnitPrice: 1200,
+				Quantity:  2,
+				Amount:    2200,
+				Discounts: []models.OrderItemDiscount{
+					{Name: "Happy hour", Value: 200, Amount: 200},
+				},
+				Modifiers: []models.OrderItemModifier{
+					{Name: "Extra cheese", UnitPrice: 100, Quantity: 2, Amount: 200},
+				},
+			},
+		},
+	}
+}
+
+func sampleSquareOrder() *square.Order {
+	money := func(amount int64) *square.Money {
+		return &square.Money{Amount: square.Int64(amount), Currency: square.Currency("USD").Ptr()}
+	}
+
+	return &square.Order{
+		ID:         square.String("SQ-ORDER-1"),
+		LocationID: "LOCATION1",
+		LineItems: []*square.OrderLineItem{
+			{
+				Name:           square.String("Burger"),
+				Note:           square.String("No onions"),
+				Quantity:       "2",
+				BasePriceMoney: money(1200),
+				TotalMoney:     money(2200),
+				Modifiers: []*square.OrderLineItemModifier{
+					{Name: square.String("Extra cheese"), BasePriceMoney: money(100), TotalPriceMoney: money(200)},
+				},
+				AppliedDiscounts: []*square.OrderLineItemAppliedDiscount{
+					{DiscountUID: "discount-1", AppliedMoney: money(200)},
+				},
+			},
+		},
+		Discounts: []*square.OrderLineItemDiscount{
+			{
+				UID:         square.String("discount-1"),
+				Name:        square.String("Happy hour"),
+				AmountMoney: money(200),
+				Type:        square.OrderLineItemDiscountType("FIXED_AMOUNT").Ptr(),
+			},
+		},
+		TotalMoney:              money(2862),
+		TotalTaxMoney:           money(212),
+		TotalDiscountMoney:      money(200),
+		TotalServiceChargeMoney: money(450),
+	}
+}
+
+func samplePayment() models.Payment {
+	return models.Payment{
+		Model:           &gorm.Model{ID: 9},
+		OrderID:         "42",
+		RestaurantID:    3,
+		BillAmount:      2862,
+		TipAmount:       500,
+		TotalAmount:     3362,
+		RefundedAmount:  1000,
+		Status:          "COMPLETED",
+		PaymentMethod:   "card",
+		ProcessedAt:     fixedTime,
+		RawSquareData:   datatypes.JSON(`{"id":"SQ-PAY-1"}`),
+		SquarePaymentID: "SQ-PAY-1",
+		Restaurant:      sampleRestaurant(),
+	}
+}
+
+func TestOrderResponse(t *testing.T) {
+	assertGolden(t, "order", mappers.ToOrderResponse(sampleOrder()))
+}
+
+func TestOrderResponseFromSquare(t *testing.T) {
+	assertGolden(t, "order_from_square", mappers.ToOrderResponseFromSquare(sampleOrder(), sampleSquareOrder()))
+}
+
+func TestOrderListResponse(t *testing.T) {
+	empty := sampleOrder()
+	empty.Model = &gorm.Model{ID: 43}
+	empty.Status = "pending"
+	empty.Items = nil
+	empty.Totals = models.OrderTotals{}
+
+	assertGolden(t, "order_list", mappers.ToOrderListResponse([]models.Order{sampleOrder(), empty}))
+}
+
+func TestPaymentResponse(t *testing.T) {
+	assertGolden(t, "payment", mappers.ToPaymentResponse(samplePayment(), "Payment intent created on Square, ready for processing"))
+}
+
+func TestRefundResponse(t *testing.T) {
+	refund := &square.PaymentRefund{
+		ID:          "SQ-REFUND-1",
+		Status:      square.String("PENDING"),
+		AmountMoney: &square.Money{Amount: square.Int64(1000), Currency: square.Currency("USD").Ptr()},
+	}
+
+	assertGolden(t, "refund", mappers.ToRefundResponse(refund, samplePayment(), sampleOrder(), "Refund submitted to Square"))
+}
+
+func TestLoginResponse(t *testing.T) {
+	memberships := []models.Membership{
+		{UserID: 7, RestaurantID: 3, Role: "manager", IsActive: true, Restaurant: sampleRestaurant()},
+		{UserID: 7, RestaurantID: 5, Role: "admin", LocationIDs: "LOCATION2,LOCATION3", IsActive: true, Restaurant: models.Restaurant{Name: "Harbor Grill Uptown"}},
+	}
+
+	assertGolden(t, "login", mappers.ToLoginResponse("jwt-token", "refresh-token", sampleUser(), memberships, fixedTime.Add(15*time.Minute)))
+}
+
+func TestProfileResponse(t *testing.T) {
+	assertGolden(t, "profile", mappers.ToProfileResponse(sampleUser()))
+}
+
+func TestRestaurantRegistrationResponse(t *testing.T) {
+	assertGolden(t, "restaurant_registration", mappers.ToRestaurantRegistrationResponse(sampleRestaurant(), sampleUser(), "Restaurant registered successfully"))
+}
+
+func TestTaxRuleListResponse(t *testing.T) {
+	rule := models.TaxRule{
+		RestaurantID:          3,
+		Name:                  "State sales tax",
+		Percentage:            "8.875",
+		InclusionType:         "ADDITIVE",
+		Enabled:               true,
+		SquareCatalogObjectID: "TAX-1",
+		SquareCatalogVersion:  1700000000000,
+		Restaurant:            sampleRestaurant(),
+	}
+	rule.ID = 5
+
+	assertGolden(t, "tax_rules", mappers.ToTaxRuleListResponse([]models.TaxRule{rule}))
+}
+
+func TestServiceChargeListResponse(t *testing.T) {
+	gratuity := models.ServiceChargeRule{
+		RestaurantID:     3,
+		Name:             "Large party gratuity",
+		Percentage:       "18",
+		CalculationPhase: "SUBTOTAL_PHASE",
+		Enabled:          true,
+		MinGuestCount:    6,
+	}
+	gratuity.ID = 1
+	delivery := models.ServiceChargeRule{
+		RestaurantID:     3,
+		LocationID:       "LOCATION1",
+		Name:             "Delivery fee",
+		Amount:           499,
+		CalculationPhase: "TOTAL_PHASE",
+		Enabled:          true,
+		OrderType:        "delivery",
+	}
+	delivery.ID = 2
+
+	assertGolden(t, "service_charges", mappers.ToServiceChargeListResponse([]models.ServiceChargeRule{gratuity, delivery}))
+}
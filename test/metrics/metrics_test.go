@@ -0,0 +1,97 @@
+package metrics
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"square-pos-integration/internal/apperrors"
+	"square-pos-integration/internal/metrics"
+	"square-pos-integration/internal/middleware"
+)
+
+// scrape returns the metrics as Prometheus serves them
+func scrape(t *testing.T) string {
+	t.Helper()
+	w := httptest.NewRecorder()
+	metrics.Handler().ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	require.Equal(t, http.StatusOK, w.Code)
+	return w.Body.String()
+}
+
+func TestSquareEndpointReplacesIDs(t *testing.T) {
+	assert.Equal(t, "POST /v2/payments/{id}/complete", metrics.SquareEndpoint(http.MethodPost, "/v2/payments/PAY0000000001FAKE/complete"))
+	assert.Equal(t, "POST /v2/orders/calculate", metrics.SquareEndpoint(http.MethodPost, "/v2/orders/calculate"))
+	assert.Equal(t, "GET /v2/catalog/object/{id}", metrics.SquareEndpoint(http.MethodGet, "/v2/catalog/object/3XKZ4ABC"))
+	assert.Equal(t, "GET /v2/locations", metrics.SquareEndpoint(http.MethodGet, "/v2/locations"))
+}
+
+func TestSquareCategory(t *testing.T) {
+	cases := map[int]string{
+		http.StatusOK:                  "ok",
+		http.StatusBadRequest:          "invalid_request",
+		http.StatusUnauthorized:        "authentication",
+		http.StatusPaymentRequired:     "payment_method",
+		http.StatusTooManyRequests:     "rate_limit",
+		http.StatusServiceUnavailable:  "api_error",
+		http.StatusNotFound:            "invalid_request",
+		http.StatusInternalServerError: "api_error",
+	}
+	for status, category := range cases {
+		assert.Equal(t, category, metrics.SquareCategory(&http.Response{StatusCode: status}, nil), status)
+	}
+	assert.Equal(t, "timeout", metrics.SquareCategory(nil, context.DeadlineExceeded))
+	assert.Equal(t, "network", metrics.SquareCategory(nil, errors.New("connection refused")))
+}
+
+func TestRestaurantLabelsAreLimited(t *testing.T) {
+	metrics.LimitRestaurants(2)
+	defer metrics.LimitRestaurants(100)
+
+	metrics.OrderCreated(901)
+	metrics.OrderCreated(902)
+	metrics.OrderCreated(903)
+	metrics.OrderCreated(904)
+	metrics.PaymentCompleted(901, 250)
+
+	body := scrape(t)
+	assert.Contains(t, body, `pos_orders_created_total{restaurant="901"} 1`)
+	assert.Contains(t, body, `pos_orders_created_total{restaurant="902"} 1`)
+	assert.Contains(t, body, `pos_orders_created_total{restaurant="other"} 2`)
+	assert.NotContains(t, body, `restaurant="903"`)
+	assert.Contains(t, body, `pos_tips_cents_total{restaurant="901"} 250`)
+}
+
+func TestMiddlewareRecordsRouteAndRenderedStatus(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(middleware.Metrics(), middleware.ErrorHandler())
+	router.GET("/widgets/:id", func(c *gin.Context) {
+		c.Error(apperrors.ErrOrderNotFound)
+	})
+	router.GET("/metrics", middleware.StaticToken("scrape-token"), gin.WrapH(metrics.Handler()))
+
+	router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/widgets/12", nil))
+	router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/no/such/path", nil))
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+
+	req := httptest.NewRequest(http.MethodGet, "/metrics", nil)
+	req.Header.Set("Authorization", "Bearer scrape-token")
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	require.Equal(t, http.StatusOK, w.Code)
+
+	body := w.Body.String()
+	assert.Contains(t, body, `http_request_duration_seconds_count{method="GET",route="/widgets/:id",status="404"} 1`)
+	assert.Contains(t, body, `http_request_duration_seconds_count{method="GET",route="unmatched",status="404"} 1`)
+	assert.NotContains(t, body, "/widgets/12")
+}
@@ -0,0 +1,376 @@
+package migrate
+
+import (
+	"bytes"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/glebarez/sqlite"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+
+	"square-pos-integration/internal/migrate"
+	"square-pos-integration/internal/models"
+	"square-pos-integration/migrations"
+)
+
+func setupDB(t *testing.T) *gorm.DB {
+	db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "test.db")), &gorm.Config{
+		Logger: logger.Default.LogMode(logger.Silent),
+	})
+	require.NoError(t, err)
+	return db
+}
+
+var files = fstest.MapFS{
+	"0001_create_notes.up.sql": {Data: []byte(`-- Notes left on orders
+CREATE TABLE notes (
+  id INTEGER PRIMARY KEY,
+  body TEXT NOT NULL
+);
+INSERT INTO notes (body) VALUES ('first; with a semicolon');
+`)},
+	"0001_create_notes.down.sql": {Data: []byte("DROP TABLE notes;\n")},
+	"0002_add_author.up.sql":     {Data: []byte("ALTER TABLE notes ADD COLUMN author TEXT;\n")},
+	"0002_add_author.down.sql":   {Data: []byte("ALTER TABLE notes DROP COLUMN author;\n")},
+	"0010_create_tags.up.sql":    {Data: []byte("CREATE TABLE tags (id INTEGER PRIMARY KEY);\n")},
+	"0010_create_tags.down.sql":  {Data: []byte("DROP TABLE tags;\n")},
+	"README.md":                  {Data: []byte("not a migration")},
+}
+
+func versions(migrations []migrate.Migration) []int64 {
+	var result []int64
+	for _, m := range migrations {
+		result = append(result, m.Version)
+	}
+	return result
+}
+
+func TestUpAppliesPendingMigrationsInOrder(t *testing.T) {
+	db := setupDB(t)
+	migrator, err := migrate.New(db, files)
+	require.NoError(t, err)
+
+	applied, err := migrator.Up()
+	require.NoError(t, err)
+	assert.Equal(t, []int64{1, 2, 10}, versions(applied))
+	assert.True(t, db.Migrator().HasColumn("notes", "author"))
+	assert.True(t, db.Migrator().HasTable("tags"))
+
+	var body string
+	require.NoError(t, db.Raw("SELECT body FROM notes").Scan(&body).Error)
+	assert.Equal(t, "first; with a semicolon", body)
+
+	// Nothing is left to apply
+	applied, err = migrator.Up()
+	require.NoError(t, err)
+	assert.Empty(t, applied)
+	pending, err := migrator.Pending()
+	require.NoError(t, err)
+	assert.Empty(t, pending)
+}
+
+func TestDownRevertsNewestFirst(t *testing.T) {
+	db := setupDB(t)
+	migrator, err := migrate.New(db, files)
+	require.NoError(t, err)
+	_, err = migrator.Up()
+	require.NoError(t, err)
+
+	reverted, err := migrator.Down(2)
+	require.NoError(t, err)
+	assert.Equal(t, []int64{10, 2}, versions(reverted))
+	assert.False(t, db.Migrator().HasTable("tags"))
+	assert.False(t, db.Migrator().HasColumn("notes", "author"))
+	assert.True(t, db.Migrator().HasTable("notes"))
+
+	pending, err := migrator.Pending()
+	require.NoError(t, err)
+	assert.Equal(t, []int64{2, 10}, versions(pending))
+
+	// Asking for more steps than are applied reverts what there is
+	reverted, err = migrator.Down(5)
+	require.NoError(t, err)
+	assert.Equal(t, []int64{1}, versions(reverted))
+	assert.False(t, db.Migrator().HasTable("notes"))
+}
+
+func TestFailedMigrationIsRolledBackAndNotRecorded(t *testing.T) {
+	db := setupDB(t)
+	broken := fstest.MapFS{
+		"0001_create_notes.up.sql": files["0001_create_notes.up.sql"],
+		"0002_broken.up.sql":       {Data: []byte("CREATE TABLE tags (id INTEGER PRIMARY KEY);\nINSERT INTO missing VALUES (1);\n")},
+	}
+	migrator, err := migrate.New(db, broken)
+	require.NoError(t, err)
+
+	applied, err := migrator.Up()
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "0002_broken")
+	assert.Equal(t, []int64{1}, versions(applied))
+	assert.False(t, db.Migrator().HasTable("tags"))
+
+	pending, err := migrator.Pending()
+	require.NoError(t, err)
+	assert.Equal(t, []int64{2}, versions(pending))
+}
+
+func TestStatus(t *testing.T) {
+	db := setupDB(t)
+	migrator, err := migrate.New(db, files)
+	require.NoError(t, err)
+	migrator.Migrations = migrator.Migrations[:1]
+	_, err = migrator.Up()
+	require.NoError(t, err)
+
+	// A newer build knows more migrations, an older one fewer than are applied
+	migrator, err = migrate.New(db, fstest.MapFS{
+		"0002_add_author.up.sql": files["0002_add_author.up.sql"],
+	})
+	require.NoError(t, err)
+	statuses, err := migrator.Status()
+	require.NoError(t, err)
+
+	require.Len(t, statuses, 2)
+	assert.Equal(t, int64(1), statuses[0].Version)
+	assert.Equal(t, "create_notes", statuses[0].Name)
+	assert.NotNil(t, statuses[0].AppliedAt)
+	assert.True(t, statuses[0].Missing)
+	assert.Equal(t, int64(2), statuses[1].Version)
+	assert.Nil(t, statuses[1].AppliedAt)
+	assert.False(t, statuses[1].Missing)
+}
+
+func TestLoadRejectsInvalidFiles(t *testing.T) {
+	tests := []struct {
+		name  string
+		files fstest.MapFS
+		err   string
+	}{
+		{"bad name", fstest.MapFS{"create_notes.sql": {}}, "is not named"},
+		{"no up file", fstest.MapFS{"0001_create_notes.down.sql": {}}, "has no up file"},
+		{"duplicate version", fstest.MapFS{
+			"0001_create_notes.up.sql": {},
+			"0001_create_tags.up.sql":  {},
+		}, "is used by both"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := migrate.Load(tt.files)
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), tt.err)
+		})
+	}
+}
+
+func TestCreateNumbersAfterNewest(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "0007_create_notes.up.sql"), nil, 0o644))
+
+	paths, err := migrate.Create(dir, "Add Order Notes")
+	require.NoError(t, err)
+	assert.Equal(t, []string{
+		filepath.Join(dir, "0008_add_order_notes.up.sql"),
+		filepath.Join(dir, "0008_add_order_notes.down.sql"),
+	}, paths)
+
+	loaded, err := migrate.Load(os.DirFS(dir))
+	require.NoError(t, err)
+	assert.Equal(t, []int64{7, 8}, versions(loaded))
+}
+
+func TestCreateAddsToEveryDatabase(t *testing.T) {
+	dir := t.TempDir()
+	for _, dialect := range []string{"mysql", "sqlite"} {
+		require.NoError(t, os.Mkdir(filepath.Join(dir, dialect), 0o755))
+	}
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "mysql", "0003_create_notes.up.sql"), nil, 0o644))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "sqlite", "0002_create_notes.up.sql"), nil, 0o644))
+
+	paths, err := migrate.Create(dir, "add_tags")
+	require.NoError(t, err)
+	assert.Equal(t, []string{
+		filepath.Join(dir, "mysql", "0004_add_tags.up.sql"),
+		filepath.Join(dir, "mysql", "0004_add_tags.down.sql"),
+		filepath.Join(dir, "sqlite", "0004_add_tags.up.sql"),
+		filepath.Join(dir, "sqlite", "0004_add_tags.down.sql"),
+	}, paths)
+}
+
+func TestRunCommands(t *testing.T) {
+	db := setupDB(t)
+	open := func() (*gorm.DB, error) { return db, nil }
+	source := func(dialect string) (fs.FS, error) {
+		assert.Equal(t, "sqlite", dialect)
+		return files, nil
+	}
+	run := func(args ...string) string {
+		var out bytes.Buffer
+		require.NoError(t, migrate.Run(args, source, open, &out))
+		return out.String()
+	}
+
+	assert.Contains(t, run("status"), "0001     create_notes  pending\n")
+	assert.Equal(t, "Applied 0001_create_notes\nApplied 0002_add_author\nApplied 0010_create_tags\n", run("up"))
+	assert.Equal(t, "No pending migrations\n", run("up"))
+	assert.Equal(t, "Reverted 0010_create_tags\n", run("down"))
+
+	status := run("status")
+	assert.NotContains(t, status, "create_notes  pending")
+	assert.Contains(t, status, "0010     create_tags   pending\n")
+
+	err := migrate.Run([]string{"sideways"}, source, open, &bytes.Buffer{})
+	assert.EqualError(t, err, `unknown command "sideways"`)
+}
+
+// Every database has the same migration versions, numbered without gaps, and each can be
+// reverted
+func TestShippedMigrations(t *testing.T) {
+	var expected []string
+	for _, dialect := range migrations.Dialects {
+		fsys, err := migrations.For(dialect)
+		require.NoError(t, err)
+		loaded, err := migrate.Load(fsys)
+		require.NoError(t, err)
+		require.NotEmpty(t, loaded, dialect)
+
+		var names []string
+		for i, migration := range loaded {
+			assert.Equal(t, int64(i+1), migration.Version, "%s/%s", dialect, migration)
+			assert.NotEmpty(t, strings.TrimSpace(migration.Down), "%s/%s has no down file", dialect, migration)
+			names = append(names, migration.String())
+		}
+		if expected == nil {
+			expected = names
+		}
+		assert.Equal(t, expected, names, dialect)
+	}
+}
+
+// assertModelColumns checks that the database has a column for every field of every model
+func assertModelColumns(t *testing.T, db *gorm.DB) {
+	t.Helper()
+	for _, model := range []interface{}{
+		&models.Restaurant{}, &models.User{}, &models.Order{}, &models.OrderItem{},
+		&models.OrderItemDiscount{}, &models.OrderItemModifier{}, &models.Payment{},
+		&models.TaxRule{}, &models.ServiceChargeRule{}, &models.RefreshToken{},
+		&models.RevokedToken{}, &models.Device{}, &models.Role{}, &models.RolePermission{},
+		&models.UserToken{}, &models.LoginAttempt{}, &models.AuditLog{}, &models.RecoveryCode{},
+		&models.SigningKey{}, &models.Membership{}, &models.Location{}, &models.Table{},
+	} {
+		stmt := &gorm.Statement{DB: db}
+		require.NoError(t, stmt.Parse(model))
+		require.True(t, db.Migrator().HasTable(stmt.Schema.Table), stmt.Schema.Table)
+		for _, field := range stmt.Schema.Fields {
+			if field.DBName != "" {
+				assert.True(t, db.Migrator().HasColumn(model, field.DBName), "%s.%s", stmt.Schema.Table, field.DBName)
+			}
+		}
+	}
+}
+
+// The SQLite migrations create a column for every field of every model, and revert cleanly
+func TestSQLiteMigrationsMatchModels(t *testing.T) {
+	db := setupDB(t)
+	fsys, err := migrations.For("sqlite")
+	require.NoError(t, err)
+	migrator, err := migrate.New(db, fsys)
+	require.NoError(t, err)
+	_, err = migrator.Up()
+	require.NoError(t, err)
+
+	assertModelColumns(t, db)
+
+	_, err = migrator.Down(len(migrator.Migrations))
+	require.NoError(t, err)
+	var tables []string
+	require.NoError(t, db.Raw("SELECT name FROM sqlite_master WHERE type = 'table' AND name NOT IN ('schema_migrations', 'sqlite_sequence')").Scan(&tables).Error)
+	assert.Empty(t, tables)
+}
+
+// The restaurants, users and payments tables as AutoMigrate created them before migrations
+// existed, without the columns added since
+type baselineRestaurant struct {
+	gorm.Model
+	Name        string `gorm:"not null"`
+	SquareAppID string `gorm:"type:varchar(255);uniqueIndex"`
+	SquareToken string `gorm:"not null"`
+	MerchantID  string `gorm:"not null"`
+	LocationID  string `gorm:"not null"`
+}
+
+func (baselineRestaurant) TableName() string { return "restaurants" }
+
+type baselineUser struct {
+	gorm.Model
+	Username     string `gorm:"not null;uniqueIndex;size:100"`
+	Email        string `gorm:"not null;uniqueIndex;size:255"`
+	PasswordHash string `gorm:"not null;size:255"`
+	RestaurantID uint   `gorm:"not null;index"`
+	Role         string `gorm:"not null;size:50;default:staff"`
+	IsActive     bool   `gorm:"default:true"`
+}
+
+func (baselineUser) TableName() string { return "users" }
+
+type baselinePayment struct {
+	gorm.Model
+	OrderID          string `gorm:"not null;size:255;index"`
+	RestaurantID     uint   `gorm:"not null;index"`
+	BillAmount       int    `gorm:"not null"`
+	TipAmount        int    `gorm:"default:0"`
+	TotalAmount      int    `gorm:"not null"`
+	Status           string `gorm:"default:pending;size:100"`
+	PaymentMethod    string `gorm:"size:50"`
+	ProcessedAt      time.Time
+	RawSquareData    string `gorm:"type:json"`
+	SquarePaymentID  string `gorm:"size:255"`
+	SquareLocationID string `gorm:"size:255"`
+	Currency         string `gorm:"default:USD;size:10"`
+	TransactionFee   int    `gorm:"default:0"`
+	NetAmount        int    `gorm:"default:0"`
+}
+
+func (baselinePayment) TableName() string { return "payments" }
+
+// A database AutoMigrate created adopts the migrations, gets the columns and tables added
+// since, and has its users and restaurants backfilled
+func TestSQLiteMigrationsUpgradeAutoMigrateSchema(t *testing.T) {
+	db := setupDB(t)
+	require.NoError(t, db.AutoMigrate(&baselineRestaurant{}, &baselineUser{}, &baselinePayment{}))
+	restaurant := baselineRestaurant{Name: "Corner Bistro", SquareToken: "token", MerchantID: "M1", LocationID: "L1"}
+	require.NoError(t, db.Create(&restaurant).Error)
+	user := baselineUser{Username: "owner", Email: "owner@example.com", PasswordHash: "hash", RestaurantID: restaurant.ID, Role: "admin", IsActive: true}
+	require.NoError(t, db.Create(&user).Error)
+
+	fsys, err := migrations.For("sqlite")
+	require.NoError(t, err)
+	migrator, err := migrate.New(db, fsys)
+	require.NoError(t, err)
+	_, err = migrator.Up()
+	require.NoError(t, err)
+
+	assertModelColumns(t, db)
+
+	var upgraded models.User
+	require.NoError(t, db.First(&upgraded, user.ID).Error)
+	assert.Equal(t, 0, upgraded.TokenVersion)
+	assert.Nil(t, upgraded.PinHash)
+	var membership models.Membership
+	require.NoError(t, db.Where("user_id = ? AND restaurant_id = ?", user.ID, restaurant.ID).First(&membership).Error)
+	assert.Equal(t, "admin", membership.Role)
+	var location models.Location
+	require.NoError(t, db.Where("restaurant_id = ?", restaurant.ID).First(&location).Error)
+	assert.Equal(t, "L1", location.SquareLocationID)
+
+	// Custom role names fit the role column
+	shiftLead := models.User{Username: "lead", Email: "lead@example.com", PasswordHash: "hash", RestaurantID: restaurant.ID, Role: "shift_lead", IsActive: true}
+	require.NoError(t, db.Create(&shiftLead).Error)
+}
@@ -0,0 +1,96 @@
+package server
+
+import (
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"square-pos-integration/internal/server"
+)
+
+// start serves handler on a free port until the returned cancel is called, and returns the
+// server's address and the result of Serve
+func start(t *testing.T, srv *server.Server) (string, context.CancelFunc, <-chan error) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- srv.Serve(ctx, listener) }()
+	t.Cleanup(cancel)
+	return "http://" + listener.Addr().String(), cancel, done
+}
+
+func TestShutdownDrainsRequestsAndStopsWorkers(t *testing.T) {
+	started := make(chan struct{})
+	srv := &server.Server{
+		HTTP: &http.Server{Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			close(started)
+			time.Sleep(200 * time.Millisecond)
+			w.Write([]byte("paid"))
+		})},
+		ShutdownTimeout: 5 * time.Second,
+	}
+	var shutdownCalled, workerStopped bool
+	srv.OnShutdown = func() { shutdownCalled = true }
+	srv.Workers = []server.Worker{func(stop <-chan struct{}) {
+		<-stop
+		// Work in progress finishes before the worker returns
+		time.Sleep(50 * time.Millisecond)
+		workerStopped = true
+	}}
+	url, cancel, done := start(t, srv)
+
+	response := make(chan string, 1)
+	go func() {
+		resp, err := http.Get(url)
+		if err != nil {
+			response <- err.Error()
+			return
+		}
+		defer resp.Body.Close()
+		body, _ := io.ReadAll(resp.Body)
+		response <- string(body)
+	}()
+	<-started
+	cancel()
+
+	// The running request completes, then Serve returns
+	assert.Equal(t, "paid", <-response)
+	require.NoError(t, <-done)
+	assert.True(t, shutdownCalled)
+	assert.True(t, workerStopped)
+
+	// New connections are refused
+	_, err := http.Get(url)
+	assert.Error(t, err)
+}
+
+func TestShutdownTimesOut(t *testing.T) {
+	release := make(chan struct{})
+	started := make(chan struct{})
+	srv := &server.Server{
+		HTTP: &http.Server{Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			close(started)
+			<-release
+		})},
+		ShutdownTimeout: 100 * time.Millisecond,
+		Workers:         []server.Worker{func(stop <-chan struct{}) { <-release }},
+	}
+	defer close(release)
+	url, cancel, done := start(t, srv)
+
+	go http.Get(url)
+	<-started
+	cancel()
+
+	err := <-done
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "requests still running after 100ms")
+	assert.Contains(t, err.Error(), "background workers still running after 100ms")
+}
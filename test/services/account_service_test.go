@@ -0,0 +1,132 @@
+package services
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
+
+	"square-pos-integration/internal/apperrors"
+	"square-pos-integration/internal/mailer"
+	"square-pos-integration/internal/service"
+	"square-pos-integration/internal/signing"
+	"square-pos-integration/internal/utils"
+)
+
+// testKeys are the keys the services under test sign tokens, hash PINs and encrypt secrets with
+var testKeys = service.Keys{
+	Signing: signing.NewEphemeralKeyRing(),
+	PINs:    utils.NewPINHasher("pin-secret"),
+	Secrets: utils.NewSecretBox("encryption-key"),
+}
+
+// recordingMailer keeps sent messages so tests can read the links in them
+type recordingMailer struct {
+	sent []mailer.Message
+}
+
+func (m *recordingMailer) Send(message mailer.Message) error {
+	m.sent = append(m.sent, message)
+	return nil
+}
+
+// newAccountService creates an account service whose sessions are signed with testKeys
+func newAccountService(db *gorm.DB, mail *recordingMailer) *service.AccountService {
+	return service.NewAccountService(db, mail, service.NewSessionService(db, testKeys.Signing))
+}
+
+func mockUserTokenQuery(mock sqlmock.Sqlmock, token string, expiresAt time.Time, usedAt *time.Time) {
+	mock.ExpectQuery("^SELECT \\* FROM `user_tokens` WHERE token_hash = \\? AND purpose = \\?").
+		WithArgs(utils.HashToken(token), "password_reset", 1).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "user_id", "purpose", "token_hash", "expires_at", "used_at"}).
+			AddRow(5, 7, "password_reset", utils.HashToken(token), expiresAt, usedAt))
+}
+
+func TestAccountService_ResetPassword(t *testing.T) {
+	t.Run("valid token sets the password and ends sessions", func(t *testing.T) {
+		db, mock := SetupMockDB()
+		mockUserTokenQuery(mock, "reset-token", time.Now().Add(time.Hour), nil)
+		mock.ExpectBegin()
+		mock.ExpectExec("^UPDATE `user_tokens` SET `used_at`=\\? WHERE id = \\? AND used_at IS NULL").
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectCommit()
+		mock.ExpectBegin()
+		mock.ExpectExec("^UPDATE `users` SET `password_hash`=\\?").WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectCommit()
+		mock.ExpectBegin()
+		mock.ExpectExec("^UPDATE `users` SET `token_version`=token_version \\+ 1").WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectExec("^UPDATE `refresh_tokens` SET `revoked_at`=\\?").WillReturnResult(sqlmock.NewResult(0, 2))
+		mock.ExpectCommit()
+
+		err := newAccountService(db, &recordingMailer{}).ResetPassword("reset-token", "new-password")
+		assert.NoError(t, err)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("used token is rejected", func(t *testing.T) {
+		db, mock := SetupMockDB()
+		usedAt := time.Now().Add(-time.Minute)
+		mockUserTokenQuery(mock, "reset-token", time.Now().Add(time.Hour), &usedAt)
+
+		err := newAccountService(db, &recordingMailer{}).ResetPassword("reset-token", "new-password")
+		assert.ErrorIs(t, err, apperrors.ErrInvalidResetToken)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("expired token is rejected", func(t *testing.T) {
+		db, mock := SetupMockDB()
+		mockUserTokenQuery(mock, "reset-token", time.Now().Add(-time.Minute), nil)
+
+		err := newAccountService(db, &recordingMailer{}).ResetPassword("reset-token", "new-password")
+		assert.ErrorIs(t, err, apperrors.ErrInvalidResetToken)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("token used by a concurrent request is rejected", func(t *testing.T) {
+		db, mock := SetupMockDB()
+		mockUserTokenQuery(mock, "reset-token", time.Now().Add(time.Hour), nil)
+		mock.ExpectBegin()
+		mock.ExpectExec("^UPDATE `user_tokens` SET `used_at`=\\?").WillReturnResult(sqlmock.NewResult(0, 0))
+		mock.ExpectCommit()
+
+		err := newAccountService(db, &recordingMailer{}).ResetPassword("reset-token", "new-password")
+		assert.ErrorIs(t, err, apperrors.ErrInvalidResetToken)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+}
+
+func TestAccountService_RequestPasswordReset(t *testing.T) {
+	t.Run("unknown email sends nothing", func(t *testing.T) {
+		db, mock := SetupMockDB()
+		mock.ExpectQuery("^SELECT \\* FROM `users` WHERE email = \\?").WillReturnRows(sqlmock.NewRows([]string{"id"}))
+
+		mail := &recordingMailer{}
+		assert.NoError(t, newAccountService(db, mail).RequestPasswordReset("nobody@example.com"))
+		assert.Empty(t, mail.sent)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("known email gets a link with a fresh token", func(t *testing.T) {
+		db, mock := SetupMockDB()
+		mock.ExpectQuery("^SELECT \\* FROM `users` WHERE email = \\?").
+			WillReturnRows(sqlmock.NewRows([]string{"id", "email", "is_active"}).AddRow(7, "owner@example.com", true))
+		mock.ExpectBegin()
+		mock.ExpectExec("^UPDATE `user_tokens` SET `used_at`=\\? WHERE user_id = \\? AND purpose = \\? AND used_at IS NULL").
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectExec("^INSERT INTO `user_tokens`").WillReturnResult(sqlmock.NewResult(6, 1))
+		mock.ExpectCommit()
+
+		mail := &recordingMailer{}
+		accounts := newAccountService(db, mail)
+		accounts.BaseURL = "https://pos.example.com"
+		assert.NoError(t, accounts.RequestPasswordReset("owner@example.com"))
+
+		assert.Len(t, mail.sent, 1)
+		assert.Equal(t, "owner@example.com", mail.sent[0].To)
+		assert.True(t, strings.Contains(mail.sent[0].Body, "https://pos.example.com/reset-password?token="))
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+}
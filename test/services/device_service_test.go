@@ -0,0 +1,179 @@
+package services
+
+import (
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
+
+	"square-pos-integration/internal/apperrors"
+	"square-pos-integration/internal/models"
+	"square-pos-integration/internal/service"
+	"square-pos-integration/internal/utils"
+)
+
+func newDeviceService(db *gorm.DB) *service.DeviceService {
+	return service.NewDeviceService(db, service.NewTwoFactorService(db, newAccountService(db, &recordingMailer{}), testKeys.Secrets), testKeys.PINs, testKeys.Signing)
+}
+
+func mockPinUserQuery(mock sqlmock.Sqlmock, pin string, failedAttempts int, lockedUntil *time.Time) {
+	mockPinUserQueryWithRole(mock, pin, "staff", "", failedAttempts, lockedUntil)
+}
+
+// mockPinUserQueryWithRole mocks loading user 7, whose home is restaurant 3, and their
+// membership of restaurant 3 with the role. The restaurant requires two-factor
+// authentication for twoFactorRoles.
+func mockPinUserQueryWithRole(mock sqlmock.Sqlmock, pin, role, twoFactorRoles string, failedAttempts int, lockedUntil *time.Time) {
+	pinHash := testKeys.PINs.Hash(3, pin)
+	mock.ExpectQuery("^SELECT \\* FROM `users`").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "email", "restaurant_id", "role", "is_active", "pin_hash", "failed_pin_attempts", "pin_locked_until"}).
+			AddRow(7, "server@example.com", 3, "staff", true, pinHash, failedAttempts, lockedUntil))
+	mockPinMembershipQuery(mock, 3, role, twoFactorRoles)
+}
+
+// mockPinMembershipQuery mocks loading user 7's membership of the restaurant with the role
+func mockPinMembershipQuery(mock sqlmock.Sqlmock, restaurantID uint, role, twoFactorRoles string) {
+	mock.ExpectQuery("^SELECT \\* FROM `memberships` WHERE user_id = \\? AND restaurant_id = \\? AND is_active = \\?").
+		WithArgs(uint(7), restaurantID, true, 1).
+		WillReturnRows(sqlmock.NewRows(memberColumns).AddRow(21, 7, restaurantID, role, "", true))
+	mock.ExpectQuery("^SELECT \\* FROM `restaurants`").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "name", "two_factor_roles"}).AddRow(restaurantID, "Harbor Grill", twoFactorRoles))
+}
+
+// mockPinFailure mocks counting a wrong PIN on the table's row, which then holds
+// failedAttempts. When the limit is reached the transaction stays open for the lock.
+func mockPinFailure(mock sqlmock.Sqlmock, table string, failedAttempts int) {
+	mock.ExpectBegin()
+	mock.ExpectExec("^UPDATE `" + table + "` SET `failed_pin_attempts`=failed_pin_attempts \\+ 1").WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery("^SELECT `failed_pin_attempts` FROM `" + table + "`").
+		WillReturnRows(sqlmock.NewRows([]string{"failed_pin_attempts"}).AddRow(failedAttempts))
+	if failedAttempts < service.MaxPinAttempts {
+		mock.ExpectCommit()
+	}
+}
+
+func TestDeviceService_PinLogin(t *testing.T) {
+	device := models.Device{RestaurantID: 3}
+	device.ID = 11
+
+	t.Run("correct pin signs in with a device token", func(t *testing.T) {
+		db, mock := SetupMockDB()
+		mockPinUserQuery(mock, "4821", 0, nil)
+		mock.ExpectBegin()
+		mock.ExpectExec("^UPDATE `devices` SET").WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectCommit()
+
+		user, token, err := newDeviceService(db).PinLogin(device, 7, "4821")
+		assert.NoError(t, err)
+		assert.Equal(t, uint(7), user.ID)
+
+		claims, err := utils.ValidateJWT(testKeys.Signing, token)
+		assert.NoError(t, err)
+		assert.Equal(t, uint(11), claims.DeviceID)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("wrong pin counts against device and user", func(t *testing.T) {
+		db, mock := SetupMockDB()
+		mockPinUserQuery(mock, "4821", 0, nil)
+		mockPinFailure(mock, "devices", 1)
+		mockPinFailure(mock, "users", 1)
+
+		_, _, err := newDeviceService(db).PinLogin(device, 7, "0000")
+		assert.ErrorIs(t, err, apperrors.ErrInvalidPin)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("last allowed failure locks the device", func(t *testing.T) {
+		db, mock := SetupMockDB()
+		mock.ExpectQuery("^SELECT \\* FROM `users`").WillReturnRows(sqlmock.NewRows([]string{"id"}))
+		mockPinFailure(mock, "devices", service.MaxPinAttempts)
+		mock.ExpectExec("^UPDATE `devices` SET `failed_pin_attempts`=\\?,`locked_until`=\\?").WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectCommit()
+
+		_, _, err := newDeviceService(db).PinLogin(device, 0, "0000")
+		assert.ErrorIs(t, err, apperrors.ErrInvalidPin)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("locked user is rejected before the pin is checked", func(t *testing.T) {
+		db, mock := SetupMockDB()
+		lockedUntil := time.Now().Add(time.Minute)
+		mockPinUserQuery(mock, "4821", 0, &lockedUntil)
+
+		_, _, err := newDeviceService(db).PinLogin(device, 7, "4821")
+		assert.ErrorIs(t, err, apperrors.ErrPinLocked)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("role requiring two-factor cannot use a pin", func(t *testing.T) {
+		db, mock := SetupMockDB()
+		mockPinUserQueryWithRole(mock, "4821", "manager", "admin,manager", 0, nil)
+
+		_, _, err := newDeviceService(db).PinLogin(device, 7, "4821")
+		assert.ErrorIs(t, err, apperrors.ErrPinLoginNotAllowed)
+		assert.NoError(t, mock.ExpectationsWereMet(), "no token is issued and the device is not touched")
+	})
+
+	t.Run("member from another restaurant acts with their membership", func(t *testing.T) {
+		db, mock := SetupMockDB()
+		visiting := device
+		visiting.RestaurantID = 5
+		mock.ExpectQuery("^SELECT \\* FROM `users`").
+			WillReturnRows(sqlmock.NewRows([]string{"id", "email", "restaurant_id", "role", "is_active", "pin_hash"}).
+				AddRow(7, "server@example.com", 3, "staff", true, testKeys.PINs.Hash(3, "4821")))
+		mockPinMembershipQuery(mock, 5, "manager", "")
+		mock.ExpectBegin()
+		mock.ExpectExec("^UPDATE `devices` SET").WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectCommit()
+
+		user, token, err := newDeviceService(db).PinLogin(visiting, 7, "4821")
+		assert.NoError(t, err)
+		assert.Equal(t, uint(5), user.RestaurantID)
+		assert.Equal(t, "manager", user.Role)
+
+		claims, err := utils.ValidateJWT(testKeys.Signing, token)
+		assert.NoError(t, err)
+		assert.Equal(t, uint(5), claims.RestaurantID)
+		assert.Equal(t, "manager", claims.Role)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("user who is not a member counts as unknown", func(t *testing.T) {
+		db, mock := SetupMockDB()
+		mock.ExpectQuery("^SELECT \\* FROM `users`").
+			WillReturnRows(sqlmock.NewRows([]string{"id", "email", "restaurant_id", "role", "is_active", "pin_hash"}).
+				AddRow(7, "server@example.com", 8, "admin", true, testKeys.PINs.Hash(8, "4821")))
+		mock.ExpectQuery("^SELECT \\* FROM `memberships`").WillReturnRows(sqlmock.NewRows(memberColumns))
+		mockPinFailure(mock, "devices", 1)
+
+		_, _, err := newDeviceService(db).PinLogin(device, 7, "4821")
+		assert.ErrorIs(t, err, apperrors.ErrInvalidPin)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("unverified user is rejected", func(t *testing.T) {
+		db, mock := SetupMockDB()
+		mock.ExpectQuery("^SELECT \\* FROM `users`").
+			WillReturnRows(sqlmock.NewRows([]string{"id", "email", "restaurant_id", "role", "is_active", "pin_hash", "email_verification_pending"}).
+				AddRow(7, "server@example.com", 3, "staff", true, testKeys.PINs.Hash(3, "4821"), true))
+		mockPinMembershipQuery(mock, 3, "staff", "")
+
+		_, _, err := newDeviceService(db).PinLogin(device, 7, "4821")
+		assert.ErrorIs(t, err, apperrors.ErrEmailNotVerified)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("locked device is rejected without a lookup", func(t *testing.T) {
+		db, mock := SetupMockDB()
+		lockedUntil := time.Now().Add(time.Minute)
+		locked := device
+		locked.LockedUntil = &lockedUntil
+
+		_, _, err := newDeviceService(db).PinLogin(locked, 7, "4821")
+		assert.ErrorIs(t, err, apperrors.ErrDeviceLocked)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+}
@@ -0,0 +1,157 @@
+package services
+
+import (
+	"strconv"
+
+	"gorm.io/gorm"
+
+	"square-pos-integration/internal/models"
+)
+
+// FakeOrderRepository keeps orders in memory so domain services can be tested without a
+// database. Like the GORM repository, lookups only see the given restaurant's orders.
+type FakeOrderRepository struct {
+	Orders map[uint]models.Order
+	nextID uint
+}
+
+func NewFakeOrderRepository(orders ...models.Order) *FakeOrderRepository {
+	repo := &FakeOrderRepository{Orders: map[uint]models.Order{}}
+	for _, order := range orders {
+		repo.Orders[order.ID] = order
+		if order.ID > repo.nextID {
+			repo.nextID = order.ID
+		}
+	}
+	return repo
+}
+
+func (r *FakeOrderRepository) Create(order *models.Order, items []models.OrderItem) error {
+	r.nextID++
+	order.Model = &gorm.Model{ID: r.nextID}
+	for i := range items {
+		items[i].OrderID = strconv.FormatUint(uint64(order.ID), 10)
+	}
+	order.Items = items
+	r.Orders[order.ID] = *order
+	return nil
+}
+
+func (r *FakeOrderRepository) FindByID(restaurantID uint, orderID string) (models.Order, error) {
+	for _, order := range r.Orders {
+		if order.RestaurantID == restaurantID && strconv.FormatUint(uint64(order.ID), 10) == orderID {
+			return order, nil
+		}
+	}
+	return models.Order{}, gorm.ErrRecordNotFound
+}
+
+func (r *FakeOrderRepository) FindByPaymentID(restaurantID uint, paymentID string) (models.Order, error) {
+	for _, order := range r.Orders {
+		if order.RestaurantID == restaurantID && order.PaymentID == paymentID {
+			return order, nil
+		}
+	}
+	return models.Order{}, gorm.ErrRecordNotFound
+}
+
+func (r *FakeOrderRepository) ListByTable(restaurantID uint, tableNumber string) ([]models.Order, error) {
+	var orders []models.Order
+	for _, order := range r.Orders {
+		if order.RestaurantID == restaurantID && strconv.Itoa(order.TableNumber) == tableNumber {
+			orders = append(orders, order)
+		}
+	}
+	return orders, nil
+}
+
+// Update stores the order as the service left it. The fields are the columns the GORM
+// repository writes, which the service has already set on the order.
+func (r *FakeOrderRepository) Update(order *models.Order, fields map[string]interface{}) error {
+	stored, ok := r.Orders[order.ID]
+	if !ok || stored.RestaurantID != order.RestaurantID {
+		return gorm.ErrRecordNotFound
+	}
+	if status, ok := fields["status"].(string); ok {
+		order.Status = status
+	}
+	if paymentID, ok := fields["payment_id"].(string); ok {
+		order.PaymentID = paymentID
+	}
+	r.Orders[order.ID] = *order
+	return nil
+}
+
+// FakePaymentRepository keeps payments in memory
+type FakePaymentRepository struct {
+	Payments map[uint]models.Payment
+	nextID   uint
+}
+
+func NewFakePaymentRepository(payments ...models.Payment) *FakePaymentRepository {
+	repo := &FakePaymentRepository{Payments: map[uint]models.Payment{}}
+	for _, payment := range payments {
+		repo.Payments[payment.ID] = payment
+		if payment.ID > repo.nextID {
+			repo.nextID = payment.ID
+		}
+	}
+	return repo
+}
+
+func (r *FakePaymentRepository) Create(payment *models.Payment) error {
+	r.nextID++
+	payment.Model = &gorm.Model{ID: r.nextID}
+	r.Payments[payment.ID] = *payment
+	return nil
+}
+
+func (r *FakePaymentRepository) FindByID(restaurantID uint, paymentID string) (models.Payment, error) {
+	for _, payment := range r.Payments {
+		if payment.RestaurantID == restaurantID && strconv.FormatUint(uint64(payment.ID), 10) == paymentID {
+			return payment, nil
+		}
+	}
+	return models.Payment{}, gorm.ErrRecordNotFound
+}
+
+func (r *FakePaymentRepository) FindBySquareID(restaurantID uint, squarePaymentID string) (models.Payment, error) {
+	for _, payment := range r.Payments {
+		if payment.RestaurantID == restaurantID && payment.SquarePaymentID == squarePaymentID {
+			return payment, nil
+		}
+	}
+	return models.Payment{}, gorm.ErrRecordNotFound
+}
+
+func (r *FakePaymentRepository) ListCompleted(restaurantID uint, orderID string) ([]models.Payment, error) {
+	var payments []models.Payment
+	for _, payment := range r.Payments {
+		if payment.RestaurantID == restaurantID && payment.OrderID == orderID && payment.Status == "COMPLETED" {
+			payments = append(payments, payment)
+		}
+	}
+	return payments, nil
+}
+
+func (r *FakePaymentRepository) Save(payment *models.Payment) error {
+	r.Payments[payment.ID] = *payment
+	return nil
+}
+
+func (r *FakePaymentRepository) Update(payment *models.Payment, fields map[string]interface{}) error {
+	r.Payments[payment.ID] = *payment
+	return nil
+}
+
+// FakeLocations allows every location unless Err is set
+type FakeLocations struct {
+	Err error
+}
+
+func (l *FakeLocations) ResolveLocation(membership models.Membership, squareLocationID string) (models.Location, error) {
+	if l.Err != nil {
+		return models.Location{}, l.Err
+	}
+	return models.Location{RestaurantID: membership.RestaurantID, SquareLocationID: squareLocationID, Status: models.LocationStatusActive}, nil
+}
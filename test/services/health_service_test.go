@@ -0,0 +1,96 @@
+package services
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+
+	"square-pos-integration/internal/config"
+	"square-pos-integration/internal/migrate"
+	"square-pos-integration/internal/service"
+	"square-pos-integration/migrations"
+)
+
+func migratedDB(t *testing.T) (*gorm.DB, *migrate.Migrator) {
+	db, err := config.OpenDB("sqlite://" + filepath.Join(t.TempDir(), "pos.db"))
+	require.NoError(t, err)
+	db.Logger = logger.Default.LogMode(logger.Silent)
+	fsys, err := migrations.For("sqlite")
+	require.NoError(t, err)
+	migrator, err := migrate.New(db, fsys)
+	require.NoError(t, err)
+	_, err = migrator.Up()
+	require.NoError(t, err)
+	return db, migrator
+}
+
+func TestReadyChecksDatabaseAndMigrations(t *testing.T) {
+	db, migrator := migratedDB(t)
+	health := service.NewHealthService(db)
+
+	checks, ready := health.Ready(context.Background())
+	assert.True(t, ready)
+	assert.Equal(t, map[string]string{"database": "ok", "migrations": "ok"}, checks)
+
+	_, err := migrator.Down(1)
+	require.NoError(t, err)
+	checks, ready = health.Ready(context.Background())
+	assert.False(t, ready)
+	assert.Contains(t, checks["migrations"], "1 pending")
+
+	_, err = migrator.Up()
+	require.NoError(t, err)
+	sqlDB, err := db.DB()
+	require.NoError(t, err)
+	require.NoError(t, sqlDB.Close())
+	checks, ready = health.Ready(context.Background())
+	assert.False(t, ready)
+	assert.NotEqual(t, "ok", checks["database"])
+}
+
+func TestReadyFailsOnceDraining(t *testing.T) {
+	db, _ := migratedDB(t)
+	health := service.NewHealthService(db)
+	health.Drain()
+
+	checks, ready := health.Ready(context.Background())
+	assert.False(t, ready)
+	assert.Equal(t, "shutting down", checks["database"])
+}
+
+func TestSquareProbeKeepsLastResult(t *testing.T) {
+	status := http.StatusUnauthorized
+	square := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/v2/locations", r.URL.Path)
+		w.WriteHeader(status)
+	}))
+	defer square.Close()
+
+	db, _ := migratedDB(t)
+	health := service.NewHealthService(db)
+	health.Square = service.NewSquareProbe(square.URL, time.Second)
+
+	// Not ready until the first check
+	checks, ready := health.Ready(context.Background())
+	assert.False(t, ready)
+	assert.Equal(t, "not checked yet", checks["square"])
+
+	// An unauthorized answer means Square is up
+	require.NoError(t, health.Square.Check(context.Background()))
+	_, ready = health.Ready(context.Background())
+	assert.True(t, ready)
+
+	status = http.StatusServiceUnavailable
+	assert.Error(t, health.Square.Check(context.Background()))
+	checks, ready = health.Ready(context.Background())
+	assert.False(t, ready)
+	assert.Equal(t, "answered 503 Service Unavailable", checks["square"])
+}
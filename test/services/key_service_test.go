@@ -0,0 +1,48 @@
+package services
+
+import (
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"square-pos-integration/internal/service"
+	"square-pos-integration/internal/signing"
+)
+
+func TestKeyService_LoadDecryptsStoredKeys(t *testing.T) {
+	db, mock := SetupMockDB()
+	key, err := signing.GenerateKey(signing.AlgorithmEdDSA, time.Now().Add(-time.Hour))
+	require.NoError(t, err)
+	pemBytes, err := key.MarshalPrivateKey()
+	require.NoError(t, err)
+	encrypted, err := testKeys.Secrets.Encrypt(string(pemBytes))
+	require.NoError(t, err)
+
+	mock.ExpectQuery("^SELECT \\* FROM `signing_keys` WHERE retires_at IS NULL OR retires_at > \\?").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "kid", "algorithm", "private_key", "activates_at", "retires_at", "created_at"}).
+			AddRow(1, key.ID, key.Algorithm, encrypted, key.ActivatesAt, nil, time.Now()))
+
+	ring := signing.NewKeyRing()
+	require.NoError(t, service.NewKeyService(db, ring, testKeys.Secrets).Load())
+
+	loaded, err := ring.SigningKey(time.Now())
+	require.NoError(t, err)
+	assert.Equal(t, key.ID, loaded.ID)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestKeyService_RotateIfDueSkipsRecentKey(t *testing.T) {
+	db, mock := SetupMockDB()
+
+	mock.ExpectBegin()
+	mock.ExpectQuery("^SELECT \\* FROM `signing_keys` ORDER BY activates_at DESC,`signing_keys`.`id` LIMIT \\? FOR UPDATE").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "kid", "algorithm", "activates_at"}).
+			AddRow(1, "kid-1", signing.AlgorithmRS256, time.Now().Add(-24*time.Hour)))
+	mock.ExpectCommit()
+
+	assert.NoError(t, service.NewKeyService(db, signing.NewKeyRing(), testKeys.Secrets).RotateIfDue())
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
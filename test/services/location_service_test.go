@@ -0,0 +1,79 @@
+package services
+
+import (
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+
+	"square-pos-integration/internal/apperrors"
+	"square-pos-integration/internal/models"
+	"square-pos-integration/internal/service"
+)
+
+var locationColumns = []string{"id", "restaurant_id", "square_location_id", "name", "status"}
+
+func mockLocationQuery(mock sqlmock.Sqlmock, rows *sqlmock.Rows) {
+	mock.ExpectQuery("^SELECT \\* FROM `locations` WHERE square_location_id = \\? AND `locations`.`restaurant_id` = \\?").
+		WithArgs("LOCATION2", uint(3), 1).
+		WillReturnRows(rows)
+}
+
+func TestLocationService_ResolveLocationOfOtherRestaurant(t *testing.T) {
+	db, mock := SetupMockDB()
+	locations := service.NewLocationService(db)
+	mockLocationQuery(mock, sqlmock.NewRows(locationColumns))
+
+	_, err := locations.ResolveLocation(models.Membership{RestaurantID: 3}, "LOCATION2")
+
+	assert.ErrorIs(t, err, apperrors.ErrLocationNotFound)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestLocationService_ResolveInactiveLocation(t *testing.T) {
+	db, mock := SetupMockDB()
+	locations := service.NewLocationService(db)
+	mockLocationQuery(mock, sqlmock.NewRows(locationColumns).AddRow(1, 3, "LOCATION2", "Uptown", models.LocationStatusInactive))
+
+	_, err := locations.ResolveLocation(models.Membership{RestaurantID: 3}, "LOCATION2")
+
+	assert.ErrorIs(t, err, apperrors.ErrLocationInactive)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestLocationService_ResolveUnassignedLocation(t *testing.T) {
+	db, mock := SetupMockDB()
+	locations := service.NewLocationService(db)
+	mockLocationQuery(mock, sqlmock.NewRows(locationColumns).AddRow(1, 3, "LOCATION2", "Uptown", models.LocationStatusActive))
+
+	_, err := locations.ResolveLocation(models.Membership{RestaurantID: 3, LocationIDs: "LOCATION1"}, "LOCATION2")
+
+	assert.ErrorIs(t, err, apperrors.ErrLocationNotAllowed)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestLocationService_ResolveLocation(t *testing.T) {
+	db, mock := SetupMockDB()
+	locations := service.NewLocationService(db)
+	mockLocationQuery(mock, sqlmock.NewRows(locationColumns).AddRow(1, 3, "LOCATION2", "Uptown", models.LocationStatusActive))
+
+	location, err := locations.ResolveLocation(models.Membership{RestaurantID: 3, LocationIDs: "LOCATION1,LOCATION2"}, "LOCATION2")
+
+	assert.NoError(t, err)
+	assert.Equal(t, "Uptown", location.Name)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestLocationService_EnsureLocationsOfOtherRestaurant(t *testing.T) {
+	db, mock := SetupMockDB()
+	locations := service.NewLocationService(db)
+
+	mock.ExpectQuery("^SELECT count\\(\\*\\) FROM `locations` WHERE square_location_id IN \\(\\?,\\?\\) AND `locations`.`restaurant_id` = \\?").
+		WithArgs("LOCATION1", "OTHER1", uint(3)).
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
+
+	err := locations.EnsureLocations(3, []string{"LOCATION1", "OTHER1"})
+
+	assert.ErrorIs(t, err, apperrors.ErrLocationNotFound)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
@@ -0,0 +1,122 @@
+package services
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+
+	"square-pos-integration/internal/apperrors"
+	"square-pos-integration/internal/lockout"
+	"square-pos-integration/internal/models"
+	"square-pos-integration/internal/service"
+)
+
+// expectAuditInserts mocks writing n entries to the audit log
+func expectAuditInserts(mock sqlmock.Sqlmock, n int) {
+	for i := 0; i < n; i++ {
+		mock.ExpectBegin()
+		mock.ExpectExec("^INSERT INTO `audit_logs`").WillReturnResult(sqlmock.NewResult(int64(i+1), 1))
+		mock.ExpectCommit()
+	}
+}
+
+func TestLoginGuard_LocksAccountAfterMaxFailures(t *testing.T) {
+	db, mock := SetupMockDB()
+	guard := service.NewLoginGuard(db, lockout.NewMemoryStore())
+	guard.Policy.Account = service.AttemptLimit{FreeFailures: 10, MaxFailures: 3}
+
+	user := models.User{Email: "owner@example.com", RestaurantID: 1}
+	user.ID = 7
+
+	// Two failures, then a third that also records the lockout
+	expectAuditInserts(mock, 4)
+	for i := 0; i < 3; i++ {
+		assert.NoError(t, guard.RecordFailure(context.Background(), &user, "Owner@example.com", "10.0.0.1"))
+	}
+
+	expectAuditInserts(mock, 1)
+	err := guard.Check(context.Background(), "owner@example.com", "10.0.0.2")
+	assert.ErrorIs(t, err, apperrors.ErrAccountLocked)
+	retry := err.(*apperrors.AppError).Details.(apperrors.RetryDetails)
+	assert.InDelta(t, 15*60, retry.RetryAfterSeconds, 1)
+
+	expectAuditInserts(mock, 1)
+	assert.NoError(t, guard.Unlock(context.Background(), user, 1, "10.0.0.3"))
+	assert.NoError(t, guard.Check(context.Background(), "owner@example.com", "10.0.0.2"))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestLoginGuard_DelaysRetriesAfterFreeFailures(t *testing.T) {
+	db, mock := SetupMockDB()
+	guard := service.NewLoginGuard(db, lockout.NewMemoryStore())
+
+	expectAuditInserts(mock, 2)
+	assert.NoError(t, guard.RecordFailure(context.Background(), nil, "unknown@example.com", "10.0.0.1"))
+	assert.NoError(t, guard.RecordFailure(context.Background(), nil, "unknown@example.com", "10.0.0.1"))
+	assert.NoError(t, guard.Check(context.Background(), "unknown@example.com", "10.0.0.1"))
+
+	// The third failure must wait BaseDelay, the fourth twice as long
+	expectAuditInserts(mock, 2)
+	assert.NoError(t, guard.RecordFailure(context.Background(), nil, "unknown@example.com", "10.0.0.1"))
+	err := guard.Check(context.Background(), "unknown@example.com", "10.0.0.1")
+	assert.ErrorIs(t, err, apperrors.ErrLoginThrottled)
+	assert.Equal(t, 1, err.(*apperrors.AppError).Details.(apperrors.RetryDetails).RetryAfterSeconds)
+
+	expectAuditInserts(mock, 2)
+	assert.NoError(t, guard.RecordFailure(context.Background(), nil, "unknown@example.com", "10.0.0.1"))
+	err = guard.Check(context.Background(), "unknown@example.com", "10.0.0.1")
+	assert.ErrorIs(t, err, apperrors.ErrLoginThrottled)
+	assert.Equal(t, 2, err.(*apperrors.AppError).Details.(apperrors.RetryDetails).RetryAfterSeconds)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestLoginGuard_LocksIPAcrossAccounts(t *testing.T) {
+	db, mock := SetupMockDB()
+	guard := service.NewLoginGuard(db, lockout.NewMemoryStore())
+	guard.Policy.IP = service.AttemptLimit{FreeFailures: 10, MaxFailures: 3}
+
+	expectAuditInserts(mock, 3)
+	for _, email := range []string{"a@example.com", "b@example.com", "c@example.com"} {
+		assert.NoError(t, guard.RecordFailure(context.Background(), nil, email, "10.0.0.1"))
+	}
+
+	expectAuditInserts(mock, 1)
+	assert.ErrorIs(t, guard.Check(context.Background(), "d@example.com", "10.0.0.1"), apperrors.ErrTooManyLoginAttempts)
+	assert.NoError(t, guard.Check(context.Background(), "d@example.com", "10.0.0.2"))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestLoginGuard_SuccessClearsAccountFailures(t *testing.T) {
+	db, mock := SetupMockDB()
+	store := lockout.NewMemoryStore()
+	guard := service.NewLoginGuard(db, store)
+
+	user := models.User{Email: "owner@example.com", RestaurantID: 1}
+	user.ID = 7
+
+	expectAuditInserts(mock, 3)
+	assert.NoError(t, guard.RecordFailure(context.Background(), &user, user.Email, "10.0.0.1"))
+	assert.NoError(t, guard.RecordFailure(context.Background(), &user, user.Email, "10.0.0.1"))
+	assert.NoError(t, guard.RecordSuccess(context.Background(), user, "10.0.0.1"))
+
+	account, _ := store.Get("account:owner@example.com")
+	assert.Equal(t, 0, account.Failures)
+	address, _ := store.Get("ip:10.0.0.1")
+	assert.Equal(t, 2, address.Failures)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestMemoryStore_ForgetsFailuresOutsideWindow(t *testing.T) {
+	store := lockout.NewMemoryStore()
+	start := time.Now()
+
+	store.RecordFailure("ip:10.0.0.1", start, time.Minute)
+	attempts, _ := store.RecordFailure("ip:10.0.0.1", start.Add(30*time.Second), time.Minute)
+	assert.Equal(t, 2, attempts.Failures)
+
+	attempts, _ = store.RecordFailure("ip:10.0.0.1", start.Add(2*time.Minute), time.Minute)
+	assert.Equal(t, 1, attempts.Failures)
+}
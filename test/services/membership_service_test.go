@@ -0,0 +1,142 @@
+package services
+
+import (
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+
+	"square-pos-integration/internal/apperrors"
+	"square-pos-integration/internal/authz"
+	"square-pos-integration/internal/models"
+	"square-pos-integration/internal/requests"
+	"square-pos-integration/internal/service"
+)
+
+// admin holds every permission, so role checks always pass
+var admin = authz.NewSet(authz.All...)
+
+var memberColumns = []string{"id", "user_id", "restaurant_id", "role", "location_ids", "is_active"}
+
+// mockMemberQuery mocks loading the membership of user 4 in restaurant 3, with the user
+// whose home restaurant is homeRestaurantID
+func mockMemberQuery(mock sqlmock.Sqlmock, role string, homeRestaurantID uint) {
+	mock.ExpectQuery("^SELECT \\* FROM `memberships` WHERE restaurant_id = \\? AND user_id = \\?").
+		WithArgs(uint(3), "4", 1).
+		WillReturnRows(sqlmock.NewRows(memberColumns).AddRow(9, 4, 3, role, "", true))
+	mock.ExpectQuery("^SELECT \\* FROM `users`").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "created_at", "updated_at", "deleted_at", "email", "restaurant_id", "role", "is_active"}).
+			AddRow(4, time.Now(), time.Now(), nil, "area@example.com", homeRestaurantID, "manager", true))
+}
+
+func TestDefaultMembership(t *testing.T) {
+	user := models.User{RestaurantID: 3}
+	home := models.Membership{RestaurantID: 3, Role: "manager"}
+	other := models.Membership{RestaurantID: 5, Role: "admin"}
+
+	membership, err := service.DefaultMembership(user, []models.Membership{other, home})
+	assert.NoError(t, err)
+	assert.Equal(t, home, membership, "the home restaurant comes first")
+
+	membership, err = service.DefaultMembership(user, []models.Membership{other})
+	assert.NoError(t, err)
+	assert.Equal(t, other, membership, "removed from home, the oldest membership is used")
+
+	_, err = service.DefaultMembership(user, nil)
+	assert.ErrorIs(t, err, apperrors.ErrNoActiveMembership)
+}
+
+func TestActAs(t *testing.T) {
+	user := models.User{Email: "area@example.com", RestaurantID: 3, Role: "manager"}
+	membership := models.Membership{RestaurantID: 5, Role: "admin", Restaurant: models.Restaurant{Name: "Uptown"}}
+
+	acting := service.ActAs(user, membership)
+
+	assert.Equal(t, uint(5), acting.RestaurantID)
+	assert.Equal(t, "admin", acting.Role)
+	assert.Equal(t, "Uptown", acting.Restaurant.Name)
+	assert.Equal(t, uint(3), user.RestaurantID, "the stored user is unchanged")
+}
+
+func TestMembershipService_FindMembershipOfOtherRestaurant(t *testing.T) {
+	db, mock := SetupMockDB()
+	memberships := service.NewMembershipService(db)
+
+	mock.ExpectQuery("^SELECT \\* FROM `memberships` WHERE user_id = \\? AND restaurant_id = \\? AND is_active = \\?").
+		WithArgs(uint(4), uint(8), true, 1).
+		WillReturnRows(sqlmock.NewRows(memberColumns))
+
+	_, err := memberships.FindMembership(4, 8)
+
+	assert.ErrorIs(t, err, apperrors.ErrNotAMember)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestMembershipService_RemoveHomeMembership(t *testing.T) {
+	db, mock := SetupMockDB()
+	memberships := service.NewMembershipService(db)
+	mockMemberQuery(mock, "manager", 3)
+
+	err := memberships.RemoveMember(admin, 3, "4")
+
+	assert.ErrorIs(t, err, apperrors.ErrHomeMembership)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestMembershipService_RemoveLastAdmin(t *testing.T) {
+	db, mock := SetupMockDB()
+	memberships := service.NewMembershipService(db)
+	mockMemberQuery(mock, "admin", 1)
+
+	mock.ExpectBegin()
+	mock.ExpectQuery("^SELECT .* FROM `memberships` JOIN users .* FOR UPDATE").
+		WithArgs(true, uint(3), "admin", true, uint(4)).
+		WillReturnRows(sqlmock.NewRows(memberColumns))
+	mock.ExpectRollback()
+
+	err := memberships.RemoveMember(admin, 3, "4")
+
+	assert.ErrorIs(t, err, apperrors.ErrLastAdmin)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestMembershipService_RemoveMember(t *testing.T) {
+	db, mock := SetupMockDB()
+	memberships := service.NewMembershipService(db)
+	mockMemberQuery(mock, "manager", 1)
+
+	mock.ExpectBegin()
+	mock.ExpectExec("^DELETE FROM `memberships` WHERE `memberships`.`id` = \\?").
+		WithArgs(uint(9)).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	assert.NoError(t, memberships.RemoveMember(admin, 3, "4"))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestMembershipService_CustomRoleCannotGrantAdmin(t *testing.T) {
+	db, mock := SetupMockDB()
+	memberships := service.NewMembershipService(db)
+	mockMemberQuery(mock, "staff", 1)
+
+	manager, _ := authz.BuiltinRole("manager")
+	shiftLead := authz.NewSet(append(manager, authz.UsersManage)...)
+	_, err := memberships.UpdateMember(shiftLead, 3, "4", requests.UpdateMemberRequest{Role: "admin"})
+
+	assert.ErrorIs(t, err, apperrors.ErrRoleExceedsPermissions)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestMembershipService_CustomRoleCannotAddAdmin(t *testing.T) {
+	db, mock := SetupMockDB()
+	memberships := service.NewMembershipService(db)
+
+	manager, _ := authz.BuiltinRole("manager")
+	shiftLead := authz.NewSet(append(manager, authz.UsersManage)...)
+	_, err := memberships.AddMember(shiftLead, 3, requests.AddMemberRequest{Email: "area@example.com", Role: "admin"})
+
+	assert.ErrorIs(t, err, apperrors.ErrRoleExceedsPermissions)
+	assert.NoError(t, mock.ExpectationsWereMet(), "nothing is looked up or created")
+}
@@ -1,27 +1,119 @@
 package services
 
 import (
+	"context"
 	"square-pos-integration/internal/service"
 	"github.com/DATA-DOG/go-sqlmock"
+	square "github.com/square/square-go-sdk/v2"
 	"gorm.io/driver/mysql"
 	"gorm.io/gorm"
 	"square-pos-integration/internal/models"
+	"square-pos-integration/internal/requests"
+	"square-pos-integration/internal/tenant"
 	"time"
 )
 
-// MockSquareService is a mock implementation of the SquareService.
+// MockSquareService is a mock implementation of the SquareService. Calls without a
+// function set succeed with empty results.
 type MockSquareService struct {
-	FetchLocationIDFunc func(token string) (string, error)
-	
+	FetchLocationIDFunc     func(token string) (string, error)
+	SyncLocationsFunc       func(restaurantID uint) ([]models.Location, error)
+	CreateOrderFunc         func(restaurantID uint, orderRequest requests.CreateOrderRequest, idempotencyKey string) (*square.Order, error)
+	PreviewOrderFunc        func(restaurantID uint, orderRequest requests.CreateOrderRequest) (*square.Order, error)
+	GetOrderDetailsFunc     func(restaurantID uint, squareOrderID string) (*square.Order, error)
+	CalculateOrderFunc      func(restaurantID uint, order *square.Order) (*square.Order, error)
+	CancelOrderFunc         func(restaurantID uint, squareOrderID string) (*square.Order, error)
+	CreatePaymentIntentFunc func(restaurantID uint, squareOrderID string, paymentRequest requests.SubmitPaymentRequest) (*square.Payment, error)
+	CompletePaymentFunc     func(restaurantID uint, squarePaymentID string, tipAmount float64, currency string) (*square.Payment, error)
+	RefundPaymentFunc       func(restaurantID uint, squarePaymentID string, amount int64, currency, reason string) (*square.PaymentRefund, error)
+	SyncCatalogTaxesFunc    func(restaurantID uint) ([]models.TaxRule, error)
+	PushTaxRuleFunc         func(restaurantID uint, rule *models.TaxRule) error
 }
 
-func (m *MockSquareService) FetchLocationID(token string) (string, error) {
+func (m *MockSquareService) FetchLocationID(ctx context.Context, token string) (string, error) {
 	if m.FetchLocationIDFunc != nil {
 		return m.FetchLocationIDFunc(token)
 	}
 	return "mock_location_id", nil
 }
 
+func (m *MockSquareService) SyncLocations(ctx context.Context, restaurantID uint) ([]models.Location, error) {
+	if m.SyncLocationsFunc != nil {
+		return m.SyncLocationsFunc(restaurantID)
+	}
+	return nil, nil
+}
+
+func (m *MockSquareService) CreateOrder(ctx context.Context, restaurantID uint, orderRequest requests.CreateOrderRequest, idempotencyKey string) (*square.Order, error) {
+	if m.CreateOrderFunc != nil {
+		return m.CreateOrderFunc(restaurantID, orderRequest, idempotencyKey)
+	}
+	return &square.Order{}, nil
+}
+
+func (m *MockSquareService) PreviewOrder(ctx context.Context, restaurantID uint, orderRequest requests.CreateOrderRequest) (*square.Order, error) {
+	if m.PreviewOrderFunc != nil {
+		return m.PreviewOrderFunc(restaurantID, orderRequest)
+	}
+	return &square.Order{}, nil
+}
+
+func (m *MockSquareService) GetOrderDetails(ctx context.Context, restaurantID uint, squareOrderID string) (*square.Order, error) {
+	if m.GetOrderDetailsFunc != nil {
+		return m.GetOrderDetailsFunc(restaurantID, squareOrderID)
+	}
+	return &square.Order{ID: square.String(squareOrderID)}, nil
+}
+
+func (m *MockSquareService) CalculateOrder(ctx context.Context, restaurantID uint, order *square.Order) (*square.Order, error) {
+	if m.CalculateOrderFunc != nil {
+		return m.CalculateOrderFunc(restaurantID, order)
+	}
+	return order, nil
+}
+
+func (m *MockSquareService) CancelOrder(ctx context.Context, restaurantID uint, squareOrderID string) (*square.Order, error) {
+	if m.CancelOrderFunc != nil {
+		return m.CancelOrderFunc(restaurantID, squareOrderID)
+	}
+	return &square.Order{ID: square.String(squareOrderID), State: square.OrderState("CANCELED").Ptr()}, nil
+}
+
+func (m *MockSquareService) CreatePaymentIntent(ctx context.Context, restaurantID uint, squareOrderID string, paymentRequest requests.SubmitPaymentRequest) (*square.Payment, error) {
+	if m.CreatePaymentIntentFunc != nil {
+		return m.CreatePaymentIntentFunc(restaurantID, squareOrderID, paymentRequest)
+	}
+	return &square.Payment{}, nil
+}
+
+func (m *MockSquareService) CompletePayment(ctx context.Context, restaurantID uint, squarePaymentID string, tipAmount float64, currency string) (*square.Payment, error) {
+	if m.CompletePaymentFunc != nil {
+		return m.CompletePaymentFunc(restaurantID, squarePaymentID, tipAmount, currency)
+	}
+	return &square.Payment{ID: square.String(squarePaymentID), Status: square.String("COMPLETED")}, nil
+}
+
+func (m *MockSquareService) RefundPayment(ctx context.Context, restaurantID uint, squarePaymentID string, amount int64, currency, reason string) (*square.PaymentRefund, error) {
+	if m.RefundPaymentFunc != nil {
+		return m.RefundPaymentFunc(restaurantID, squarePaymentID, amount, currency, reason)
+	}
+	return &square.PaymentRefund{}, nil
+}
+
+func (m *MockSquareService) SyncCatalogTaxes(ctx context.Context, restaurantID uint) ([]models.TaxRule, error) {
+	if m.SyncCatalogTaxesFunc != nil {
+		return m.SyncCatalogTaxesFunc(restaurantID)
+	}
+	return nil, nil
+}
+
+func (m *MockSquareService) PushTaxRule(ctx context.Context, restaurantID uint, rule *models.TaxRule) error {
+	if m.PushTaxRuleFunc != nil {
+		return m.PushTaxRuleFunc(restaurantID, rule)
+	}
+	return nil
+}
+
 // Ensure MockSquareService implements the interface used by the controller.
 var _ service.ISquareService = (*MockSquareService)(nil)
 
@@ -39,6 +131,9 @@ func SetupMockDB() (*gorm.DB, sqlmock.Sqlmock) {
 	if err != nil {
 		panic("failed to open gorm database")
 	}
+	if err := gormDB.Use(tenant.Plugin{}); err != nil {
+		panic("failed to register tenant scoping")
+	}
 
 	return gormDB, mock
 }
@@ -0,0 +1,132 @@
+package services
+
+import (
+	"context"
+	"testing"
+
+	square "github.com/square/square-go-sdk/v2"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
+
+	"square-pos-integration/internal/apperrors"
+	"square-pos-integration/internal/models"
+	"square-pos-integration/internal/requests"
+	"square-pos-integration/internal/service"
+)
+
+func money(amount int64) *square.Money {
+	return &square.Money{Amount: square.Int64(amount), Currency: square.Currency("USD").Ptr()}
+}
+
+func newOrderService(orders *FakeOrderRepository, payments *FakePaymentRepository, squareService *MockSquareService) *service.OrderService {
+	return &service.OrderService{
+		Orders:    orders,
+		Payments:  payments,
+		Locations: &FakeLocations{},
+		Square:    squareService,
+	}
+}
+
+func TestOrderService_CreateOrder(t *testing.T) {
+	orders := NewFakeOrderRepository()
+	squareService := &MockSquareService{
+		CreateOrderFunc: func(restaurantID uint, orderRequest requests.CreateOrderRequest, idempotencyKey string) (*square.Order, error) {
+			assert.Equal(t, uint(3), restaurantID)
+			return &square.Order{
+				ID:         square.String("SQ-ORDER-1"),
+				TotalMoney: money(2400),
+				LineItems: []*square.OrderLineItem{{
+					UID:            square.String("line-1"),
+					Name:           square.String("Burger"),
+					Quantity:       "2",
+					BasePriceMoney: money(1200),
+					TotalMoney:     money(2400),
+				}},
+			}, nil
+		},
+	}
+	orderService := newOrderService(orders, NewFakePaymentRepository(), squareService)
+
+	order, _, err := orderService.CreateOrder(context.Background(), models.Membership{RestaurantID: 3}, 7, requests.CreateOrderRequest{TableNumber: 4, LocationID: "LOCATION1"})
+
+	assert.NoError(t, err)
+	assert.Equal(t, uint(3), order.RestaurantID)
+	assert.Equal(t, uint(7), order.UserID)
+	assert.Equal(t, "SQ-ORDER-1", order.SquareOrderID)
+	assert.Equal(t, int64(2400), order.TotalAmount)
+	assert.Equal(t, "pending", order.Status)
+	if assert.Len(t, orders.Orders[order.ID].Items, 1) {
+		assert.Equal(t, "Burger", orders.Orders[order.ID].Items[0].Name)
+		assert.Equal(t, "1", orders.Orders[order.ID].Items[0].OrderID)
+	}
+}
+
+func TestOrderService_CreateOrderAtUnassignedLocation(t *testing.T) {
+	squareService := &MockSquareService{
+		CreateOrderFunc: func(restaurantID uint, orderRequest requests.CreateOrderRequest, idempotencyKey string) (*square.Order, error) {
+			t.Fatal("the order must not reach Square")
+			return nil, nil
+		},
+	}
+	orderService := newOrderService(NewFakeOrderRepository(), NewFakePaymentRepository(), squareService)
+	orderService.Locations = &FakeLocations{Err: apperrors.ErrLocationNotAllowed}
+
+	_, _, err := orderService.CreateOrder(context.Background(), models.Membership{RestaurantID: 3}, 7, requests.CreateOrderRequest{LocationID: "LOCATION2"})
+
+	assert.ErrorIs(t, err, apperrors.ErrLocationNotAllowed)
+}
+
+func TestOrderService_GetOrderOfAnotherRestaurant(t *testing.T) {
+	orders := NewFakeOrderRepository(models.Order{Model: &gorm.Model{ID: 1}, RestaurantID: 2})
+	orderService := newOrderService(orders, NewFakePaymentRepository(), &MockSquareService{})
+
+	_, err := orderService.GetOrder(context.Background(), 3, "1")
+
+	assert.ErrorIs(t, err, apperrors.ErrOrderNotFound)
+}
+
+func TestOrderService_CancelPaidOrder(t *testing.T) {
+	order := models.Order{Model: &gorm.Model{ID: 1}, RestaurantID: 3, Status: "paid"}
+	orderService := newOrderService(NewFakeOrderRepository(order), NewFakePaymentRepository(), &MockSquareService{})
+
+	_, err := orderService.CancelOrder(context.Background(), &order)
+
+	assert.ErrorIs(t, err, apperrors.ErrOrderNotCancellable)
+}
+
+func TestOrderService_CancelOrder(t *testing.T) {
+	order := models.Order{Model: &gorm.Model{ID: 1}, RestaurantID: 3, Status: "pending", SquareOrderID: "SQ-ORDER-1"}
+	orders := NewFakeOrderRepository(order)
+	orderService := newOrderService(orders, NewFakePaymentRepository(), &MockSquareService{})
+
+	_, err := orderService.CancelOrder(context.Background(), &order)
+
+	assert.NoError(t, err)
+	assert.Equal(t, "cancelled", orders.Orders[1].Status)
+}
+
+func TestOrderService_RefreshTotals(t *testing.T) {
+	order := models.Order{Model: &gorm.Model{ID: 1}, RestaurantID: 3, SquareOrderID: "SQ-ORDER-1"}
+	orders := NewFakeOrderRepository(order)
+	payments := NewFakePaymentRepository(
+		models.Payment{Model: &gorm.Model{ID: 1}, RestaurantID: 3, OrderID: "1", Status: "COMPLETED", BillAmount: 1500, TipAmount: 200, RefundedAmount: 500},
+		models.Payment{Model: &gorm.Model{ID: 2}, RestaurantID: 3, OrderID: "1", Status: "COMPLETED", BillAmount: 900},
+		models.Payment{Model: &gorm.Model{ID: 3}, RestaurantID: 3, OrderID: "1", Status: "pending", BillAmount: 900},
+	)
+	squareService := &MockSquareService{
+		CalculateOrderFunc: func(restaurantID uint, order *square.Order) (*square.Order, error) {
+			return &square.Order{TotalMoney: money(2400), TotalTaxMoney: money(200)}, nil
+		},
+	}
+	orderService := newOrderService(orders, payments, squareService)
+
+	_, err := orderService.RefreshTotals(context.Background(), &order)
+
+	assert.NoError(t, err)
+	stored := orders.Orders[1]
+	assert.Equal(t, int64(1900), stored.PayedAmount)
+	assert.Equal(t, int64(200), stored.TipAmount)
+	assert.Equal(t, 2400, stored.Totals.Total)
+	assert.Equal(t, 500, stored.Totals.Due)
+	assert.Equal(t, 200, stored.Totals.Tax)
+}
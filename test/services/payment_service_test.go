@@ -0,0 +1,149 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	square "github.com/square/square-go-sdk/v2"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
+
+	"square-pos-integration/internal/apperrors"
+	"square-pos-integration/internal/models"
+	"square-pos-integration/internal/requests"
+	"square-pos-integration/internal/service"
+)
+
+func newPaymentService(orders *FakeOrderRepository, payments *FakePaymentRepository, squareService *MockSquareService) *service.PaymentService {
+	return &service.PaymentService{
+		Payments:     payments,
+		Orders:       orders,
+		OrderService: newOrderService(orders, payments, squareService),
+		Locations:    &FakeLocations{},
+		Square:       squareService,
+	}
+}
+
+func TestPaymentService_CreatePaymentIntent(t *testing.T) {
+	orders := NewFakeOrderRepository(models.Order{Model: &gorm.Model{ID: 1}, RestaurantID: 3, LocationID: "LOCATION1", SquareOrderID: "SQ-ORDER-1", Status: "open"})
+	payments := NewFakePaymentRepository()
+	squareService := &MockSquareService{
+		CreatePaymentIntentFunc: func(restaurantID uint, squareOrderID string, paymentRequest requests.SubmitPaymentRequest) (*square.Payment, error) {
+			assert.Equal(t, "SQ-ORDER-1", squareOrderID)
+			return &square.Payment{ID: square.String("SQ-PAY-1"), AmountMoney: money(2400)}, nil
+		},
+	}
+	paymentService := newPaymentService(orders, payments, squareService)
+
+	payment, err := paymentService.CreatePaymentIntent(context.Background(), models.Membership{RestaurantID: 3}, "1", requests.SubmitPaymentRequest{LocationID: "LOCATION1", Amount: 24})
+
+	assert.NoError(t, err)
+	assert.Equal(t, "SQ-PAY-1", payment.SquarePaymentID)
+	assert.Equal(t, 2400, payment.BillAmount)
+	assert.Equal(t, "pending", orders.Orders[1].Status)
+	assert.Equal(t, "1", orders.Orders[1].PaymentID)
+}
+
+func TestPaymentService_CreatePaymentIntentAtOtherLocation(t *testing.T) {
+	orders := NewFakeOrderRepository(models.Order{Model: &gorm.Model{ID: 1}, RestaurantID: 3, LocationID: "LOCATION1"})
+	paymentService := newPaymentService(orders, NewFakePaymentRepository(), &MockSquareService{})
+
+	_, err := paymentService.CreatePaymentIntent(context.Background(), models.Membership{RestaurantID: 3}, "1", requests.SubmitPaymentRequest{LocationID: "LOCATION2"})
+
+	assert.ErrorIs(t, err, apperrors.ErrLocationMismatch)
+}
+
+func TestPaymentService_CompletePaymentWithTip(t *testing.T) {
+	orders := NewFakeOrderRepository(models.Order{Model: &gorm.Model{ID: 1}, RestaurantID: 3, PaymentID: "1", Status: "pending", SquareOrderID: "SQ-ORDER-1"})
+	payments := NewFakePaymentRepository(models.Payment{Model: &gorm.Model{ID: 1}, RestaurantID: 3, OrderID: "1", SquarePaymentID: "SQ-PAY-1", Status: "pending", BillAmount: 2400, Currency: "USD"})
+	squareService := &MockSquareService{
+		CompletePaymentFunc: func(restaurantID uint, squarePaymentID string, tipAmount float64, currency string) (*square.Payment, error) {
+			assert.Equal(t, 3.5, tipAmount)
+			assert.Equal(t, "USD", currency)
+			return &square.Payment{
+				ID:          square.String(squarePaymentID),
+				Status:      square.String("COMPLETED"),
+				AmountMoney: money(2400),
+				TipMoney:    money(350),
+				TotalMoney:  money(2750),
+			}, nil
+		},
+		CalculateOrderFunc: func(restaurantID uint, order *square.Order) (*square.Order, error) {
+			return &square.Order{TotalMoney: money(2400)}, nil
+		},
+	}
+	paymentService := newPaymentService(orders, payments, squareService)
+
+	order, _, err := paymentService.CompletePayment(context.Background(), 3, "SQ-PAY-1", 3.5)
+
+	assert.NoError(t, err)
+	assert.Equal(t, "paid", order.Status)
+	assert.Equal(t, 350, payments.Payments[1].TipAmount)
+	assert.Equal(t, 2750, payments.Payments[1].TotalAmount)
+	assert.Equal(t, 0, orders.Orders[1].Totals.Due)
+	assert.Equal(t, 350, orders.Orders[1].Totals.Tips)
+}
+
+func TestPaymentService_CompletePaymentKeepsStoredTotalsWhenRefreshFails(t *testing.T) {
+	orders := NewFakeOrderRepository(models.Order{Model: &gorm.Model{ID: 1}, RestaurantID: 3, PaymentID: "1", Status: "pending", SquareOrderID: "SQ-ORDER-1",
+		Totals: models.OrderTotals{Total: 2400, Due: 2400}})
+	payments := NewFakePaymentRepository(models.Payment{Model: &gorm.Model{ID: 1}, RestaurantID: 3, OrderID: "1", SquarePaymentID: "SQ-PAY-1", Status: "pending", BillAmount: 2400})
+	squareService := &MockSquareService{
+		GetOrderDetailsFunc: func(restaurantID uint, squareOrderID string) (*square.Order, error) {
+			return nil, errors.New("square unavailable")
+		},
+	}
+	paymentService := newPaymentService(orders, payments, squareService)
+
+	order, squareOrder, err := paymentService.CompletePayment(context.Background(), 3, "SQ-PAY-1", 0)
+
+	assert.NoError(t, err, "the payment is captured, so the client must not retry it")
+	assert.Nil(t, squareOrder)
+	assert.Equal(t, "paid", order.Status)
+	assert.Equal(t, 2400, order.Totals.Due)
+	assert.Equal(t, "COMPLETED", payments.Payments[1].Status)
+}
+
+func TestPaymentService_CompletePaymentTwice(t *testing.T) {
+	payments := NewFakePaymentRepository(models.Payment{Model: &gorm.Model{ID: 1}, RestaurantID: 3, SquarePaymentID: "SQ-PAY-1", Status: "COMPLETED"})
+	paymentService := newPaymentService(NewFakeOrderRepository(), payments, &MockSquareService{})
+
+	_, _, err := paymentService.CompletePayment(context.Background(), 3, "SQ-PAY-1", 0)
+
+	assert.ErrorIs(t, err, apperrors.ErrPaymentAlreadyCompleted)
+}
+
+func TestPaymentService_RefundMoreThanBalance(t *testing.T) {
+	payments := NewFakePaymentRepository(models.Payment{Model: &gorm.Model{ID: 1}, RestaurantID: 3, Status: "COMPLETED", TotalAmount: 2400, RefundedAmount: 2000})
+	paymentService := newPaymentService(NewFakeOrderRepository(), payments, &MockSquareService{})
+
+	_, err := paymentService.RefundPayment(context.Background(), 3, "1", requests.RefundPaymentRequest{Amount: 5})
+
+	assert.ErrorIs(t, err, apperrors.ErrRefundExceedsBalance)
+}
+
+func TestPaymentService_RefundSendsRoundedCentsInPaymentCurrency(t *testing.T) {
+	payments := NewFakePaymentRepository(models.Payment{Model: &gorm.Model{ID: 1}, RestaurantID: 3, SquarePaymentID: "SQ-PAY-1", Status: "COMPLETED", TotalAmount: 2400, Currency: "CAD"})
+	refunded := errors.New("refund sent")
+	paymentService := newPaymentService(NewFakeOrderRepository(), payments, &MockSquareService{
+		RefundPaymentFunc: func(restaurantID uint, squarePaymentID string, amount int64, currency, reason string) (*square.PaymentRefund, error) {
+			assert.Equal(t, int64(29), amount, "0.29 is not truncated to 28 cents")
+			assert.Equal(t, "CAD", currency)
+			return nil, refunded
+		},
+	})
+
+	_, err := paymentService.RefundPayment(context.Background(), 3, "1", requests.RefundPaymentRequest{Amount: 0.29})
+
+	assert.ErrorIs(t, err, refunded)
+}
+
+func TestPaymentService_RefundPaymentOfAnotherRestaurant(t *testing.T) {
+	payments := NewFakePaymentRepository(models.Payment{Model: &gorm.Model{ID: 1}, RestaurantID: 2, Status: "COMPLETED", TotalAmount: 2400})
+	paymentService := newPaymentService(NewFakeOrderRepository(), payments, &MockSquareService{})
+
+	_, err := paymentService.RefundPayment(context.Background(), 3, "1", requests.RefundPaymentRequest{Amount: 5})
+
+	assert.ErrorIs(t, err, apperrors.ErrPaymentNotFound)
+}
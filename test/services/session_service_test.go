@@ -0,0 +1,79 @@
+package services
+
+import (
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+
+	"square-pos-integration/internal/apperrors"
+	"square-pos-integration/internal/service"
+	"square-pos-integration/internal/utils"
+)
+
+func TestSessionService_Authenticate(t *testing.T) {
+	userColumns := []string{"id", "created_at", "updated_at", "deleted_at", "email", "restaurant_id", "role", "is_active", "token_version"}
+
+	tests := []struct {
+		name        string
+		revoked     int
+		isActive    bool
+		version     int
+		expectedErr error
+	}{
+		{name: "live session", isActive: true},
+		{name: "revoked access token", revoked: 1, isActive: true, expectedErr: apperrors.ErrTokenRevoked},
+		{name: "deactivated user", isActive: false, expectedErr: apperrors.ErrAccountDisabled},
+		{name: "sessions revoked after token was issued", isActive: true, version: 1, expectedErr: apperrors.ErrTokenRevoked},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, mock := SetupMockDB()
+			sessions := service.NewSessionService(db, testKeys.Signing)
+
+			mock.ExpectQuery("^SELECT count\\(\\*\\) FROM `revoked_tokens`").
+				WithArgs("token-1").
+				WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(tt.revoked))
+			if tt.revoked == 0 {
+				mock.ExpectQuery("^SELECT \\* FROM `users`").
+					WillReturnRows(sqlmock.NewRows(userColumns).
+						AddRow(1, time.Now(), time.Now(), nil, "jane@example.com", 3, "staff", tt.isActive, tt.version))
+			}
+
+			claims := &utils.JWTClaims{UserID: 1, RestaurantID: 3, Role: "staff"}
+			claims.ID = "token-1"
+
+			user, err := sessions.Authenticate(claims)
+			if tt.expectedErr != nil {
+				assert.ErrorIs(t, err, tt.expectedErr)
+			} else {
+				assert.NoError(t, err)
+				assert.Equal(t, uint(1), user.ID)
+			}
+			assert.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
+
+func TestSessionService_RefreshAfterRemovalFromRestaurant(t *testing.T) {
+	db, mock := SetupMockDB()
+	sessions := service.NewSessionService(db, testKeys.Signing)
+
+	mock.ExpectQuery("^SELECT \\* FROM `refresh_tokens` WHERE token_hash = \\?").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "user_id", "restaurant_id", "token_hash", "family_id", "expires_at", "revoked_at"}).
+			AddRow(1, 4, 5, utils.HashToken("refresh-token"), "family-1", time.Now().Add(time.Hour), nil))
+	mock.ExpectQuery("^SELECT \\* FROM `users`").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "created_at", "updated_at", "deleted_at", "email", "restaurant_id", "role", "is_active"}).
+			AddRow(4, time.Now(), time.Now(), nil, "area@example.com", 3, "manager", true))
+	// The session acts in restaurant 5, where the user is no longer a member
+	mock.ExpectQuery("^SELECT \\* FROM `memberships`").
+		WithArgs(uint(4), uint(5), true, 1).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}))
+
+	_, _, err := sessions.Refresh("refresh-token")
+
+	assert.ErrorIs(t, err, apperrors.ErrNotAMember)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
@@ -0,0 +1,131 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"square-pos-integration/internal/logging"
+	"square-pos-integration/internal/requests"
+	"square-pos-integration/internal/service"
+)
+
+func TestSquareServiceSendsConfiguredVersionAndTimesOut(t *testing.T) {
+	var version string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		version = r.Header.Get("Square-Version")
+		if r.Header.Get("Authorization") == "Bearer slow" {
+			time.Sleep(200 * time.Millisecond)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"locations":[{"id":"L1"}]}`))
+	}))
+	defer server.Close()
+
+	squareService := service.NewSquareService(nil)
+	squareService.BaseURL = server.URL
+	squareService.APIVersion = "2024-01-18"
+	squareService.Timeout = 50 * time.Millisecond
+	squareService.MaxAttempts = 1
+
+	locationID, err := squareService.FetchLocationID(context.Background(), "token")
+	require.NoError(t, err)
+	assert.Equal(t, "L1", locationID)
+	assert.Equal(t, "2024-01-18", version)
+
+	_, err = squareService.FetchLocationID(context.Background(), "slow")
+	assert.Error(t, err)
+}
+
+func TestSquareServiceForwardsRequestID(t *testing.T) {
+	requestIDs := map[string]string{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requestIDs[r.URL.Path] = r.Header.Get("X-Request-ID")
+		w.Header().Set("Content-Type", "application/json")
+		switch r.URL.Path {
+		case "/v2/locations":
+			w.Write([]byte(`{"locations":[{"id":"L1"}]}`))
+		case "/v2/payments/P1/complete":
+			w.Write([]byte(`{"payment":{"id":"P1","status":"COMPLETED"}}`))
+		default:
+			w.Write([]byte(`{}`))
+		}
+	}))
+	defer server.Close()
+
+	db, mock := SetupMockDB()
+	squareService := service.NewSquareService(db)
+	squareService.BaseURL = server.URL
+
+	ctx := logging.WithRequestID(context.Background(), "req-42")
+	_, err := squareService.FetchLocationID(ctx, "token")
+	require.NoError(t, err)
+
+	mock.ExpectQuery("^SELECT \\* FROM `restaurants`").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "square_token"}).AddRow(3, "token"))
+	_, err = squareService.CompletePayment(ctx, 3, "P1", 0, "USD")
+	require.NoError(t, err)
+
+	mock.ExpectQuery("^SELECT \\* FROM `restaurants`").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "square_token"}).AddRow(3, "token"))
+	_, err = squareService.SyncCatalogTaxes(ctx, 3)
+	require.NoError(t, err)
+
+	assert.Equal(t, map[string]string{
+		"/v2/locations":            "req-42",
+		"/v2/payments/P1/complete": "req-42",
+		"/v2/catalog/list":         "req-42",
+	}, requestIDs)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestSquareServicePricesOrdersInLocationCurrency(t *testing.T) {
+	var order struct {
+		Order struct {
+			LineItems []struct {
+				BasePriceMoney struct{ Currency string } `json:"base_price_money"`
+			} `json:"line_items"`
+			ServiceCharges []struct {
+				AmountMoney struct{ Currency string } `json:"amount_money"`
+			} `json:"service_charges"`
+		} `json:"order"`
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewDecoder(r.Body).Decode(&order)
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"order":{"location_id":"L1"}}`))
+	}))
+	defer server.Close()
+
+	db, mock := SetupMockDB()
+	squareService := service.NewSquareService(db)
+	squareService.BaseURL = server.URL
+
+	mock.ExpectQuery("^SELECT \\* FROM `locations`").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "restaurant_id", "square_location_id", "currency"}).AddRow(1, 3, "L1", "CAD"))
+	mock.ExpectQuery("^SELECT \\* FROM `tax_rules`").WillReturnRows(sqlmock.NewRows([]string{"id"}))
+	mock.ExpectQuery("^SELECT \\* FROM `service_charge_rules`").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "restaurant_id", "name", "amount", "calculation_phase", "enabled"}).
+			AddRow(4, 3, "Delivery fee", 500, "SUBTOTAL_PHASE", true))
+	mock.ExpectQuery("^SELECT \\* FROM `restaurants`").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "square_token"}).AddRow(3, "token"))
+
+	_, err := squareService.PreviewOrder(context.Background(), 3, requests.CreateOrderRequest{
+		LocationID: "L1",
+		Items:      []requests.CreateOrderItem{{Name: "Poutine", Quantity: 1, UnitPrice: 1200}},
+	})
+	require.NoError(t, err)
+
+	require.Len(t, order.Order.LineItems, 1)
+	assert.Equal(t, "CAD", order.Order.LineItems[0].BasePriceMoney.Currency)
+	require.Len(t, order.Order.ServiceCharges, 1)
+	assert.Equal(t, "CAD", order.Order.ServiceCharges[0].AmountMoney.Currency)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
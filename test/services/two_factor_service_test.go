@@ -0,0 +1,116 @@
+package services
+
+import (
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+
+	"square-pos-integration/internal/apperrors"
+	"square-pos-integration/internal/models"
+	"square-pos-integration/internal/service"
+	"square-pos-integration/internal/utils"
+)
+
+// enrolledUser returns a user with an encrypted TOTP secret and the secret itself
+func enrolledUser(t *testing.T, enabled bool) (models.User, string) {
+	secret, err := utils.GenerateTOTPSecret()
+	assert.NoError(t, err)
+	encrypted, err := testKeys.Secrets.Encrypt(secret)
+	assert.NoError(t, err)
+
+	user := models.User{Email: "owner@example.com", Role: "admin", RestaurantID: 3, IsActive: true, TOTPSecret: &encrypted}
+	user.ID = 7
+	if enabled {
+		enabledAt := time.Now().Add(-24 * time.Hour)
+		user.TOTPEnabledAt = &enabledAt
+	}
+	return user, secret
+}
+
+func newTwoFactorService(t *testing.T) (*service.TwoFactorService, sqlmock.Sqlmock) {
+	db, mock := SetupMockDB()
+	return service.NewTwoFactorService(db, newAccountService(db, &recordingMailer{}), testKeys.Secrets), mock
+}
+
+func TestTwoFactorService_Activate(t *testing.T) {
+	twoFactor, mock := newTwoFactorService(t)
+	user, secret := enrolledUser(t, false)
+	code, _ := utils.TOTPCode(secret, time.Now())
+
+	mock.ExpectBegin()
+	mock.ExpectExec("^UPDATE `users` SET `totp_last_step`=\\?,`updated_at`=\\? WHERE \\(id = \\? AND totp_last_step < \\?\\)").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+	mock.ExpectBegin()
+	mock.ExpectExec("^UPDATE `users` SET `totp_enabled_at`=\\?").WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+	mock.ExpectBegin()
+	mock.ExpectExec("^DELETE FROM `recovery_codes` WHERE user_id = \\?").WithArgs(uint(7)).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec("^INSERT INTO `recovery_codes`").WillReturnResult(sqlmock.NewResult(1, 10))
+	mock.ExpectCommit()
+
+	recoveryCodes, err := twoFactor.Activate(&user, code)
+	assert.NoError(t, err)
+	assert.Len(t, recoveryCodes, service.RecoveryCodeCount)
+	assert.NotNil(t, user.TOTPEnabledAt)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestTwoFactorService_RejectsReplayedCode(t *testing.T) {
+	twoFactor, mock := newTwoFactorService(t)
+	user, secret := enrolledUser(t, true)
+	code, _ := utils.TOTPCode(secret, time.Now())
+	user.TOTPLastStep = time.Now().Unix() / int64(utils.TOTPPeriod.Seconds())
+
+	_, err := twoFactor.RegenerateRecoveryCodes(&user, code)
+	assert.ErrorIs(t, err, apperrors.ErrInvalidTwoFactorCode)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestTwoFactorService_DisableRequiredByRole(t *testing.T) {
+	twoFactor, mock := newTwoFactorService(t)
+	user, secret := enrolledUser(t, true)
+	user.Restaurant.TwoFactorRoles = "admin,manager"
+	code, _ := utils.TOTPCode(secret, time.Now())
+
+	err := twoFactor.Disable(&user, code)
+	assert.ErrorIs(t, err, apperrors.ErrTwoFactorRequired)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestTwoFactorService_CompleteChallengeWithRecoveryCode(t *testing.T) {
+	twoFactor, mock := newTwoFactorService(t)
+	user, _ := enrolledUser(t, true)
+
+	mock.ExpectBegin()
+	mock.ExpectExec("^UPDATE `recovery_codes` SET `used_at`=\\? WHERE user_id = \\? AND code_hash = \\? AND used_at IS NULL").
+		WithArgs(sqlmock.AnyArg(), uint(7), utils.HashToken("abcde-fghij")).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+	mock.ExpectQuery("^SELECT \\* FROM `user_tokens` WHERE token_hash = \\? AND purpose = \\?").
+		WithArgs(utils.HashToken("challenge"), models.TokenPurposeLoginChallenge, 1).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "user_id", "purpose", "token_hash", "expires_at", "used_at"}).
+			AddRow(9, 7, models.TokenPurposeLoginChallenge, utils.HashToken("challenge"), time.Now().Add(time.Minute), nil))
+	mock.ExpectBegin()
+	mock.ExpectExec("^UPDATE `user_tokens` SET `used_at`=\\? WHERE id = \\? AND used_at IS NULL").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	recoveryCodes, err := twoFactor.CompleteChallenge("challenge", &user, "", "ABCDE FGHIJ")
+	assert.NoError(t, err)
+	assert.Nil(t, recoveryCodes)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestTwoFactorService_Required(t *testing.T) {
+	twoFactor, _ := newTwoFactorService(t)
+
+	user := models.User{Role: "manager"}
+	user.Restaurant.TwoFactorRoles = "admin, manager"
+	assert.True(t, twoFactor.Required(user))
+
+	user.Role = "staff"
+	assert.False(t, twoFactor.Required(user))
+}
@@ -0,0 +1,86 @@
+package signing
+
+import (
+	"crypto/ed25519"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"square-pos-integration/internal/signing"
+)
+
+func TestKeyRing_SignsWithNewestActiveKey(t *testing.T) {
+	now := time.Now()
+	retiresAt := now.Add(30 * time.Minute)
+
+	current, err := signing.GenerateKey(signing.AlgorithmEdDSA, now.Add(-24*time.Hour))
+	require.NoError(t, err)
+	current.RetiresAt = &retiresAt
+	upcoming, err := signing.GenerateKey(signing.AlgorithmEdDSA, now.Add(10*time.Minute))
+	require.NoError(t, err)
+
+	ring := signing.NewKeyRing(upcoming, current)
+
+	// The upcoming key is published and verifies, but does not sign yet
+	key, err := ring.SigningKey(now)
+	require.NoError(t, err)
+	assert.Equal(t, current.ID, key.ID)
+	_, ok := ring.VerificationKey(upcoming.ID, now)
+	assert.True(t, ok)
+	assert.Len(t, ring.JWKS(now).Keys, 2)
+
+	// Once it activates it signs, and after the old key retires only it is published
+	key, err = ring.SigningKey(now.Add(15 * time.Minute))
+	require.NoError(t, err)
+	assert.Equal(t, upcoming.ID, key.ID)
+
+	later := now.Add(time.Hour)
+	_, ok = ring.VerificationKey(current.ID, later)
+	assert.False(t, ok)
+	jwks := ring.JWKS(later)
+	require.Len(t, jwks.Keys, 1)
+	assert.Equal(t, upcoming.ID, jwks.Keys[0].KeyID)
+}
+
+func TestKeyRing_NoSigningKey(t *testing.T) {
+	upcoming, err := signing.GenerateKey(signing.AlgorithmEdDSA, time.Now().Add(time.Hour))
+	require.NoError(t, err)
+
+	_, err = signing.NewKeyRing(upcoming).SigningKey(time.Now())
+	assert.ErrorIs(t, err, signing.ErrNoSigningKey)
+}
+
+func TestKey_JWK(t *testing.T) {
+	rsaKey, err := signing.GenerateKey(signing.AlgorithmRS256, time.Now())
+	require.NoError(t, err)
+	jwk := rsaKey.JWK()
+	assert.Equal(t, "RSA", jwk.KeyType)
+	assert.Equal(t, "RS256", jwk.Algorithm)
+	assert.Equal(t, "sig", jwk.Use)
+	assert.Equal(t, "AQAB", jwk.E)
+	assert.Len(t, jwk.N, 342) // 2048 bit modulus
+
+	edKey, err := signing.GenerateKey(signing.AlgorithmEdDSA, time.Now())
+	require.NoError(t, err)
+	jwk = edKey.JWK()
+	assert.Equal(t, "OKP", jwk.KeyType)
+	assert.Equal(t, "Ed25519", jwk.Curve)
+	assert.Equal(t, edKey.ID, jwk.KeyID)
+	assert.Empty(t, jwk.N)
+}
+
+func TestKey_PrivateKeyRoundTrip(t *testing.T) {
+	key, err := signing.GenerateKey(signing.AlgorithmEdDSA, time.Now())
+	require.NoError(t, err)
+
+	pemBytes, err := key.MarshalPrivateKey()
+	require.NoError(t, err)
+	parsed, err := signing.ParsePrivateKey(pemBytes)
+	require.NoError(t, err)
+	assert.True(t, parsed.(ed25519.PrivateKey).Equal(key.PrivateKey))
+
+	_, err = signing.GenerateKey("HS256", time.Now())
+	assert.Error(t, err)
+}
@@ -0,0 +1,295 @@
+package squarefake
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	square "github.com/square/square-go-sdk/v2"
+	"github.com/square/square-go-sdk/v2/catalog"
+	"github.com/square/square-go-sdk/v2/client"
+	"github.com/square/square-go-sdk/v2/option"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"square-pos-integration/internal/apperrors"
+	"square-pos-integration/internal/service"
+	"square-pos-integration/internal/squarefake"
+)
+
+// newClient starts a fake Square server and returns a client for the merchant behind token
+func newClient(t *testing.T, token string) (*squarefake.Server, *client.Client) {
+	fake := squarefake.NewServer()
+	t.Cleanup(fake.Close)
+	return fake, clientFor(fake, token)
+}
+
+func clientFor(fake *squarefake.Server, token string) *client.Client {
+	return client.NewClient(option.WithToken(token), option.WithBaseURL(fake.URL), option.WithMaxAttempts(1))
+}
+
+func usd(amount int64) *square.Money {
+	return &square.Money{Amount: square.Int64(amount), Currency: square.Currency("USD").Ptr()}
+}
+
+func locationID(t *testing.T, sq *client.Client) string {
+	resp, err := sq.Locations.List(context.Background())
+	require.NoError(t, err)
+	require.NotEmpty(t, resp.Locations)
+	return *resp.Locations[0].ID
+}
+
+// createOrder creates an order for two $10.00 burgers with a $1.00 modifier and a $2.00
+// line item discount, 10% additive tax and a taxable 5% service charge
+func createOrder(t *testing.T, sq *client.Client, key string) *square.Order {
+	resp, err := sq.Orders.Create(context.Background(), &square.CreateOrderRequest{
+		IdempotencyKey: square.String(key),
+		Order: &square.Order{
+			LocationID: locationID(t, sq),
+			LineItems: []*square.OrderLineItem{{
+				Name:             square.String("Burger"),
+				Quantity:         "2",
+				BasePriceMoney:   usd(1000),
+				Modifiers:        []*square.OrderLineItemModifier{{Name: square.String("Cheese"), BasePriceMoney: usd(100)}},
+				AppliedDiscounts: []*square.OrderLineItemAppliedDiscount{{DiscountUID: "happy-hour"}},
+			}},
+			Discounts: []*square.OrderLineItemDiscount{{
+				UID:         square.String("happy-hour"),
+				Name:        square.String("Happy hour"),
+				Type:        square.OrderLineItemDiscountType("FIXED_AMOUNT").Ptr(),
+				AmountMoney: usd(200),
+				Scope:       square.OrderLineItemDiscountScope("LINE_ITEM").Ptr(),
+			}},
+			Taxes: []*square.OrderLineItemTax{{
+				Name:       square.String("Sales tax"),
+				Percentage: square.String("10"),
+				Type:       square.OrderLineItemTaxType("ADDITIVE").Ptr(),
+				Scope:      square.OrderLineItemTaxScope("ORDER").Ptr(),
+			}},
+			ServiceCharges: []*square.OrderServiceCharge{{
+				Name:             square.String("Service"),
+				Percentage:       square.String("5"),
+				CalculationPhase: square.OrderServiceChargeCalculationPhase("SUBTOTAL_PHASE").Ptr(),
+				Taxable:          square.Bool(true),
+			}},
+		},
+	})
+	require.NoError(t, err)
+	return resp.Order
+}
+
+func TestOrderPricing(t *testing.T) {
+	_, sq := newClient(t, "merchant-a")
+
+	order := createOrder(t, sq, "order-1")
+
+	// 2 x (10.00 + 1.00) - 2.00 = 20.00, service 1.00, tax 10% of 21.00 = 2.10
+	assert.Equal(t, "OPEN", string(*order.State))
+	assert.Equal(t, int64(2200), *order.LineItems[0].GrossSalesMoney.Amount)
+	assert.Equal(t, int64(200), *order.TotalDiscountMoney.Amount)
+	assert.Equal(t, int64(100), *order.TotalServiceChargeMoney.Amount)
+	assert.Equal(t, int64(210), *order.TotalTaxMoney.Amount)
+	assert.Equal(t, int64(2310), *order.TotalMoney.Amount)
+	assert.Equal(t, int64(2310), *order.NetAmountDueMoney.Amount)
+
+	calculated, err := sq.Orders.Calculate(context.Background(), &square.CalculateOrderRequest{
+		Order: &square.Order{LocationID: order.LocationID, LineItems: order.LineItems, Discounts: order.Discounts,
+			Taxes: order.Taxes, ServiceCharges: order.ServiceCharges},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, int64(2310), *calculated.Order.TotalMoney.Amount)
+}
+
+func TestOrderIdempotencyAndVersions(t *testing.T) {
+	_, sq := newClient(t, "merchant-a")
+
+	first := createOrder(t, sq, "order-1")
+	again := createOrder(t, sq, "order-1")
+	assert.Equal(t, *first.ID, *again.ID)
+
+	_, err := sq.Orders.Create(context.Background(), &square.CreateOrderRequest{
+		IdempotencyKey: square.String("order-1"),
+		Order:          &square.Order{LocationID: first.LocationID},
+	})
+	assert.Equal(t, "SQUARE_IDEMPOTENCY_CONFLICT", string(apperrors.FromSquare(err).Code))
+
+	_, err = sq.Orders.Update(context.Background(), &square.UpdateOrderRequest{
+		OrderID: *first.ID,
+		Order:   &square.Order{LocationID: first.LocationID, Version: square.Int(7), State: square.OrderState("CANCELED").Ptr()},
+	})
+	assert.Equal(t, "SQUARE_VERSION_MISMATCH", string(apperrors.FromSquare(err).Code))
+
+	canceled, err := sq.Orders.Update(context.Background(), &square.UpdateOrderRequest{
+		OrderID: *first.ID,
+		Order:   &square.Order{LocationID: first.LocationID, Version: first.Version, State: square.OrderState("CANCELED").Ptr()},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "CANCELED", string(*canceled.Order.State))
+	assert.Equal(t, 2, *canceled.Order.Version)
+}
+
+func TestDelayedPaymentWithTipCompletesOrder(t *testing.T) {
+	_, sq := newClient(t, "merchant-a")
+	order := createOrder(t, sq, "order-1")
+
+	created, err := sq.Payments.Create(context.Background(), &square.CreatePaymentRequest{
+		IdempotencyKey: "pay-1",
+		SourceID:       "cnon:card-nonce-ok",
+		AmountMoney:    usd(2310),
+		OrderID:        order.ID,
+		LocationID:     square.String(order.LocationID),
+		Autocomplete:   square.Bool(false),
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "APPROVED", *created.Payment.Status)
+
+	_, err = sq.Payments.Update(context.Background(), &square.UpdatePaymentRequest{
+		PaymentID:      *created.Payment.ID,
+		IdempotencyKey: "tip-1",
+		Payment:        &square.Payment{TipMoney: usd(400)},
+	})
+	require.NoError(t, err)
+
+	completed, err := sq.Payments.Complete(context.Background(), &square.CompletePaymentRequest{PaymentID: *created.Payment.ID})
+	require.NoError(t, err)
+	assert.Equal(t, "COMPLETED", *completed.Payment.Status)
+	assert.Equal(t, int64(2710), *completed.Payment.TotalMoney.Amount)
+
+	paid, err := sq.Orders.Get(context.Background(), &square.GetOrdersRequest{OrderID: *order.ID})
+	require.NoError(t, err)
+	assert.Equal(t, "COMPLETED", string(*paid.Order.State))
+	assert.Equal(t, int64(400), *paid.Order.TotalTipMoney.Amount)
+	assert.Equal(t, int64(0), *paid.Order.NetAmountDueMoney.Amount)
+	assert.Len(t, paid.Order.Tenders, 1)
+
+	// A completed payment can be refunded up to its total, refunds settle after creation
+	refund, err := sq.Refunds.RefundPayment(context.Background(), &square.RefundPaymentRequest{
+		IdempotencyKey: "refund-1", PaymentID: created.Payment.ID, AmountMoney: usd(1000),
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "PENDING", *refund.Refund.Status)
+
+	settled, err := sq.Refunds.Get(context.Background(), &square.GetRefundsRequest{RefundID: refund.Refund.ID})
+	require.NoError(t, err)
+	assert.Equal(t, "COMPLETED", *settled.Refund.Status)
+
+	_, err = sq.Refunds.RefundPayment(context.Background(), &square.RefundPaymentRequest{
+		IdempotencyKey: "refund-2", PaymentID: created.Payment.ID, AmountMoney: usd(2000),
+	})
+	assert.Equal(t, "SQUARE_REFUND_ERROR", string(apperrors.FromSquare(err).Code))
+}
+
+func TestPaymentErrors(t *testing.T) {
+	fake, sq := newClient(t, "merchant-a")
+	order := createOrder(t, sq, "order-1")
+
+	tests := []struct {
+		name         string
+		request      *square.CreatePaymentRequest
+		expectedCode string
+	}{
+		{
+			name:         "declined card",
+			request:      &square.CreatePaymentRequest{SourceID: "cnon:card-nonce-declined", AmountMoney: usd(500), OrderID: order.ID},
+			expectedCode: "SQUARE_CARD_DECLINED",
+		},
+		{
+			name:         "wrong CVV",
+			request:      &square.CreatePaymentRequest{SourceID: "cnon:card-nonce-rejected-cvv", AmountMoney: usd(500)},
+			expectedCode: "SQUARE_CARD_VERIFICATION_FAILED",
+		},
+		{
+			name:         "more than the order total",
+			request:      &square.CreatePaymentRequest{SourceID: "cnon:card-nonce-ok", AmountMoney: usd(5000), OrderID: order.ID},
+			expectedCode: "SQUARE_INVALID_REQUEST",
+		},
+		{
+			name: "location of another order",
+			request: &square.CreatePaymentRequest{SourceID: "cnon:card-nonce-ok", AmountMoney: usd(500), OrderID: order.ID,
+				LocationID: square.String(fake.AddLocation("merchant-a", "Patio"))},
+			expectedCode: "SQUARE_INVALID_REQUEST",
+		},
+	}
+
+	for i, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.request.IdempotencyKey = "pay-" + string(rune('a'+i))
+			_, err := sq.Payments.Create(context.Background(), tt.request)
+			if assert.Error(t, err) {
+				assert.Equal(t, tt.expectedCode, string(apperrors.FromSquare(err).Code))
+			}
+		})
+	}
+}
+
+func TestMerchantsAreIsolated(t *testing.T) {
+	fake, sq := newClient(t, "merchant-a")
+	order := createOrder(t, sq, "order-1")
+
+	_, err := clientFor(fake, "merchant-b").Orders.Get(context.Background(), &square.GetOrdersRequest{OrderID: *order.ID})
+	assert.Equal(t, "SQUARE_NOT_FOUND", string(apperrors.FromSquare(err).Code))
+
+	fake.RevokeToken("merchant-a")
+	_, err = sq.Locations.List(context.Background())
+	assert.Equal(t, "SQUARE_AUTHENTICATION_FAILED", string(apperrors.FromSquare(err).Code))
+}
+
+func TestCatalogTaxes(t *testing.T) {
+	_, sq := newClient(t, "merchant-a")
+
+	created, err := sq.Catalog.Object.Upsert(context.Background(), &catalog.UpsertCatalogObjectRequest{
+		IdempotencyKey: "tax-1",
+		Object: &square.CatalogObject{Type: "TAX", Tax: &square.CatalogObjectTax{
+			ID:      "#city-tax",
+			TaxData: &square.CatalogTax{Name: square.String("City tax"), Percentage: square.String("8.5")},
+		}},
+	})
+	require.NoError(t, err)
+	tax := created.CatalogObject.Tax
+	require.Len(t, created.IDMappings, 1)
+	assert.Equal(t, tax.ID, *created.IDMappings[0].ObjectID)
+
+	page, err := sq.Catalog.List(context.Background(), &square.ListCatalogRequest{Types: square.String("TAX")})
+	require.NoError(t, err)
+	require.Len(t, page.Results, 1)
+	assert.Equal(t, tax.ID, page.Results[0].Tax.ID)
+
+	// Orders pick up name and rate of catalog taxes
+	order, err := sq.Orders.Calculate(context.Background(), &square.CalculateOrderRequest{Order: &square.Order{
+		LocationID: locationID(t, sq),
+		LineItems:  []*square.OrderLineItem{{Name: square.String("Salad"), Quantity: "1", BasePriceMoney: usd(1000)}},
+		Taxes:      []*square.OrderLineItemTax{{CatalogObjectID: square.String(tax.ID)}},
+	}})
+	require.NoError(t, err)
+	assert.Equal(t, int64(85), *order.Order.TotalTaxMoney.Amount)
+	assert.Equal(t, "City tax", *order.Order.Taxes[0].Name)
+
+	_, err = sq.Catalog.Object.Upsert(context.Background(), &catalog.UpsertCatalogObjectRequest{
+		IdempotencyKey: "tax-2",
+		Object: &square.CatalogObject{Type: "TAX", Tax: &square.CatalogObjectTax{
+			ID: tax.ID, Version: square.Int64(*tax.Version - 1), TaxData: tax.TaxData,
+		}},
+	})
+	assert.Equal(t, "SQUARE_VERSION_MISMATCH", string(apperrors.FromSquare(err).Code))
+}
+
+func TestInjectedFailures(t *testing.T) {
+	fake, sq := newClient(t, "merchant-a")
+	fake.Fail("GET /v2/locations", &squarefake.Error{Status: http.StatusTooManyRequests, Category: "RATE_LIMIT_ERROR", Code: "RATE_LIMITED"})
+
+	_, err := sq.Locations.List(context.Background())
+	assert.Equal(t, "SQUARE_RATE_LIMITED", string(apperrors.FromSquare(err).Code))
+
+	_, err = sq.Locations.List(context.Background())
+	assert.NoError(t, err)
+}
+
+func TestSquareServiceAgainstFake(t *testing.T) {
+	fake := squarefake.NewServer()
+	defer fake.Close()
+
+	squareService := &service.SquareService{BaseURL: fake.URL}
+	locationID, err := squareService.FetchLocationID(context.Background(), "merchant-a")
+	require.NoError(t, err)
+	assert.NotEmpty(t, locationID)
+}
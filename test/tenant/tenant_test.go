@@ -0,0 +1,116 @@
+package tenant
+
+import (
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/driver/mysql"
+	"gorm.io/gorm"
+
+	"square-pos-integration/internal/models"
+	"square-pos-integration/internal/tenant"
+)
+
+func setupMockDB(t *testing.T) (*gorm.DB, sqlmock.Sqlmock) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	gormDB, err := gorm.Open(mysql.New(mysql.Config{Conn: db, SkipInitializeWithVersion: true}), &gorm.Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := gormDB.Use(tenant.Plugin{}); err != nil {
+		t.Fatal(err)
+	}
+	return gormDB, mock
+}
+
+func TestScopedQueryAddsRestaurant(t *testing.T) {
+	db, mock := setupMockDB(t)
+
+	mock.ExpectQuery("^SELECT \\* FROM `orders` WHERE id = \\? AND `orders`.`restaurant_id` = \\? AND `orders`.`deleted_at` IS NULL").
+		WithArgs("42", uint(3), 1).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}))
+
+	var order models.Order
+	err := tenant.Scoped(db, 3).Where("id = ?", "42").First(&order).Error
+
+	assert.ErrorIs(t, err, gorm.ErrRecordNotFound)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestUnscopedQueryFails(t *testing.T) {
+	db, mock := setupMockDB(t)
+
+	var payments []models.Payment
+	err := db.Where("square_payment_id = ?", "SQ-PAY-1").Find(&payments).Error
+
+	assert.ErrorIs(t, err, tenant.ErrMissingScope)
+	assert.NoError(t, mock.ExpectationsWereMet(), "nothing reaches the database")
+}
+
+func TestTablesWithoutTenantAreNotScoped(t *testing.T) {
+	db, mock := setupMockDB(t)
+
+	mock.ExpectQuery("^SELECT \\* FROM `users` WHERE email = \\? AND `users`.`deleted_at` IS NULL").
+		WithArgs("jane@example.com", 1).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(7))
+
+	var user models.User
+	assert.NoError(t, db.Where("email = ?", "jane@example.com").First(&user).Error)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestScopedCreateStampsRestaurant(t *testing.T) {
+	db, mock := setupMockDB(t)
+
+	mock.ExpectBegin()
+	mock.ExpectExec("^INSERT INTO `devices`").
+		WithArgs(sqlmock.AnyArg(), sqlmock.AnyArg(), nil, uint(3), "Bar", "hash", nil, nil, 0, nil).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
+
+	device := models.Device{Name: "Bar", CredentialHash: "hash"}
+	assert.NoError(t, tenant.Scoped(db, 3).Create(&device).Error)
+	assert.Equal(t, uint(3), device.RestaurantID)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestScopedCreateRejectsOtherRestaurant(t *testing.T) {
+	db, mock := setupMockDB(t)
+	mock.ExpectBegin()
+	mock.ExpectRollback()
+
+	device := models.Device{RestaurantID: 5, Name: "Bar", CredentialHash: "hash"}
+	err := tenant.Scoped(db, 3).Create(&device).Error
+
+	assert.ErrorIs(t, err, tenant.ErrCrossTenant)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestScopedUpdateRejectsRecordOfOtherRestaurant(t *testing.T) {
+	db, mock := setupMockDB(t)
+	mock.ExpectBegin()
+	mock.ExpectRollback()
+
+	order := models.Order{Model: &gorm.Model{ID: 42}, RestaurantID: 5}
+	err := tenant.Scoped(db, 3).Model(&order).Update("status", "cancelled").Error
+
+	assert.ErrorIs(t, err, tenant.ErrCrossTenant)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestSystemQueryIsNotScoped(t *testing.T) {
+	db, mock := setupMockDB(t)
+
+	mock.ExpectQuery("^SELECT \\* FROM `devices` WHERE credential_hash = \\? AND `devices`.`deleted_at` IS NULL").
+		WithArgs("hash", 1).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "restaurant_id"}).AddRow(1, 5))
+
+	var device models.Device
+	assert.NoError(t, tenant.System(db).Where("credential_hash = ?", "hash").First(&device).Error)
+	assert.Equal(t, uint(5), device.RestaurantID)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
@@ -0,0 +1,96 @@
+package utils
+
+import (
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"square-pos-integration/internal/models"
+	"square-pos-integration/internal/signing"
+	"square-pos-integration/internal/utils"
+)
+
+func testUser() models.User {
+	user := models.User{Email: "jane@example.com", RestaurantID: 3, Role: "manager"}
+	user.ID = 7
+	return user
+}
+
+func TestGenerateJWT_SignsWithKeyID(t *testing.T) {
+	for _, algorithm := range []string{signing.AlgorithmRS256, signing.AlgorithmEdDSA} {
+		t.Run(algorithm, func(t *testing.T) {
+			key, err := signing.GenerateKey(algorithm, time.Now().Add(-time.Minute))
+			require.NoError(t, err)
+			keys := signing.NewKeyRing(key)
+
+			token, err := utils.GenerateJWT(keys, testUser())
+			require.NoError(t, err)
+
+			parsed, _, err := jwt.NewParser().ParseUnverified(token, &utils.JWTClaims{})
+			require.NoError(t, err)
+			assert.Equal(t, key.ID, parsed.Header["kid"])
+			assert.Equal(t, algorithm, parsed.Header["alg"])
+
+			claims, err := utils.ValidateJWT(keys, token)
+			require.NoError(t, err)
+			assert.Equal(t, uint(7), claims.UserID)
+		})
+	}
+}
+
+func TestValidateJWT_AfterRotation(t *testing.T) {
+	oldKey, err := signing.GenerateKey(signing.AlgorithmEdDSA, time.Now().Add(-time.Hour))
+	require.NoError(t, err)
+	keys := signing.NewKeyRing(oldKey)
+	token, err := utils.GenerateJWT(keys, testUser())
+	require.NoError(t, err)
+
+	// A token signed before rotation verifies until its key retires
+	newKey, err := signing.GenerateKey(signing.AlgorithmEdDSA, time.Now().Add(-time.Second))
+	require.NoError(t, err)
+	retiresAt := time.Now().Add(time.Hour)
+	oldKey.RetiresAt = &retiresAt
+	keys.Replace([]signing.Key{oldKey, newKey})
+	_, err = utils.ValidateJWT(keys, token)
+	assert.NoError(t, err)
+
+	retiredAt := time.Now().Add(-time.Second)
+	oldKey.RetiresAt = &retiredAt
+	keys.Replace([]signing.Key{oldKey, newKey})
+	_, err = utils.ValidateJWT(keys, token)
+	assert.Error(t, err)
+}
+
+func TestValidateJWT_RejectsUnknownKeysAndSharedSecrets(t *testing.T) {
+	key, err := signing.GenerateKey(signing.AlgorithmEdDSA, time.Now().Add(-time.Minute))
+	require.NoError(t, err)
+	keys := signing.NewKeyRing(key)
+
+	claims := utils.JWTClaims{
+		UserID: 7,
+		RegisteredClaims: jwt.RegisteredClaims{
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Minute)),
+		},
+	}
+
+	// HS256 signed with a secret, even one claiming a known kid
+	hmacToken := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+	hmacToken.Header["kid"] = key.ID
+	signed, err := hmacToken.SignedString([]byte("secret"))
+	require.NoError(t, err)
+	_, err = utils.ValidateJWT(keys, signed)
+	assert.Error(t, err)
+
+	// Signed by a key that is not in the ring
+	otherKey, err := signing.GenerateKey(signing.AlgorithmEdDSA, time.Now())
+	require.NoError(t, err)
+	otherToken := jwt.NewWithClaims(jwt.SigningMethodEdDSA, claims)
+	otherToken.Header["kid"] = otherKey.ID
+	signed, err = otherToken.SignedString(otherKey.PrivateKey)
+	require.NoError(t, err)
+	_, err = utils.ValidateJWT(keys, signed)
+	assert.Error(t, err)
+}
@@ -0,0 +1,56 @@
+package utils
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"square-pos-integration/internal/utils"
+)
+
+// rfcSecret is the RFC 6238 SHA-1 test key "12345678901234567890" in base32
+const rfcSecret = "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+
+func TestTOTPCode_RFC6238Vectors(t *testing.T) {
+	vectors := map[int64]string{
+		59:         "287082",
+		1111111109: "081804",
+		1234567890: "005924",
+		2000000000: "279037",
+	}
+	for unix, expected := range vectors {
+		code, err := utils.TOTPCode(rfcSecret, time.Unix(unix, 0))
+		assert.NoError(t, err)
+		assert.Equal(t, expected, code, "at %d", unix)
+	}
+}
+
+func TestVerifyTOTP_AcceptsOneStepOfDrift(t *testing.T) {
+	now := time.Unix(1111111109, 0)
+
+	step, ok := utils.VerifyTOTP(rfcSecret, "081804", now.Add(utils.TOTPPeriod))
+	assert.True(t, ok)
+	assert.Equal(t, now.Unix()/30, step)
+
+	_, ok = utils.VerifyTOTP(rfcSecret, "081804", now.Add(3*utils.TOTPPeriod))
+	assert.False(t, ok)
+}
+
+func TestTOTPProvisioningURI(t *testing.T) {
+	uri := utils.TOTPProvisioningURI(rfcSecret, "Square POS", "jane@example.com")
+
+	assert.True(t, strings.HasPrefix(uri, "otpauth://totp/Square%20POS:jane@example.com?"))
+	assert.Contains(t, uri, "secret="+rfcSecret)
+	assert.Contains(t, uri, "issuer=Square+POS")
+}
+
+func TestNormalizeRecoveryCode(t *testing.T) {
+	codes, err := utils.GenerateRecoveryCodes(2)
+	assert.NoError(t, err)
+	assert.Len(t, codes, 2)
+	assert.NotEqual(t, codes[0], codes[1])
+
+	assert.Equal(t, codes[0], utils.NormalizeRecoveryCode(strings.ToUpper(strings.ReplaceAll(codes[0], "-", " "))))
+}